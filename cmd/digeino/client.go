@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/originaleric/digeino/gateway"
+	"github.com/originaleric/digeino/gateway/client"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/runtime"
+)
+
+// toolBackend is what the client subcommands need from a gateway: a remote HTTP or
+// gRPC gateway, or an in-process runtime built from config.
+type toolBackend interface {
+	Manifest(ctx context.Context) (protocol.ToolManifest, error)
+	Call(ctx context.Context, call protocol.ToolCall) (protocol.ToolResult, error)
+	FetchArtifact(ctx context.Context, id string) ([]byte, string, error)
+	Close() error
+}
+
+type httpBackend struct{ *client.Client }
+
+func (httpBackend) Close() error { return nil }
+
+// localBackend executes calls in-process against a runtime.Runtime.
+type localBackend struct{ rt *runtime.Runtime }
+
+func (b localBackend) Manifest(context.Context) (protocol.ToolManifest, error) {
+	return b.rt.Manifest(), nil
+}
+
+func (b localBackend) Call(ctx context.Context, call protocol.ToolCall) (protocol.ToolResult, error) {
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
+	}
+	res := b.rt.Execute(ctx, &call)
+	if res.Error != nil {
+		return *res, res.Error
+	}
+	return *res, nil
+}
+
+func (b localBackend) FetchArtifact(ctx context.Context, id string) ([]byte, string, error) {
+	store := b.rt.ArtifactStore()
+	if store == nil {
+		return nil, "", fmt.Errorf("artifact store disabled (Gateway.ArtifactEnabled)")
+	}
+	return store.Get(ctx, strings.TrimPrefix(id, "digeino-artifact://"))
+}
+
+func (localBackend) Close() error { return nil }
+
+// targetFlags selects the backend shared by call / manifest / artifact.
+type targetFlags struct {
+	config     configFlags
+	gatewayURL *string
+	grpcAddr   *string
+	token      *string
+	output     *string
+}
+
+func addTargetFlags(fs *flag.FlagSet) targetFlags {
+	return targetFlags{
+		config:     addConfigFlags(fs),
+		gatewayURL: fs.String("gateway", "", "remote HTTP gateway base URL, e.g. http://127.0.0.1:8787"),
+		grpcAddr:   fs.String("grpc", "", "remote gRPC gateway address, e.g. 127.0.0.1:8788"),
+		token:      fs.String("token", os.Getenv("DIGEINO_TOKEN"), "gateway auth token (default $DIGEINO_TOKEN)"),
+		output:     fs.String("o", "table", "output format: table | json"),
+	}
+}
+
+// open returns the selected backend; without --gateway / --grpc the tools run in-process.
+func (t targetFlags) open() toolBackend {
+	switch {
+	case *t.gatewayURL != "" && *t.grpcAddr != "":
+		log.Fatal("use only one of --gateway and --grpc")
+	case *t.gatewayURL != "":
+		return httpBackend{client.New(*t.gatewayURL, *t.token)}
+	case *t.grpcAddr != "":
+		c, err := client.NewGRPC(*t.grpcAddr, *t.token)
+		if err != nil {
+			log.Fatalf("grpc: %v", err)
+		}
+		return c
+	}
+	cfg, err := t.config.load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	return localBackend{rt: gateway.NewRuntime(cfg)}
+}
+
+func (t targetFlags) json() bool {
+	switch *t.output {
+	case "json":
+		return true
+	case "table":
+		return false
+	}
+	log.Fatalf("unknown output format %q (table | json)", *t.output)
+	return false
+}
+
+func runCall(args []string) {
+	fs := flag.NewFlagSet("call", flag.ExitOnError)
+	target := addTargetFlags(fs)
+	input := fs.String("input", "", "tool input as a JSON object")
+	inputFile := fs.String("input-file", "", "read tool input JSON from file ('-' for stdin)")
+	id := fs.String("id", "", "call id (default cli_<uuid>)")
+	tenant := fs.String("tenant", "", "context.tenant_id")
+	timeout := fs.Duration("timeout", 0, "policy.timeout_ms as a duration, e.g. 30s")
+	domains := fs.String("domains", "", "policy.allowed_domains, comma separated")
+	cacheMode := fs.String("cache", "", "policy.cache: bypass | refresh | only")
+	stream := fs.Bool("stream", false, "print progress events to stderr (gRPC only)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: digeino call [flags] <tool> [key=value ...]\n\n"+
+			"key=value pairs are merged into the input; values that parse as JSON keep their type.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	asJSON := target.json()
+
+	in, err := buildInput(*input, *inputFile, fs.Args()[1:])
+	if err != nil {
+		log.Fatalf("input: %v", err)
+	}
+	call := protocol.ToolCall{
+		Type:    protocol.TypeToolCall,
+		ID:      *id,
+		Tool:    fs.Arg(0),
+		Input:   in,
+		Context: protocol.CallContext{TenantID: *tenant, Host: "digeino-cli"},
+		Policy: protocol.CallPolicy{
+			TimeoutMs: int(timeout.Milliseconds()),
+			Cache:     *cacheMode,
+		},
+	}
+	if call.ID == "" {
+		call.ID = "cli_" + uuid.NewString()
+	}
+	if *domains != "" {
+		call.Policy.AllowedDomains = splitList(*domains)
+	}
+
+	backend := target.open()
+	defer backend.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var res protocol.ToolResult
+	if g, ok := backend.(*client.GRPCClient); ok && *stream {
+		res, err = g.CallStream(ctx, call, func(p client.Progress) {
+			fmt.Fprintf(os.Stderr, "[%6dms] %s attempt=%d %s\n", p.ElapsedMs, p.Stage, p.Attempt, p.Message)
+		})
+	} else {
+		res, err = backend.Call(ctx, call)
+	}
+	if err != nil && res.Type == "" {
+		log.Fatalf("call: %v", err)
+	}
+
+	if asJSON {
+		printJSON(res)
+	} else {
+		printResultTable(os.Stdout, res)
+	}
+	if res.Status != "success" {
+		os.Exit(1)
+	}
+}
+
+// buildInput merges --input / --input-file with key=value arguments into one JSON object.
+func buildInput(inline, file string, pairs []string) (json.RawMessage, error) {
+	obj := map[string]any{}
+	var raw []byte
+	switch {
+	case inline != "" && file != "":
+		return nil, fmt.Errorf("use only one of --input and --input-file")
+	case inline != "":
+		raw = []byte(inline)
+	case file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+	if len(strings.TrimSpace(string(raw))) > 0 {
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, fmt.Errorf("input must be a JSON object: %w", err)
+		}
+	}
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", p)
+		}
+		var val any
+		if err := json.Unmarshal([]byte(v), &val); err != nil {
+			val = v
+		}
+		obj[k] = val
+	}
+	return json.Marshal(obj)
+}
+
+func runManifest(args []string) {
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	target := addTargetFlags(fs)
+	tool := fs.String("tool", "", "show only this tool, including its schemas")
+	_ = fs.Parse(args)
+	asJSON := target.json()
+
+	backend := target.open()
+	defer backend.Close()
+	m, err := backend.Manifest(context.Background())
+	if err != nil {
+		log.Fatalf("manifest: %v", err)
+	}
+
+	if *tool != "" {
+		for _, d := range m.Tools {
+			if d.Name == *tool {
+				if asJSON {
+					printJSON(d)
+				} else {
+					printToolDetail(os.Stdout, d)
+				}
+				return
+			}
+		}
+		log.Fatalf("tool %q not in manifest", *tool)
+	}
+	if asJSON {
+		printJSON(m)
+		return
+	}
+	fmt.Printf("%s %s  instance=%s  tools=%d\n\n", m.Runtime, m.RuntimeVersion, m.InstanceID, len(m.Tools))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tRISK\tCAPABILITIES\tDESCRIPTION")
+	for _, d := range m.Tools {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Name, d.Risk, strings.Join(d.Capabilities, ","), truncate(d.Description, 80))
+	}
+	_ = tw.Flush()
+}
+
+func runArtifact(args []string) {
+	fs := flag.NewFlagSet("artifact", flag.ExitOnError)
+	target := addTargetFlags(fs)
+	out := fs.String("out", "", "output file ('-' for stdout; default <id> plus an extension from the content type)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: digeino artifact [flags] <id | digeino-artifact://id>\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	asJSON := target.json()
+	id := strings.TrimPrefix(fs.Arg(0), "digeino-artifact://")
+
+	backend := target.open()
+	defer backend.Close()
+	data, contentType, err := backend.FetchArtifact(context.Background(), id)
+	if err != nil {
+		log.Fatalf("artifact: %v", err)
+	}
+	if *out == "-" {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+	path := *out
+	if path == "" {
+		path = id
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			path += exts[0]
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatalf("artifact: %v", err)
+	}
+	if asJSON {
+		printJSON(map[string]any{"id": id, "path": path, "content_type": contentType, "size": len(data)})
+		return
+	}
+	fmt.Printf("saved %s (%s, %d bytes)\n", path, contentType, len(data))
+}
+
+func printResultTable(w io.Writer, res protocol.ToolResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "id\t%s\n", res.ID)
+	fmt.Fprintf(tw, "status\t%s\n", res.Status)
+	fmt.Fprintf(tw, "duration\t%s\n", time.Duration(res.Usage.DurationMs)*time.Millisecond)
+	if res.Usage.Attempts > 1 {
+		fmt.Fprintf(tw, "attempts\t%d\n", res.Usage.Attempts)
+	}
+	if res.CacheHit {
+		fmt.Fprintf(tw, "cache_hit\ttrue\n")
+	}
+	if e := res.Error; e != nil {
+		fmt.Fprintf(tw, "error\t%s: %s\n", e.Code, e.Message)
+		if e.Retryable {
+			fmt.Fprintf(tw, "retryable\ttrue (retry after %dms)\n", e.RetryAfterMs)
+		}
+	}
+	for _, a := range res.Artifacts {
+		fmt.Fprintf(tw, "artifact\t%s %s %d bytes\n", a.ID, a.Type, a.Size)
+	}
+	var out map[string]any
+	if len(res.Output) > 0 && json.Unmarshal(res.Output, &out) == nil {
+		keys := make([]string, 0, len(out))
+		for k := range out {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(tw, "\t")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "output.%s\t%s\n", k, truncate(compactValue(out[k]), 200))
+		}
+	}
+	_ = tw.Flush()
+}
+
+func printToolDetail(w io.Writer, d protocol.ToolDescriptor) {
+	fmt.Fprintf(w, "%s\n  %s\n", d.Name, d.Description)
+	if d.Risk != "" {
+		fmt.Fprintf(w, "  risk: %s\n", d.Risk)
+	}
+	if len(d.Capabilities) > 0 {
+		fmt.Fprintf(w, "  capabilities: %s\n", strings.Join(d.Capabilities, ", "))
+	}
+	for _, s := range []struct {
+		name   string
+		schema json.RawMessage
+	}{{"input_schema", d.InputSchema}, {"output_schema", d.OutputSchema}} {
+		if len(s.schema) == 0 {
+			continue
+		}
+		var v any
+		_ = json.Unmarshal(s.schema, &v)
+		data, _ := json.MarshalIndent(v, "  ", "  ")
+		fmt.Fprintf(w, "  %s: %s\n", s.name, data)
+	}
+}
+
+func compactValue(v any) string {
+	if s, ok := v.(string); ok {
+		return strings.ReplaceAll(s, "\n", `\n`)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("encode: %v", err)
+	}
+}
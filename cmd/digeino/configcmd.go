@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// configFlags are the --config / --set flags shared by every command that loads config.yaml.
+type configFlags struct {
+	path  *string
+	set   *stringList
+	watch *bool
+}
+
+func addConfigFlags(fs *flag.FlagSet) configFlags {
+	c := configFlags{
+		path: fs.String("config", "config/config.yaml", "path to config.yaml"),
+		set:  &stringList{},
+	}
+	fs.Var(c.set, "set", "override a config field, e.g. --set Gateway.ListenAddr=:9000 (repeatable)")
+	return c
+}
+
+// addWatchedConfigFlags adds --watch-config for long-running commands.
+func addWatchedConfigFlags(fs *flag.FlagSet) configFlags {
+	c := addConfigFlags(fs)
+	c.watch = fs.Bool("watch-config", true, "reload the config file when it changes or on SIGHUP")
+	return c
+}
+
+func (c configFlags) load() (*config.Config, error) {
+	return loadConfig(*c.path, *c.set)
+}
+
+// options loads the config file strictly: a missing file is an error.
+func (c configFlags) options() config.LoadOptions {
+	opts := config.LoadOptions{Overrides: *c.set}
+	if *c.path != "" {
+		opts.Files = []string{*c.path}
+	}
+	return opts
+}
+
+// startWatch reloads the config file on change and on SIGHUP until ctx ends; subscribers of
+// config.Subscribe apply the new values. A failed reload keeps the previous config and is logged.
+func (c configFlags) startWatch(ctx context.Context) {
+	if c.watch == nil || !*c.watch || *c.path == "" {
+		return
+	}
+	if _, err := os.Stat(*c.path); err != nil {
+		return
+	}
+	w := config.NewWatcher(c.options(), 0)
+	w.OnReload = func(*config.Config) { log.Printf("config reloaded from %s", *c.path) }
+	w.OnError = func(err error) { log.Printf("config reload failed, keeping previous config: %v", err) }
+	go w.Run(ctx)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				_ = w.Reload()
+			}
+		}
+	}()
+}
+
+func runConfig(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "usage: digeino config print|validate|schema [flags]")
+		os.Exit(2)
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "print":
+		runConfigPrint(args)
+	case "validate":
+		runConfigValidate(args)
+	case "schema":
+		runConfigSchema(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config action %q (print | validate | schema)\n", action)
+		os.Exit(2)
+	}
+}
+
+func runConfigPrint(args []string) {
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	output := fs.String("o", "yaml", "output format: yaml | json")
+	showSecrets := fs.Bool("show-secrets", false, "print tokens, passwords and API keys instead of ******")
+	_ = fs.Parse(args)
+
+	cfg, err := config.LoadWithOptions(cf.options())
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if !*showSecrets {
+		if cfg, err = redactConfig(cfg); err != nil {
+			log.Fatalf("redact config: %v", err)
+		}
+	}
+	switch *output {
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(cfg); err != nil {
+			log.Fatal(err)
+		}
+	case "json":
+		printJSON(cfg)
+	default:
+		log.Fatalf("unknown output format %q (yaml | json)", *output)
+	}
+}
+
+func runConfigValidate(args []string) {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	output := fs.String("o", "table", "output format: table | json")
+	_ = fs.Parse(args)
+
+	_, err := config.LoadWithOptions(cf.options())
+	var fieldErrs config.ValidationError
+	if err != nil && !errors.As(err, &fieldErrs) {
+		fieldErrs = config.ValidationError{{Message: err.Error()}}
+	}
+	if *output == "json" {
+		printJSON(map[string]any{"config": *cf.path, "valid": len(fieldErrs) == 0, "errors": fieldErrs})
+	} else {
+		if len(fieldErrs) == 0 {
+			fmt.Printf("%s: OK\n", *cf.path)
+		}
+		for _, fe := range fieldErrs {
+			fmt.Printf("%s: %s\n", *cf.path, fe.Error())
+		}
+	}
+	if len(fieldErrs) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runConfigSchema(args []string) {
+	fs := flag.NewFlagSet("config schema", flag.ExitOnError)
+	out := fs.String("out", "", "write the schema to this file instead of stdout")
+	_ = fs.Parse(args)
+
+	data, err := json.MarshalIndent(config.JSONSchema(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", *out)
+}
+
+// redactConfig masks non-empty secrets; ${VAR} and secret:// references are kept since they are not secret.
+func redactConfig(cfg *config.Config) (*config.Config, error) {
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	redactNode(&node)
+	var out config.Config
+	if err := node.Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func redactNode(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if val.Kind == yaml.ScalarNode && secrets.IsSecretName(key.Value) && val.Value != "" && !strings.HasPrefix(val.Value, "${") && !secrets.IsRef(val.Value) {
+				val.Value = "******"
+				val.Tag = "!!str"
+				continue
+			}
+			redactNode(val)
+		}
+		return
+	}
+	for _, c := range n.Content {
+		redactNode(c)
+	}
+}
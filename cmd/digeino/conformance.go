@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol/conformance"
+	"github.com/originaleric/digeino/secrets"
+)
+
+func runConformance(args []string) {
+	if len(args) == 0 || (args[0] != "host" && args[0] != "collector") {
+		fmt.Fprintln(os.Stderr, "usage: digeino conformance host|collector [flags]")
+		os.Exit(2)
+	}
+	role, args := args[0], args[1:]
+	fs := flag.NewFlagSet("conformance "+role, flag.ExitOnError)
+	wsPath := fs.String("ws-path", "/digeino/v1/collector/ws", "collector WebSocket path")
+	token := fs.String("token", "", "collector token (plain or secret:// reference)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each step")
+	large := fs.Int("large", 1<<20, "size of the large-payload frames in bytes")
+	output := fs.String("o", "table", "output format: table | json")
+	// host under test
+	server := fs.String("server", "", "host: base URL of the host under test (http(s) or ws(s))")
+	submitURL := fs.String("submit-url", "", "host: API the kit posts calls to, e.g. http://host/calls?wait=false or http://dev-host/dev/enqueue; empty skips call checks")
+	apiToken := fs.String("api-token", "", "host: Bearer token for --submit-url (default: --token)")
+	// collector under test
+	listen := fs.String("listen", "127.0.0.1:8791", "collector: address the scripted host listens on; point the collector's ServerURL here")
+	tool := fs.String("tool", "", "collector: tool to call in the push check (default: a tool that does not exist)")
+	input := fs.String("input", "{}", "collector: JSON input for --tool")
+	connectTimeout := fs.Duration("connect-timeout", time.Minute, "collector: wait this long for the collector to connect and to reconnect")
+	_ = fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var report conformance.Report
+	switch role {
+	case "host":
+		if *server == "" {
+			log.Fatal("conformance host: --server is required")
+		}
+		opts := conformance.HostOptions{
+			ServerURL:    *server,
+			WSPath:       *wsPath,
+			Token:        secrets.Lookup(*token),
+			Timeout:      *timeout,
+			LargePayload: *large,
+		}
+		if *submitURL != "" {
+			key := *apiToken
+			if key == "" {
+				key = *token
+			}
+			opts.Submit = conformance.HTTPSubmitter(*submitURL, secrets.Lookup(key))
+		}
+		report = conformance.CheckHost(ctx, opts)
+	case "collector":
+		if !json.Valid([]byte(*input)) {
+			log.Fatal("conformance collector: --input must be JSON")
+		}
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			log.Fatalf("conformance collector: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "waiting for a collector on ws://%s%s (start it with Collector.ServerURL=http://%s)\n", ln.Addr(), *wsPath, ln.Addr())
+		report = conformance.CheckCollector(ctx, conformance.CollectorOptions{
+			Listener:       ln,
+			WSPath:         *wsPath,
+			Token:          secrets.Lookup(*token),
+			Tool:           *tool,
+			Input:          json.RawMessage(*input),
+			Timeout:        *timeout,
+			ConnectTimeout: *connectTimeout,
+			LargePayload:   *large,
+		})
+	}
+	switch *output {
+	case "json":
+		printJSON(report)
+	case "table":
+		conformance.WriteText(os.Stdout, report)
+	default:
+		log.Fatalf("unknown output format %q (table | json)", *output)
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
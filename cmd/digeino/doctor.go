@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/originaleric/digeino/internal/doctor"
+)
+
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	launch := fs.Bool("launch-browser", false, "start and close a headless Chrome")
+	network := fs.Bool("network", false, "probe the collector host and status store database")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each probe")
+	strict := fs.Bool("strict", false, "exit non-zero on warnings as well as failures")
+	output := fs.String("o", "table", "output format: table | json")
+	_ = fs.Parse(args)
+
+	cfg, err := cf.load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	report := doctor.Run(context.Background(), cfg, doctor.Options{
+		ConfigPath:    *cf.path,
+		LaunchBrowser: *launch,
+		Network:       *network,
+		Timeout:       *timeout,
+	})
+	switch *output {
+	case "json":
+		printJSON(report)
+	case "table":
+		doctor.WriteText(os.Stdout, report)
+	default:
+		log.Fatalf("unknown output format %q (table | json)", *output)
+	}
+	if report.Count(doctor.Fail) > 0 || (*strict && report.Count(doctor.Warn) > 0) {
+		os.Exit(1)
+	}
+}
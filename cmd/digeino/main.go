@@ -2,23 +2,36 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/originaleric/digeino/config"
 	"github.com/originaleric/digeino/gateway"
 	"github.com/originaleric/digeino/gateway/collector"
 	"github.com/originaleric/digeino/gateway/devhost"
+	grpcgw "github.com/originaleric/digeino/gateway/grpc"
+	"github.com/originaleric/digeino/gateway/host"
 	httpgw "github.com/originaleric/digeino/gateway/http"
 	mcpgw "github.com/originaleric/digeino/gateway/mcp"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/runtime"
 	stdiogw "github.com/originaleric/digeino/gateway/stdio"
+	"github.com/originaleric/digeino/gateway/taskqueue"
+	"github.com/originaleric/digeino/secrets"
 )
 
 func main() {
+	log.SetOutput(secrets.ScrubWriter(os.Stderr))
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
@@ -27,14 +40,32 @@ func main() {
 	switch os.Args[1] {
 	case "gateway":
 		runGateway(os.Args[2:])
+	case "grpc":
+		runGRPC(os.Args[2:])
 	case "collector":
 		runCollector(os.Args[2:])
 	case "dev-host":
 		runDevHost(os.Args[2:])
+	case "host":
+		runHost(os.Args[2:])
 	case "mcp":
 		runMCP(os.Args[2:])
 	case "stdio":
 		runStdio(os.Args[2:])
+	case "call":
+		runCall(os.Args[2:])
+	case "manifest":
+		runManifest(os.Args[2:])
+	case "artifact":
+		runArtifact(os.Args[2:])
+	case "doctor":
+		runDoctor(os.Args[2:])
+	case "conformance":
+		runConformance(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:])
+	case "secrets":
+		runSecrets(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -49,24 +80,40 @@ func printUsage() {
 
 Usage:
   digeino gateway [flags]     HTTP Tool Gateway
+  digeino grpc [flags]        gRPC Tool Gateway
   digeino collector [flags]   WebSocket reverse connector
   digeino mcp [flags]         MCP server (stdio, for IDE)
   digeino stdio [flags]       JSON-line gateway on stdin/stdout
   digeino dev-host [flags]    Local reference host (dev only)
+  digeino host [flags]        Collector host with routing, a call API and a web dashboard
+  digeino host keygen         Generate a key pair for signing remote collector config
+  digeino call [flags] <tool> [key=value ...]
+                              Call a tool (in-process, or --gateway / --grpc)
+  digeino manifest [flags]    Print the tool manifest
+  digeino artifact [flags] <id>
+                              Download an artifact
+  digeino doctor [flags]      Check configuration and environment
+  digeino conformance host|collector [flags]
+                              Check a third-party host or collector against the wire protocol
+  digeino config print|validate|schema [flags]
+                              Show, check or describe the merged configuration
+  digeino secrets set|rm|list|check [flags] [key]
+                              Manage the encrypted vault and check secret:// references
   digeino help
 
 Host projects can import: github.com/originaleric/digeino/gateway/client
+(HTTP: client.New, gRPC: client.NewGRPC)
 
 `)
 }
 
 func runGateway(args []string) {
 	fs := flag.NewFlagSet("gateway", flag.ExitOnError)
-	configPath := fs.String("config", "config/config.yaml", "path to config.yaml")
+	cf := addWatchedConfigFlags(fs)
 	addr := fs.String("addr", "", "listen address (overrides Gateway.ListenAddr)")
 	_ = fs.Parse(args)
 
-	cfg, err := loadConfig(*configPath)
+	cfg, err := cf.load()
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
@@ -80,52 +127,110 @@ func runGateway(args []string) {
 	}
 
 	rt := gateway.NewRuntime(cfg)
-	srv := httpgw.NewServer(rt, rt.ArtifactStore(), cfg.Gateway.AuthToken)
+	followConfig(cf, rt, gateway.ApplyConfig)
+	startUsageExport(rt, cfg)
+	srv := httpgw.NewServer(rt, rt.ArtifactStore(), authToken(cfg))
 	log.Printf("DigEino HTTP gateway listening on %s (instance=%s)", listen, cfg.Gateway.InstanceID)
 	if err := srv.ListenAndServe(listen); err != nil {
 		log.Fatalf("gateway server: %v", err)
 	}
 }
 
+func runGRPC(args []string) {
+	fs := flag.NewFlagSet("grpc", flag.ExitOnError)
+	cf := addWatchedConfigFlags(fs)
+	addr := fs.String("addr", "", "listen address (overrides Gateway.GRPCListenAddr)")
+	_ = fs.Parse(args)
+
+	cfg, err := cf.load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	listen := cfg.Gateway.GRPCListenAddr
+	if *addr != "" {
+		listen = *addr
+	}
+	if listen == "" {
+		listen = ":8788"
+	}
+
+	rt := gateway.NewRuntime(cfg)
+	followConfig(cf, rt, gateway.ApplyConfig)
+	startUsageExport(rt, cfg)
+	srv := grpcgw.NewServer(rt, rt.ArtifactStore(), authToken(cfg))
+	log.Printf("DigEino gRPC gateway listening on %s (instance=%s)", listen, cfg.Gateway.InstanceID)
+	if err := srv.ListenAndServe(listen); err != nil {
+		log.Fatalf("grpc server: %v", err)
+	}
+}
+
+// followConfig applies every config reload to rt and starts watching the config file.
+func followConfig(cf configFlags, rt *runtime.Runtime, apply func(*runtime.Runtime, *config.Config)) {
+	config.Subscribe(func(_, next *config.Config) { apply(rt, next) })
+	cf.startWatch(context.Background())
+}
+
+// startUsageExport writes the previous day's usage report to Gateway.Usage.ReportDir each night.
+func startUsageExport(rt *runtime.Runtime, cfg *config.Config) {
+	if dir := cfg.Gateway.Usage.ReportDir; dir != "" && rt.Usage() != nil {
+		go rt.Usage().RunDailyExport(context.Background(), dir)
+	}
+}
+
 func runCollector(args []string) {
 	fs := flag.NewFlagSet("collector", flag.ExitOnError)
-	configPath := fs.String("config", "config/config.yaml", "path to config.yaml")
+	cf := addWatchedConfigFlags(fs)
 	server := fs.String("server", "", "host base URL (overrides Collector.ServerURL)")
 	token := fs.String("token", "", "auth token (overrides Collector.Token)")
 	instanceID := fs.String("instance-id", "", "collector instance id")
 	pullSec := fs.Int("pull-interval", -1, "pull interval seconds; -1 uses config")
 	_ = fs.Parse(args)
 
-	cfg, err := loadConfig(*configPath)
-	if err != nil {
-		log.Fatalf("load config: %v", err)
-	}
+	// 作为 --set 覆盖项传入，热更新重新加载时同样生效。
 	if *server != "" {
-		cfg.Collector.ServerURL = *server
+		*cf.set = append(*cf.set, "Collector.ServerURL="+*server)
 	}
 	if *token != "" {
-		cfg.Collector.Token = *token
+		*cf.set = append(*cf.set, "Collector.Token="+*token)
 	}
 	if *instanceID != "" {
-		cfg.Collector.InstanceID = *instanceID
+		*cf.set = append(*cf.set, "Collector.InstanceID="+*instanceID)
 	}
 	if *pullSec >= 0 {
-		cfg.Collector.PullIntervalSec = *pullSec
+		*cf.set = append(*cf.set, fmt.Sprintf("Collector.PullIntervalSec=%d", *pullSec))
+	}
+	cfg, err := cf.load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
 	}
 
 	opts := collector.OptionsFromConfig(cfg)
-	if opts.ServerURL == "" {
-		log.Fatal("collector: --server or Collector.ServerURL is required")
+	if *server != "" {
+		opts.Hosts = nil // --server connects to that host only
+	}
+	hosts := opts.Endpoints()
+	if len(hosts) == 0 {
+		log.Fatal("collector: --server, Collector.ServerURL or Collector.Hosts is required")
 	}
 
 	rt := gateway.NewCollectorRuntime(cfg)
+	followConfig(cf, rt, gateway.ApplyCollectorConfig)
 	client := collector.NewClient(opts, rt)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop() // 第二次信号直接退出，不再等待 drain
+	}()
 
-	log.Printf("DigEino collector connecting to %s instance=%s pull=%s",
-		opts.ServerURL, opts.InstanceID, opts.PullInterval)
+	names := make([]string, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+	log.Printf("DigEino collector connecting to %s (%s) instance=%s pull=%s",
+		strings.Join(names, ", "), opts.Mode, opts.InstanceID, opts.PullInterval)
 	if err := client.Run(ctx); err != nil && err != context.Canceled {
 		log.Fatalf("collector: %v", err)
 	}
@@ -136,25 +241,102 @@ func runDevHost(args []string) {
 	addr := fs.String("addr", ":8790", "listen address")
 	token := fs.String("token", "dev", "auth token for collectors")
 	wsPath := fs.String("ws-path", "/digeino/v1/collector/ws", "WebSocket path")
+	minProto := fs.Int("min-protocol", 0, "reject collectors below this protocol version (0 = protocol minimum)")
 	_ = fs.Parse(args)
 
 	srv := devhost.NewServer(*token, *wsPath)
-	log.Printf("DigEino dev-host (reference only — implement production host in your project)")
+	srv.MinProtocolVersion = *minProto
+	log.Printf("DigEino dev-host (reference only — use gateway/host or `digeino host` in production)")
 	if err := srv.ListenAndServe(*addr); err != nil {
 		log.Fatalf("dev-host: %v", err)
 	}
 }
 
+func runHost(args []string) {
+	if len(args) > 0 && args[0] == "keygen" {
+		runHostKeygen()
+		return
+	}
+	fs := flag.NewFlagSet("host", flag.ExitOnError)
+	addr := fs.String("addr", ":8790", "listen address")
+	token := fs.String("token", "", "auth token for collectors (plain or secret:// reference)")
+	apiToken := fs.String("api-token", "", "auth token for the /calls API (default: --token)")
+	wsPath := fs.String("ws-path", "/digeino/v1/collector/ws", "WebSocket path")
+	minProto := fs.Int("min-protocol", 0, "reject collectors below this protocol version (0 = protocol minimum)")
+	callTimeout := fs.Duration("call-timeout", 2*time.Minute, "timeout for calls without policy.timeout_ms")
+	queueDir := fs.String("queue-dir", "", "persist queued calls and dead letters in this directory (default: in memory)")
+	leaseTimeout := fs.Duration("lease-timeout", 5*time.Minute, "redeliver a call when its collector sends no result within this time")
+	maxAttempts := fs.Int("max-attempts", 3, "deliveries before a call is dead-lettered")
+	artifactBase := fs.String("artifact-base-url", "", "dashboard: resolve collector artifacts to {url}/artifacts/{id}")
+	configKey := fs.String("config-key", "", "base64 Ed25519 key signing remote collector config (plain or secret:// reference; see host keygen)")
+	_ = fs.Parse(args)
+
+	var signer ed25519.PrivateKey
+	if *configKey != "" {
+		k, err := protocol.ParseConfigPrivateKey(secrets.Lookup(*configKey))
+		if err != nil {
+			log.Fatalf("--config-key: %v", err)
+		}
+		signer = k
+	}
+
+	qopts := taskqueue.Options{MaxAttempts: *maxAttempts}
+	var queue taskqueue.Queue = taskqueue.NewMemoryQueue(qopts)
+	if *queueDir != "" {
+		fq, err := taskqueue.OpenFileQueue(*queueDir, qopts)
+		if err != nil {
+			log.Fatalf("task queue: %v", err)
+		}
+		queue = fq
+	}
+	h := host.New(host.Options{
+		WSPath:             *wsPath,
+		Token:              secrets.Lookup(*token),
+		APIToken:           secrets.Lookup(*apiToken),
+		MinProtocolVersion: *minProto,
+		CallTimeout:        *callTimeout,
+		Queue:              queue,
+		LeaseTimeout:       *leaseTimeout,
+		ArtifactBaseURL:    *artifactBase,
+		ConfigKey:          signer,
+	})
+	srv := &http.Server{Addr: *addr, Handler: h.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = h.Close()
+		_ = srv.Close()
+	}()
+	log.Printf("DigEino host listening on %s ws=%s dashboard=/dashboard/", *addr, *wsPath)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("host: %v", err)
+	}
+}
+
+// runHostKeygen prints a new config signing key pair: the private key for host --config-key and the
+// public key for Collector.RemoteConfig.TrustedKeys.
+func runHostKeygen() {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("private: %s  (host --config-key; keep it secret)\n", base64.StdEncoding.EncodeToString(priv.Seed()))
+	fmt.Printf("public:  %s  (Collector.RemoteConfig.TrustedKeys)\n", base64.StdEncoding.EncodeToString(pub))
+	fmt.Printf("key id:  %s\n", protocol.ConfigKeyID(pub))
+}
+
 func runMCP(args []string) {
 	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
-	configPath := fs.String("config", "config/config.yaml", "path to config.yaml")
+	cf := addWatchedConfigFlags(fs)
 	_ = fs.Parse(args)
 
-	cfg, err := loadConfig(*configPath)
+	cfg, err := cf.load()
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
 	rt := gateway.NewRuntime(cfg)
+	followConfig(cf, rt, gateway.ApplyConfig)
 	log.Printf("DigEino MCP server on stdio (instance=%s)", cfg.Gateway.InstanceID)
 	if err := mcpgw.ServeStdio(rt); err != nil {
 		log.Fatalf("mcp: %v", err)
@@ -163,14 +345,15 @@ func runMCP(args []string) {
 
 func runStdio(args []string) {
 	fs := flag.NewFlagSet("stdio", flag.ExitOnError)
-	configPath := fs.String("config", "config/config.yaml", "path to config.yaml")
+	cf := addWatchedConfigFlags(fs)
 	_ = fs.Parse(args)
 
-	cfg, err := loadConfig(*configPath)
+	cfg, err := cf.load()
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
 	rt := gateway.NewRuntime(cfg)
+	followConfig(cf, rt, gateway.ApplyConfig)
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 	if err := stdiogw.NewServer(rt).Run(ctx); err != nil && err != context.Canceled {
@@ -178,16 +361,34 @@ func runStdio(args []string) {
 	}
 }
 
-func loadConfig(path string) (*config.Config, error) {
-	if path == "" {
-		return config.Get(), nil
-	}
-	if _, err := os.Stat(path); err != nil {
-		if os.IsNotExist(err) {
+// loadConfig 在默认值之上合并 path（不存在时仅使用默认值）、DIGEINO_* 环境变量与 --set 覆盖项，并设为当前配置。
+func loadConfig(path string, overrides []string) (*config.Config, error) {
+	var files []string
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
 			log.Printf("config file %q not found, using defaults", path)
-			return config.Get(), nil
+		} else {
+			files = append(files, path)
 		}
+	}
+	cfg, err := config.LoadWithOptions(config.LoadOptions{Files: files, Overrides: overrides})
+	if err != nil {
 		return nil, err
 	}
-	return config.Load(path)
+	config.Set(cfg)
+	secrets.RememberConfig(cfg)
+	return cfg, nil
+}
+
+// authToken resolves Gateway.AuthToken; an unresolvable secret:// reference is fatal so the
+// gateway never starts without the auth it was configured with.
+func authToken(cfg *config.Config) string {
+	token, err := secrets.Resolve(cfg.Gateway.AuthToken)
+	if err != nil {
+		log.Fatalf("Gateway.AuthToken: %v", err)
+	}
+	return token
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/originaleric/digeino/secrets"
+)
+
+func runSecrets(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "usage: digeino secrets set|rm|list|check [flags] [key]")
+		os.Exit(2)
+	}
+	action, args := args[0], args[1:]
+	switch action {
+	case "set", "rm", "list":
+		runSecretsVault(action, args)
+	case "check":
+		runSecretsCheck(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown secrets action %q (set | rm | list | check)\n", action)
+		os.Exit(2)
+	}
+}
+
+// runSecretsVault edits the vault file; values are read from stdin and never printed.
+func runSecretsVault(action string, args []string) {
+	fs := flag.NewFlagSet("secrets "+action, flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	vaultPath := fs.String("vault", "", "vault file (overrides Secrets.VaultFile)")
+	_ = fs.Parse(args)
+
+	cfg, err := cf.load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	settings := cfg.Secrets
+	if *vaultPath != "" {
+		settings.VaultFile = *vaultPath
+	}
+	pass, err := secrets.Passphrase(settings, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vault, err := secrets.OpenVault(secrets.VaultPath(settings), pass)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if action == "list" {
+		for _, k := range vault.Keys() {
+			fmt.Println(k)
+		}
+		return
+	}
+	if fs.NArg() != 1 {
+		log.Fatalf("usage: digeino secrets %s [flags] <key>", action)
+	}
+	key := fs.Arg(0)
+	switch action {
+	case "set":
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprintf(os.Stderr, "enter the value for %s, then press Ctrl-D:\n", key)
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			log.Fatal("empty value; pipe the secret on stdin")
+		}
+		vault.Set(key, value)
+	case "rm":
+		if !vault.Delete(key) {
+			log.Fatalf("%s: %v", key, secrets.ErrNotFound)
+		}
+	}
+	if err := vault.Save(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "%s %s in %s (reference: secret://%s/%s)\n", action, key, vault.Path(), secrets.KindVaultFile, key)
+}
+
+// runSecretsCheck resolves every secret:// reference in the config without printing any value.
+func runSecretsCheck(args []string) {
+	fs := flag.NewFlagSet("secrets check", flag.ExitOnError)
+	cf := addConfigFlags(fs)
+	output := fs.String("o", "table", "output format: table | json")
+	_ = fs.Parse(args)
+
+	cfg, err := cf.load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	type refResult struct {
+		Path  string `json:"path"`
+		Ref   string `json:"ref"`
+		OK    bool   `json:"ok"`
+		Error string `json:"error,omitempty"`
+	}
+	var results []refResult
+	failed := 0
+	for _, f := range secrets.Fields(cfg) {
+		if !f.IsRef() {
+			continue
+		}
+		res := refResult{Path: f.Path, Ref: f.Value, OK: true}
+		if _, err := secrets.Resolve(f.Value); err != nil {
+			res.OK, res.Error = false, secrets.Scrub(err.Error())
+			failed++
+		}
+		results = append(results, res)
+	}
+	if *output == "json" {
+		printJSON(map[string]any{"config": *cf.path, "refs": results, "failed": failed})
+	} else {
+		if len(results) == 0 {
+			fmt.Println("no secret:// references in config")
+		}
+		for _, r := range results {
+			status := "ok"
+			if !r.OK {
+				status = "error: " + strings.TrimPrefix(r.Error, r.Ref+": ")
+			}
+			fmt.Printf("%-40s %-36s %s\n", r.Path, r.Ref, status)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,10 +1,6 @@
 package config
 
-import (
-	"os"
-
-	"gopkg.in/yaml.v3"
-)
+import "sync/atomic"
 
 // Config 根配置结构
 type Config struct {
@@ -19,6 +15,7 @@ type Config struct {
 	UIUX       UIUXConfig       `yaml:"UIUX" json:"UIUX"`
 	Tools      ToolsConfig      `yaml:"Tools" json:"Tools"`
 	Learning   LearningConfig   `yaml:"Learning" json:"Learning"`
+	Secrets    SecretsConfig    `yaml:"Secrets" json:"Secrets"`
 }
 
 // HttpServerConfig HTTP 服务配置
@@ -32,6 +29,7 @@ type HttpServerConfig struct {
 type GatewayConfig struct {
 	Enabled            *bool    `yaml:"Enabled" json:"Enabled,omitempty"`
 	ListenAddr         string   `yaml:"ListenAddr" json:"ListenAddr,omitempty"`
+	GRPCListenAddr     string   `yaml:"GRPCListenAddr" json:"GRPCListenAddr,omitempty"` // digeino grpc 监听地址，默认 :8788
 	InstanceID         string   `yaml:"InstanceID" json:"InstanceID,omitempty"`
 	AuthToken          string   `yaml:"AuthToken" json:"AuthToken,omitempty"`
 	AllowedTools       []string `yaml:"AllowedTools" json:"AllowedTools,omitempty"`
@@ -40,20 +38,103 @@ type GatewayConfig struct {
 	ArtifactEnabled    *bool    `yaml:"ArtifactEnabled" json:"ArtifactEnabled,omitempty"`
 	ArtifactDir        string   `yaml:"ArtifactDir" json:"ArtifactDir,omitempty"`
 	ArtifactTTLMinutes int      `yaml:"ArtifactTTLMinutes" json:"ArtifactTTLMinutes,omitempty"`
+	// ToolLimits 按工具名配置超时、并发隔离与熔断；键 "*" 作为未单独配置工具的默认值。
+	ToolLimits map[string]ToolLimitConfig `yaml:"ToolLimits" json:"ToolLimits,omitempty"`
+	// ResultCache 只读工具（平台读取、browser.browse 读模式）的结果缓存。
+	ResultCache ResultCacheConfig `yaml:"ResultCache" json:"ResultCache,omitempty"`
+	// Usage 按租户 / 工具聚合的用量统计（GET /usage）与每日报表导出。
+	Usage UsageConfig `yaml:"Usage" json:"Usage,omitempty"`
+}
+
+// UsageConfig 网关用量统计配置。
+type UsageConfig struct {
+	Enabled       *bool  `yaml:"Enabled" json:"Enabled,omitempty"`             // 默认开启
+	RetentionDays int    `yaml:"RetentionDays" json:"RetentionDays,omitempty"` // 内存中保留的小时桶天数，默认 8
+	ReportDir     string `yaml:"ReportDir" json:"ReportDir,omitempty"`         // 每日 00:01 导出前一天 CSV / JSON，空表示不导出
+}
+
+// ResultCacheConfig 网关结果缓存配置；Backend 为空时关闭缓存。
+type ResultCacheConfig struct {
+	Backend       string `yaml:"Backend" json:"Backend,omitempty"`             // memory | disk
+	Dir           string `yaml:"Dir" json:"Dir,omitempty"`                     // disk 后端目录
+	MaxEntries    int    `yaml:"MaxEntries" json:"MaxEntries,omitempty"`       // 最多缓存条数（memory / disk），超出时淘汰最旧的，默认 1000
+	DefaultTTLSec int    `yaml:"DefaultTTLSec" json:"DefaultTTLSec,omitempty"` // 默认缓存时长，默认 600
+}
+
+// ToolLimitConfig 单个网关工具的执行限制。
+type ToolLimitConfig struct {
+	DefaultTimeoutSec       int `yaml:"DefaultTimeoutSec" json:"DefaultTimeoutSec,omitempty"`             // 调用未指定 timeout_ms 时使用
+	MaxTimeoutSec           int `yaml:"MaxTimeoutSec" json:"MaxTimeoutSec,omitempty"`                     // timeout_ms 上限，0 表示不限制
+	MaxConcurrent           int `yaml:"MaxConcurrent" json:"MaxConcurrent,omitempty"`                     // 最大并发执行数，0 表示不限制
+	MaxQueue                int `yaml:"MaxQueue" json:"MaxQueue,omitempty"`                               // 并发已满时的最大排队数
+	BreakerFailureThreshold int `yaml:"BreakerFailureThreshold" json:"BreakerFailureThreshold,omitempty"` // 连续 TOOL_EXEC_FAILED 次数达到后熔断（间隔超过 BreakerOpenSec 的失败不算连续），0 表示关闭熔断
+	BreakerOpenSec          int `yaml:"BreakerOpenSec" json:"BreakerOpenSec,omitempty"`                   // 熔断持续时间，之后放行一次半开探测
+	RetryMaxAttempts        int `yaml:"RetryMaxAttempts" json:"RetryMaxAttempts,omitempty"`               // 含首次尝试；<=1 不重试，仅对可重试错误生效
+	RetryBackoffMs          int `yaml:"RetryBackoffMs" json:"RetryBackoffMs,omitempty"`                   // 首次重试退避（指数增长并带抖动）
+	RetryMaxBackoffMs       int `yaml:"RetryMaxBackoffMs" json:"RetryMaxBackoffMs,omitempty"`             // 单次退避上限
+	AttemptTimeoutSec       int `yaml:"AttemptTimeoutSec" json:"AttemptTimeoutSec,omitempty"`             // 单次尝试超时，0 表示使用剩余总预算
+	CacheTTLSec             int `yaml:"CacheTTLSec" json:"CacheTTLSec,omitempty"`                         // 覆盖 ResultCache.DefaultTTLSec；<0 表示该工具不缓存
 }
 
 // CollectorConfig 本地 Collector（WebSocket 反向连接）配置。
 type CollectorConfig struct {
-	ServerURL            string   `yaml:"ServerURL" json:"ServerURL,omitempty"`
-	WSPath               string   `yaml:"WSPath" json:"WSPath,omitempty"`
-	Token                string   `yaml:"Token" json:"Token,omitempty"`
-	InstanceID           string   `yaml:"InstanceID" json:"InstanceID,omitempty"`
-	HeartbeatIntervalSec int      `yaml:"HeartbeatIntervalSec" json:"HeartbeatIntervalSec,omitempty"`
-	PullIntervalSec      int      `yaml:"PullIntervalSec" json:"PullIntervalSec,omitempty"`
-	PullBatchSize        int      `yaml:"PullBatchSize" json:"PullBatchSize,omitempty"`
-	ReconnectDelaySec    int      `yaml:"ReconnectDelaySec" json:"ReconnectDelaySec,omitempty"`
-	MaxConcurrentCalls   int      `yaml:"MaxConcurrentCalls" json:"MaxConcurrentCalls,omitempty"`
-	AllowedTools         []string `yaml:"AllowedTools" json:"AllowedTools,omitempty"`
+	ServerURL            string                   `yaml:"ServerURL" json:"ServerURL,omitempty"`
+	WSPath               string                   `yaml:"WSPath" json:"WSPath,omitempty"`
+	Token                string                   `yaml:"Token" json:"Token,omitempty"`
+	InstanceID           string                   `yaml:"InstanceID" json:"InstanceID,omitempty"`
+	Labels               map[string]string        `yaml:"Labels" json:"Labels,omitempty"` // 随 hello / status 上报的标签（如 region），覆盖同名的自动探测标签
+	Mode                 string                   `yaml:"Mode" json:"Mode,omitempty"`     // failover（默认，按顺序连接 Hosts）| all（同时连接全部 Hosts）
+	Hosts                []CollectorHostConfig    `yaml:"Hosts" json:"Hosts,omitempty"`   // 多宿主；设置后忽略 ServerURL / Token
+	HeartbeatIntervalSec int                      `yaml:"HeartbeatIntervalSec" json:"HeartbeatIntervalSec,omitempty"`
+	PullIntervalSec      int                      `yaml:"PullIntervalSec" json:"PullIntervalSec,omitempty"`
+	PullBatchSize        int                      `yaml:"PullBatchSize" json:"PullBatchSize,omitempty"`
+	ReconnectDelaySec    int                      `yaml:"ReconnectDelaySec" json:"ReconnectDelaySec,omitempty"`       // 首次重连等待，之后指数退避（带抖动）
+	ReconnectMaxDelaySec int                      `yaml:"ReconnectMaxDelaySec" json:"ReconnectMaxDelaySec,omitempty"` // 退避上限，默认 120
+	DrainTimeoutSec      int                      `yaml:"DrainTimeoutSec" json:"DrainTimeoutSec,omitempty"`           // 退出时等待执行中调用的时长，默认 30
+	MaxConcurrentCalls   int                      `yaml:"MaxConcurrentCalls" json:"MaxConcurrentCalls,omitempty"`
+	QueueSize            int                      `yaml:"QueueSize" json:"QueueSize,omitempty"`                   // 本地排队上限（等待并发槽位或限流冷却），默认 100
+	RateLimits           []CollectorRateLimitRule `yaml:"RateLimits" json:"RateLimits,omitempty"`                 // 按 key / 域名限速，取第一条匹配；无匹配时冷却 1 秒
+	RateLimitStateFile   string                   `yaml:"RateLimitStateFile" json:"RateLimitStateFile,omitempty"` // 限速状态持久化文件，重启后不重置节奏；为空只保存在内存
+	AllowedTools         []string                 `yaml:"AllowedTools" json:"AllowedTools,omitempty"`
+	OutboxDir            string                   `yaml:"OutboxDir" json:"OutboxDir,omitempty"`               // 未确认结果的持久化目录，为空时不持久化
+	OutboxMaxEntries     int                      `yaml:"OutboxMaxEntries" json:"OutboxMaxEntries,omitempty"` // 超出时丢弃最旧的结果，默认 1000
+	OutboxMaxAgeSec      int                      `yaml:"OutboxMaxAgeSec" json:"OutboxMaxAgeSec,omitempty"`   // 超过该时长仍未确认的结果被丢弃，默认 86400
+	RemoteConfig         CollectorRemoteConfig    `yaml:"RemoteConfig" json:"RemoteConfig,omitempty"`         // 宿主下发配置（collector_config_update）的本地上限
+}
+
+// CollectorRemoteConfig 限定宿主可通过 collector_config_update 下发的内容。
+// 未配置 TrustedKeys 或 StateFile 时不接受任何远程配置；Disabled 为本地强制开关。
+type CollectorRemoteConfig struct {
+	Disabled           bool     `yaml:"Disabled" json:"Disabled,omitempty"`                     // 拒绝全部远程配置，也不再向宿主声明 remote_config 能力
+	TrustedKeys        []string `yaml:"TrustedKeys" json:"TrustedKeys,omitempty"`               // base64 Ed25519 公钥（digeino host keygen 生成），支持 secret://env/NAME、secret://file/路径 引用
+	AllowedTools       []string `yaml:"AllowedTools" json:"AllowedTools,omitempty"`             // 远程可启用的工具上限（仍受 Collector.AllowedTools 限制），为空表示不限
+	AllowedDomains     []string `yaml:"AllowedDomains" json:"AllowedDomains,omitempty"`         // 远程可下发的域名上限（含子域名），为空表示不允许下发域名
+	RateLimits         bool     `yaml:"RateLimits" json:"RateLimits,omitempty"`                 // 是否允许下发限速规则
+	MinIntervalMsFloor int      `yaml:"MinIntervalMsFloor" json:"MinIntervalMsFloor,omitempty"` // 下发规则的 MinIntervalMs 不得低于该值
+	StateFile          string   `yaml:"StateFile" json:"StateFile,omitempty"`                   // 已应用的远程配置及版本（防重放水位）的持久化文件，必填；多宿主时每个宿主一个同名后缀文件
+	MaxAgeSec          int      `yaml:"MaxAgeSec" json:"MaxAgeSec,omitempty"`                   // 拒绝签发时间早于该时长的配置，默认 600
+}
+
+// CollectorHostConfig 一个宿主端点。Token 为空时沿用 Collector.Token，WSPath 为空时沿用 Collector.WSPath；
+// AllowedTools 在 Collector.AllowedTools 基础上进一步限制该宿主可调用的工具。
+type CollectorHostConfig struct {
+	Name         string   `yaml:"Name" json:"Name,omitempty"` // 日志与事件中的名称，默认取 URL 主机名
+	ServerURL    string   `yaml:"ServerURL" json:"ServerURL,omitempty"`
+	WSPath       string   `yaml:"WSPath" json:"WSPath,omitempty"`
+	Token        string   `yaml:"Token" json:"Token,omitempty"`
+	AllowedTools []string `yaml:"AllowedTools" json:"AllowedTools,omitempty"`
+}
+
+// CollectorRateLimitRule 单条限速规则：Match 匹配 policy.rate_limit_key（path.Match 通配，如 "xhs:*"），
+// Domain 匹配 key 中的主机名或调用 input.url 的主机名（含子域名）。
+type CollectorRateLimitRule struct {
+	Match         string `yaml:"Match" json:"Match,omitempty"`
+	Domain        string `yaml:"Domain" json:"Domain,omitempty"`
+	MinIntervalMs int    `yaml:"MinIntervalMs" json:"MinIntervalMs,omitempty"` // 同一 key 两次调用的最小间隔
+	JitterMs      int    `yaml:"JitterMs" json:"JitterMs,omitempty"`           // 在最小间隔上追加 [0, JitterMs] 的随机间隔
+	MaxCalls      int    `yaml:"MaxCalls" json:"MaxCalls,omitempty"`           // 每个 WindowSec 内最多调用次数，0 不限
+	WindowSec     int    `yaml:"WindowSec" json:"WindowSec,omitempty"`
+	DailyCap      int    `yaml:"DailyCap" json:"DailyCap,omitempty"` // 每个自然日（本地时区）的调用上限，0 不限
 }
 
 // StatusConfig 状态相关配置：包含 Webhook、Store 与 DataFlow
@@ -71,6 +152,13 @@ type DataFlowConfig struct {
 	Enabled *bool `yaml:"Enabled" json:"Enabled,omitempty"` // 是否启用，nil 或 false 表示禁用（默认关闭）
 }
 
+// SecretsConfig secret:// 引用的解析配置（见 secrets 包）。
+type SecretsConfig struct {
+	VaultFile      string `yaml:"VaultFile" json:"VaultFile,omitempty"`           // secret://vault-file/<key> 读取的加密文件，默认 storage/secrets.vault
+	PassphraseFile string `yaml:"PassphraseFile" json:"PassphraseFile,omitempty"` // vault 口令文件；为空时读取环境变量 DIGEINO_VAULT_PASSPHRASE
+	CacheTTLSec    int    `yaml:"CacheTTLSec" json:"CacheTTLSec,omitempty"`       // 已解析值的缓存秒数，默认 300；配置重载时清空
+}
+
 // LearningConfig PostRun 自进化学习（默认关闭；由宿主注册 learning.Host 后启用）。
 type LearningConfig struct {
 	Enabled              bool                `yaml:"Enabled" json:"Enabled,omitempty"`
@@ -412,38 +500,35 @@ type EmbeddingConfig struct {
 	Dimensions int    `yaml:"Dimensions" json:"Dimensions"`
 }
 
-var (
-	// DefaultConfig 全局默认配置
-	currentConfig *Config
-)
+// currentConfig 当前全局配置；读取无锁，替换见 Set。
+var currentConfig atomic.Pointer[Config]
 
-// Load 从文件加载配置
+// Load 从文件加载配置：在 Default() 之上合并文件（含 include）与 DIGEINO_* 环境变量，校验后设为当前配置。
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	cfg, err := LoadWithOptions(LoadOptions{Files: []string{path}})
 	if err != nil {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
-	}
-
-	currentConfig = &cfg
-	return &cfg, nil
+	Set(cfg)
+	return cfg, nil
 }
 
-// Get 获取当前配置
+// Get 获取当前配置。返回值视为只读：热更新会整体替换而不是原地修改，长期持有的组件应通过 Subscribe 获取新配置。
 func Get() *Config {
-	if currentConfig == nil {
-		currentConfig = Default()
+	if cfg := currentConfig.Load(); cfg != nil {
+		return cfg
 	}
-	return currentConfig
+	currentConfig.CompareAndSwap(nil, Default())
+	return currentConfig.Load()
 }
 
-// Set 设置当前配置
+// Set 设置当前配置并通知订阅者（见 Subscribe）。
 func Set(cfg *Config) {
-	currentConfig = cfg
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	old := currentConfig.Swap(cfg)
+	notify(old, cfg)
 }
 
 // Default 返回默认配置
@@ -483,11 +568,21 @@ func Default() *Config {
 			PullIntervalSec:      0,
 			PullBatchSize:        1,
 			ReconnectDelaySec:    5,
+			ReconnectMaxDelaySec: 120,
+			DrainTimeoutSec:      30,
 			MaxConcurrentCalls:   1,
+			QueueSize:            100,
+			RateLimitStateFile:   "storage/app/collector_ratelimit.json",
 			AllowedTools: []string{
 				"browser.browse",
 				"wechat.article.read",
 			},
+			OutboxDir:        "storage/app/collector_outbox",
+			OutboxMaxEntries: 1000,
+			OutboxMaxAgeSec:  86400,
+			RemoteConfig: CollectorRemoteConfig{
+				StateFile: "storage/app/collector_remote_config.json",
+			},
 		},
 		Status: StatusConfig{
 			Webhook: AppWebhookConfig{
@@ -653,5 +748,9 @@ func Default() *Config {
 			},
 			ExecutionPhase: 0,
 		},
+		Secrets: SecretsConfig{
+			VaultFile:   "storage/secrets.vault",
+			CacheTTLSec: 300,
+		},
 	}
 }
@@ -0,0 +1,534 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvPrefix 环境变量覆盖的默认前缀，例如 DIGEINO_GATEWAY_AUTHTOKEN。
+const DefaultEnvPrefix = "DIGEINO"
+
+// LoadOptions 分层加载配置：Default() → Files（支持 include）→ 环境变量 → Overrides，后者覆盖前者。
+type LoadOptions struct {
+	// Files 按顺序合并的 YAML 文件；文件顶层 include: 引用的文件（相对当前文件）先于其自身内容合并。
+	Files []string
+	// EnvPrefix 环境变量前缀，默认 DIGEINO；设为 "-" 关闭环境变量覆盖。
+	EnvPrefix string
+	// Environ 形如 KEY=VALUE 的环境变量列表，nil 时使用 os.Environ()；同时用于 ${VAR} 展开。
+	Environ []string
+	// Overrides 形如 Gateway.ListenAddr=:9000 的覆盖项（通常来自 --set）；map 键用方括号，例如 Gateway.ToolLimits[browser.browse].MaxConcurrent=2。
+	Overrides []string
+	// SkipValidate 跳过 Validate（未知字段与类型错误仍会报错）。
+	SkipValidate bool
+}
+
+// LoadWithOptions 按 opts 分层加载配置；与 Load 不同，不修改当前全局配置。
+func LoadWithOptions(opts LoadOptions) (*Config, error) {
+	cfg, _, err := load(opts)
+	return cfg, err
+}
+
+// load 同 LoadWithOptions，并返回读取过的全部文件（含 include），供 Watcher 监视。
+func load(opts LoadOptions) (*Config, []string, error) {
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	env := envMap(environ)
+
+	tree, err := defaultTree()
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []string
+	for _, file := range opts.Files {
+		layer, err := readLayer(file, env, nil, &files)
+		if err != nil {
+			return nil, files, err
+		}
+		tree = mergeTree(tree, layer)
+	}
+
+	var errs ValidationError
+	rootType := reflect.TypeOf(Config{})
+	if opts.EnvPrefix != "-" {
+		prefix := opts.EnvPrefix
+		if prefix == "" {
+			prefix = DefaultEnvPrefix
+		}
+		applyEnv(tree, rootType, environ, prefix+"_")
+	}
+	for _, o := range opts.Overrides {
+		key, value, ok := strings.Cut(o, "=")
+		if !ok {
+			errs = append(errs, FieldError{Path: o, Message: "override must be key=value"})
+			continue
+		}
+		if err := setOverride(tree, rootType, strings.TrimSpace(key), value); err != nil {
+			errs = append(errs, FieldError{Path: strings.TrimSpace(key), Message: err.Error()})
+		}
+	}
+
+	normalized := normalizeValue(tree, rootType, "", &errs)
+	if len(errs) > 0 {
+		return nil, files, errs
+	}
+	data, err := yaml.Marshal(normalized)
+	if err != nil {
+		return nil, files, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, files, err
+	}
+	if !opts.SkipValidate {
+		if err := cfg.Validate(); err != nil {
+			return nil, files, err
+		}
+	}
+	return &cfg, files, nil
+}
+
+// defaultTree 将 Default() 转为通用 map，作为最底层。
+func defaultTree() (map[string]any, error) {
+	data, err := yaml.Marshal(Default())
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]any{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// readLayer 读取一个文件及其 include，展开 ${VAR}，返回合并后的 map。
+func readLayer(path string, env map[string]string, stack []string, files *[]string) (map[string]any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("config include cycle: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+	*files = append(*files, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]any{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	includes, err := includeList(doc["include"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	delete(doc, "include")
+
+	out := map[string]any{}
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		layer, err := readLayer(inc, env, stack, files)
+		if err != nil {
+			return nil, err
+		}
+		out = mergeTree(out, layer)
+	}
+	return mergeTree(out, expandTree(doc, env).(map[string]any)), nil
+}
+
+func includeList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include entries must be strings, got %T", item)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("include must be a string or a list of strings, got %T", v)
+	}
+}
+
+// mergeTree 将 over 深度合并进 base：map 递归合并，其余类型（含列表）整体替换。
+func mergeTree(base, over map[string]any) map[string]any {
+	if base == nil {
+		base = map[string]any{}
+	}
+	for k, v := range over {
+		if om, ok := v.(map[string]any); ok {
+			if bm, ok := base[k].(map[string]any); ok {
+				base[k] = mergeTree(bm, om)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
+
+var envRefPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandTree 展开字符串中的 ${VAR} 与 ${VAR:-default}；$${ 表示字面量 ${。
+// 未设置且无默认值的变量保持原样，交由 ChatModel / OCR 等按各自规则解析或报错。
+func expandTree(v any, env map[string]string) any {
+	switch v := v.(type) {
+	case string:
+		return expandString(v, env)
+	case map[string]any:
+		for k, item := range v {
+			v[k] = expandTree(item, env)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = expandTree(item, env)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func expandString(s string, env map[string]string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := envRefPattern.FindStringSubmatch(ref)
+		if val, ok := env[m[1]]; ok && val != "" {
+			return val
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		return ref
+	})
+}
+
+func envMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// applyEnv 将 PREFIX_SECTION_FIELD 形式的环境变量写入 tree。字段名不区分大小写，
+// 下划线可选（DIGEINO_TOOLS_LOCALBROWSER_ALLOWEDDOMAINS）；列表用逗号分隔；
+// 无法对应到字段的变量（如 DIGEINO_TOKEN）被忽略。map 类型字段不支持环境变量覆盖。
+func applyEnv(tree map[string]any, root reflect.Type, environ []string, prefix string) {
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		segs := strings.Split(strings.TrimPrefix(key, prefix), "_")
+		path, leaf, ok := resolveEnvPath(root, segs)
+		if !ok || !isLeaf(leaf) {
+			continue
+		}
+		setPath(tree, path, rawValue(value, leaf))
+	}
+}
+
+func resolveEnvPath(t reflect.Type, segs []string) ([]string, reflect.Type, bool) {
+	t = deref(t)
+	if len(segs) == 0 {
+		return nil, t, true
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil, false
+	}
+	for _, f := range structFields(t) {
+		norm := strings.ToLower(strings.ReplaceAll(f.Name, "_", ""))
+		for k := 1; k <= len(segs); k++ {
+			if strings.ToLower(strings.Join(segs[:k], "")) != norm {
+				continue
+			}
+			rest, leaf, ok := resolveEnvPath(f.Type, segs[k:])
+			if ok {
+				return append([]string{f.Name}, rest...), leaf, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// setOverride 应用一条 key=value 覆盖；key 为 YAML 字段路径，不区分大小写。
+func setOverride(tree map[string]any, root reflect.Type, key, value string) error {
+	segs, err := splitPath(key)
+	if err != nil {
+		return err
+	}
+	t := root
+	path := make([]string, 0, len(segs))
+	for _, seg := range segs {
+		t = deref(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByName(t, seg)
+			if !ok {
+				return fmt.Errorf("unknown field %q", seg)
+			}
+			path = append(path, f.Name)
+			t = f.Type
+		case reflect.Map:
+			path = append(path, seg)
+			t = t.Elem()
+		default:
+			return fmt.Errorf("%q is not an object", strings.Join(path, "."))
+		}
+	}
+	if !isLeaf(t) {
+		return fmt.Errorf("cannot set an object from a flag, set its fields instead")
+	}
+	setPath(tree, path, rawValue(value, t))
+	return nil
+}
+
+// splitPath 解析 A.B[key.with.dots].C。
+func splitPath(key string) ([]string, error) {
+	var segs []string
+	for key != "" {
+		switch {
+		case key[0] == '[':
+			end := strings.IndexByte(key, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", key)
+			}
+			segs = append(segs, key[1:end])
+			key = strings.TrimPrefix(key[end+1:], ".")
+		default:
+			end := strings.IndexAny(key, ".[")
+			if end < 0 {
+				end = len(key)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty path segment")
+			}
+			segs = append(segs, key[:end])
+			key = strings.TrimPrefix(key[end:], ".")
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("empty key")
+	}
+	return segs, nil
+}
+
+func setPath(tree map[string]any, path []string, value any) {
+	m := tree
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// rawValue 列表字段按逗号拆分，其余保持字符串，由 normalizeValue 统一转换类型。
+func rawValue(value string, t reflect.Type) any {
+	if t.Kind() != reflect.Slice {
+		return value
+	}
+	if strings.TrimSpace(value) == "" {
+		return []any{}
+	}
+	parts := strings.Split(value, ",")
+	out := make([]any, len(parts))
+	for i, p := range parts {
+		out[i] = strings.TrimSpace(p)
+	}
+	return out
+}
+
+// normalizeValue 按 Config 的结构校验未知字段，并把字符串标量（来自环境变量、--set 或 ${VAR}）转换为字段类型。
+func normalizeValue(v any, t reflect.Type, path string, errs *ValidationError) any {
+	if v == nil {
+		return nil
+	}
+	t = deref(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok {
+			*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("expected an object, got %s", describe(v))})
+			return v
+		}
+		fields := map[string]fieldInfo{}
+		for _, f := range structFields(t) {
+			fields[f.Name] = f
+		}
+		for k, item := range m {
+			f, ok := fields[k]
+			if !ok {
+				msg := "unknown field"
+				if f, ok := fieldByName(t, k); ok {
+					msg = fmt.Sprintf("unknown field, did you mean %q?", f.Name)
+				}
+				*errs = append(*errs, FieldError{Path: joinPath(path, k), Message: msg})
+				continue
+			}
+			m[k] = normalizeValue(item, f.Type, joinPath(path, k), errs)
+		}
+		return m
+	case reflect.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("expected an object, got %s", describe(v))})
+			return v
+		}
+		for k, item := range m {
+			m[k] = normalizeValue(item, t.Elem(), path+"["+k+"]", errs)
+		}
+		return m
+	case reflect.Slice:
+		list, ok := v.([]any)
+		if !ok {
+			*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("expected a list, got %s", describe(v))})
+			return v
+		}
+		for i, item := range list {
+			list[i] = normalizeValue(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		return list
+	case reflect.Interface:
+		return v
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	var (
+		out any
+		err error
+	)
+	switch t.Kind() {
+	case reflect.Bool:
+		out, err = strconv.ParseBool(strings.TrimSpace(s))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out, err = strconv.ParseInt(strings.TrimSpace(s), 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		out, err = strconv.ParseUint(strings.TrimSpace(s), 10, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		out, err = strconv.ParseFloat(strings.TrimSpace(s), t.Bits())
+	default:
+		return s
+	}
+	if err != nil {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("invalid %s value %q", t.Kind(), s)})
+		return v
+	}
+	return out
+}
+
+func describe(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "a list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// fieldInfo 是一个 YAML 可见字段；inline 结构体的字段被展开到外层。
+type fieldInfo struct {
+	Name string
+	Type reflect.Type
+}
+
+func structFields(t reflect.Type) []fieldInfo {
+	var out []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			out = append(out, structFields(deref(f.Type))...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		out = append(out, fieldInfo{Name: name, Type: f.Type})
+	}
+	return out
+}
+
+func fieldByName(t reflect.Type, name string) (fieldInfo, bool) {
+	for _, f := range structFields(t) {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isLeaf 报告字段能否由单个字符串（环境变量 / --set）设置：标量或标量列表。
+func isLeaf(t reflect.Type) bool {
+	t = deref(t)
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return false
+	case reflect.Slice:
+		k := deref(t.Elem()).Kind()
+		return k != reflect.Struct && k != reflect.Map && k != reflect.Slice
+	default:
+		return true
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWithOptionsLayers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "base.yaml", `
+Gateway:
+  ListenAddr: ":9000"
+  InstanceID: "base"
+  ArtifactTTLMinutes: 5
+`)
+	main := writeFile(t, dir, "main.yaml", `
+include: base.yaml
+Gateway:
+  InstanceID: "main"
+  AuthToken: "${GW_TOKEN}"
+  ArtifactDir: "${ARTIFACT_DIR:-/tmp/artifacts}"
+  ToolLimits:
+    browser.browse:
+      MaxConcurrent: 2
+Tools:
+  OCR:
+    OpenAICompatible:
+      ApiKey: "${UNSET_OCR_KEY}"
+`)
+
+	cfg, err := LoadWithOptions(LoadOptions{
+		Files: []string{main},
+		Environ: []string{
+			"GW_TOKEN=s3cret",
+			"DIGEINO_GATEWAY_ARTIFACTTTLMINUTES=30",
+			"DIGEINO_TOOLS_LOCAL_BROWSER_ALLOWEDDOMAINS=a.com, b.com",
+			"DIGEINO_TOKEN=ignored",
+		},
+		Overrides: []string{
+			"gateway.instanceid=flag",
+			"Gateway.ToolLimits[browser.browse].MaxQueue=7",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := cfg.Gateway
+	if g.ListenAddr != ":9000" || g.InstanceID != "flag" || g.AuthToken != "s3cret" || g.ArtifactDir != "/tmp/artifacts" {
+		t.Fatalf("unexpected gateway config: %+v", g)
+	}
+	if g.ArtifactTTLMinutes != 30 {
+		t.Fatalf("env override not applied: %d", g.ArtifactTTLMinutes)
+	}
+	if l := g.ToolLimits["browser.browse"]; l.MaxConcurrent != 2 || l.MaxQueue != 7 {
+		t.Fatalf("tool limits = %+v", l)
+	}
+	if got := cfg.Tools.LocalBrowser.AllowedDomains; len(got) != 2 || got[1] != "b.com" {
+		t.Fatalf("AllowedDomains = %v", got)
+	}
+	if cfg.Tools.OCR.OpenAICompatible.ApiKey != "${UNSET_OCR_KEY}" {
+		t.Fatalf("unset variable should stay literal, got %q", cfg.Tools.OCR.OpenAICompatible.ApiKey)
+	}
+	// 未在文件中出现的字段保留 Default() 的值。
+	if cfg.HttpServer.Api.Port != ":20201" || len(g.AllowedTools) == 0 {
+		t.Fatalf("defaults not merged: port=%q tools=%v", cfg.HttpServer.Api.Port, g.AllowedTools)
+	}
+}
+
+func TestLoadWithOptionsReportsFieldPaths(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "bad.yaml", `
+Gateway:
+  ListnAddr: ":1"
+  ListenAddr: "nope"
+  ResultCache:
+    Backend: redis
+Status:
+  Event:
+    SampleRate: 150
+`)
+	_, err := LoadWithOptions(LoadOptions{Files: []string{path}, Environ: []string{}})
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if len(verr) != 1 || verr[0].Path != "Gateway.ListnAddr" {
+		t.Fatalf("unknown field must be reported before validation: %v", verr)
+	}
+
+	path = writeFile(t, dir, "bad.yaml", `
+Gateway:
+  ListenAddr: "nope"
+  ResultCache:
+    Backend: redis
+Status:
+  Event:
+    SampleRate: 150
+`)
+	_, err = LoadWithOptions(LoadOptions{Files: []string{path}, Environ: []string{"DIGEINO_COLLECTOR_PULLBATCHSIZE=many"}})
+	if !errors.As(err, &verr) || len(verr) != 1 || verr[0].Path != "Collector.PullBatchSize" {
+		t.Fatalf("expected type error for Collector.PullBatchSize, got %v", err)
+	}
+
+	_, err = LoadWithOptions(LoadOptions{Files: []string{path}, Environ: []string{}})
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	for _, want := range []string{"Gateway.ListenAddr", "Gateway.ResultCache.Backend", "Status.Event.SampleRate"} {
+		if !strings.Contains(err.Error(), want+":") {
+			t.Fatalf("missing %s in %v", want, err)
+		}
+	}
+}
+
+func TestLoadWithOptionsIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", "include: b.yaml\n")
+	b := writeFile(t, dir, "b.yaml", "include: [a.yaml]\n")
+	if _, err := LoadWithOptions(LoadOptions{Files: []string{b}}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	s := JSONSchema()
+	props := s["properties"].(map[string]any)
+	gw := props["Gateway"].(map[string]any)["properties"].(map[string]any)
+	backend := gw["ResultCache"].(map[string]any)["properties"].(map[string]any)["Backend"].(map[string]any)
+	if _, ok := backend["enum"]; !ok {
+		t.Fatalf("Backend has no enum: %v", backend)
+	}
+	if gw["Enabled"].(map[string]any)["type"].([]any)[1] != "null" {
+		t.Fatalf("*bool should be nullable: %v", gw["Enabled"])
+	}
+	webhook := props["Status"].(map[string]any)["properties"].(map[string]any)["Webhook"].(map[string]any)["properties"].(map[string]any)
+	if _, ok := webhook["retry_count"]; !ok {
+		t.Fatal("inline WebhookConfig fields missing")
+	}
+	if _, ok := props["include"]; !ok {
+		t.Fatal("include missing")
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+)
+
+// JSONSchema 根据 Config 结构生成 JSON Schema（draft 2020-12），供编辑器对 config.yaml 做补全与校验，
+// 例如 VS Code YAML 插件的 "# yaml-language-server: $schema=./digeino.schema.json"。
+func JSONSchema() map[string]any {
+	schema := schemaFor(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "DigEino config"
+	props := schema["properties"].(map[string]any)
+	props["include"] = map[string]any{
+		"description": "Files merged before this one, relative to this file",
+		"oneOf": []any{
+			map[string]string{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]string{"type": "string"}},
+		},
+	}
+	return schema
+}
+
+func schemaFor(t reflect.Type, path string) map[string]any {
+	nullable := t.Kind() == reflect.Pointer
+	t = deref(t)
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		for _, f := range structFields(t) {
+			props[f.Name] = schemaFor(f.Type, joinPath(path, f.Name))
+		}
+		s = map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Map:
+		s = map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), path+"[*]")}
+	case reflect.Slice:
+		s = map[string]any{"type": "array", "items": schemaFor(t.Elem(), path+"[*]")}
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		s = map[string]any{"type": "number"}
+	case reflect.String:
+		s = map[string]any{"type": "string"}
+		if values, ok := enumValues[path]; ok {
+			s["enum"] = values
+		}
+	default:
+		return map[string]any{}
+	}
+	if nullable {
+		s["type"] = []any{s["type"], "null"}
+	}
+	return s
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// FieldError 是一条带字段路径的配置错误，例如 Gateway.ListenAddr。
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError 汇总加载或校验配置时发现的所有字段错误。
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+	return "invalid config:\n  " + strings.Join(lines, "\n  ")
+}
+
+// enumValues 按字段路径列出可选值；Validate 与 JSONSchema 共用。空字符串表示使用默认值。
+var enumValues = map[string][]string{
+	"Gateway.ResultCache.Backend":        {"", "memory", "disk"},
+	"Status.Store.Type":                  {"", "memory", "mysql", "pinecone", "hybrid"},
+	"Feishu.ConnectionMode":              {"", "websocket", "webhook"},
+	"Feishu.API.ReceiveIDType":           {"", "chat_id", "open_id", "user_id", "union_id", "email"},
+	"Tools.WebSearch.Engine":             {"", "bocha", "serpapi", "google", "bing", "duckduckgo", "firecrawl", "tavily"},
+	"Tools.WebSearch.Tavily.SearchDepth": {"", "basic", "fast", "advanced", "ultra-fast"},
+	"Tools.WebSearch.Tavily.Topic":       {"", "general", "news", "finance"},
+	"Tools.OCR.DeepSeek.Mode":            {"", "chat", "ocr", "ocr_endpoint"},
+	"Collector.Mode":                     {"", "failover", "all"},
+}
+
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) nonNegative(path string, n int) {
+	if n < 0 {
+		v.add(path, "must be >= 0, got %d", n)
+	}
+}
+
+func (v *validator) between(path string, n, lo, hi int) {
+	if n < lo || n > hi {
+		v.add(path, "must be between %d and %d, got %d", lo, hi, n)
+	}
+}
+
+func (v *validator) enum(path, value string) {
+	allowed := enumValues[path]
+	if !slices.Contains(allowed, value) {
+		v.add(path, "must be one of %s, got %q", strings.Join(allowed[1:], ", "), value)
+	}
+}
+
+func (v *validator) listenAddr(path, addr string) {
+	if addr == "" {
+		return
+	}
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		v.add(path, "must be host:port or :port, got %q", addr)
+	}
+}
+
+func (v *validator) url(path, raw string, schemes ...string) {
+	if raw == "" || strings.Contains(raw, "${") {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		v.add(path, "must be an absolute %s URL, got %q", strings.Join(schemes, "/"), raw)
+	}
+}
+
+// Validate 检查取值范围、枚举与地址格式，返回 ValidationError（字段路径 + 说明）或 nil。
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	g := c.Gateway
+	v.listenAddr("Gateway.ListenAddr", g.ListenAddr)
+	v.listenAddr("Gateway.GRPCListenAddr", g.GRPCListenAddr)
+	v.nonNegative("Gateway.ArtifactTTLMinutes", g.ArtifactTTLMinutes)
+	v.enum("Gateway.ResultCache.Backend", g.ResultCache.Backend)
+	v.nonNegative("Gateway.ResultCache.MaxEntries", g.ResultCache.MaxEntries)
+	v.nonNegative("Gateway.ResultCache.DefaultTTLSec", g.ResultCache.DefaultTTLSec)
+	v.nonNegative("Gateway.Usage.RetentionDays", g.Usage.RetentionDays)
+	for _, name := range sortedKeys(g.ToolLimits) {
+		l := g.ToolLimits[name]
+		p := "Gateway.ToolLimits[" + name + "]."
+		v.nonNegative(p+"DefaultTimeoutSec", l.DefaultTimeoutSec)
+		v.nonNegative(p+"MaxTimeoutSec", l.MaxTimeoutSec)
+		v.nonNegative(p+"MaxConcurrent", l.MaxConcurrent)
+		v.nonNegative(p+"MaxQueue", l.MaxQueue)
+		v.nonNegative(p+"BreakerFailureThreshold", l.BreakerFailureThreshold)
+		v.nonNegative(p+"BreakerOpenSec", l.BreakerOpenSec)
+		v.nonNegative(p+"RetryMaxAttempts", l.RetryMaxAttempts)
+		v.nonNegative(p+"RetryBackoffMs", l.RetryBackoffMs)
+		v.nonNegative(p+"RetryMaxBackoffMs", l.RetryMaxBackoffMs)
+		v.nonNegative(p+"AttemptTimeoutSec", l.AttemptTimeoutSec)
+		if l.MaxTimeoutSec > 0 && l.DefaultTimeoutSec > l.MaxTimeoutSec {
+			v.add(p+"DefaultTimeoutSec", "must not exceed MaxTimeoutSec (%d), got %d", l.MaxTimeoutSec, l.DefaultTimeoutSec)
+		}
+	}
+
+	col := c.Collector
+	v.url("Collector.ServerURL", col.ServerURL, "http", "https", "ws", "wss")
+	v.enum("Collector.Mode", col.Mode)
+	names := map[string]bool{}
+	for i, h := range col.Hosts {
+		p := fmt.Sprintf("Collector.Hosts[%d].", i)
+		if strings.TrimSpace(h.ServerURL) == "" {
+			v.add(p+"ServerURL", "is required")
+		}
+		v.url(p+"ServerURL", h.ServerURL, "http", "https", "ws", "wss")
+		if h.Name != "" {
+			if names[h.Name] {
+				v.add(p+"Name", "duplicate host name %q", h.Name)
+			}
+			names[h.Name] = true
+		}
+	}
+	v.nonNegative("Collector.HeartbeatIntervalSec", col.HeartbeatIntervalSec)
+	v.nonNegative("Collector.PullIntervalSec", col.PullIntervalSec)
+	v.nonNegative("Collector.PullBatchSize", col.PullBatchSize)
+	v.nonNegative("Collector.ReconnectDelaySec", col.ReconnectDelaySec)
+	v.nonNegative("Collector.ReconnectMaxDelaySec", col.ReconnectMaxDelaySec)
+	v.nonNegative("Collector.DrainTimeoutSec", col.DrainTimeoutSec)
+	v.nonNegative("Collector.MaxConcurrentCalls", col.MaxConcurrentCalls)
+	v.nonNegative("Collector.QueueSize", col.QueueSize)
+	for i, r := range col.RateLimits {
+		p := fmt.Sprintf("Collector.RateLimits[%d].", i)
+		if strings.TrimSpace(r.Match) == "" && strings.TrimSpace(r.Domain) == "" {
+			v.add(p+"Match", "Match or Domain is required")
+		}
+		if _, err := path.Match(r.Match, ""); err != nil {
+			v.add(p+"Match", "invalid pattern %q", r.Match)
+		}
+		v.nonNegative(p+"MinIntervalMs", r.MinIntervalMs)
+		v.nonNegative(p+"JitterMs", r.JitterMs)
+		v.nonNegative(p+"MaxCalls", r.MaxCalls)
+		v.nonNegative(p+"WindowSec", r.WindowSec)
+		v.nonNegative(p+"DailyCap", r.DailyCap)
+		if r.MaxCalls > 0 && r.WindowSec <= 0 {
+			v.add(p+"WindowSec", "must be > 0 when MaxCalls is set")
+		}
+	}
+	v.nonNegative("Collector.OutboxMaxEntries", col.OutboxMaxEntries)
+	v.nonNegative("Collector.OutboxMaxAgeSec", col.OutboxMaxAgeSec)
+	v.nonNegative("Collector.RemoteConfig.MinIntervalMsFloor", col.RemoteConfig.MinIntervalMsFloor)
+	v.nonNegative("Collector.RemoteConfig.MaxAgeSec", col.RemoteConfig.MaxAgeSec)
+	for i, k := range col.RemoteConfig.TrustedKeys {
+		if strings.TrimSpace(k) == "" {
+			v.add(fmt.Sprintf("Collector.RemoteConfig.TrustedKeys[%d]", i), "must not be empty")
+		}
+	}
+
+	st := c.Status
+	v.url("Status.Webhook.URL", st.Webhook.URL, "http", "https")
+	v.url("Status.Webhook.url", st.Webhook.Config.URL, "http", "https")
+	v.nonNegative("Status.Webhook.timeout", st.Webhook.Config.Timeout)
+	v.nonNegative("Status.Webhook.retry_count", st.Webhook.Config.RetryCount)
+	v.nonNegative("Status.Webhook.retry_delay", st.Webhook.Config.RetryDelay)
+	v.enum("Status.Store.Type", st.Store.Type)
+	v.between("Status.Store.MySQL.Port", st.Store.MySQL.Port, 0, 65535)
+	v.between("Status.Event.SampleRate", st.Event.SampleRate, 0, 100)
+	v.nonNegative("Status.Event.MaxPayloadBytes", st.Event.MaxPayloadBytes)
+
+	fs := c.Feishu
+	v.enum("Feishu.ConnectionMode", fs.ConnectionMode)
+	v.enum("Feishu.API.ReceiveIDType", fs.API.ReceiveIDType)
+	v.url("Feishu.API.BaseURL", fs.API.BaseURL, "http", "https")
+	v.between("Feishu.EventIngest.WebhookPort", fs.EventIngest.WebhookPort, 0, 65535)
+	v.nonNegative("Feishu.API.Timeout", fs.API.Timeout)
+	v.nonNegative("Feishu.API.RetryCount", fs.API.RetryCount)
+
+	v.url("WeCom.Callback.URL", c.WeCom.Callback.URL, "http", "https")
+
+	t := c.Tools
+	v.enum("Tools.WebSearch.Engine", t.WebSearch.Engine)
+	v.enum("Tools.WebSearch.Tavily.SearchDepth", t.WebSearch.Tavily.SearchDepth)
+	v.enum("Tools.WebSearch.Tavily.Topic", t.WebSearch.Tavily.Topic)
+	lb := t.LocalBrowser
+	v.nonNegative("Tools.LocalBrowser.MaxConcurrency", lb.MaxConcurrency)
+	v.nonNegative("Tools.LocalBrowser.TotalTimeoutSec", lb.TotalTimeoutSec)
+	v.nonNegative("Tools.LocalBrowser.NavigateTimeoutSec", lb.NavigateTimeoutSec)
+	v.nonNegative("Tools.LocalBrowser.WaitSelectorTimeoutSec", lb.WaitSelectorTimeoutSec)
+	ocr := t.OCR
+	v.enum("Tools.OCR.DeepSeek.Mode", ocr.DeepSeek.Mode)
+	v.nonNegative("Tools.OCR.MaxImageBytes", ocr.MaxImageBytes)
+	v.nonNegative("Tools.OCR.URLDownloadTimeoutSec", ocr.URLDownloadTimeoutSec)
+	v.nonNegative("Tools.OCR.TimeoutSec", ocr.TimeoutSec)
+	v.nonNegative("Tools.OCR.RetryCount", ocr.RetryCount)
+	v.nonNegative("Tools.Embedding.Dimensions", t.Embedding.Dimensions)
+
+	l := c.Learning
+	if l.MinConfidence < 0 || l.MinConfidence > 1 {
+		v.add("Learning.MinConfidence", "must be between 0 and 1, got %g", l.MinConfidence)
+	}
+	v.between("Learning.ExecutionPhase", l.ExecutionPhase, 0, 2)
+	v.nonNegative("Learning.Retry.MaxAttempts", l.Retry.MaxAttempts)
+	v.nonNegative("Learning.Retry.BackoffMs", l.Retry.BackoffMs)
+	v.nonNegative("Secrets.CacheTTLSec", c.Secrets.CacheTTLSec)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Subscriber 在当前配置被替换后调用，old 可能为 nil（首次 Set）。
+type Subscriber func(old, new *Config)
+
+var (
+	// notifyMu 串行化 Set 与回调，保证订阅者按替换顺序看到每次更新。
+	notifyMu    sync.Mutex
+	subsMu      sync.Mutex
+	subscribers = map[uint64]Subscriber{}
+	nextSubID   uint64
+)
+
+// Subscribe 注册配置变更回调，返回取消函数。回调在 Set / Load / Watcher 重载后同步执行，
+// 按注册顺序、逐次串行；回调内不得调用 Set。
+func Subscribe(fn Subscriber) (cancel func()) {
+	subsMu.Lock()
+	nextSubID++
+	id := nextSubID
+	subscribers[id] = fn
+	subsMu.Unlock()
+	return func() {
+		subsMu.Lock()
+		delete(subscribers, id)
+		subsMu.Unlock()
+	}
+}
+
+func notify(old, cfg *Config) {
+	subsMu.Lock()
+	ids := make([]uint64, 0, len(subscribers))
+	for id := range subscribers {
+		ids = append(ids, id)
+	}
+	subsMu.Unlock()
+	slices.Sort(ids)
+	for _, id := range ids {
+		subsMu.Lock()
+		fn, ok := subscribers[id]
+		subsMu.Unlock()
+		if ok {
+			callSubscriber(fn, old, cfg)
+		}
+	}
+}
+
+func callSubscriber(fn Subscriber, old, cfg *Config) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[config] subscriber panic: %v", r)
+		}
+	}()
+	fn(old, cfg)
+}
+
+// ReloadStatus 是 Watcher 最近一次重载的结果。
+type ReloadStatus struct {
+	Files      []string  `json:"files"`
+	LastReload time.Time `json:"last_reload,omitempty"` // 最近一次成功应用新配置的时间
+	LastError  string    `json:"last_error,omitempty"`  // 最近一次重载失败原因；成功后清空
+	ErrorAt    time.Time `json:"error_at,omitempty"`
+}
+
+// Watcher 轮询配置文件（含 include 引用的文件），变更后用同一组 LoadOptions 重新加载：
+// 成功且内容有变化时 Set 新配置并通知订阅者；失败时保留上一份有效配置，记录错误并调用 OnError。
+type Watcher struct {
+	opts     LoadOptions
+	interval time.Duration
+
+	// OnReload 在新配置生效后调用。
+	OnReload func(*Config)
+	// OnError 在重载失败时调用；为 nil 时写日志。
+	OnError func(error)
+
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+	status ReloadStatus
+}
+
+type fileStamp struct {
+	modTime int64
+	size    int64
+	exists  bool
+}
+
+// NewWatcher 创建 Watcher；interval <= 0 时每 2 秒检查一次。
+func NewWatcher(opts LoadOptions, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	w := &Watcher{opts: opts, interval: interval}
+	_, files, _ := load(opts)
+	if len(files) == 0 {
+		files = opts.Files
+	}
+	w.track(files)
+	return w
+}
+
+// Run 轮询直到 ctx 结束。
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.changed() {
+				_ = w.Reload()
+			}
+		}
+	}
+}
+
+// Reload 立即重新加载（例如收到 SIGHUP）；返回加载或校验错误，此时当前配置不变。
+func (w *Watcher) Reload() error {
+	cfg, files, err := load(w.opts)
+	if len(files) == 0 {
+		files = w.opts.Files
+	}
+	w.track(files)
+	if err != nil {
+		w.mu.Lock()
+		w.status.LastError = err.Error()
+		w.status.ErrorAt = time.Now()
+		w.mu.Unlock()
+		if w.OnError != nil {
+			w.OnError(err)
+		} else {
+			log.Printf("[config] reload failed, keeping previous config: %v", err)
+		}
+		return err
+	}
+	w.mu.Lock()
+	w.status.LastError = ""
+	w.status.ErrorAt = time.Time{}
+	w.mu.Unlock()
+	if reflect.DeepEqual(cfg, Get()) {
+		return nil
+	}
+	Set(cfg)
+	w.mu.Lock()
+	w.status.LastReload = time.Now()
+	w.mu.Unlock()
+	if w.OnReload != nil {
+		w.OnReload(cfg)
+	}
+	return nil
+}
+
+// Status 返回监视的文件与最近一次重载结果。
+func (w *Watcher) Status() ReloadStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := w.status
+	st.Files = append([]string(nil), st.Files...)
+	return st
+}
+
+func (w *Watcher) track(files []string) {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, f := range files {
+		stamps[f] = stat(f)
+	}
+	w.mu.Lock()
+	w.stamps = stamps
+	w.status.Files = files
+	w.mu.Unlock()
+}
+
+func (w *Watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for f, s := range w.stamps {
+		if stat(f) != s {
+			return true
+		}
+	}
+	return false
+}
+
+func stat(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size(), exists: true}
+}
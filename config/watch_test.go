@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherReloadKeepsLastGoodConfig(t *testing.T) {
+	orig := Get()
+	defer Set(orig)
+
+	dir := t.TempDir()
+	writeFile(t, dir, "base.yaml", "Gateway:\n  InstanceID: one\n")
+	main := writeFile(t, dir, "main.yaml", "include: base.yaml\n")
+	cfg, err := Load(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	cancel := Subscribe(func(old, next *Config) {
+		seen = append(seen, old.Gateway.InstanceID+"->"+next.Gateway.InstanceID)
+	})
+	defer cancel()
+
+	w := NewWatcher(LoadOptions{Files: []string{main}}, time.Hour)
+	var reloadErr error
+	w.OnError = func(err error) { reloadErr = err }
+	if files := w.Status().Files; len(files) != 2 {
+		t.Fatalf("included file not watched: %v", files)
+	}
+
+	// 修改被 include 的文件：检测到变化并通知订阅者。
+	bumpFile(t, filepath.Join(dir, "base.yaml"), "Gateway:\n  InstanceID: two\n")
+	if !w.changed() {
+		t.Fatal("change in included file not detected")
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if Get().Gateway.InstanceID != "two" || len(seen) != 1 || seen[0] != "one->two" {
+		t.Fatalf("current=%q seen=%v", Get().Gateway.InstanceID, seen)
+	}
+	if cfg.Gateway.InstanceID != "one" {
+		t.Fatal("previously returned config must not be mutated")
+	}
+
+	// 无效配置：保留上一份有效配置并报告错误。
+	bumpFile(t, filepath.Join(dir, "base.yaml"), "Gateway:\n  ListenAddr: bogus\n")
+	if err := w.Reload(); err == nil || reloadErr == nil {
+		t.Fatalf("expected reload error, got %v / %v", err, reloadErr)
+	}
+	if Get().Gateway.InstanceID != "two" || len(seen) != 1 {
+		t.Fatalf("failed reload replaced config: current=%q seen=%v", Get().Gateway.InstanceID, seen)
+	}
+	if st := w.Status(); st.LastError == "" {
+		t.Fatal("LastError not recorded")
+	}
+
+	// 内容未变时不通知。
+	bumpFile(t, filepath.Join(dir, "base.yaml"), "Gateway:\n  InstanceID: two\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || w.Status().LastError != "" {
+		t.Fatalf("unexpected notification or stale error: %v %+v", seen, w.Status())
+	}
+}
+
+// bumpFile rewrites path and moves its mtime forward so the change is visible on coarse clocks.
+func bumpFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
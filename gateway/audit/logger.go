@@ -30,7 +30,7 @@ func (l *Logger) LogCall(call *protocol.ToolCall, result *protocol.ToolResult) {
 		}
 	}
 	l.logger.Printf(
-		"[gateway-audit] tool=%s call_id=%s trace_id=%s host=%s status=%s err_code=%s duration_ms=%d",
+		"[gateway-audit] tool=%s call_id=%s trace_id=%s host=%s status=%s err_code=%s duration_ms=%d attempts=%d cache_hit=%t",
 		sanitize(call.Tool),
 		sanitize(call.ID),
 		sanitize(call.Context.TraceID),
@@ -38,6 +38,8 @@ func (l *Logger) LogCall(call *protocol.ToolCall, result *protocol.ToolResult) {
 		status,
 		errCode,
 		resultUsageMs(result),
+		resultAttempts(result),
+		result != nil && result.CacheHit,
 	)
 }
 
@@ -48,6 +50,13 @@ func resultUsageMs(r *protocol.ToolResult) int64 {
 	return r.Usage.DurationMs
 }
 
+func resultAttempts(r *protocol.ToolResult) int {
+	if r == nil {
+		return 0
+	}
+	return r.Usage.Attempts
+}
+
 func sanitize(s string) string {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -1,13 +1,17 @@
 package gateway
 
 import (
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/originaleric/digeino/config"
 	"github.com/originaleric/digeino/gateway/artifact"
+	"github.com/originaleric/digeino/gateway/cache"
 	"github.com/originaleric/digeino/gateway/executor"
 	"github.com/originaleric/digeino/gateway/registry"
 	"github.com/originaleric/digeino/gateway/runtime"
+	"github.com/originaleric/digeino/gateway/usage"
 )
 
 // RegistryOptions configures tool registration.
@@ -37,6 +41,9 @@ func NewRegistry(cfg *config.Config, opts RegistryOptions) *registry.Registry {
 	reg.Register(executor.XiaohongshuNoteReadEntry(domains, store))
 	reg.Register(executor.DouyinVideoReadEntry(domains, store))
 	reg.Register(executor.XPostReadEntry(domains, store))
+	if cfg.Tools.OCR.Enabled != nil && *cfg.Tools.OCR.Enabled {
+		reg.Register(executor.ImageOCREntry())
+	}
 	if len(readPaths) > 0 {
 		reg.Register(executor.FileReadEntry(readPaths))
 	}
@@ -56,6 +63,56 @@ func NewArtifactStore(cfg *config.Config) (artifact.Store, error) {
 	return artifact.NewDiskStore(dir, ttl)
 }
 
+// NewResultCache creates the result cache from Gateway.ResultCache; nil when disabled.
+func NewResultCache(cfg *config.Config) (cache.Store, error) {
+	rc := cfg.Gateway.ResultCache
+	switch rc.Backend {
+	case "":
+		return nil, nil
+	case "memory":
+		return cache.NewMemoryStore(rc.MaxEntries), nil
+	case "disk":
+		dir := rc.Dir
+		if dir == "" {
+			dir = "storage/app/gateway_cache"
+		}
+		s, err := cache.NewDiskStore(dir, rc.MaxEntries)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown Gateway.ResultCache.Backend %q", rc.Backend)
+	}
+}
+
+// newResultCacheOrLog is NewResultCache for runtime constructors: a broken cache setting disables
+// caching with a log line instead of failing startup silently.
+func newResultCacheOrLog(cfg *config.Config) cache.Store {
+	s, err := NewResultCache(cfg)
+	if err != nil {
+		log.Printf("[gateway] result cache disabled: %v", err)
+	}
+	return s
+}
+
+// ResultCacheTTL returns the default result cache TTL.
+func ResultCacheTTL(cfg *config.Config) time.Duration {
+	if sec := cfg.Gateway.ResultCache.DefaultTTLSec; sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	return 10 * time.Minute
+}
+
+// NewUsageAggregator creates the usage aggregator from Gateway.Usage; nil when disabled.
+func NewUsageAggregator(cfg *config.Config) *usage.Aggregator {
+	u := cfg.Gateway.Usage
+	if u.Enabled != nil && !*u.Enabled {
+		return nil
+	}
+	return usage.NewAggregator(time.Duration(u.RetentionDays) * 24 * time.Hour)
+}
+
 // NewRuntime creates a runtime with registry and gateway options from config.
 func NewRuntime(cfg *config.Config) *runtime.Runtime {
 	gw := cfg.Gateway
@@ -70,12 +127,12 @@ func NewRuntime(cfg *config.Config) *runtime.Runtime {
 		}
 	}
 	reg := NewRegistry(cfg, RegistryOptions{ArtifactStore: store})
-	return runtime.New(reg, runtime.Options{
-		InstanceID:    instanceID,
-		AllowedTools:  gw.AllowedTools,
-		ConfigDomains: cfg.Tools.LocalBrowser.AllowedDomains,
-		ArtifactStore: store,
-	})
+	opts := reloadableOptions(cfg, gw.AllowedTools)
+	opts.InstanceID = instanceID
+	opts.ArtifactStore = store
+	opts.Cache = newResultCacheOrLog(cfg)
+	opts.Usage = NewUsageAggregator(cfg)
+	return runtime.New(reg, opts)
 }
 
 // NewCollectorRuntime creates a runtime for the local Collector process.
@@ -88,19 +145,74 @@ func NewCollectorRuntime(cfg *config.Config) *runtime.Runtime {
 	if instanceID == "" {
 		instanceID = "digeino-collector"
 	}
-	allowed := col.AllowedTools
-	if len(allowed) == 0 {
-		allowed = cfg.Gateway.AllowedTools
-	}
 	var store artifact.Store
 	if s, err := NewArtifactStore(cfg); err == nil {
 		store = s
 	}
 	reg := NewRegistry(cfg, RegistryOptions{ArtifactStore: store})
-	return runtime.New(reg, runtime.Options{
-		InstanceID:    instanceID,
+	opts := reloadableOptions(cfg, collectorAllowedTools(cfg))
+	opts.InstanceID = instanceID
+	opts.ArtifactStore = store
+	opts.Cache = newResultCacheOrLog(cfg)
+	opts.Usage = NewUsageAggregator(cfg)
+	return runtime.New(reg, opts)
+}
+
+func collectorAllowedTools(cfg *config.Config) []string {
+	if len(cfg.Collector.AllowedTools) > 0 {
+		return cfg.Collector.AllowedTools
+	}
+	return cfg.Gateway.AllowedTools
+}
+
+// reloadableOptions are the runtime options that ApplyConfig can change without a restart.
+func reloadableOptions(cfg *config.Config, allowed []string) runtime.Options {
+	return runtime.Options{
 		AllowedTools:  allowed,
 		ConfigDomains: cfg.Tools.LocalBrowser.AllowedDomains,
-		ArtifactStore: store,
-	})
+		ToolPolicies:  ToolPolicies(cfg),
+		CacheTTL:      ResultCacheTTL(cfg),
+	}
+}
+
+// ApplyConfig hot-reloads a runtime built by NewRuntime: the registry (domains, read paths,
+// OCR on/off), Gateway.AllowedTools, Gateway.ToolLimits and the cache TTL. Listen address,
+// artifact store, cache backend and usage settings still need a restart.
+func ApplyConfig(rt *runtime.Runtime, cfg *config.Config) {
+	reg := NewRegistry(cfg, RegistryOptions{ArtifactStore: rt.ArtifactStore()})
+	rt.Reload(reg, reloadableOptions(cfg, cfg.Gateway.AllowedTools))
+}
+
+// ApplyCollectorConfig is ApplyConfig for a runtime built by NewCollectorRuntime.
+func ApplyCollectorConfig(rt *runtime.Runtime, cfg *config.Config) {
+	reg := NewRegistry(cfg, RegistryOptions{ArtifactStore: rt.ArtifactStore()})
+	rt.Reload(reg, reloadableOptions(cfg, collectorAllowedTools(cfg)))
+}
+
+// ToolPolicies converts Gateway.ToolLimits into runtime tool policies.
+func ToolPolicies(cfg *config.Config) map[string]runtime.ToolPolicy {
+	if len(cfg.Gateway.ToolLimits) == 0 {
+		return nil
+	}
+	out := make(map[string]runtime.ToolPolicy, len(cfg.Gateway.ToolLimits))
+	for name, l := range cfg.Gateway.ToolLimits {
+		out[name] = runtime.ToolPolicy{
+			DefaultTimeout: time.Duration(l.DefaultTimeoutSec) * time.Second,
+			MaxTimeout:     time.Duration(l.MaxTimeoutSec) * time.Second,
+			MaxConcurrent:  l.MaxConcurrent,
+			MaxQueue:       l.MaxQueue,
+			Breaker: runtime.BreakerPolicy{
+				FailureThreshold: l.BreakerFailureThreshold,
+				OpenDuration:     time.Duration(l.BreakerOpenSec) * time.Second,
+			},
+			Retry: runtime.RetryPolicy{
+				MaxAttempts:    l.RetryMaxAttempts,
+				InitialBackoff: time.Duration(l.RetryBackoffMs) * time.Millisecond,
+				MaxBackoff:     time.Duration(l.RetryMaxBackoffMs) * time.Millisecond,
+				AttemptTimeout: time.Duration(l.AttemptTimeoutSec) * time.Second,
+			},
+			CacheTTL: time.Duration(l.CacheTTLSec) * time.Second,
+		}
+	}
+	return out
 }
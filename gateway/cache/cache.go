@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// Entry is a cached successful tool output.
+type Entry struct {
+	Tool      string          `json:"tool"`
+	Output    json.RawMessage `json:"output"`
+	StoredAt  time.Time       `json:"stored_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// Store persists tool results for read-only tools.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, bool, error)
+	Set(ctx context.Context, key string, e Entry) error
+}
+
+// Key hashes tenant, tool, the effective domain allow-list and the normalized JSON input.
+// Normalization re-encodes the input so key order and whitespace do not matter. Including
+// domains keeps a result cached under a wider allow-list from being served to a call whose
+// allow-list forbids the URL; such a call misses and the handler rejects it.
+func Key(call *protocol.ToolCall, domains []string) string {
+	h := sha256.New()
+	h.Write([]byte(call.Context.TenantID))
+	h.Write([]byte{0})
+	h.Write([]byte(call.Tool))
+	h.Write([]byte{0})
+	h.Write([]byte(normalizeDomains(domains)))
+	h.Write([]byte{0})
+	h.Write(normalizeInput(call.Input))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func normalizeDomains(domains []string) string {
+	out := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			out = append(out, d)
+		}
+	}
+	slices.Sort(out)
+	return strings.Join(slices.Compact(out), ",")
+}
+
+func normalizeInput(raw json.RawMessage) []byte {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+func TestKeyNormalizesInput(t *testing.T) {
+	t.Parallel()
+	a := &protocol.ToolCall{Tool: "x.post.read", Input: json.RawMessage(`{"url":"https://x.com/a/status/1","format":["text"]}`)}
+	b := &protocol.ToolCall{Tool: "x.post.read", Input: json.RawMessage(`{ "format": ["text"],
+		"url": "https://x.com/a/status/1" }`)}
+	if Key(a, nil) != Key(b, nil) {
+		t.Fatal("expected equal keys for equivalent inputs")
+	}
+	if Key(a, []string{"x.com", "Example.com"}) != Key(b, []string{" example.com", "x.com"}) {
+		t.Fatal("expected domain order and case not to matter")
+	}
+	if Key(a, nil) == Key(b, []string{"example.com"}) {
+		t.Fatal("expected the domain allow-list to be part of the key")
+	}
+	b.Context.TenantID = "team_b"
+	if Key(a, nil) == Key(b, nil) {
+		t.Fatal("expected tenant to be part of the key")
+	}
+}
+
+func TestMemoryStoreExpiryAndEviction(t *testing.T) {
+	t.Parallel()
+	s := NewMemoryStore(2)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	entry := Entry{Output: json.RawMessage(`{}`), ExpiresAt: now.Add(time.Minute)}
+
+	_ = s.Set(ctx, "a", entry)
+	_ = s.Set(ctx, "b", entry)
+	_ = s.Set(ctx, "c", entry)
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Fatal("expected oldest entry to be evicted")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "c"); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+}
+
+func TestDiskStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+	s, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	in := Entry{Tool: "x.post.read", Output: json.RawMessage(`{"results":[]}`), ExpiresAt: time.Now().Add(time.Minute)}
+	if err := s.Set(ctx, "k1", in); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := s.Get(ctx, "k1")
+	if err != nil || !ok || string(got.Output) != string(in.Output) {
+		t.Fatalf("unexpected entry: %+v ok=%v err=%v", got, ok, err)
+	}
+}
+
+func TestDiskStoreBoundsEntries(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	s, err := NewDiskStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	short := Entry{Output: json.RawMessage(`{}`), ExpiresAt: now.Add(time.Minute)}
+	long := Entry{Output: json.RawMessage(`{}`), ExpiresAt: now.Add(time.Hour)}
+
+	_ = s.Set(ctx, "a", short)
+	now = now.Add(time.Second)
+	_ = s.Set(ctx, "b", long)
+	now = now.Add(time.Second)
+	_ = s.Set(ctx, "c", long)
+	if _, ok, _ := s.Get(ctx, "a"); ok || s.Len() != 2 {
+		t.Fatalf("expected oldest entry to be evicted, %d stored", s.Len())
+	}
+	// 过期条目在写入时清理，无需再被读取
+	now = now.Add(2 * time.Minute)
+	_ = s.Set(ctx, "d", short)
+	now = now.Add(2 * time.Minute)
+	_ = s.Set(ctx, "e", long)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("files on disk = %v", files)
+	}
+
+	reopened, err := NewDiskStore(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := reopened.Get(ctx, "e"); !ok || reopened.Len() != 1 {
+		t.Fatalf("reopened store kept %d entries", reopened.Len())
+	}
+}
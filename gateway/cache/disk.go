@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskStore keeps one JSON file per key under BaseDir. Expired files are removed on read and
+// whenever an entry is written; beyond MaxEntries the oldest stored entries are removed first.
+type DiskStore struct {
+	BaseDir    string
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]diskItem
+	now     func() time.Time
+}
+
+type diskItem struct {
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+// NewDiskStore creates baseDir if needed and indexes the entries already stored there; expired or
+// unreadable files are removed. maxEntries <= 0 defaults to 1000.
+func NewDiskStore(baseDir string, maxEntries int) (*DiskStore, error) {
+	if strings.TrimSpace(baseDir) == "" {
+		return nil, fmt.Errorf("cache base dir is required")
+	}
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	abs, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, err
+	}
+	s := &DiskStore{BaseDir: abs, MaxEntries: maxEntries, entries: map[string]diskItem{}, now: time.Now}
+	files, err := filepath.Glob(filepath.Join(abs, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		e, err := readDiskEntry(f)
+		if err != nil {
+			_ = os.Remove(f)
+			continue
+		}
+		s.entries[strings.TrimSuffix(filepath.Base(f), ".json")] = diskItem{storedAt: e.StoredAt, expiresAt: e.ExpiresAt}
+	}
+	s.mu.Lock()
+	s.pruneLocked()
+	s.mu.Unlock()
+	return s, nil
+}
+
+func (s *DiskStore) Get(_ context.Context, key string) (Entry, bool, error) {
+	key = filepath.Base(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			delete(s.entries, key)
+			return Entry{}, false, nil
+		}
+		return Entry{}, false, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		s.removeLocked(key)
+		return Entry{}, false, nil
+	}
+	if !s.now().Before(e.ExpiresAt) {
+		s.removeLocked(key)
+		return Entry{}, false, nil
+	}
+	return e, true, nil
+}
+
+func (s *DiskStore) Set(_ context.Context, key string, e Entry) error {
+	key = filepath.Base(key)
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := s.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		return err
+	}
+	s.entries[key] = diskItem{storedAt: s.now(), expiresAt: e.ExpiresAt}
+	s.pruneLocked()
+	return nil
+}
+
+// Len returns the number of stored entries, expired ones not yet removed included.
+func (s *DiskStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// pruneLocked removes expired entries, then the oldest ones beyond MaxEntries.
+func (s *DiskStore) pruneLocked() {
+	now := s.now()
+	keys := make([]string, 0, len(s.entries))
+	for key, it := range s.entries {
+		if !now.Before(it.expiresAt) {
+			s.removeLocked(key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) <= s.MaxEntries {
+		return
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(s.entries[a].storedAt.Compare(s.entries[b].storedAt), cmp.Compare(a, b))
+	})
+	for _, key := range keys[:len(keys)-s.MaxEntries] {
+		s.removeLocked(key)
+	}
+}
+
+func (s *DiskStore) removeLocked(key string) {
+	delete(s.entries, key)
+	_ = os.Remove(s.path(key))
+}
+
+func (s *DiskStore) path(key string) string {
+	return filepath.Join(s.BaseDir, key+".json")
+}
+
+func readDiskEntry(path string) (Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process LRU cache with per-entry expiry.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryItem struct {
+	key   string
+	entry Entry
+}
+
+// NewMemoryStore creates a memory cache; maxEntries <= 0 defaults to 1000.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	item := el.Value.(*memoryItem)
+	if !s.now().Before(item.entry.ExpiresAt) {
+		s.order.Remove(el)
+		delete(s.items, key)
+		return Entry{}, false, nil
+	}
+	s.order.MoveToFront(el)
+	return item.entry, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		el.Value.(*memoryItem).entry = e
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.items[key] = s.order.PushFront(&memoryItem{key: key, entry: e})
+	for s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
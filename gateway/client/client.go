@@ -64,10 +64,14 @@ func (c *Client) Call(ctx context.Context, call protocol.ToolCall) (protocol.Too
 	if err != nil {
 		return protocol.ToolResult{}, err
 	}
+	var result protocol.ToolResult
 	if resp.StatusCode >= 400 {
+		// 工具级错误仍返回 ToolResult 正文，便于调用方读取 error.code / retryable。
+		if json.Unmarshal(data, &result) == nil && result.Type == protocol.TypeToolResult && result.Error != nil {
+			return result, result.Error
+		}
 		return protocol.ToolResult{}, fmt.Errorf("gateway http %d: %s", resp.StatusCode, string(data))
 	}
-	var result protocol.ToolResult
 	if err := json.Unmarshal(data, &result); err != nil {
 		return protocol.ToolResult{}, err
 	}
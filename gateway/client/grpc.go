@@ -0,0 +1,147 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/protocol/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCClient calls a remote DigEino gRPC Tool Gateway (digeino grpc).
+type GRPCClient struct {
+	conn  *grpc.ClientConn
+	rpc   pb.ToolGatewayClient
+	Token string
+}
+
+// Progress is a CallStream progress event.
+type Progress struct {
+	CallID    string
+	Stage     string // queued | running | retrying
+	Attempt   int
+	Message   string
+	ElapsedMs int64
+}
+
+// NewGRPC dials target (host:port). Without dial options the connection is plaintext;
+// pass grpc.WithTransportCredentials for TLS.
+func NewGRPC(target, token string, opts ...grpc.DialOption) (*GRPCClient, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(strings.TrimSpace(target), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{conn: conn, rpc: pb.NewToolGatewayClient(conn), Token: strings.TrimSpace(token)}, nil
+}
+
+// Close releases the underlying connection.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// Manifest fetches the tool manifest.
+func (c *GRPCClient) Manifest(ctx context.Context) (protocol.ToolManifest, error) {
+	m, err := c.rpc.GetManifest(c.withAuth(ctx), &pb.GetManifestRequest{})
+	if err != nil {
+		return protocol.ToolManifest{}, err
+	}
+	return *protocol.ManifestFromPB(m), nil
+}
+
+// Call executes a tool. Like Client.Call, tool-level failures return the result together with result.Error.
+func (c *GRPCClient) Call(ctx context.Context, call protocol.ToolCall) (protocol.ToolResult, error) {
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
+	}
+	res, err := c.rpc.Call(c.withAuth(ctx), protocol.ToolCallToPB(&call))
+	if err != nil {
+		return protocol.ToolResult{}, err
+	}
+	return toolResult(res)
+}
+
+// CallStream executes a tool and invokes onProgress (may be nil) for each progress event.
+func (c *GRPCClient) CallStream(ctx context.Context, call protocol.ToolCall, onProgress func(Progress)) (protocol.ToolResult, error) {
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
+	}
+	stream, err := c.rpc.CallStream(c.withAuth(ctx), protocol.ToolCallToPB(&call))
+	if err != nil {
+		return protocol.ToolResult{}, err
+	}
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return protocol.ToolResult{}, errors.New("call stream ended without result")
+		}
+		if err != nil {
+			return protocol.ToolResult{}, err
+		}
+		if p := ev.GetProgress(); p != nil {
+			if onProgress != nil {
+				onProgress(Progress{
+					CallID:    p.GetCallId(),
+					Stage:     p.GetStage(),
+					Attempt:   int(p.GetAttempt()),
+					Message:   p.GetMessage(),
+					ElapsedMs: p.GetElapsedMs(),
+				})
+			}
+			continue
+		}
+		if res := ev.GetResult(); res != nil {
+			return toolResult(res)
+		}
+	}
+}
+
+// FetchArtifact downloads an artifact by id or digeino-artifact:// URI.
+func (c *GRPCClient) FetchArtifact(ctx context.Context, id string) ([]byte, string, error) {
+	id = strings.TrimPrefix(id, "digeino-artifact://")
+	stream, err := c.rpc.GetArtifact(c.withAuth(ctx), &pb.GetArtifactRequest{Id: id})
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	contentType := ""
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return buf.Bytes(), contentType, nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if chunk.GetContentType() != "" {
+			contentType = chunk.GetContentType()
+		}
+		buf.Write(chunk.GetData())
+	}
+}
+
+func (c *GRPCClient) withAuth(ctx context.Context) context.Context {
+	if c.Token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.Token)
+}
+
+func toolResult(res *pb.ToolResult) (protocol.ToolResult, error) {
+	r, err := protocol.ToolResultFromPB(res)
+	if err != nil {
+		return protocol.ToolResult{}, err
+	}
+	if r.Error != nil {
+		return *r, r.Error
+	}
+	return *r, nil
+}
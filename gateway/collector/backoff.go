@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// backoff returns the delay before reconnect attempt n (0-based): ReconnectDelay doubled per failed
+// attempt, capped at ReconnectMaxDelay, with "equal jitter" (a random value in [d/2, d]) so a fleet
+// of collectors does not reconnect in lockstep after a host restart.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = 5 * time.Second
+	}
+	if max < base {
+		max = base
+	}
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
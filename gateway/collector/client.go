@@ -2,13 +2,17 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"path/filepath"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/originaleric/digeino/gateway/gwversion"
+	"github.com/originaleric/digeino/gateway/policy"
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/runtime"
 )
@@ -17,285 +21,393 @@ import (
 // gorilla/websocket does not permit concurrent Conn.WriteMessage.
 type envelopeWriter func(env protocol.Envelope) error
 
-// Client maintains a reverse WebSocket connection to a host and executes tool calls.
+// Client maintains reverse WebSocket connections to one or more hosts and executes tool calls.
+// With ModeFailover it keeps one session over the ordered Hosts; with ModeAll it keeps one session
+// per host. All sessions share the runtime, queue, concurrency budget and rate limits.
 type Client struct {
-	opts    Options
-	rt      *runtime.Runtime
-	limiter *RateLimiter
-	log     *log.Logger
+	opts     Options
+	rt       *runtime.Runtime
+	limiter  *RateLimiter
+	log      *log.Logger
+	sessions []*session
 
 	activeCalls atomic.Int32
+	// calls outlive the session that delivered them: a result finished after a disconnect goes to
+	// the outbox and is resent after the next handshake.
+	calls  sync.WaitGroup
+	sem    chan struct{}
+	queue  *callQueue
+	labels *labelSet
+
+	mu       sync.Mutex
+	inflight map[callKey]context.CancelFunc // for cancel_call
+	draining bool
+
+	// OnEvent, when set before Run, receives connection lifecycle events. It is called synchronously
+	// from the connection goroutines and must not block.
+	OnEvent func(Event)
 }
 
-func NewClient(opts Options, rt *runtime.Runtime) *Client {
-	return &Client{
-		opts:    opts,
-		rt:      rt,
-		limiter: NewRateLimiter(time.Second),
-		log:     log.Default(),
-	}
+// callKey identifies a call: IDs are only unique per host.
+type callKey struct {
+	s  *session
+	id string
 }
 
-// Run connects to the host and blocks until ctx is cancelled.
-func (c *Client) Run(ctx context.Context) error {
-	if c.opts.ServerURL == "" {
-		return errEmptyServerURL
+// defaultQueueSize is the local queue depth when Options.QueueSize is unset.
+const defaultQueueSize = 100
+
+// drainCancelWait bounds the wait for calls to return after the drain grace period cancelled them.
+const drainCancelWait = 5 * time.Second
+
+func NewClient(opts Options, rt *runtime.Runtime) *Client {
+	maxConc := opts.MaxConcurrentCalls
+	if maxConc <= 0 {
+		maxConc = 1
 	}
-	for {
-		if err := c.connectOnce(ctx); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	c := &Client{
+		opts:     opts,
+		rt:       rt,
+		limiter:  NewRateLimiter(time.Second, opts.RateLimits...),
+		log:      log.Default(),
+		sem:      make(chan struct{}, maxConc),
+		queue:    newCallQueue(queueSize),
+		labels:   &labelSet{static: staticLabels(opts), cookieDir: opts.CookieStoreDir},
+		inflight: make(map[callKey]context.CancelFunc),
+	}
+	eps := opts.Endpoints()
+	switch {
+	case len(eps) == 0:
+	case opts.Mode == ModeAll && len(eps) > 1:
+		for _, ep := range eps {
+			dir := ""
+			if opts.OutboxDir != "" {
+				dir = filepath.Join(opts.OutboxDir, pathSafe(ep.Name))
 			}
-			c.log.Printf("[collector] session ended: %v; reconnect in %s", err, c.opts.ReconnectDelay)
-		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(c.opts.ReconnectDelay):
+			remote := newRemoteConfig(opts.RemoteConfig, opts.InstanceID, hostStateFile(opts.RemoteConfig.StateFile, ep.Name))
+			c.sessions = append(c.sessions, &session{c: c, name: ep.Name, endpoints: []Endpoint{ep}, outboxDir: dir, remote: remote})
 		}
+	default:
+		remote := newRemoteConfig(opts.RemoteConfig, opts.InstanceID, opts.RemoteConfig.StateFile)
+		c.sessions = []*session{{c: c, name: eps[0].Name, endpoints: eps, outboxDir: opts.OutboxDir, remote: remote}}
 	}
+	return c
 }
 
-func (c *Client) connectOnce(ctx context.Context) error {
-	wsURL, hdr, err := buildWSURL(c.opts.ServerURL, c.opts.WSPath, c.opts.Token)
-	if err != nil {
-		return err
-	}
-	dialer := websocket.Dialer{}
-	conn, _, err := dialer.DialContext(ctx, wsURL, hdr)
-	if err != nil {
-		return err
+// Outbox returns the first session's result outbox, or nil when Options.OutboxDir is empty or Run
+// has not started.
+func (c *Client) Outbox() *Outbox {
+	if len(c.sessions) == 0 {
+		return nil
 	}
-	defer conn.Close()
-
-	c.log.Printf("[collector] connected to %s instance=%s", wsURL, c.opts.InstanceID)
+	return c.sessions[0].outbox
+}
 
-	sessionCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// Features returns the capabilities negotiated in the first session's current (or last) connection.
+func (c *Client) Features() []string {
+	if len(c.sessions) == 0 {
+		return nil
+	}
+	return c.sessions[0].Features()
+}
 
-	var writeMu sync.Mutex
-	writeEnv := envelopeWriter(func(env protocol.Envelope) error {
-		data, encErr := env.Encode()
-		if encErr != nil {
-			return encErr
+// Run connects to the hosts and blocks until ctx is cancelled. Failed sessions are retried with
+// exponential backoff and jitter. Cancelling ctx drains the collector: it reports "draining", stops
+// pulling and accepting calls, waits up to DrainTimeout for in-flight calls, cancels the rest and
+// then closes the connections.
+func (c *Client) Run(ctx context.Context) error {
+	if len(c.sessions) == 0 {
+		return errEmptyServerURL
+	}
+	for _, s := range c.sessions {
+		if s.outbox != nil || s.outboxDir == "" {
+			continue
+		}
+		ob, err := OpenOutbox(s.outboxDir, c.opts.OutboxMaxEntries, c.opts.OutboxMaxAge)
+		if err != nil {
+			return fmt.Errorf("collector outbox: %w", err)
+		}
+		s.outbox = ob
+		if n := ob.Len(); n > 0 {
+			c.log.Printf("[collector] outbox: %d unacknowledged results will be resent to %s", n, s.name)
 		}
-		writeMu.Lock()
-		defer writeMu.Unlock()
-		return conn.WriteMessage(websocket.TextMessage, data)
-	})
-
-	writeRaw := func(msgType int, payload []byte) error {
-		writeMu.Lock()
-		defer writeMu.Unlock()
-		return conn.WriteMessage(msgType, payload)
 	}
-
-	if err := c.handshake(sessionCtx, conn, writeEnv); err != nil {
-		return err
+	if c.opts.RateLimitStateFile != "" {
+		if err := c.limiter.Persist(c.opts.RateLimitStateFile); err != nil {
+			return fmt.Errorf("collector rate limit state: %w", err)
+		}
 	}
+	for _, s := range c.sessions {
+		if err := s.remote.load(); err != nil {
+			c.log.Printf("[collector] remote config: ignoring %s: %v", s.remote.file, err)
+		} else if v := s.remote.Version(); v > 0 {
+			c.limiter.SetRemoteRules(s.name, s.remote.rateLimits())
+			c.log.Printf("[collector] remote config: version %d restored for %s", v, s.name)
+		}
+	}
+	// calls are cancelled by drain rather than by ctx, so they get the grace period
+	callCtx, cancelCalls := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelCalls()
+	go c.dispatchLoop(callCtx)
+	defer func() {
+		c.drain(nil, nil, cancelCalls) // no-op when a session already drained
+		c.emit(Event{Type: EventStopped, ActiveCalls: int(c.activeCalls.Load())})
+	}()
 
-	sem := make(chan struct{}, c.opts.MaxConcurrentCalls)
 	var wg sync.WaitGroup
-	defer wg.Wait()
-
-	errCh := make(chan error, 1)
-	go func() {
-		errCh <- c.readLoop(sessionCtx, conn, writeEnv, sem, &wg)
-	}()
+	for _, s := range c.sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.run(ctx, cancelCalls)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
 
-	if c.opts.HeartbeatInterval > 0 {
-		go c.heartbeatLoop(sessionCtx, writeEnv)
+// drain stops accepting calls, waits up to DrainTimeout for in-flight ones and cancels the rest.
+// A non-nil writeEnv first reports the draining status of s to its host. Every session drains on
+// shutdown; they share one grace period.
+func (c *Client) drain(writeEnv envelopeWriter, s *session, cancelCalls context.CancelFunc) {
+	c.mu.Lock()
+	first := !c.draining
+	c.draining = true
+	c.mu.Unlock()
+	active := int(c.activeCalls.Load())
+	if first {
+		c.log.Printf("[collector] draining: %d in-flight calls, grace %s", active, c.opts.DrainTimeout)
+		c.emit(Event{Type: EventDraining, ActiveCalls: active})
 	}
-	if c.opts.PullInterval > 0 {
-		go c.pullLoop(sessionCtx, writeEnv)
+	for _, qc := range c.queue.takeAll() {
+		qc.sess.deliver(errorResult(qc.call.ID, protocol.CodeRateLimited, "collector is draining; retry on another instance"))
+	}
+	if writeEnv != nil {
+		_ = writeEnv(c.instanceStatus(s, "draining"))
 	}
 
+	done := make(chan struct{})
+	go func() {
+		c.calls.Wait()
+		close(done)
+	}()
 	select {
-	case <-ctx.Done():
-		cancel()
-		_ = writeRaw(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-		return ctx.Err()
-	case err := <-errCh:
-		cancel()
-		return err
+	case <-done:
+		return
+	case <-time.After(c.opts.DrainTimeout):
+	}
+	c.log.Printf("[collector] drain grace period over: canceling %d in-flight calls", c.activeCalls.Load())
+	cancelCalls()
+	select {
+	case <-done:
+	case <-time.After(drainCancelWait):
+		c.log.Printf("[collector] %d calls ignored cancellation; exiting without their results", c.activeCalls.Load())
 	}
 }
 
-func (c *Client) handshake(_ context.Context, conn *websocket.Conn, writeEnv envelopeWriter) error {
-	hello := protocol.NewCollectorHello(c.opts.InstanceID, gwversion.RuntimeName, gwversion.RuntimeVersion)
-	if err := writeEnv(hello); err != nil {
-		return err
+// Draining reports whether shutdown has started.
+func (c *Client) Draining() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.draining
+}
+
+// scheduleCall queues a call from s for dispatchLoop. Calls are rejected only while draining, when
+// the host may not call the tool, when the local queue is full or when their rate-limit key reached
+// its daily cap.
+func (c *Client) scheduleCall(s *session, call protocol.ToolCall) {
+	if c.Draining() {
+		s.deliver(errorResult(call.ID, protocol.CodeRateLimited, "collector is draining; retry on another instance"))
+		return
 	}
-	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
-	_, data, err := conn.ReadMessage()
-	if err != nil {
-		return err
+	if err := policy.ValidateToolAllowed(call.Tool, s.current().AllowedTools); err != nil {
+		te, _ := protocol.AsToolError(err)
+		s.deliver(protocol.ToolResult{Type: protocol.TypeToolResult, ID: call.ID, Status: "error", Error: te})
+		return
 	}
-	_ = conn.SetReadDeadline(time.Time{})
-	env, err := protocol.DecodeEnvelope(data)
-	if err != nil {
-		return err
+	if err := policy.ValidateToolAllowed(call.Tool, s.remote.tools()); err != nil {
+		te, _ := protocol.AsToolError(err)
+		s.deliver(protocol.ToolResult{Type: protocol.TypeToolResult, ID: call.ID, Status: "error", Error: te})
+		return
 	}
-	if env.Type == protocol.TypeWireError {
-		return errHelloRejected
+	domains, ok := narrowDomains(call.Policy.AllowedDomains, s.remote.domains())
+	if !ok {
+		s.deliver(errorResult(call.ID, protocol.CodeDomainNotAllowed, "call domains are outside the collector's allowed domains"))
+		return
 	}
-	if env.Type != protocol.TypeCollectorHelloAck || !env.OK {
-		msg := env.Message
-		if msg == "" {
-			msg = "hello not acknowledged"
-		}
-		c.log.Printf("[collector] hello rejected: %s", msg)
-		return errHelloRejected
+	call.Policy.AllowedDomains = domains
+	call.Policy.RateLimitKey = c.limiter.KeyFor(call, s.name)
+	if c.limiter.Exhausted(call.Policy.RateLimitKey) {
+		s.deliver(errorResult(call.ID, protocol.CodeRateLimited, "daily cap reached for "+call.Policy.RateLimitKey))
+		return
 	}
-
-	manifest := c.rt.Manifest()
-	if err := writeEnv(protocol.NewCollectorManifest(manifest)); err != nil {
-		return err
+	if !c.queue.push(s, call, time.Now()) {
+		c.log.Printf("[collector] dropping call %s: local queue full", call.ID)
+		s.deliver(errorResult(call.ID, protocol.CodeRateLimited, "collector queue full"))
 	}
-	status := protocol.NewInstanceStatus(c.opts.InstanceID, "online", 0)
-	return writeEnv(status)
 }
 
-func (c *Client) heartbeatLoop(ctx context.Context, writeEnv envelopeWriter) {
-	ticker := time.NewTicker(c.opts.HeartbeatInterval)
-	defer ticker.Stop()
+// dispatchLoop starts queued calls as concurrency slots and rate-limit keys free up, and rejects
+// calls whose timeout expires while they wait.
+func (c *Client) dispatchLoop(ctx context.Context) {
 	for {
+		// only this goroutine fills sem, so a free slot observed here stays free
+		slotFree := len(c.sem) < cap(c.sem)
+		qc, expired, wait := c.queue.next(time.Now(), slotFree, c.limiter.Remaining)
+		for _, e := range expired {
+			e.sess.deliver(errorResult(e.call.ID, protocol.CodeTimeout, "call timed out in collector queue"))
+		}
+		if qc != nil {
+			c.startCall(ctx, qc)
+			continue
+		}
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			busy := "online"
-			active := int(c.activeCalls.Load())
-			if active > 0 {
-				busy = "busy"
-			}
-			env := protocol.NewInstanceStatus(c.opts.InstanceID, busy, active)
-			if err := writeEnv(env); err != nil {
-				return
-			}
+		case <-c.queue.wake:
+		case <-timer:
 		}
 	}
 }
 
-func (c *Client) pullLoop(ctx context.Context, writeEnv envelopeWriter) {
-	ticker := time.NewTicker(c.opts.PullInterval)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if c.activeCalls.Load() >= int32(c.opts.MaxConcurrentCalls) {
-				continue
-			}
-			if err := writeEnv(protocol.NewPullTasks(c.opts.PullBatchSize)); err != nil {
-				return
-			}
-		}
+func (c *Client) startCall(ctx context.Context, qc *queuedCall) {
+	call, s := qc.call, qc.sess
+	if !qc.deadline.IsZero() {
+		// the host's timeout covers the time spent queued
+		call.Policy.TimeoutMs = max(1, int(time.Until(qc.deadline).Milliseconds()))
 	}
+	// draining is checked under c.mu together with calls.Add so drain's calls.Wait never races an Add
+	c.mu.Lock()
+	if c.draining {
+		c.mu.Unlock()
+		s.deliver(errorResult(call.ID, protocol.CodeRateLimited, "collector is draining; retry on another instance"))
+		return
+	}
+	c.sem <- struct{}{}
+	c.calls.Add(1)
+	c.mu.Unlock()
+	key := call.Policy.RateLimitKey
+	c.queue.started(key)
+	go func() {
+		defer c.calls.Done()
+		defer func() {
+			<-c.sem
+			c.queue.finished(key)
+		}()
+		c.executeAndReply(ctx, s, call)
+	}()
 }
 
-func (c *Client) readLoop(ctx context.Context, conn *websocket.Conn, writeEnv envelopeWriter, sem chan struct{}, wg *sync.WaitGroup) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-		_, data, err := conn.ReadMessage()
-		if err != nil {
-			return err
-		}
-		env, err := protocol.DecodeEnvelope(data)
-		if err != nil {
-			c.log.Printf("[collector] invalid envelope: %v", err)
-			continue
-		}
-		if err := c.dispatch(ctx, writeEnv, env, sem, wg); err != nil {
-			return err
-		}
+func (c *Client) executeAndReply(ctx context.Context, s *session, call protocol.ToolCall) {
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
 	}
+	c.activeCalls.Add(1)
+	defer c.activeCalls.Add(-1)
+	// the cooldown starts before finished() wakes the dispatcher
+	defer c.limiter.Touch(call.Policy.RateLimitKey)
+
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	k := callKey{s, call.ID}
+	c.trackCall(k, cancel)
+	defer c.untrackCall(k)
+
+	s.deliver(*c.rt.Execute(callCtx, &call))
 }
 
-func (c *Client) dispatch(ctx context.Context, writeEnv envelopeWriter, env protocol.Envelope, sem chan struct{}, wg *sync.WaitGroup) error {
-	switch env.Type {
-	case protocol.TypePing:
-		return writeEnv(protocol.Envelope{Type: protocol.TypePong})
-	case protocol.TypePong, protocol.TypeCollectorHelloAck:
-		return nil
-	case protocol.TypeToolCall:
-		if env.ToolCall == nil {
-			return nil
-		}
-		c.scheduleCall(ctx, writeEnv, *env.ToolCall, sem, wg)
-		return nil
-	case protocol.TypePullTasksAck:
-		for i := range env.Calls {
-			c.scheduleCall(ctx, writeEnv, env.Calls[i], sem, wg)
-		}
-		return nil
-	case protocol.TypeWireError:
-		if env.Error != nil {
-			c.log.Printf("[collector] host error: %s %s", env.Error.Code, env.Error.Message)
-		}
-		return nil
-	default:
-		return nil
-	}
+func (c *Client) trackCall(k callKey, cancel context.CancelFunc) {
+	c.mu.Lock()
+	c.inflight[k] = cancel
+	c.mu.Unlock()
 }
 
-func (c *Client) scheduleCall(ctx context.Context, writeEnv envelopeWriter, call protocol.ToolCall, sem chan struct{}, wg *sync.WaitGroup) {
-	select {
-	case sem <- struct{}{}:
-	default:
-		c.log.Printf("[collector] dropping call %s: max concurrent reached", call.ID)
-		res := protocol.ToolResult{
-			Type:   protocol.TypeToolResult,
-			ID:     call.ID,
-			Status: "error",
-			Error: &protocol.ToolError{
-				Code:    "RATE_LIMITED",
-				Message: "collector at max concurrent calls",
-			},
-		}
-		_ = writeEnv(protocol.NewToolResultEnvelope(res))
+func (c *Client) untrackCall(k callKey) {
+	c.mu.Lock()
+	delete(c.inflight, k)
+	c.mu.Unlock()
+}
+
+// cancelCall aborts an in-flight call; the runtime then reports CANCELED. A queued call is
+// answered with CANCELED directly.
+func (c *Client) cancelCall(s *session, id string) {
+	if _, ok := c.queue.remove(s, id); ok {
+		c.log.Printf("[collector] cancel requested for queued call %s", id)
+		s.deliver(errorResult(id, protocol.CodeCanceled, "canceled while queued"))
 		return
 	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer func() { <-sem }()
-		c.executeAndReply(ctx, writeEnv, call)
-	}()
+	c.mu.Lock()
+	cancel, ok := c.inflight[callKey{s, id}]
+	c.mu.Unlock()
+	if ok {
+		c.log.Printf("[collector] cancel requested for %s", id)
+		cancel()
+	}
 }
 
-func (c *Client) executeAndReply(ctx context.Context, writeEnv envelopeWriter, call protocol.ToolCall) {
-	if call.Type == "" {
-		call.Type = protocol.TypeToolCall
+// features returns the capabilities this collector offers; remote_config only when updates could
+// be accepted.
+func (c *Client) features() []string {
+	f := protocol.SupportedFeatures()
+	if !c.opts.RemoteConfig.enabled() {
+		f = slices.DeleteFunc(f, func(s string) bool { return s == protocol.FeatureRemoteConfig })
 	}
-	key := call.Policy.RateLimitKey
-	if !c.limiter.Check(key) {
-		res := protocol.ToolResult{
-			Type:   protocol.TypeToolResult,
-			ID:     call.ID,
-			Status: "error",
-			Error: &protocol.ToolError{
-				Code:    "RATE_LIMITED",
-				Message: "rate limit cooldown active",
-			},
-		}
-		_ = writeEnv(protocol.NewToolResultEnvelope(res))
-		return
+	return f
+}
+
+// RemoteConfigVersion returns the version of the remote config applied on the first session; 0 when
+// none.
+func (c *Client) RemoteConfigVersion() int64 {
+	if len(c.sessions) == 0 {
+		return 0
 	}
+	return c.sessions[0].remote.Version()
+}
 
-	c.activeCalls.Add(1)
-	defer c.activeCalls.Add(-1)
-	defer c.limiter.Touch(key)
+// applyRemoteConfig handles collector_config_update from s: the config is verified and applied to
+// s only, its manifest is resent and the update is acknowledged. A refused update is acknowledged
+// with the version still in effect.
+func (c *Client) applyRemoteConfig(s *session, writeEnv envelopeWriter, u *protocol.ConfigUpdate) error {
+	if u == nil {
+		return writeEnv(protocol.NewConfigAck(s.remote.Version(), errors.New("config_update is required")))
+	}
+	version, err := s.remote.apply(*u)
+	if err != nil {
+		c.log.Printf("[collector] remote config from %s refused: %v", s.name, err)
+		return writeEnv(protocol.NewConfigAck(version, err))
+	}
+	c.limiter.SetRemoteRules(s.name, s.remote.rateLimits())
+	c.log.Printf("[collector] remote config from %s: version %d applied", s.name, version)
+	c.emit(Event{Type: EventConfigApplied, Host: s.name, ConfigVersion: version})
+	if err := writeEnv(protocol.NewCollectorManifest(s.manifest())); err != nil {
+		return err
+	}
+	return writeEnv(protocol.NewConfigAck(version, nil))
+}
+
+// instanceStatus reports the shared load, including calls waiting in the local queue, and the
+// current labels with the features negotiated on s.
+func (c *Client) instanceStatus(s *session, status string) protocol.Envelope {
+	env := protocol.NewInstanceStatus(c.opts.InstanceID, status, int(c.activeCalls.Load()), s.Features())
+	env.QueuedCalls = c.queue.Len()
+	env.Labels = c.labels.get()
+	return env
+}
 
-	result := c.rt.Execute(ctx, &call)
-	if err := writeEnv(protocol.NewToolResultEnvelope(*result)); err != nil {
-		c.log.Printf("[collector] failed to send result for %s: %v", call.ID, err)
+func errorResult(id, code, msg string) protocol.ToolResult {
+	return protocol.ToolResult{
+		Type:   protocol.TypeToolResult,
+		ID:     id,
+		Status: "error",
+		Error:  protocol.NewToolError(code, "%s", msg),
 	}
 }
 
@@ -306,3 +418,13 @@ func writeEnvelope(conn *websocket.Conn, env protocol.Envelope) error {
 	}
 	return conn.WriteMessage(websocket.TextMessage, data)
 }
+
+// encodeFrame picks a protobuf binary frame when negotiated, JSON text otherwise.
+func encodeFrame(env protocol.Envelope, binary bool) (int, []byte, error) {
+	if binary {
+		data, err := env.EncodeBinary()
+		return websocket.BinaryMessage, data, err
+	}
+	data, err := env.Encode()
+	return websocket.TextMessage, data, err
+}
@@ -0,0 +1,274 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/runtime"
+)
+
+func TestClientCancelCall(t *testing.T) {
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "slow.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			<-ctx.Done()
+			return nil, nil, ctx.Err()
+		},
+	})
+	rt := runtime.New(reg, runtime.Options{})
+
+	results := make(chan protocol.ToolResult, 1)
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+			if err != nil {
+				continue
+			}
+			switch env.Type {
+			case protocol.TypeCollectorHello:
+				_ = writeEnvelope(conn, protocol.NegotiateHello(env, "s1", nil, 0))
+			case protocol.TypeInstanceStatus:
+				if !protocol.HasFeature(env.Capabilities, protocol.FeatureCancel) {
+					t.Errorf("expected cancel in negotiated capabilities, got %v", env.Capabilities)
+				}
+				call, _ := json.Marshal(protocol.ToolCall{Type: protocol.TypeToolCall, ID: "c1", Tool: "slow.tool"})
+				_ = conn.WriteMessage(websocket.TextMessage, call)
+				time.Sleep(50 * time.Millisecond)
+				_ = writeEnvelope(conn, protocol.NewCancelCall("c1"))
+			case protocol.TypeToolResult:
+				results <- *env.ToolResult
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := NewClient(Options{ServerURL: ts.URL, ReconnectDelay: time.Second, MaxConcurrentCalls: 1}, rt)
+	go func() { _ = c.Run(ctx) }()
+
+	select {
+	case res := <-results:
+		if res.ID != "c1" || res.Error == nil || res.Error.Code != protocol.CodeCanceled {
+			t.Fatalf("expected CANCELED result, got %+v", res)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for canceled result")
+	}
+}
+
+func TestClientResendsResultAfterReconnect(t *testing.T) {
+	release := make(chan struct{})
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "slow.scrape"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			<-release
+			return map[string]any{"ok": true}, nil, nil
+		},
+	})
+	rt := runtime.New(reg, runtime.Options{})
+
+	results := make(chan protocol.ToolResult, 1)
+	var sessions atomic.Int32
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := sessions.Add(1)
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+			if err != nil {
+				continue
+			}
+			switch env.Type {
+			case protocol.TypeCollectorHello:
+				_ = writeEnvelope(conn, protocol.NegotiateHello(env, "s", []string{protocol.FeaturePush, protocol.FeatureResultAck}, 0))
+			case protocol.TypeInstanceStatus:
+				if n == 1 {
+					// 下发调用后立即断开：结果只能经 outbox 在下一次会话送达
+					_ = writeEnvelope(conn, protocol.Envelope{Type: protocol.TypeToolCall, ToolCall: &protocol.ToolCall{ID: "c1", Tool: "slow.scrape"}})
+					time.Sleep(50 * time.Millisecond)
+					close(release)
+					return
+				}
+			case protocol.TypeToolResult:
+				_ = writeEnvelope(conn, protocol.NewToolResultAck(env.ToolResult.ID))
+				results <- *env.ToolResult
+			}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := NewClient(Options{ServerURL: ts.URL, ReconnectDelay: 200 * time.Millisecond, MaxConcurrentCalls: 1, OutboxDir: t.TempDir()}, rt)
+	go func() { _ = c.Run(ctx) }()
+
+	select {
+	case res := <-results:
+		if res.ID != "c1" || res.Status != "success" {
+			t.Fatalf("unexpected result %+v", res)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for resent result")
+	}
+	if n := sessions.Load(); n < 2 {
+		t.Fatalf("result must arrive on a later session, got session %d", n)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Outbox().Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("outbox not cleared by tool_result_ack")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBackoffGrowsWithJitterAndCap(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			d := backoff(base, max, attempt)
+			if d < want/2 || d > want {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestClientDrainsInFlightCallsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "slow.scrape"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			close(started)
+			select {
+			case <-time.After(200 * time.Millisecond):
+				return map[string]any{"ok": true}, nil, nil
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		},
+	})
+	rt := runtime.New(reg, runtime.Options{})
+
+	var mu sync.Mutex
+	var frames []string
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sent := false
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+			if err != nil {
+				continue
+			}
+			mu.Lock()
+			switch env.Type {
+			case protocol.TypeInstanceStatus:
+				frames = append(frames, env.Status)
+			case protocol.TypeToolResult:
+				frames = append(frames, "result:"+env.ToolResult.Status)
+			}
+			mu.Unlock()
+			switch {
+			case env.Type == protocol.TypeCollectorHello:
+				_ = writeEnvelope(conn, protocol.NegotiateHello(env, "s1", nil, 0))
+			case env.Type == protocol.TypeInstanceStatus && !sent:
+				sent = true
+				_ = writeEnvelope(conn, protocol.Envelope{Type: protocol.TypeToolCall, ToolCall: &protocol.ToolCall{ID: "c1", Tool: "slow.scrape"}})
+			}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var events []EventType
+	c := NewClient(Options{ServerURL: ts.URL, ReconnectDelay: time.Second, MaxConcurrentCalls: 1, DrainTimeout: 2 * time.Second}, rt)
+	c.OnEvent = func(e Event) { events = append(events, e.Type) }
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call never started")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after drain")
+	}
+
+	// the host reads the last frames asynchronously
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(frames)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"online", "draining", "result:success"}
+	if len(frames) != len(want) {
+		t.Fatalf("frames = %v, want %v", frames, want)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Fatalf("frames = %v, want %v", frames, want)
+		}
+	}
+	wantEvents := []EventType{EventConnected, EventHandshake, EventDraining, EventStopped}
+	if len(events) != len(wantEvents) {
+		t.Fatalf("events = %v, want %v", events, wantEvents)
+	}
+	for i := range wantEvents {
+		if events[i] != wantEvents[i] {
+			t.Fatalf("events = %v, want %v", events, wantEvents)
+		}
+	}
+}
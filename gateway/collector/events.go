@@ -0,0 +1,43 @@
+package collector
+
+import "time"
+
+// EventType identifies a connection lifecycle event.
+type EventType string
+
+const (
+	EventConnected     EventType = "connected"      // WebSocket dialed, handshake pending
+	EventHandshake     EventType = "handshake"      // hello acknowledged; SessionID and Features set
+	EventDisconnected  EventType = "disconnected"   // session ended or dial failed; Err and RetryIn set
+	EventDraining      EventType = "draining"       // shutdown started; no new calls are accepted
+	EventConfigApplied EventType = "config_applied" // a remote config update was applied; ConfigVersion set
+	EventStopped       EventType = "stopped"        // Run returned
+)
+
+// Event is passed to Client.OnEvent.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Host names the endpoint (Endpoint.Name) for connection events; empty for draining / stopped.
+	Host      string
+	URL       string
+	SessionID string
+	Features  []string
+	// Attempt counts consecutive failed connection attempts (0 after a successful handshake).
+	Attempt int
+	RetryIn time.Duration
+	// ActiveCalls is the number of in-flight calls (draining / stopped).
+	ActiveCalls   int
+	ConfigVersion int64
+	Err           error
+}
+
+func (c *Client) emit(e Event) {
+	if c.OnEvent == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	c.OnEvent(e)
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/originaleric/digeino/config"
+)
+
+// Auto-detected label keys. Collector.Labels entries with the same key take precedence.
+const (
+	LabelOS              = "os"
+	LabelArch            = "arch"
+	LabelBrowser         = "browser"          // e.g. "Google Chrome 126.0.6478.126"
+	LabelBrowserHeadless = "browser.headless" // "true" | "false"
+	// LabelCookiePrefix + domain is "valid" when CookieStoreDir holds an unexpired cookie for it.
+	LabelCookiePrefix = "cookie."
+)
+
+// cookieLabelsTTL bounds how often the cookie store is rescanned for status envelopes.
+const cookieLabelsTTL = 30 * time.Second
+
+// DetectLabels returns the machine facts that do not change while the collector runs: OS,
+// architecture, browser version and headless mode.
+func DetectLabels(cfg *config.Config) map[string]string {
+	labels := map[string]string{
+		LabelOS:   goruntime.GOOS,
+		LabelArch: goruntime.GOARCH,
+	}
+	lb := cfg.Tools.LocalBrowser
+	bin := lb.ChromePath
+	if bin == "" {
+		bin, _ = launcher.LookPath()
+	}
+	if v := browserVersion(bin); v != "" {
+		labels[LabelBrowser] = v
+	}
+	labels[LabelBrowserHeadless] = strconv.FormatBool(lb.Headless == nil || *lb.Headless)
+	return labels
+}
+
+func browserVersion(bin string) string {
+	if bin == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, bin, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// CookieLabels reports a cookie.<domain> label for every domain file in dir (as written by the
+// browser tools) that holds at least one session cookie or cookie expiring after now.
+func CookieLabels(dir string, now time.Time) map[string]string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	labels := map[string]string{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var cookies []struct {
+			Expires float64 `json:"expires"`
+		}
+		if json.Unmarshal(data, &cookies) != nil {
+			continue
+		}
+		for _, c := range cookies {
+			if c.Expires <= 0 || time.Unix(int64(c.Expires), 0).After(now) {
+				labels[LabelCookiePrefix+strings.TrimSuffix(filepath.Base(f), ".json")] = "valid"
+				break
+			}
+		}
+	}
+	return labels
+}
+
+// staticLabels runs opts.DetectLabels and overlays opts.Labels.
+func staticLabels(opts Options) map[string]string {
+	if opts.DetectLabels == nil {
+		return opts.Labels
+	}
+	labels := opts.DetectLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	maps.Copy(labels, opts.Labels)
+	return labels
+}
+
+// labelSet merges detected cookie labels with the static labels, rescanning the cookie store at
+// most every cookieLabelsTTL.
+type labelSet struct {
+	static    map[string]string
+	cookieDir string
+
+	mu      sync.Mutex
+	merged  map[string]string
+	scanned time.Time
+}
+
+func (l *labelSet) get() map[string]string {
+	if l.cookieDir == "" {
+		return l.static
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := time.Now(); l.merged == nil || now.Sub(l.scanned) >= cookieLabelsTTL {
+		merged := CookieLabels(l.cookieDir, now)
+		maps.Copy(merged, l.static)
+		l.merged, l.scanned = merged, now
+	}
+	return l.merged
+}
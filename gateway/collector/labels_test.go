@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCookieLabelsSkipExpiredDomains(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]string{
+		"xiaohongshu.com.json": `[{"name":"a","expires":` + unix(now.Add(-time.Hour)) + `},{"name":"b","expires":` + unix(now.Add(time.Hour)) + `}]`,
+		"weibo.com.json":       `[{"name":"a","expires":` + unix(now.Add(-time.Minute)) + `}]`,
+		"zhihu.com.json":       `[{"name":"session"}]`,
+		"broken.json":          `{`,
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := CookieLabels(dir, now)
+	want := map[string]string{"cookie.xiaohongshu.com": "valid", "cookie.zhihu.com": "valid"}
+	if len(got) != len(want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("labels = %v, want %v", got, want)
+		}
+	}
+
+	// 配置标签覆盖探测结果
+	ls := &labelSet{static: map[string]string{"cookie.zhihu.com": "off", "region": "cn-east"}, cookieDir: dir}
+	if l := ls.get(); l["cookie.zhihu.com"] != "off" || l["region"] != "cn-east" || l["cookie.xiaohongshu.com"] != "valid" {
+		t.Fatalf("merged labels = %v", l)
+	}
+}
+
+func TestDetectLabelsRunInNewClient(t *testing.T) {
+	t.Parallel()
+	detected := 0
+	opts := Options{
+		Labels:       map[string]string{"os": "custom"},
+		DetectLabels: func() map[string]string { detected++; return map[string]string{"os": "linux", "arch": "amd64"} },
+	}
+	c := NewClient(opts, nil)
+	if detected != 1 {
+		t.Fatalf("DetectLabels ran %d times, want 1", detected)
+	}
+	if l := c.labels.get(); l["os"] != "custom" || l["arch"] != "amd64" {
+		t.Fatalf("labels = %v", l)
+	}
+}
+
+func unix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
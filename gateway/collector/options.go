@@ -1,23 +1,105 @@
 package collector
 
 import (
+	"cmp"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/secrets"
 )
 
+// Connection modes for Options.Hosts.
+const (
+	ModeFailover = "failover" // one session; hosts are tried in order, primary first
+	ModeAll      = "all"      // one concurrent session per host
+)
+
+// Endpoint is one host the collector connects to.
+type Endpoint struct {
+	Name      string // used in logs, events and the per-host outbox directory; defaults to the URL host
+	ServerURL string
+	WSPath    string
+	Token     string
+	// AllowedTools narrows the runtime allow-list on this host; empty exposes every runtime tool.
+	AllowedTools []string
+}
+
 // Options configures the Collector client.
 type Options struct {
+	// ServerURL / WSPath / Token describe a single host; Hosts, when set, takes precedence.
 	ServerURL            string
 	WSPath               string
 	Token                string
+	Hosts                []Endpoint
+	Mode                 string // ModeFailover (default) or ModeAll
 	InstanceID           string
+	// Labels are advertised in hello and status envelopes together with cookie labels and the
+	// labels DetectLabels returns; Labels win on equal keys.
+	Labels               map[string]string
+	// DetectLabels runs once in NewClient (it may exec the browser); nil detects nothing.
+	// OptionsFromConfig sets it to the package DetectLabels for cfg.
+	DetectLabels         func() map[string]string
+	CookieStoreDir       string // scanned for cookie.<domain> labels; empty disables them
 	HeartbeatInterval    time.Duration
 	PullInterval         time.Duration
 	PullBatchSize        int
-	ReconnectDelay       time.Duration
+	ReconnectDelay       time.Duration // first reconnect delay, doubled per failed attempt
+	ReconnectMaxDelay    time.Duration // backoff cap
+	DrainTimeout         time.Duration // shutdown grace period for in-flight calls; 0 cancels them at once
 	MaxConcurrentCalls   int
+	QueueSize            int // calls waiting for a slot or a rate-limit cooldown; beyond it calls are rejected
+	RateLimits           []RateLimitRule
+	RateLimitStateFile   string // persists pacing state across restarts; empty keeps it in memory
+	// OutboxDir persists unacknowledged results across reconnects and restarts; empty disables it.
+	OutboxDir            string
+	OutboxMaxEntries     int
+	OutboxMaxAge         time.Duration
+	RemoteConfig         RemoteConfigOptions
+}
+
+// Endpoints returns Hosts, or the single ServerURL host, with names and paths defaulted.
+func (o Options) Endpoints() []Endpoint {
+	eps := slices.Clone(o.Hosts)
+	if len(eps) == 0 {
+		if strings.TrimSpace(o.ServerURL) == "" {
+			return nil
+		}
+		eps = []Endpoint{{ServerURL: o.ServerURL, WSPath: o.WSPath, Token: o.Token}}
+	}
+	seen := map[string]int{}
+	for i := range eps {
+		ep := &eps[i]
+		if ep.WSPath == "" {
+			ep.WSPath = o.WSPath
+		}
+		if ep.Name == "" {
+			ep.Name = ep.ServerURL
+			if u, err := url.Parse(ep.ServerURL); err == nil && u.Host != "" {
+				ep.Name = u.Host
+			}
+		}
+		if seen[ep.Name]++; seen[ep.Name] > 1 {
+			ep.Name = fmt.Sprintf("%s#%d", ep.Name, seen[ep.Name])
+		}
+	}
+	return eps
+}
+
+// pathSafe maps a host name onto a directory name.
+func pathSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, name)
 }
 
 // OptionsFromConfig builds collector options from app config.
@@ -32,6 +114,14 @@ func OptionsFromConfig(cfg *config.Config) Options {
 	if reconnect <= 0 {
 		reconnect = 5 * time.Second
 	}
+	reconnectMax := time.Duration(c.ReconnectMaxDelaySec) * time.Second
+	if reconnectMax <= 0 {
+		reconnectMax = 2 * time.Minute
+	}
+	drain := time.Duration(c.DrainTimeoutSec) * time.Second
+	if drain <= 0 {
+		drain = 30 * time.Second
+	}
 	batch := c.PullBatchSize
 	if batch <= 0 {
 		batch = 1
@@ -40,6 +130,10 @@ func OptionsFromConfig(cfg *config.Config) Options {
 	if maxConc <= 0 {
 		maxConc = 1
 	}
+	queueSize := c.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
 	instanceID := strings.TrimSpace(c.InstanceID)
 	if instanceID == "" {
 		instanceID = cfg.Gateway.InstanceID
@@ -47,6 +141,58 @@ func OptionsFromConfig(cfg *config.Config) Options {
 	if instanceID == "" {
 		instanceID = "digeino-collector"
 	}
+	outboxMax := c.OutboxMaxEntries
+	if outboxMax <= 0 {
+		outboxMax = 1000
+	}
+	outboxAge := time.Duration(c.OutboxMaxAgeSec) * time.Second
+	if outboxAge <= 0 {
+		outboxAge = 24 * time.Hour
+	}
+	var rules []RateLimitRule
+	for _, r := range c.RateLimits {
+		rules = append(rules, RateLimitRule{
+			Match:       strings.TrimSpace(r.Match),
+			Domain:      strings.TrimSpace(r.Domain),
+			MinInterval: time.Duration(r.MinIntervalMs) * time.Millisecond,
+			Jitter:      time.Duration(r.JitterMs) * time.Millisecond,
+			MaxCalls:    r.MaxCalls,
+			Window:      time.Duration(r.WindowSec) * time.Second,
+			DailyCap:    r.DailyCap,
+		})
+	}
+	var hosts []Endpoint
+	for _, h := range c.Hosts {
+		hosts = append(hosts, Endpoint{
+			Name:         strings.TrimSpace(h.Name),
+			ServerURL:    strings.TrimSpace(h.ServerURL),
+			WSPath:       strings.TrimSpace(h.WSPath),
+			Token:        secrets.Lookup(cmp.Or(h.Token, c.Token)),
+			AllowedTools: h.AllowedTools,
+		})
+	}
+	rc := c.RemoteConfig
+	var keys []ed25519.PublicKey
+	for i, k := range rc.TrustedKeys {
+		pub, err := protocol.ParseConfigPublicKey(secrets.Lookup(k))
+		if err != nil {
+			log.Printf("[collector] ignoring Collector.RemoteConfig.TrustedKeys[%d]: %v", i, err)
+			continue
+		}
+		keys = append(keys, pub)
+	}
+	labels := map[string]string{}
+	for k, v := range c.Labels {
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	cookieDir := strings.TrimSpace(cfg.Tools.LocalBrowser.CookieStoreDir)
+	if cookieDir == "" {
+		cookieDir = "storage/app/browser_cookies"
+	}
+	mode := strings.TrimSpace(c.Mode)
+	if mode == "" {
+		mode = ModeFailover
+	}
 	wsPath := strings.TrimSpace(c.WSPath)
 	if wsPath == "" {
 		wsPath = "/digeino/v1/collector/ws"
@@ -54,12 +200,35 @@ func OptionsFromConfig(cfg *config.Config) Options {
 	return Options{
 		ServerURL:          strings.TrimSpace(c.ServerURL),
 		WSPath:             wsPath,
-		Token:              strings.TrimSpace(c.Token),
+		Token:              secrets.Lookup(c.Token),
+		Hosts:              hosts,
+		Mode:               mode,
 		InstanceID:         instanceID,
+		Labels:             labels,
+		DetectLabels:       func() map[string]string { return DetectLabels(cfg) },
+		CookieStoreDir:     cookieDir,
 		HeartbeatInterval:  heartbeat,
 		PullInterval:       pull,
 		PullBatchSize:      batch,
 		ReconnectDelay:     reconnect,
+		ReconnectMaxDelay:  reconnectMax,
+		DrainTimeout:       drain,
 		MaxConcurrentCalls: maxConc,
+		QueueSize:          queueSize,
+		RateLimits:         rules,
+		RateLimitStateFile: strings.TrimSpace(c.RateLimitStateFile),
+		OutboxDir:          strings.TrimSpace(c.OutboxDir),
+		OutboxMaxEntries:   outboxMax,
+		OutboxMaxAge:       outboxAge,
+		RemoteConfig: RemoteConfigOptions{
+			Disabled:       rc.Disabled,
+			TrustedKeys:    keys,
+			AllowedTools:   rc.AllowedTools,
+			AllowedDomains: rc.AllowedDomains,
+			RateLimits:     rc.RateLimits,
+			MinInterval:    time.Duration(rc.MinIntervalMsFloor) * time.Millisecond,
+			StateFile:      strings.TrimSpace(rc.StateFile),
+			MaxAge:         time.Duration(rc.MaxAgeSec) * time.Second,
+		},
 	}
 }
@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// Outbox keeps one JSON file per unacknowledged ToolResult under Dir, so a result produced while the
+// connection is down (or written but never acknowledged) is resent after the next handshake.
+// Retention is bounded by MaxEntries (oldest dropped first) and MaxAge.
+type Outbox struct {
+	Dir        string
+	MaxEntries int
+	MaxAge     time.Duration
+
+	mu      sync.Mutex
+	entries map[string]time.Time // call ID -> queued at
+	now     func() time.Time
+}
+
+type outboxEntry struct {
+	QueuedAt time.Time           `json:"queued_at"`
+	Result   protocol.ToolResult `json:"result"`
+}
+
+// OpenOutbox creates dir if needed and loads pending results; expired or unreadable files are removed.
+func OpenOutbox(dir string, maxEntries int, maxAge time.Duration) (*Outbox, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, fmt.Errorf("outbox dir is required")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, err
+	}
+	o := &Outbox{Dir: abs, MaxEntries: maxEntries, MaxAge: maxAge, entries: map[string]time.Time{}, now: time.Now}
+	files, err := filepath.Glob(filepath.Join(abs, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		e, err := readOutboxEntry(f)
+		if err != nil || e.Result.ID == "" {
+			_ = os.Remove(f)
+			continue
+		}
+		o.entries[e.Result.ID] = e.QueuedAt
+	}
+	o.mu.Lock()
+	o.pruneLocked()
+	o.mu.Unlock()
+	return o, nil
+}
+
+// Put persists res until Ack is called with its ID.
+func (o *Outbox) Put(res protocol.ToolResult) error {
+	if res.ID == "" {
+		return fmt.Errorf("outbox: result has no call id")
+	}
+	e := outboxEntry{QueuedAt: o.now(), Result: res}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	path := o.path(res.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	o.entries[res.ID] = e.QueuedAt
+	o.pruneLocked()
+	return nil
+}
+
+// Ack removes the result for callID and reports whether it was pending.
+func (o *Outbox) Ack(callID string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.entries[callID]; !ok {
+		return false
+	}
+	o.removeLocked(callID)
+	return true
+}
+
+// Pending returns the unacknowledged results, oldest first.
+func (o *Outbox) Pending() []protocol.ToolResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pruneLocked()
+	var out []protocol.ToolResult
+	for _, id := range o.idsLocked() {
+		e, err := readOutboxEntry(o.path(id))
+		if err != nil {
+			o.removeLocked(id)
+			continue
+		}
+		out = append(out, e.Result)
+	}
+	return out
+}
+
+// Len returns the number of unacknowledged results.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// idsLocked returns pending IDs ordered by queue time.
+func (o *Outbox) idsLocked() []string {
+	ids := make([]string, 0, len(o.entries))
+	for id := range o.entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		ti, tj := o.entries[ids[i]], o.entries[ids[j]]
+		if ti.Equal(tj) {
+			return ids[i] < ids[j]
+		}
+		return ti.Before(tj)
+	})
+	return ids
+}
+
+// pruneLocked drops expired results, then the oldest ones beyond MaxEntries.
+func (o *Outbox) pruneLocked() {
+	ids := o.idsLocked()
+	if o.MaxAge > 0 {
+		cutoff := o.now().Add(-o.MaxAge)
+		for len(ids) > 0 && o.entries[ids[0]].Before(cutoff) {
+			log.Printf("[collector] outbox: dropping result %s unacknowledged for over %s", ids[0], o.MaxAge)
+			o.removeLocked(ids[0])
+			ids = ids[1:]
+		}
+	}
+	if o.MaxEntries > 0 {
+		for len(ids) > o.MaxEntries {
+			log.Printf("[collector] outbox: dropping result %s, more than %d pending", ids[0], o.MaxEntries)
+			o.removeLocked(ids[0])
+			ids = ids[1:]
+		}
+	}
+}
+
+func (o *Outbox) removeLocked(id string) {
+	delete(o.entries, id)
+	_ = os.Remove(o.path(id))
+}
+
+// path hashes the call ID: IDs come from the host and may contain path separators.
+func (o *Outbox) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(o.Dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func readOutboxEntry(path string) (outboxEntry, error) {
+	var e outboxEntry
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(data, &e)
+	return e, err
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+func TestOutboxRetentionAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ob, err := OpenOutbox(dir, 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ob.now = func() time.Time { return now }
+	for i, id := range []string{"c1", "c2", "../c3"} {
+		now = now.Add(time.Duration(i) * time.Second)
+		if err := ob.Put(protocol.ToolResult{Type: protocol.TypeToolResult, ID: id, Status: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := ob.Pending(); len(got) != 2 || got[0].ID != "c2" || got[1].ID != "../c3" {
+		t.Fatalf("expected oldest result dropped, got %+v", got)
+	}
+	if !ob.Ack("c2") || ob.Ack("c2") {
+		t.Fatal("Ack must remove the entry exactly once")
+	}
+
+	reopened, err := OpenOutbox(dir, 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Pending(); len(got) != 1 || got[0].ID != "../c3" {
+		t.Fatalf("pending after reopen = %+v", got)
+	}
+	reopened.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if n := len(reopened.Pending()); n != 0 {
+		t.Fatalf("expired result kept: %d pending", n)
+	}
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// callQueue holds calls waiting for a concurrency slot or a rate-limit cooldown. Calls with a higher
+// Policy.Priority run first, FIFO within a priority. The queue is bounded and small, so next scans it.
+type callQueue struct {
+	mu      sync.Mutex
+	items   []*queuedCall
+	max     int
+	seq     uint64
+	running map[string]int // rate-limit key -> executing calls
+	wake    chan struct{}
+}
+
+type queuedCall struct {
+	sess     *session // delivered the call; receives its result
+	call     protocol.ToolCall
+	seq      uint64
+	deadline time.Time // zero when the call has no timeout
+}
+
+func newCallQueue(max int) *callQueue {
+	return &callQueue{
+		max:     max,
+		running: make(map[string]int),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// push queues a call from s; it returns false when the queue is full.
+func (q *callQueue) push(s *session, call protocol.ToolCall, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= q.max {
+		return false
+	}
+	q.seq++
+	qc := &queuedCall{sess: s, call: call, seq: q.seq}
+	if call.Policy.TimeoutMs > 0 {
+		qc.deadline = now.Add(time.Duration(call.Policy.TimeoutMs) * time.Millisecond)
+	}
+	q.items = append(q.items, qc)
+	q.signal()
+	return true
+}
+
+// next removes the highest-priority call that may start now. Calls whose deadline passed while queued
+// are removed and returned in expired. With no startable call, wait is the time until a deadline or
+// key cooldown changes that; 0 means wait for a push or a finished call.
+func (q *callQueue) next(now time.Time, slotFree bool, cooldown func(key string) time.Duration) (qc *queuedCall, expired []*queuedCall, wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	best := -1
+	kept := q.items[:0]
+	for _, it := range q.items {
+		if !it.deadline.IsZero() && !now.Before(it.deadline) {
+			expired = append(expired, it)
+			continue
+		}
+		kept = append(kept, it)
+		if !it.deadline.IsZero() {
+			wait = minWait(wait, it.deadline.Sub(now))
+		}
+		if !slotFree {
+			continue
+		}
+		if key := it.call.Policy.RateLimitKey; key != "" {
+			if q.running[key] > 0 {
+				continue // finished() wakes the loop
+			}
+			if d := cooldown(key); d > 0 {
+				wait = minWait(wait, d)
+				continue
+			}
+		}
+		if best < 0 || higher(it, kept[best]) {
+			best = len(kept) - 1
+		}
+	}
+	clear(q.items[len(kept):])
+	q.items = kept
+	if best >= 0 {
+		qc = q.items[best]
+		q.items = append(q.items[:best], q.items[best+1:]...)
+		return qc, expired, 0
+	}
+	return nil, expired, wait
+}
+
+// remove takes a queued call from s out, for cancel_call.
+func (q *callQueue) remove(s *session, id string) (*queuedCall, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, it := range q.items {
+		if it.sess == s && it.call.ID == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return it, true
+		}
+	}
+	return nil, false
+}
+
+// takeAll empties the queue, for draining.
+func (q *callQueue) takeAll() []*queuedCall {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+func (q *callQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *callQueue) started(key string) {
+	if key == "" {
+		return
+	}
+	q.mu.Lock()
+	q.running[key]++
+	q.mu.Unlock()
+}
+
+// finished releases key and wakes the dispatcher: a slot or a key may have become free.
+func (q *callQueue) finished(key string) {
+	q.mu.Lock()
+	if key != "" {
+		if q.running[key]--; q.running[key] <= 0 {
+			delete(q.running, key)
+		}
+	}
+	q.signal()
+	q.mu.Unlock()
+}
+
+func (q *callQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func higher(a, b *queuedCall) bool {
+	if a.call.Policy.Priority != b.call.Policy.Priority {
+		return a.call.Policy.Priority > b.call.Policy.Priority
+	}
+	return a.seq < b.seq
+}
+
+func minWait(cur, d time.Duration) time.Duration {
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	if cur == 0 || d < cur {
+		return d
+	}
+	return cur
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+func queuedIDs(t *testing.T, q *callQueue, now time.Time) []string {
+	t.Helper()
+	var ids []string
+	for {
+		qc, _, _ := q.next(now, true, func(string) time.Duration { return 0 })
+		if qc == nil {
+			return ids
+		}
+		ids = append(ids, qc.call.ID)
+	}
+}
+
+func TestCallQueuePriorityThenFIFO(t *testing.T) {
+	t.Parallel()
+	q := newCallQueue(10)
+	now := time.Now()
+	for _, c := range []struct {
+		id  string
+		pri int
+	}{{"a", 0}, {"b", 5}, {"c", 0}, {"d", 5}} {
+		q.push(nil, protocol.ToolCall{ID: c.id, Policy: protocol.CallPolicy{Priority: c.pri}}, now)
+	}
+	got := queuedIDs(t, q, now)
+	want := []string{"b", "d", "a", "c"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCallQueueBoundedAndExpires(t *testing.T) {
+	t.Parallel()
+	q := newCallQueue(2)
+	now := time.Now()
+	q.push(nil, protocol.ToolCall{ID: "short", Policy: protocol.CallPolicy{TimeoutMs: 100}}, now)
+	q.push(nil, protocol.ToolCall{ID: "long"}, now)
+	if q.push(nil, protocol.ToolCall{ID: "over"}, now) {
+		t.Fatal("push beyond capacity should fail")
+	}
+	qc, expired, wait := q.next(now, false, func(string) time.Duration { return 0 })
+	if qc != nil || len(expired) != 0 || wait != 100*time.Millisecond {
+		t.Fatalf("no slot: got %v %v %s", qc, expired, wait)
+	}
+	qc, expired, _ = q.next(now.Add(time.Second), true, func(string) time.Duration { return 0 })
+	if len(expired) != 1 || expired[0].call.ID != "short" {
+		t.Fatalf("expired = %v, want short", expired)
+	}
+	if qc == nil || qc.call.ID != "long" {
+		t.Fatalf("next = %v, want long", qc)
+	}
+}
+
+func TestCallQueueDelaysRateLimitedKey(t *testing.T) {
+	t.Parallel()
+	q := newCallQueue(10)
+	now := time.Now()
+	key := protocol.CallPolicy{RateLimitKey: "wechat"}
+	q.push(nil, protocol.ToolCall{ID: "w1", Policy: key}, now)
+	q.push(nil, protocol.ToolCall{ID: "w2", Policy: key}, now)
+	q.push(nil, protocol.ToolCall{ID: "other"}, now)
+
+	ready := func(string) time.Duration { return 0 }
+	qc, _, _ := q.next(now, true, ready)
+	if qc.call.ID != "w1" {
+		t.Fatalf("first = %s, want w1", qc.call.ID)
+	}
+	q.started("wechat")
+	if qc, _, _ = q.next(now, true, ready); qc.call.ID != "other" {
+		t.Fatalf("second = %s, want other while w1 runs", qc.call.ID)
+	}
+	q.finished("wechat")
+	cooling := func(string) time.Duration { return time.Second }
+	qc, _, wait := q.next(now, true, cooling)
+	if qc != nil || wait != time.Second {
+		t.Fatalf("during cooldown: got %v, wait %s", qc, wait)
+	}
+	if qc, _, _ = q.next(now, true, ready); qc == nil || qc.call.ID != "w2" {
+		t.Fatalf("after cooldown: got %v, want w2", qc)
+	}
+}
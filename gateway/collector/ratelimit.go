@@ -1,47 +1,321 @@
 package collector
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
 )
 
-// RateLimiter applies per-key cooldown after calls (in-memory, single collector process).
+// RateLimitRule paces calls whose RateLimitKey matches Match (a path.Match glob), or whose target
+// host is Domain or one of its subdomains. The first matching rule applies; keys without a rule get
+// the limiter's default cooldown.
+type RateLimitRule struct {
+	Match       string
+	Domain      string
+	MinInterval time.Duration // gap after a call before the next one with the same key
+	Jitter      time.Duration // random extra gap in [0, Jitter] on top of MinInterval
+	MaxCalls    int           // calls allowed per Window; 0 disables the window limit
+	Window      time.Duration
+	DailyCap    int // calls per local calendar day; 0 disables the cap
+}
+
+func (r RateLimitRule) matches(key string) bool {
+	if r.Match != "" {
+		if ok, _ := path.Match(r.Match, key); ok {
+			return true
+		}
+	}
+	if d := strings.ToLower(strings.TrimPrefix(r.Domain, ".")); d != "" {
+		host := keyHost(key)
+		return host == d || strings.HasSuffix(host, "."+d)
+	}
+	return false
+}
+
+// RateLimiter paces calls per key (single collector process). With Persist, its state survives
+// restarts so a restart does not reset anti-bot pacing.
 type RateLimiter struct {
 	mu       sync.Mutex
-	limits   map[string]time.Time
+	limits   map[string]*keyState
 	cooldown time.Duration
+	rules    []RateLimitRule
+	remote   map[string][]RateLimitRule // pushed by each host (session name); matched before rules
+	path     string
+	now      func() time.Time
+}
+
+type keyState struct {
+	Next     time.Time   `json:"next"`             // earliest start of the next call
+	Recent   []time.Time `json:"recent,omitempty"` // call times inside the rule window
+	Day      string      `json:"day,omitempty"`
+	DayCount int         `json:"day_count,omitempty"`
+}
+
+type rateLimitFile struct {
+	Keys map[string]*keyState `json:"keys"`
 }
 
-func NewRateLimiter(cooldown time.Duration) *RateLimiter {
+// NewRateLimiter uses cooldown for keys no rule matches.
+func NewRateLimiter(cooldown time.Duration, rules ...RateLimitRule) *RateLimiter {
 	if cooldown <= 0 {
 		cooldown = time.Second
 	}
 	return &RateLimiter{
-		limits:   make(map[string]time.Time),
+		limits:   make(map[string]*keyState),
 		cooldown: cooldown,
+		rules:    rules,
+		remote:   make(map[string][]RateLimitRule),
+		now:      time.Now,
+	}
+}
+
+// Persist loads saved state from file (a missing file is fine) and saves it after every call.
+func (r *RateLimiter) Persist(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o750); err != nil {
+		return err
 	}
+	var saved rateLimitFile
+	data, err := os.ReadFile(abs)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.path = abs
+	for k, st := range saved.Keys {
+		if st != nil {
+			r.limits[k] = st
+		}
+	}
+	return nil
+}
+
+// SetRemoteRules replaces the rules pushed by the host of session scope. They apply only to calls
+// from that session and are matched before the local rules; pacing state per key is kept.
+func (r *RateLimiter) SetRemoteRules(scope string, rules []RateLimitRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(rules) == 0 {
+		delete(r.remote, scope)
+		return
+	}
+	r.remote[scope] = rules
+}
+
+// KeyFor returns the key a call from session scope is paced by: Policy.RateLimitKey, or the host of
+// input.url when a rule matches that host. Hosts no rule covers are not paced, so a rule for one
+// site does not throttle the others. A key matched by a rule that scope's host pushed is tagged
+// with the session, so one host's pushed pacing never applies to another host's calls.
+func (r *RateLimiter) KeyFor(call protocol.ToolCall, scope string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := call.Policy.RateLimitKey
+	if key == "" {
+		key = inputHost(call.Input)
+		if key == "" {
+			return ""
+		}
+		if _, ok := r.ruleFor(scopedKey(scope, key)); !ok {
+			return ""
+		}
+	}
+	if _, ok := firstRule(r.remote[scope], key); ok {
+		return scopedKey(scope, key)
+	}
+	return key
 }
 
 // Check returns false if the key is still in cooldown.
 func (r *RateLimiter) Check(key string) bool {
+	return r.Remaining(key) == 0
+}
+
+// Remaining returns how long key must wait before its next call; 0 when it may run now.
+func (r *RateLimiter) Remaining(key string) time.Duration {
 	if key == "" {
-		return true
+		return 0
 	}
-	now := time.Now()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if until, ok := r.limits[key]; ok && now.Before(until) {
+	st := r.limits[key]
+	if st == nil {
+		return 0
+	}
+	now := r.now()
+	rule, _ := r.ruleFor(key)
+	wait := st.Next.Sub(now)
+	if rule.MaxCalls > 0 && rule.Window > 0 {
+		st.Recent = recentSince(st.Recent, now.Add(-rule.Window))
+		if n := len(st.Recent); n >= rule.MaxCalls {
+			wait = max(wait, st.Recent[n-rule.MaxCalls].Add(rule.Window).Sub(now))
+		}
+	}
+	if r.exhaustedLocked(st, rule, now) {
+		y, m, d := now.Date()
+		wait = max(wait, time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now))
+	}
+	return max(wait, 0)
+}
+
+// Exhausted reports whether key has reached its daily cap.
+func (r *RateLimiter) Exhausted(key string) bool {
+	if key == "" {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.limits[key]
+	if st == nil {
 		return false
 	}
-	return true
+	rule, _ := r.ruleFor(key)
+	return r.exhaustedLocked(st, rule, r.now())
 }
 
-// Touch starts cooldown for a key after a call completes.
+// Touch records a finished call and starts the key's interval.
 func (r *RateLimiter) Touch(key string) {
 	if key == "" {
 		return
 	}
 	r.mu.Lock()
-	r.limits[key] = time.Now().Add(r.cooldown)
-	r.mu.Unlock()
+	defer r.mu.Unlock()
+	now := r.now()
+	st := r.limits[key]
+	if st == nil {
+		st = &keyState{}
+		r.limits[key] = st
+	}
+	rule, ok := r.ruleFor(key)
+	if !ok {
+		st.Next = now.Add(r.cooldown)
+	} else {
+		gap := rule.MinInterval
+		if rule.Jitter > 0 {
+			gap += rand.N(rule.Jitter + 1)
+		}
+		st.Next = now.Add(gap)
+		if rule.MaxCalls > 0 && rule.Window > 0 {
+			st.Recent = append(recentSince(st.Recent, now.Add(-rule.Window)), now)
+		}
+		if rule.DailyCap > 0 {
+			if day := now.Format(time.DateOnly); st.Day != day {
+				st.Day, st.DayCount = day, 0
+			}
+			st.DayCount++
+		}
+	}
+	if r.path != "" {
+		if err := r.saveLocked(now); err != nil {
+			log.Printf("[collector] rate limit state: save %s: %v", r.path, err)
+		}
+	}
+}
+
+// ruleFor returns the rule pacing key: for a scoped key the session's pushed rules first, then the
+// local ones.
+func (r *RateLimiter) ruleFor(key string) (RateLimitRule, bool) {
+	if scope, base, ok := splitScopedKey(key); ok {
+		if rule, ok := firstRule(r.remote[scope], base); ok {
+			return rule, true
+		}
+		key = base
+	}
+	return firstRule(r.rules, key)
+}
+
+func firstRule(rules []RateLimitRule, key string) (RateLimitRule, bool) {
+	for _, rule := range rules {
+		if rule.matches(key) {
+			return rule, true
+		}
+	}
+	return RateLimitRule{}, false
+}
+
+// scopedKey tags key with the session whose pushed rules pace it: "@scope|key".
+func scopedKey(scope, key string) string {
+	return "@" + scope + "|" + key
+}
+
+func splitScopedKey(key string) (scope, base string, ok bool) {
+	if !strings.HasPrefix(key, "@") {
+		return "", key, false
+	}
+	return strings.Cut(key[1:], "|")
+}
+
+func (r *RateLimiter) exhaustedLocked(st *keyState, rule RateLimitRule, now time.Time) bool {
+	return rule.DailyCap > 0 && st.Day == now.Format(time.DateOnly) && st.DayCount >= rule.DailyCap
+}
+
+// saveLocked drops state that no longer affects pacing and atomically rewrites the state file.
+func (r *RateLimiter) saveLocked(now time.Time) error {
+	today := now.Format(time.DateOnly)
+	for k, st := range r.limits {
+		if rule, _ := r.ruleFor(k); rule.Window > 0 {
+			st.Recent = recentSince(st.Recent, now.Add(-rule.Window))
+		}
+		if !st.Next.After(now) && len(st.Recent) == 0 && st.Day != today {
+			delete(r.limits, k)
+		}
+	}
+	data, err := json.Marshal(rateLimitFile{Keys: r.limits})
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func recentSince(ts []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && !ts[i].After(since) {
+		i++
+	}
+	return ts[i:]
+}
+
+// keyHost returns the host part of keys such as "wechat:mp.weixin.qq.com", or the key itself.
+func keyHost(key string) string {
+	if i := strings.LastIndexByte(key, ':'); i >= 0 {
+		key = key[i+1:]
+	}
+	return strings.ToLower(key)
+}
+
+func inputHost(input json.RawMessage) string {
+	var in struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal(input, &in) != nil || in.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(in.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
 }
@@ -1,8 +1,12 @@
 package collector
 
 import (
+	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
 )
 
 func TestRateLimiterCooldown(t *testing.T) {
@@ -16,3 +20,108 @@ func TestRateLimiterCooldown(t *testing.T) {
 		t.Fatal("expected cooldown active")
 	}
 }
+
+func TestRateLimiterRulesWindowAndDailyCap(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 1, 20, 0, 0, 0, time.Local)
+	lim := NewRateLimiter(time.Second,
+		RateLimitRule{Match: "xhs:*", MinInterval: time.Minute, Jitter: 30 * time.Second, MaxCalls: 2, Window: time.Hour, DailyCap: 3},
+		RateLimitRule{Domain: "douyin.com", MinInterval: 10 * time.Second},
+	)
+	lim.now = func() time.Time { return now }
+
+	lim.Touch("xhs:note")
+	if d := lim.Remaining("xhs:note"); d < time.Minute || d > 90*time.Second {
+		t.Fatalf("interval with jitter = %s, want [1m, 1m30s]", d)
+	}
+	now = now.Add(2 * time.Minute)
+	lim.Touch("xhs:note")
+	now = now.Add(2 * time.Minute)
+	if d := lim.Remaining("xhs:note"); d != 56*time.Minute {
+		t.Fatalf("window wait = %s, want 56m", d)
+	}
+	now = now.Add(56 * time.Minute)
+	lim.Touch("xhs:note")
+	if !lim.Exhausted("xhs:note") {
+		t.Fatal("daily cap of 3 should be reached")
+	}
+	now = now.Add(4 * time.Hour) // next day
+	if lim.Exhausted("xhs:note") || lim.Remaining("xhs:note") != 0 {
+		t.Fatal("daily cap should reset on a new day")
+	}
+
+	lim.Touch("video:www.douyin.com")
+	if d := lim.Remaining("video:www.douyin.com"); d != 10*time.Second {
+		t.Fatalf("domain rule wait = %s, want 10s", d)
+	}
+	lim.Touch("other")
+	if d := lim.Remaining("other"); d != time.Second {
+		t.Fatalf("default cooldown = %s, want 1s", d)
+	}
+}
+
+func TestRateLimiterPersistsAcrossRestart(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "ratelimit.json")
+	rule := RateLimitRule{Match: "xhs:*", MinInterval: time.Hour, DailyCap: 1}
+	lim := NewRateLimiter(time.Second, rule)
+	if err := lim.Persist(file); err != nil {
+		t.Fatal(err)
+	}
+	lim.Touch("xhs:note")
+
+	restarted := NewRateLimiter(time.Second, rule)
+	if err := restarted.Persist(file); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Check("xhs:note") || !restarted.Exhausted("xhs:note") {
+		t.Fatal("pacing state should survive a restart")
+	}
+}
+
+func TestRateLimiterKeyForDomainRules(t *testing.T) {
+	t.Parallel()
+	call := protocol.ToolCall{Input: json.RawMessage(`{"url":"https://www.Douyin.com/video/1"}`)}
+	if key := NewRateLimiter(time.Second).KeyFor(call, ""); key != "" {
+		t.Fatalf("no domain rules: key = %q, want empty", key)
+	}
+	lim := NewRateLimiter(time.Second, RateLimitRule{Domain: "douyin.com"})
+	if key := lim.KeyFor(call, ""); key != "www.douyin.com" {
+		t.Fatalf("key = %q, want www.douyin.com", key)
+	}
+	other := protocol.ToolCall{Input: json.RawMessage(`{"url":"https://example.org/a"}`)}
+	if key := lim.KeyFor(other, ""); key != "" {
+		t.Fatalf("unmatched host: key = %q, want empty", key)
+	}
+	lim.Touch(lim.KeyFor(other, ""))
+	if d := lim.Remaining(lim.KeyFor(other, "")); d != 0 {
+		t.Fatalf("unmatched host paced for %s", d)
+	}
+	call.Policy.RateLimitKey = "dy"
+	if key := lim.KeyFor(call, ""); key != "dy" {
+		t.Fatalf("explicit key = %q, want dy", key)
+	}
+}
+
+func TestRateLimiterRemoteRulesArePerScope(t *testing.T) {
+	t.Parallel()
+	lim := NewRateLimiter(time.Second)
+	lim.SetRemoteRules("a", []RateLimitRule{{Domain: "douyin.com", MinInterval: time.Hour}})
+	call := protocol.ToolCall{Input: json.RawMessage(`{"url":"https://www.douyin.com/video/1"}`)}
+	keyA, keyB := lim.KeyFor(call, "a"), lim.KeyFor(call, "b")
+	if keyA == "" || keyB != "" {
+		t.Fatalf("keys = %q, %q; want only host a paced", keyA, keyB)
+	}
+	lim.Touch(keyA)
+	if lim.Remaining(keyA) == 0 {
+		t.Fatal("host a's pushed rule not applied")
+	}
+	call.Policy.RateLimitKey = "www.douyin.com"
+	if k := lim.KeyFor(call, "b"); lim.Remaining(k) != 0 {
+		t.Fatalf("host b paced by host a's rule (key %q)", k)
+	}
+	lim.SetRemoteRules("a", nil)
+	if k := lim.KeyFor(call, "a"); k != "www.douyin.com" {
+		t.Fatalf("cleared rules: key = %q", k)
+	}
+}
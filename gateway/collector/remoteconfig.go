@@ -0,0 +1,293 @@
+package collector
+
+import (
+	"cmp"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// RemoteConfigOptions is the local ceiling for collector_config_update: the host may only narrow the
+// tools, push domains inside AllowedDomains and, when RateLimits is set, prepend rate-limit rules no
+// faster than MinInterval. Without TrustedKeys or StateFile, or with Disabled, every update is
+// refused and the collector does not advertise remote_config.
+//
+// Each session keeps its own applied config and version: with ModeAll a host's push narrows only
+// calls from that host.
+type RemoteConfigOptions struct {
+	Disabled       bool
+	TrustedKeys    []ed25519.PublicKey
+	AllowedTools   []string // tools a push may enable; empty allows any runtime tool
+	AllowedDomains []string // domains (and their subdomains) a push may allow; empty refuses domains
+	RateLimits     bool
+	MinInterval    time.Duration
+	// StateFile persists the applied config and its version, the replay high-water mark, across
+	// restarts; with ModeAll each host gets a sibling file named after it. Required.
+	StateFile string
+	// MaxAge refuses updates issued longer ago than this, default 10 minutes. Updates dated more than
+	// configClockSkew in the future are refused too.
+	MaxAge time.Duration
+}
+
+func (o RemoteConfigOptions) enabled() bool {
+	return !o.Disabled && len(o.TrustedKeys) > 0 && o.StateFile != ""
+}
+
+const (
+	defaultConfigMaxAge = 10 * time.Minute
+	configClockSkew     = time.Minute
+)
+
+var (
+	errRemoteConfigDisabled = errors.New("remote config is disabled on this collector")
+	errRemoteConfigStale    = errors.New("config version is not newer than the applied one")
+)
+
+// remoteConfig holds the remote config applied on one session. Fields left nil keep the local
+// configuration.
+type remoteConfig struct {
+	opts       RemoteConfigOptions
+	instanceID string
+	file       string
+	now        func() time.Time
+
+	mu      sync.Mutex
+	version int64
+	cfg     protocol.RemoteConfig
+}
+
+type remoteConfigFile struct {
+	Version int64                 `json:"version"`
+	Config  protocol.RemoteConfig `json:"config"`
+}
+
+// newRemoteConfig keeps its state in file (StateFile, or a per-host sibling of it).
+func newRemoteConfig(opts RemoteConfigOptions, instanceID, file string) *remoteConfig {
+	return &remoteConfig{opts: opts, instanceID: instanceID, file: file, now: time.Now}
+}
+
+// hostStateFile returns the per-host sibling of file: "remote.json" becomes "remote.<host>.json".
+func hostStateFile(file, host string) string {
+	if file == "" {
+		return ""
+	}
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + pathSafe(host) + ext
+}
+
+// load restores the state file. A saved config outside the current ceiling (it was lowered since)
+// is not applied, but its version still guards against replays.
+func (r *remoteConfig) load() error {
+	if !r.opts.enabled() {
+		return nil
+	}
+	data, err := os.ReadFile(r.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved remoteConfigFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version = saved.Version
+	if err := r.check(saved.Config); err != nil {
+		return fmt.Errorf("saved version %d no longer allowed: %w", saved.Version, err)
+	}
+	r.cfg = saved.Config
+	return nil
+}
+
+// apply verifies u and merges it into the applied config. It returns the version in effect
+// afterwards: the new one, or the previous one when u is refused.
+func (r *remoteConfig) apply(u protocol.ConfigUpdate) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.opts.enabled() {
+		return r.version, errRemoteConfigDisabled
+	}
+	p, err := protocol.VerifyConfigUpdate(u, r.opts.TrustedKeys)
+	if err != nil {
+		return r.version, err
+	}
+	// a payload must name this instance: an untargeted one could be replayed to every collector
+	// trusting the key
+	if p.InstanceID != r.instanceID {
+		return r.version, fmt.Errorf("config is for instance %q, not %q", p.InstanceID, r.instanceID)
+	}
+	now, maxAge := r.now(), cmp.Or(r.opts.MaxAge, defaultConfigMaxAge)
+	switch {
+	case p.IssuedAt.IsZero():
+		return r.version, errors.New("config has no issued_at")
+	case p.IssuedAt.Before(now.Add(-maxAge)):
+		return r.version, fmt.Errorf("config issued at %s is older than %s", p.IssuedAt.Format(time.RFC3339), maxAge)
+	case p.IssuedAt.After(now.Add(configClockSkew)):
+		return r.version, fmt.Errorf("config issued at %s is in the future", p.IssuedAt.Format(time.RFC3339))
+	}
+	if p.Version <= r.version {
+		return r.version, fmt.Errorf("%w (%d <= %d)", errRemoteConfigStale, p.Version, r.version)
+	}
+	if err := r.check(p.Config); err != nil {
+		return r.version, err
+	}
+	next := r.cfg
+	if p.Config.AllowedTools != nil {
+		next.AllowedTools = nilIfEmpty(p.Config.AllowedTools)
+	}
+	if p.Config.AllowedDomains != nil {
+		next.AllowedDomains = nilIfEmpty(p.Config.AllowedDomains)
+	}
+	if p.Config.RateLimits != nil {
+		next.RateLimits = nilIfEmpty(p.Config.RateLimits)
+	}
+	if err := r.saveLocked(p.Version, next); err != nil {
+		return r.version, fmt.Errorf("save remote config: %w", err)
+	}
+	r.version, r.cfg = p.Version, next
+	return r.version, nil
+}
+
+// check validates cfg against the local ceiling.
+func (r *remoteConfig) check(cfg protocol.RemoteConfig) error {
+	o := r.opts
+	if len(o.AllowedTools) > 0 {
+		for _, t := range cfg.AllowedTools {
+			if !slices.Contains(o.AllowedTools, t) {
+				return fmt.Errorf("tool %q is outside the local remote-config ceiling", t)
+			}
+		}
+	}
+	for _, d := range cfg.AllowedDomains {
+		if !domainCovered(d, o.AllowedDomains) {
+			return fmt.Errorf("domain %q is outside the local remote-config ceiling", d)
+		}
+	}
+	if len(cfg.RateLimits) > 0 && !o.RateLimits {
+		return errors.New("rate limits may not be pushed to this collector")
+	}
+	for i, rule := range cfg.RateLimits {
+		if strings.TrimSpace(rule.Match) == "" && strings.TrimSpace(rule.Domain) == "" {
+			return fmt.Errorf("rate_limits[%d]: match or domain is required", i)
+		}
+		if _, err := path.Match(rule.Match, ""); err != nil {
+			return fmt.Errorf("rate_limits[%d]: invalid pattern %q", i, rule.Match)
+		}
+		if time.Duration(rule.MinIntervalMs)*time.Millisecond < o.MinInterval {
+			return fmt.Errorf("rate_limits[%d]: min_interval_ms %d is below the local floor %d", i, rule.MinIntervalMs, o.MinInterval.Milliseconds())
+		}
+	}
+	return nil
+}
+
+func (r *remoteConfig) saveLocked(version int64, cfg protocol.RemoteConfig) error {
+	data, err := json.MarshalIndent(remoteConfigFile{Version: version, Config: cfg}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0o750); err != nil {
+		return err
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.file)
+}
+
+// Version returns the applied config version; 0 when none was applied.
+func (r *remoteConfig) Version() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+// tools returns the pushed tool allow-list; nil when the host set none.
+func (r *remoteConfig) tools() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg.AllowedTools
+}
+
+// domains returns the pushed domain allow-list; nil when the host set none.
+func (r *remoteConfig) domains() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg.AllowedDomains
+}
+
+// rateLimits returns the pushed rate-limit rules.
+func (r *remoteConfig) rateLimits() []RateLimitRule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rules []RateLimitRule
+	for _, rl := range r.cfg.RateLimits {
+		rules = append(rules, RateLimitRule{
+			Match:       strings.TrimSpace(rl.Match),
+			Domain:      strings.TrimSpace(rl.Domain),
+			MinInterval: time.Duration(rl.MinIntervalMs) * time.Millisecond,
+			Jitter:      time.Duration(rl.JitterMs) * time.Millisecond,
+			MaxCalls:    rl.MaxCalls,
+			Window:      time.Duration(rl.WindowSec) * time.Second,
+			DailyCap:    rl.DailyCap,
+		})
+	}
+	return rules
+}
+
+// narrowDomains intersects the call's domain allow-list with the pushed one: a call without domains
+// gets the pushed list, otherwise only the overlap remains. ok is false when nothing overlaps.
+func narrowDomains(call, pushed []string) (out []string, ok bool) {
+	if len(pushed) == 0 {
+		return call, true
+	}
+	if len(call) == 0 {
+		return slices.Clone(pushed), true
+	}
+	for _, d := range call {
+		if domainCovered(d, pushed) {
+			out = append(out, d)
+		}
+	}
+	for _, d := range pushed {
+		if domainCovered(d, call) && !slices.Contains(out, d) {
+			out = append(out, d)
+		}
+	}
+	return out, len(out) > 0
+}
+
+// domainCovered reports whether d is one of within or a subdomain of one.
+func domainCovered(d string, within []string) bool {
+	d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+	if d == "" {
+		return false
+	}
+	for _, w := range within {
+		w = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(w), "."))
+		if w != "" && (d == w || strings.HasSuffix(d, "."+w)) {
+			return true
+		}
+	}
+	return false
+}
+
+func nilIfEmpty[T any](s []T) []T {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
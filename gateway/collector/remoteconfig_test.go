@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+func signedUpdate(t *testing.T, key ed25519.PrivateKey, version int64, cfg protocol.RemoteConfig) protocol.ConfigUpdate {
+	t.Helper()
+	return signPayload(t, key, protocol.ConfigPayload{Version: version, InstanceID: "c1", IssuedAt: time.Now(), Config: cfg})
+}
+
+func signPayload(t *testing.T, key ed25519.PrivateKey, p protocol.ConfigPayload) protocol.ConfigUpdate {
+	t.Helper()
+	u, err := protocol.SignConfigUpdate(key, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRemoteConfigEnforcesCeilingAndVersion(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	_, stranger, _ := ed25519.GenerateKey(nil)
+	state := filepath.Join(t.TempDir(), "remote.json")
+	opts := RemoteConfigOptions{
+		TrustedKeys:    []ed25519.PublicKey{pub},
+		AllowedTools:   []string{"web.read", "browser.browse"},
+		AllowedDomains: []string{"example.com"},
+		RateLimits:     true,
+		MinInterval:    time.Second,
+		StateFile:      state,
+	}
+	r := newRemoteConfig(opts, "c1", state)
+	now := time.Now()
+
+	refused := []struct {
+		name   string
+		update protocol.ConfigUpdate
+		want   string
+	}{
+		{"untrusted key", signedUpdate(t, stranger, 1, protocol.RemoteConfig{}), "signature"},
+		{"untargeted", signPayload(t, key, protocol.ConfigPayload{Version: 1, IssuedAt: now}), "instance"},
+		{"other instance", signPayload(t, key, protocol.ConfigPayload{Version: 1, InstanceID: "c2", IssuedAt: now}), "instance"},
+		{"no issued_at", signPayload(t, key, protocol.ConfigPayload{Version: 1, InstanceID: "c1"}), "issued_at"},
+		{"too old", signPayload(t, key, protocol.ConfigPayload{Version: 1, InstanceID: "c1", IssuedAt: now.Add(-time.Hour)}), "older"},
+		{"future", signPayload(t, key, protocol.ConfigPayload{Version: 1, InstanceID: "c1", IssuedAt: now.Add(time.Hour)}), "future"},
+		{"tool above ceiling", signedUpdate(t, key, 1, protocol.RemoteConfig{AllowedTools: []string{"shell.exec"}}), "shell.exec"},
+		{"domain above ceiling", signedUpdate(t, key, 1, protocol.RemoteConfig{AllowedDomains: []string{"evil.com"}}), "evil.com"},
+		{"rate below floor", signedUpdate(t, key, 1, protocol.RemoteConfig{RateLimits: []protocol.RemoteRateLimit{{Match: "*", MinIntervalMs: 10}}}), "floor"},
+	}
+	for _, tc := range refused {
+		if v, err := r.apply(tc.update); err == nil || !strings.Contains(err.Error(), tc.want) || v != 0 {
+			t.Fatalf("%s: version %d, err %v", tc.name, v, err)
+		}
+	}
+
+	v, err := r.apply(signedUpdate(t, key, 5, protocol.RemoteConfig{
+		AllowedTools:   []string{"web.read"},
+		AllowedDomains: []string{"news.example.com"},
+	}))
+	if err != nil || v != 5 || !slices.Equal(r.tools(), []string{"web.read"}) {
+		t.Fatalf("apply: version %d, err %v, tools %v", v, err, r.tools())
+	}
+	if _, err := r.apply(signedUpdate(t, key, 5, protocol.RemoteConfig{})); !errors.Is(err, errRemoteConfigStale) {
+		t.Fatalf("replayed version: err = %v", err)
+	}
+	// null fields keep the applied values, an empty list clears them
+	if _, err := r.apply(signedUpdate(t, key, 6, protocol.RemoteConfig{AllowedTools: []string{}})); err != nil {
+		t.Fatal(err)
+	}
+	if r.tools() != nil || !slices.Equal(r.domains(), []string{"news.example.com"}) {
+		t.Fatalf("after partial update: tools %v domains %v", r.tools(), r.domains())
+	}
+
+	restored := newRemoteConfig(opts, "c1", state)
+	if err := restored.load(); err != nil || restored.Version() != 6 || len(restored.domains()) != 1 {
+		t.Fatalf("restored version %d domains %v, err %v", restored.Version(), restored.domains(), err)
+	}
+	opts.Disabled = true
+	off := newRemoteConfig(opts, "c1", state)
+	if err := off.load(); err != nil || off.domains() != nil {
+		t.Fatalf("disabled collector restored %v, err %v", off.domains(), err)
+	}
+	if _, err := off.apply(signedUpdate(t, key, 7, protocol.RemoteConfig{})); !errors.Is(err, errRemoteConfigDisabled) {
+		t.Fatalf("disabled: err = %v", err)
+	}
+	opts.Disabled, opts.StateFile = false, ""
+	if opts.enabled() {
+		t.Fatal("remote config enabled without a state file")
+	}
+}
+
+func TestHostStateFile(t *testing.T) {
+	if got := hostStateFile("/var/lib/digeino/remote.json", "host-a"); got != "/var/lib/digeino/remote.host-a.json" {
+		t.Fatalf("hostStateFile = %q", got)
+	}
+}
+
+func TestNarrowDomains(t *testing.T) {
+	cases := []struct {
+		call, pushed, want []string
+		ok                 bool
+	}{
+		{nil, nil, nil, true},
+		{[]string{"a.com"}, nil, []string{"a.com"}, true},
+		{nil, []string{"a.com"}, []string{"a.com"}, true},
+		{[]string{"x.a.com", "b.com"}, []string{"a.com"}, []string{"x.a.com"}, true},
+		{[]string{"a.com"}, []string{"news.a.com"}, []string{"news.a.com"}, true},
+		{[]string{"b.com"}, []string{"a.com"}, nil, false},
+	}
+	for _, tc := range cases {
+		got, ok := narrowDomains(tc.call, tc.pushed)
+		if ok != tc.ok || !slices.Equal(got, tc.want) {
+			t.Fatalf("narrowDomains(%v, %v) = %v, %v", tc.call, tc.pushed, got, ok)
+		}
+	}
+}
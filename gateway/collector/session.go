@@ -0,0 +1,401 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/gwversion"
+	"github.com/originaleric/digeino/gateway/policy"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// session is the link to one logical host: a single endpoint, or the ordered endpoints of a failover
+// group. A call's result goes back to the session that delivered it; the queue, concurrency budget,
+// rate limits and runtime (browser pool) are shared by all sessions of a Client.
+type session struct {
+	c         *Client
+	name      string
+	endpoints []Endpoint
+	outboxDir string
+	outbox    *Outbox
+	remote    *remoteConfig // applied collector_config_update from this host
+
+	mu       sync.Mutex
+	endpoint Endpoint // connected, or last tried
+	features []string // negotiated with the host for the current connection
+	writer   envelopeWriter
+}
+
+func (s *session) Features() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.features)
+}
+
+func (s *session) hasFeature(f string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return protocol.HasFeature(s.features, f)
+}
+
+func (s *session) current() Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endpoint
+}
+
+func (s *session) setWriter(w envelopeWriter) {
+	s.mu.Lock()
+	s.writer = w
+	s.mu.Unlock()
+}
+
+func (s *session) currentWriter() envelopeWriter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer
+}
+
+// run keeps the session connected until ctx is cancelled. Endpoints are tried in order, primary
+// first; after a full round fails the session backs off. A dropped connection starts over at the
+// primary.
+func (s *session) run(ctx context.Context, cancelCalls context.CancelFunc) error {
+	c := s.c
+	attempt, idx := 0, 0
+	for {
+		ep := s.endpoints[idx]
+		established, err := s.connectOnce(ctx, ep, cancelCalls)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !established && idx+1 < len(s.endpoints) {
+			idx++
+			c.log.Printf("[collector] %s unavailable: %v; failing over to %s", ep.Name, err, s.endpoints[idx].Name)
+			continue
+		}
+		idx = 0
+		if established {
+			attempt = 0
+		}
+		delay := backoff(c.opts.ReconnectDelay, c.opts.ReconnectMaxDelay, attempt)
+		attempt++
+		c.log.Printf("[collector] %s session ended: %v; reconnect in %s", ep.Name, err, delay)
+		c.emit(Event{Type: EventDisconnected, Host: ep.Name, URL: ep.ServerURL, Attempt: attempt, RetryIn: delay, Err: err})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// connectOnce runs one connection; established reports whether the handshake succeeded.
+func (s *session) connectOnce(ctx context.Context, ep Endpoint, cancelCalls context.CancelFunc) (established bool, err error) {
+	c := s.c
+	wsURL, hdr, err := ep.WSEndpoint()
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	s.endpoint = ep
+	s.features = nil
+	s.mu.Unlock()
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, _, err := dialer.DialContext(ctx, wsURL, hdr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	// handshake frames are JSON; binary/compression switch on after negotiation
+	conn.EnableWriteCompression(false)
+
+	c.log.Printf("[collector] connected to %s (%s) instance=%s", ep.Name, wsURL, c.opts.InstanceID)
+	c.emit(Event{Type: EventConnected, Host: ep.Name, URL: wsURL})
+
+	// the connection outlives ctx while draining, so results and acks can still flow
+	connCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	var writeMu sync.Mutex
+	writeEnv := envelopeWriter(func(env protocol.Envelope) error {
+		msgType, data, encErr := encodeFrame(env, s.hasFeature(protocol.FeatureBinary))
+		if encErr != nil {
+			return encErr
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(msgType, data)
+	})
+
+	writeRaw := func(msgType int, payload []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(msgType, payload)
+	}
+
+	sessionID, err := s.handshake(conn, writeEnv)
+	if err != nil {
+		return false, err
+	}
+	c.emit(Event{Type: EventHandshake, Host: ep.Name, URL: wsURL, SessionID: sessionID, Features: s.Features()})
+	s.setWriter(writeEnv)
+	defer s.setWriter(nil)
+	if err := s.resendOutbox(writeEnv); err != nil {
+		return true, err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.readLoop(connCtx, conn, writeEnv)
+	}()
+
+	if c.opts.HeartbeatInterval > 0 {
+		go s.heartbeatLoop(connCtx, writeEnv)
+	}
+	if c.opts.PullInterval > 0 {
+		go s.pullLoop(connCtx, writeEnv)
+	}
+
+	select {
+	case <-ctx.Done():
+		c.drain(writeEnv, s, cancelCalls)
+		cancel()
+		_ = writeRaw(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		return true, ctx.Err()
+	case err := <-errCh:
+		cancel()
+		return true, err
+	}
+}
+
+// handshake exchanges hello / hello_ack and reports the manifest; it returns the host session ID.
+func (s *session) handshake(conn *websocket.Conn, writeEnv envelopeWriter) (string, error) {
+	c := s.c
+	hello := protocol.NewCollectorHello(c.opts.InstanceID, gwversion.RuntimeName, gwversion.RuntimeVersion)
+	hello.Labels = c.labels.get()
+	hello.Capabilities = c.features()
+	hello.ConfigVersion = s.remote.Version()
+	if err := writeEnv(hello); err != nil {
+		return "", err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return "", err
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	env, err := protocol.DecodeEnvelope(data)
+	if err != nil {
+		return "", err
+	}
+	if env.Type == protocol.TypeWireError {
+		if env.Error != nil && env.Error.Code == protocol.CodeUpgradeRequired {
+			c.log.Printf("[collector] hello rejected: %s", env.Error.Message)
+			return "", errUpgradeRequired
+		}
+		return "", errHelloRejected
+	}
+	if env.Type != protocol.TypeCollectorHelloAck || !env.OK {
+		msg := env.Message
+		if msg == "" {
+			msg = "hello not acknowledged"
+		}
+		c.log.Printf("[collector] hello rejected: %s", msg)
+		if env.Error != nil && env.Error.Code == protocol.CodeUpgradeRequired {
+			return "", errUpgradeRequired
+		}
+		return "", errHelloRejected
+	}
+	if err := protocol.CheckPeerVersion(env, protocol.MinCompatibleProtocolVersion); err != nil {
+		c.log.Printf("[collector] host too old: %v", err)
+		return "", errUpgradeRequired
+	}
+	features := protocol.NegotiateFeatures(c.features(), protocol.PeerFeatures(env))
+	s.mu.Lock()
+	s.features = features
+	s.mu.Unlock()
+	conn.EnableWriteCompression(protocol.HasFeature(features, protocol.FeatureCompression))
+	c.log.Printf("[collector] session=%s protocol=%d features=%v", env.SessionID, protocol.PeerVersion(env), features)
+
+	if err := writeEnv(protocol.NewCollectorManifest(s.manifest())); err != nil {
+		return "", err
+	}
+	return env.SessionID, writeEnv(c.instanceStatus(s, "online"))
+}
+
+// manifest is the runtime manifest narrowed to the connected endpoint's AllowedTools and the
+// remotely pushed tools.
+func (s *session) manifest() protocol.ToolManifest {
+	m := s.c.rt.Manifest()
+	allowed, pushed := s.current().AllowedTools, s.remote.tools()
+	if len(allowed) == 0 && len(pushed) == 0 {
+		return m
+	}
+	m.Tools = slices.DeleteFunc(m.Tools, func(t protocol.ToolDescriptor) bool {
+		return policy.ValidateToolAllowed(t.Name, allowed) != nil || policy.ValidateToolAllowed(t.Name, pushed) != nil
+	})
+	return m
+}
+
+func (s *session) heartbeatLoop(ctx context.Context, writeEnv envelopeWriter) {
+	c := s.c
+	ticker := time.NewTicker(c.opts.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			busy := "online"
+			if c.Draining() {
+				busy = "draining"
+			} else if c.activeCalls.Load() > 0 || c.queue.Len() > 0 {
+				busy = "busy"
+			}
+			if err := writeEnv(c.instanceStatus(s, busy)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *session) pullLoop(ctx context.Context, writeEnv envelopeWriter) {
+	c := s.c
+	ticker := time.NewTicker(c.opts.PullInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// pulled tasks stay on the host while local calls are still waiting
+			if c.Draining() || c.queue.Len() > 0 || c.activeCalls.Load() >= int32(c.opts.MaxConcurrentCalls) {
+				continue
+			}
+			if err := writeEnv(protocol.NewPullTasks(c.opts.PullBatchSize)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop dispatches frames until the connection ends; tool calls are queued and run under Run's
+// call context, so they survive a dropped connection.
+func (s *session) readLoop(ctx context.Context, conn *websocket.Conn, writeEnv envelopeWriter) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+		if err != nil {
+			s.c.log.Printf("[collector] invalid envelope: %v", err)
+			continue
+		}
+		if err := s.dispatch(writeEnv, env); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) dispatch(writeEnv envelopeWriter, env protocol.Envelope) error {
+	c := s.c
+	switch env.Type {
+	case protocol.TypePing:
+		return writeEnv(protocol.Envelope{Type: protocol.TypePong})
+	case protocol.TypePong, protocol.TypeCollectorHelloAck:
+		return nil
+	case protocol.TypeToolCall:
+		if env.ToolCall == nil {
+			return nil
+		}
+		c.scheduleCall(s, *env.ToolCall)
+		return nil
+	case protocol.TypePullTasksAck:
+		for i := range env.Calls {
+			c.scheduleCall(s, env.Calls[i])
+		}
+		return nil
+	case protocol.TypeToolResultAck:
+		if s.outbox != nil {
+			s.outbox.Ack(env.CallID)
+		}
+		return nil
+	case protocol.TypeCollectorConfigUpdate:
+		return c.applyRemoteConfig(s, writeEnv, env.ConfigUpdate)
+	case protocol.TypeCancelCall:
+		if s.hasFeature(protocol.FeatureCancel) {
+			c.cancelCall(s, env.CallID)
+		}
+		return nil
+	case protocol.TypeWireError:
+		if env.Error != nil {
+			c.log.Printf("[collector] host error: %s %s", env.Error.Code, env.Error.Message)
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// deliver sends res on the session's current connection. With an outbox the result is persisted
+// first and removed on tool_result_ack, or as soon as the frame is written when the host did not
+// negotiate result_ack.
+func (s *session) deliver(res protocol.ToolResult) {
+	c := s.c
+	if s.outbox != nil {
+		if err := s.outbox.Put(res); err != nil {
+			c.log.Printf("[collector] outbox: cannot persist result for %s: %v", res.ID, err)
+		}
+	}
+	writeEnv := s.currentWriter()
+	if writeEnv == nil {
+		c.log.Printf("[collector] %s not connected; result for %s %s", s.name, res.ID, s.pendingNote())
+		return
+	}
+	if err := writeEnv(protocol.NewToolResultEnvelope(res)); err != nil {
+		c.log.Printf("[collector] failed to send result for %s: %v; %s", res.ID, err, s.pendingNote())
+		return
+	}
+	if s.outbox != nil && !s.hasFeature(protocol.FeatureResultAck) {
+		s.outbox.Ack(res.ID)
+	}
+}
+
+func (s *session) pendingNote() string {
+	if s.outbox != nil {
+		return "kept in outbox for resend"
+	}
+	return "dropped (no outbox configured)"
+}
+
+// resendOutbox writes every unacknowledged result after a handshake, oldest first.
+func (s *session) resendOutbox(writeEnv envelopeWriter) error {
+	if s.outbox == nil {
+		return nil
+	}
+	pending := s.outbox.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+	s.c.log.Printf("[collector] outbox: resending %d unacknowledged results to %s", len(pending), s.name)
+	ack := s.hasFeature(protocol.FeatureResultAck)
+	for _, res := range pending {
+		if err := writeEnv(protocol.NewToolResultEnvelope(res)); err != nil {
+			return err
+		}
+		if !ack {
+			s.outbox.Ack(res.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/runtime"
+)
+
+// testHost acknowledges hello, sends call after the first instance_status and reports the manifest
+// tool names and results it receives.
+type testHost struct {
+	*httptest.Server
+	tools   chan []string
+	results chan protocol.ToolResult
+}
+
+func newTestHost(t *testing.T, token string, call protocol.ToolCall) *testHost {
+	t.Helper()
+	h := &testHost{tools: make(chan []string, 4), results: make(chan protocol.ToolResult, 4)}
+	upgrader := websocket.Upgrader{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sent := false
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+			if err != nil {
+				continue
+			}
+			switch env.Type {
+			case protocol.TypeCollectorHello:
+				_ = writeEnvelope(conn, protocol.NegotiateHello(env, "s-"+token, nil, 0))
+			case protocol.TypeCollectorManifest:
+				var names []string
+				for _, d := range env.Manifest.Tools {
+					names = append(names, d.Name)
+				}
+				h.tools <- names
+			case protocol.TypeInstanceStatus:
+				if !sent {
+					sent = true
+					_ = writeEnvelope(conn, protocol.Envelope{Type: protocol.TypeToolCall, ToolCall: &call})
+				}
+			case protocol.TypeToolResult:
+				h.results <- *env.ToolResult
+			}
+		}
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func echoRuntime(names ...string) *runtime.Runtime {
+	reg := registry.New()
+	for _, name := range names {
+		reg.Register(registry.Entry{
+			Descriptor: protocol.ToolDescriptor{Name: name},
+			Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+				return map[string]any{"tool": call.Tool}, nil, nil
+			},
+		})
+	}
+	return runtime.New(reg, runtime.Options{})
+}
+
+func TestClientFailsOverToStandby(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	standby := newTestHost(t, "standby-token", protocol.ToolCall{ID: "c1", Tool: "web.read"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := make(chan Event, 16)
+	c := NewClient(Options{
+		Hosts: []Endpoint{
+			{Name: "primary", ServerURL: down.URL, Token: "primary-token"},
+			{Name: "standby", ServerURL: standby.URL, Token: "standby-token"},
+		},
+		ReconnectDelay:     time.Second,
+		MaxConcurrentCalls: 1,
+		DrainTimeout:       time.Second,
+	}, echoRuntime("web.read"))
+	c.OnEvent = func(e Event) { events <- e }
+	go func() { _ = c.Run(ctx) }()
+
+	for {
+		select {
+		case e := <-events:
+			if e.Type == EventHandshake {
+				if e.Host != "standby" || e.SessionID != "s-standby-token" {
+					t.Fatalf("handshake on %q session %q, want standby", e.Host, e.SessionID)
+				}
+				select {
+				case res := <-standby.results:
+					if res.ID != "c1" || res.Status != "success" {
+						t.Fatalf("result = %+v", res)
+					}
+				case <-ctx.Done():
+					t.Fatal("no result from standby")
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("never connected to standby")
+		}
+	}
+}
+
+func TestClientAllModeSessionsPerHost(t *testing.T) {
+	a := newTestHost(t, "token-a", protocol.ToolCall{ID: "same-id", Tool: "web.read"})
+	b := newTestHost(t, "token-b", protocol.ToolCall{ID: "same-id", Tool: "web.read"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := NewClient(Options{
+		Mode: ModeAll,
+		Hosts: []Endpoint{
+			{Name: "a", ServerURL: a.URL, Token: "token-a"},
+			{Name: "b", ServerURL: b.URL, Token: "token-b", AllowedTools: []string{"x.search"}},
+		},
+		ReconnectDelay:     time.Second,
+		MaxConcurrentCalls: 1,
+		DrainTimeout:       time.Second,
+	}, echoRuntime("web.read", "x.search"))
+	go func() { _ = c.Run(ctx) }()
+
+	for _, tc := range []struct {
+		host      *testHost
+		wantTools []string
+		wantCode  string
+	}{
+		{a, []string{"web.read", "x.search"}, ""},
+		{b, []string{"x.search"}, protocol.CodeToolNotAllowed},
+	} {
+		select {
+		case tools := <-tc.host.tools:
+			slices.Sort(tools)
+			if !slices.Equal(tools, tc.wantTools) {
+				t.Fatalf("manifest tools = %v, want %v", tools, tc.wantTools)
+			}
+		case <-ctx.Done():
+			t.Fatal("no manifest")
+		}
+		select {
+		case res := <-tc.host.results:
+			code := ""
+			if res.Error != nil {
+				code = res.Error.Code
+			}
+			if res.ID != "same-id" || code != tc.wantCode {
+				t.Fatalf("result = %+v, want code %q", res, tc.wantCode)
+			}
+		case <-ctx.Done():
+			t.Fatal("no result")
+		}
+	}
+}
@@ -42,3 +42,17 @@ func buildWSURL(serverURL, wsPath, token string) (string, http.Header, error) {
 	}
 	return u.String(), hdr, nil
 }
+
+// WSEndpoint returns the WebSocket URL and auth headers the collector dials (the primary host when
+// Hosts is set).
+func (o Options) WSEndpoint() (string, http.Header, error) {
+	if eps := o.Endpoints(); len(eps) > 0 {
+		return eps[0].WSEndpoint()
+	}
+	return buildWSURL(o.ServerURL, o.WSPath, o.Token)
+}
+
+// WSEndpoint returns the WebSocket URL and auth headers for this host.
+func (e Endpoint) WSEndpoint() (string, http.Header, error) {
+	return buildWSURL(e.ServerURL, e.WSPath, e.Token)
+}
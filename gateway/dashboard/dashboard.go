@@ -0,0 +1,181 @@
+// Package dashboard is the embedded web UI for collector hosts: connected collectors with their
+// manifests, queued and running calls, a live result stream with inline artifacts, and a form
+// for submitting tool calls. gateway/host and gateway/devhost mount it under /dashboard/.
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+//go:embed static
+var static embed.FS
+
+// Collector is a connected collector as shown on the dashboard.
+type Collector struct {
+	InstanceID  string                    `json:"instance_id"`
+	Runtime     string                    `json:"runtime,omitempty"`
+	Status      string                    `json:"status,omitempty"` // online | busy | draining
+	ActiveCalls int                       `json:"active_calls"`
+	QueuedCalls int                       `json:"queued_calls"`
+	Features    []string                  `json:"features"`
+	Labels      map[string]string         `json:"labels,omitempty"`
+	Tools       []protocol.ToolDescriptor `json:"tools"` // manifest, including input schemas
+	ConnectedAt time.Time                 `json:"connected_at,omitempty"`
+	LastSeen    time.Time                 `json:"last_seen,omitempty"`
+}
+
+// Task states.
+const (
+	TaskQueued  = "queued"
+	TaskRunning = "running"
+)
+
+// Task is a call waiting for or running on a collector.
+type Task struct {
+	ID          string          `json:"id"`
+	Tool        string          `json:"tool"`
+	State       string          `json:"state"`
+	InstanceID  string          `json:"instance_id,omitempty"`
+	Deliveries  int             `json:"deliveries,omitempty"`
+	Input       json.RawMessage `json:"input,omitempty"`
+	SubmittedAt time.Time       `json:"submitted_at,omitempty"`
+}
+
+// Source is the host the dashboard shows.
+type Source interface {
+	Collectors() []Collector
+	Tasks() []Task
+	// Submit queues call; route constraints come from call.Route.
+	Submit(ctx context.Context, call protocol.ToolCall) (id string, err error)
+}
+
+// Options configures the handler.
+type Options struct {
+	// Token guards the dashboard API (Bearer, X-Digeino-Token or ?token= for the event stream);
+	// empty allows anyone. The page itself is static and asks for the token.
+	Token string
+	// ArtifactBaseURL resolves collector-local artifact URIs (digeino-artifact://id) to
+	// {ArtifactBaseURL}/artifacts/{id}, e.g. the collector's gateway HTTP server. Empty shows them
+	// as plain references; http(s) and data: URIs are shown inline as is.
+	ArtifactBaseURL string
+}
+
+// ErrInvalidCall is returned by a Source for calls it cannot accept (answered with 400).
+var ErrInvalidCall = errors.New("dashboard: invalid call")
+
+// Handler serves the UI and its API:
+//
+//	GET  /             the page
+//	GET  /api/state    collectors, tasks and recent results
+//	GET  /api/events   Server-Sent Events: one "result" event per finished call
+//	POST /api/calls    submit a ToolCall
+//
+// Mount it with http.StripPrefix("/dashboard", ...).
+func Handler(src Source, feed *Feed, opts Options) http.Handler {
+	if feed == nil {
+		feed = NewFeed(0)
+	}
+	page, _ := fs.Sub(static, "static")
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(page))
+	mux.HandleFunc("GET /api/state", authorized(opts.Token, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"collectors":        src.Collectors(),
+			"tasks":             src.Tasks(),
+			"results":           feed.Recent(),
+			"artifact_base_url": strings.TrimRight(opts.ArtifactBaseURL, "/"),
+		})
+	}))
+	mux.HandleFunc("GET /api/events", authorized(opts.Token, func(w http.ResponseWriter, r *http.Request) {
+		serveEvents(w, r, feed)
+	}))
+	mux.HandleFunc("POST /api/calls", authorized(opts.Token, func(w http.ResponseWriter, r *http.Request) {
+		var call protocol.ToolCall
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&call); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+			return
+		}
+		if call.Tool == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "tool is required"})
+			return
+		}
+		id, err := src.Submit(r.Context(), call)
+		if errors.Is(err, ErrInvalidCall) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		} else if err != nil {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"id": id})
+	}))
+	return mux
+}
+
+// serveEvents streams feed events until the client goes away, with a comment line every 15s to
+// keep proxies from closing the connection.
+func serveEvents(w http.ResponseWriter, r *http.Request, feed *Feed) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	events, cancel := feed.Subscribe()
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+		case ev := <-events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			_, _ = io.WriteString(w, "event: result\ndata: "+string(data)+"\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func authorized(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got := r.Header.Get("X-Digeino-Token")
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				got = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+			} else if got == "" {
+				got = r.URL.Query().Get("token") // EventSource cannot set headers
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
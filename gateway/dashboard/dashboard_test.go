@@ -0,0 +1,104 @@
+package dashboard
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+type fakeSource struct {
+	mu    sync.Mutex
+	calls []protocol.ToolCall
+}
+
+func (f *fakeSource) Collectors() []Collector {
+	return []Collector{{InstanceID: "c1", Tools: []protocol.ToolDescriptor{{Name: "x", InputSchema: json.RawMessage(`{"type":"object"}`)}}}}
+}
+
+func (f *fakeSource) Tasks() []Task {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []Task
+	for _, c := range f.calls {
+		out = append(out, Task{ID: c.ID, Tool: c.Tool, State: TaskQueued})
+	}
+	return out
+}
+
+func (f *fakeSource) Submit(_ context.Context, call protocol.ToolCall) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	call.ID = "call_1"
+	f.calls = append(f.calls, call)
+	return call.ID, nil
+}
+
+func TestHandlerServesStateSubmitAndEvents(t *testing.T) {
+	feed := NewFeed(0)
+	ts := httptest.NewServer(http.StripPrefix("/dashboard", Handler(&fakeSource{}, feed, Options{Token: "tok"})))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/dashboard/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("page = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if resp, _ := http.Get(ts.URL + "/dashboard/api/state"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated state = %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/dashboard/api/calls", strings.NewReader(`{"tool":"x","input":{"q":1}}`))
+	req.Header.Set("Authorization", "Bearer tok")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("submit = %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/dashboard/api/state?token=tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state struct {
+		Collectors []Collector `json:"collectors"`
+		Tasks      []Task      `json:"tasks"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if len(state.Collectors) != 1 || len(state.Collectors[0].Tools) != 1 || len(state.Tasks) != 1 || state.Tasks[0].ID != "call_1" {
+		t.Fatalf("state = %+v", state)
+	}
+
+	resp, err = http.Get(ts.URL + "/dashboard/api/events?token=tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	feed.Publish(Event{Tool: "x", Result: protocol.ToolResult{ID: "call_1", Status: "success"}})
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			var ev Event
+			if err := json.Unmarshal([]byte(data), &ev); err != nil || ev.Result.ID != "call_1" || ev.Tool != "x" {
+				t.Fatalf("event = %s, %v", data, err)
+			}
+			break
+		}
+	}
+	if recent := feed.Recent(); len(recent) != 1 {
+		t.Fatalf("recent = %+v", recent)
+	}
+}
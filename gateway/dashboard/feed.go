@@ -0,0 +1,86 @@
+package dashboard
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// Event is a finished call on the live result stream.
+type Event struct {
+	Tool       string              `json:"tool"`
+	InstanceID string              `json:"instance_id,omitempty"`
+	At         time.Time           `json:"at"`
+	Result     protocol.ToolResult `json:"result"`
+}
+
+// subscriberBuffer is how many events a slow dashboard may lag behind before it misses some.
+const subscriberBuffer = 64
+
+// Feed fans results out to dashboard clients and keeps the most recent ones for new clients.
+// The zero value is not usable; create it with NewFeed. A nil *Feed ignores Publish.
+type Feed struct {
+	mu     sync.Mutex
+	keep   int
+	recent []Event
+	subs   map[chan Event]struct{}
+}
+
+// NewFeed creates a feed that remembers the last keep results (default 50).
+func NewFeed(keep int) *Feed {
+	if keep <= 0 {
+		keep = 50
+	}
+	return &Feed{keep: keep, subs: make(map[chan Event]struct{})}
+}
+
+// Publish records ev and sends it to subscribers without blocking; a subscriber whose buffer is
+// full misses it (the page reloads state periodically anyway).
+func (f *Feed) Publish(ev Event) {
+	if f == nil {
+		return
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recent = append(f.recent, ev)
+	if len(f.recent) > f.keep {
+		f.recent = slices.Delete(f.recent, 0, len(f.recent)-f.keep)
+	}
+	for ch := range f.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Recent returns the remembered results, newest first.
+func (f *Feed) Recent() []Event {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Event, len(f.recent))
+	copy(out, f.recent)
+	slices.Reverse(out)
+	return out
+}
+
+// Subscribe returns a channel of new events; call cancel when done.
+func (f *Feed) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}
+}
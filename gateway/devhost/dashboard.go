@@ -0,0 +1,53 @@
+package devhost
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/originaleric/digeino/gateway/dashboard"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// dashboardSource exposes the dev host to the web dashboard at /dashboard/.
+type dashboardSource struct{ s *Server }
+
+func (d dashboardSource) Collectors() []dashboard.Collector {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+	out := make([]dashboard.Collector, 0, len(d.s.clients))
+	for _, cs := range d.s.clients {
+		out = append(out, dashboard.Collector{
+			InstanceID:  cs.instanceID,
+			Runtime:     cs.runtime,
+			Status:      cs.status,
+			ActiveCalls: cs.active,
+			QueuedCalls: cs.queued,
+			Features:    cs.features,
+			Labels:      cs.labels,
+			Tools:       cs.manifest,
+			ConnectedAt: cs.connectedAt,
+		})
+	}
+	slices.SortFunc(out, func(a, b dashboard.Collector) int { return cmp.Compare(a.InstanceID, b.InstanceID) })
+	return out
+}
+
+func (d dashboardSource) Tasks() []dashboard.Task {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+	out := make([]dashboard.Task, 0, len(d.s.tasks))
+	for _, t := range d.s.tasks {
+		out = append(out, *t)
+	}
+	slices.SortFunc(out, func(a, b dashboard.Task) int { return a.SubmittedAt.Compare(b.SubmittedAt) })
+	return out
+}
+
+func (d dashboardSource) Submit(_ context.Context, call protocol.ToolCall) (string, error) {
+	resp, _, err := d.s.enqueue("", "auto", call)
+	if err != nil {
+		return "", err
+	}
+	return resp["call_id"].(string), nil
+}
@@ -2,6 +2,7 @@ package devhost
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -11,26 +12,61 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/dashboard"
 	"github.com/originaleric/digeino/gateway/protocol"
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin:       func(r *http.Request) bool { return true },
+	EnableCompression: true,
 }
 
-// Server is a minimal reference host for local Collector development (not production Knowledge).
+// Server is a minimal reference host for local Collector development; production hosts embed
+// gateway/host.
 type Server struct {
 	Token   string
 	WSPath  string
 	log     *log.Logger
 	mu      sync.Mutex
 	clients map[string]*clientSession
+	tasks   map[string]*dashboard.Task // enqueued calls without a result yet
+	feed    *dashboard.Feed
+
+	// MinProtocolVersion rejects older collectors with UPGRADE_REQUIRED (0 = protocol minimum).
+	MinProtocolVersion int
 }
 
 type clientSession struct {
-	instanceID string
-	conn       *websocket.Conn
-	queue      []protocol.ToolCall
+	instanceID  string
+	runtime     string
+	conn        *websocket.Conn
+	features    []string
+	labels      map[string]string // from hello, refreshed by instance_status
+	status      string
+	active      int
+	queued      int // calls waiting in the collector's local queue
+	queue       []protocol.ToolCall
+	manifest    []protocol.ToolDescriptor
+	connectedAt time.Time
+	writeMu     sync.Mutex
+}
+
+// write sends env as a protobuf binary frame when negotiated, JSON text otherwise.
+func (cs *clientSession) write(env protocol.Envelope) error {
+	msgType, data := websocket.TextMessage, []byte(nil)
+	var err error
+	if protocol.HasFeature(cs.features, protocol.FeatureBinary) {
+		msgType = websocket.BinaryMessage
+		data, err = env.EncodeBinary()
+	} else {
+		data, err = env.Encode()
+	}
+	if err != nil {
+		return err
+	}
+	cs.writeMu.Lock()
+	defer cs.writeMu.Unlock()
+	return cs.conn.WriteMessage(msgType, data)
 }
 
 // NewServer creates a dev reference host.
@@ -43,6 +79,8 @@ func NewServer(token, wsPath string) *Server {
 		WSPath:  wsPath,
 		log:     log.Default(),
 		clients: make(map[string]*clientSession),
+		tasks:   make(map[string]*dashboard.Task),
+		feed:    dashboard.NewFeed(0),
 	}
 }
 
@@ -54,7 +92,10 @@ func (s *Server) Handler() http.Handler {
 	})
 	mux.HandleFunc(s.WSPath, s.handleWS)
 	mux.HandleFunc("POST /dev/enqueue", s.handleEnqueue)
+	mux.HandleFunc("POST /dev/cancel", s.handleCancel)
 	mux.HandleFunc("GET /dev/collectors", s.handleListCollectors)
+	mux.Handle("/dashboard/", http.StripPrefix("/dashboard",
+		dashboard.Handler(dashboardSource{s}, s.feed, dashboard.Options{Token: s.Token})))
 	return mux
 }
 
@@ -68,41 +109,63 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer conn.Close()
+	conn.EnableWriteCompression(false)
 
 	var session *clientSession
 	defer func() {
 		if session != nil {
 			s.mu.Lock()
-			delete(s.clients, session.instanceID)
+			if s.clients[session.instanceID] == session {
+				delete(s.clients, session.instanceID)
+			}
+			for id, t := range s.tasks {
+				if t.InstanceID == session.instanceID {
+					delete(s.tasks, id) // the dev host does not redeliver
+				}
+			}
 			s.mu.Unlock()
 		}
 	}()
 
 	for {
-		_, data, err := conn.ReadMessage()
+		msgType, data, err := conn.ReadMessage()
 		if err != nil {
 			return
 		}
-		env, err := protocol.DecodeEnvelope(data)
+		env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
 		if err != nil {
 			continue
 		}
 		switch env.Type {
 		case protocol.TypeCollectorHello:
+			if env.InstanceID == "" {
+				_ = writeEnvelope(conn, protocol.NewWireError(protocol.CodeInvalidInput, "expected collector_hello with instance_id"))
+				return
+			}
+			ack := protocol.NegotiateHello(env, uuid.NewString(), nil, s.MinProtocolVersion)
+			if !ack.OK {
+				s.log.Printf("[dev-host] rejected %s: %s", env.InstanceID, ack.Message)
+				_ = writeEnvelope(conn, ack)
+				return
+			}
+			ack.Message = "dev-host connected"
 			session = &clientSession{
-				instanceID: env.InstanceID,
-				conn:       conn,
+				instanceID:  env.InstanceID,
+				runtime:     env.Runtime,
+				conn:        conn,
+				features:    ack.Capabilities,
+				labels:      env.Labels,
+				connectedAt: time.Now(),
 			}
+			// register before acking so the collector is routable once it sees the ack; writeMu
+			// keeps pushed calls behind the ack
+			session.writeMu.Lock()
 			s.mu.Lock()
 			s.clients[env.InstanceID] = session
 			s.mu.Unlock()
-			ack := protocol.Envelope{
-				Type:      protocol.TypeCollectorHelloAck,
-				OK:        true,
-				SessionID: uuid.NewString(),
-				Message:   "dev-host connected",
-			}
-			_ = writeEnvelope(conn, ack)
+			_ = writeEnvelope(conn, ack) // always JSON: the collector learns the features from it
+			conn.EnableWriteCompression(protocol.HasFeature(session.features, protocol.FeatureCompression))
+			session.writeMu.Unlock()
 		case protocol.TypeCollectorManifest:
 			id := env.InstanceID
 			if session != nil {
@@ -111,10 +174,22 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 			tools := 0
 			if env.Manifest != nil {
 				tools = len(env.Manifest.Tools)
+				if session != nil {
+					s.mu.Lock()
+					session.manifest = env.Manifest.Tools
+					s.mu.Unlock()
+				}
 			}
 			s.log.Printf("[dev-host] manifest from %s tools=%d", id, tools)
 		case protocol.TypeInstanceStatus:
-			// heartbeat
+			if session != nil {
+				s.mu.Lock()
+				if env.Labels != nil {
+					session.labels = env.Labels
+				}
+				session.status, session.active, session.queued = env.Status, env.ActiveCalls, env.QueuedCalls
+				s.mu.Unlock()
+			}
 		case protocol.TypePullTasks:
 			if session == nil {
 				continue
@@ -124,16 +199,24 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 				limit = 1
 			}
 			calls := s.dequeue(session, limit)
-			_ = writeEnvelope(conn, protocol.Envelope{
+			_ = session.write(protocol.Envelope{
 				Type:  protocol.TypePullTasksAck,
 				Calls: calls,
 			})
 		case protocol.TypeToolResult:
 			if env.ToolResult != nil {
 				s.log.Printf("[dev-host] result id=%s status=%s", env.ToolResult.ID, env.ToolResult.Status)
+				s.finish(session, *env.ToolResult)
+				if session != nil && protocol.HasFeature(session.features, protocol.FeatureResultAck) {
+					_ = session.write(protocol.NewToolResultAck(env.ToolResult.ID))
+				}
 			}
 		case protocol.TypePing:
-			_ = writeEnvelope(conn, protocol.Envelope{Type: protocol.TypePong})
+			if session != nil {
+				_ = session.write(protocol.Envelope{Type: protocol.TypePong})
+			} else {
+				_ = writeEnvelope(conn, protocol.Envelope{Type: protocol.TypePong})
+			}
 		}
 	}
 }
@@ -150,9 +233,29 @@ func (s *Server) dequeue(session *clientSession, limit int) []protocol.ToolCall
 	out := make([]protocol.ToolCall, limit)
 	copy(out, session.queue[:limit])
 	session.queue = session.queue[limit:]
+	for _, c := range out {
+		if t, ok := s.tasks[c.ID]; ok {
+			t.State = dashboard.TaskRunning
+		}
+	}
 	return out
 }
 
+// finish drops the call's task and publishes the result to the dashboard.
+func (s *Server) finish(session *clientSession, res protocol.ToolResult) {
+	ev := dashboard.Event{Result: res}
+	if session != nil {
+		ev.InstanceID = session.instanceID
+	}
+	s.mu.Lock()
+	if t, ok := s.tasks[res.ID]; ok {
+		ev.Tool = t.Tool
+		delete(s.tasks, res.ID)
+	}
+	s.mu.Unlock()
+	s.feed.Publish(ev)
+}
+
 func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 	if !s.authenticated(r) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -164,7 +267,7 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		InstanceID string             `json:"instance_id"`
+		InstanceID string             `json:"instance_id"` // empty: any collector matching call.route
 		Mode       string             `json:"mode"` // queue | push
 		Call       protocol.ToolCall  `json:"call"`
 	}
@@ -172,44 +275,129 @@ func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	if req.Call.Type == "" {
-		req.Call.Type = protocol.TypeToolCall
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode == "" {
+		mode = "queue"
 	}
-	if req.Call.ID == "" {
-		req.Call.ID = "call_" + uuid.NewString()
+	resp, status, err := s.enqueue(req.InstanceID, mode, req.Call)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	s.mu.Lock()
-	session, ok := s.clients[req.InstanceID]
-	s.mu.Unlock()
-	if !ok {
-		http.Error(w, "collector not connected", http.StatusNotFound)
-		return
+// enqueue assigns call to a collector and pushes it (mode "push") or queues it for pull_tasks;
+// mode "auto" pushes when the collector negotiated push. On failure it returns the HTTP status
+// to answer with.
+func (s *Server) enqueue(instanceID, mode string, call protocol.ToolCall) (map[string]any, int, error) {
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
+	}
+	if call.ID == "" {
+		call.ID = "call_" + uuid.NewString()
 	}
 
-	mode := strings.ToLower(strings.TrimSpace(req.Mode))
-	if mode == "" {
+	session, status, msg := s.assign(instanceID, call.Route)
+	if session == nil {
+		return nil, status, errors.New(msg)
+	}
+	if mode == "auto" {
 		mode = "queue"
+		if protocol.HasFeature(session.features, protocol.FeaturePush) {
+			mode = "push"
+		}
 	}
+	task := &dashboard.Task{ID: call.ID, Tool: call.Tool, State: dashboard.TaskQueued, InstanceID: session.instanceID,
+		Input: call.Input, SubmittedAt: time.Now()}
+
 	if mode == "push" {
-		data, err := json.Marshal(req.Call)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := session.conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			http.Error(w, err.Error(), http.StatusBadGateway)
-			return
+		task.State = dashboard.TaskRunning
+		s.mu.Lock()
+		s.tasks[call.ID] = task
+		s.mu.Unlock()
+		if err := session.write(protocol.Envelope{Type: protocol.TypeToolCall, ToolCall: &call}); err != nil {
+			s.mu.Lock()
+			delete(s.tasks, call.ID)
+			s.mu.Unlock()
+			return nil, http.StatusBadGateway, err
 		}
-		writeJSON(w, http.StatusOK, map[string]any{"mode": "push", "call_id": req.Call.ID})
-		return
+		return map[string]any{"mode": "push", "call_id": call.ID, "instance_id": session.instanceID}, 0, nil
 	}
 
 	s.mu.Lock()
-	session.queue = append(session.queue, req.Call)
+	session.queue = append(session.queue, call)
+	s.tasks[call.ID] = task
 	queued := len(session.queue)
 	s.mu.Unlock()
-	writeJSON(w, http.StatusOK, map[string]any{"mode": "queue", "call_id": req.Call.ID, "queued": queued})
+	return map[string]any{"mode": "queue", "call_id": call.ID, "instance_id": session.instanceID, "queued": queued}, 0, nil
+}
+
+// assign picks the collector for a call: instanceID when given, otherwise the least loaded
+// collector whose labels satisfy route. On failure it returns the HTTP status and message.
+func (s *Server) assign(instanceID string, route *protocol.CallRoute) (*clientSession, int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if instanceID != "" {
+		session, ok := s.clients[instanceID]
+		switch {
+		case !ok:
+			return nil, http.StatusNotFound, "collector not connected"
+		case !route.Matches(session.labels):
+			return nil, http.StatusConflict, "collector labels do not match route"
+		}
+		return session, 0, ""
+	}
+	var best *clientSession
+	for _, cs := range s.clients {
+		if !route.Matches(cs.labels) {
+			continue
+		}
+		if best == nil || cs.load() < best.load() ||
+			(cs.load() == best.load() && cs.instanceID < best.instanceID) {
+			best = cs
+		}
+	}
+	if best == nil {
+		return nil, http.StatusConflict, "no connected collector matches route"
+	}
+	return best, 0, ""
+}
+
+// load counts calls waiting on the host and in the collector's local queue; callers hold s.mu.
+func (cs *clientSession) load() int {
+	return len(cs.queue) + cs.queued
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		InstanceID string `json:"instance_id"`
+		CallID     string `json:"call_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil || req.CallID == "" {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	session, ok := s.clients[req.InstanceID]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "collector not connected", http.StatusNotFound)
+		return
+	}
+	if !protocol.HasFeature(session.features, protocol.FeatureCancel) {
+		http.Error(w, "collector does not support cancel", http.StatusConflict)
+		return
+	}
+	if err := session.write(protocol.NewCancelCall(req.CallID)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"call_id": req.CallID, "cancel": "sent"})
 }
 
 func (s *Server) handleListCollectors(w http.ResponseWriter, r *http.Request) {
@@ -219,11 +407,13 @@ func (s *Server) handleListCollectors(w http.ResponseWriter, r *http.Request) {
 	}
 	s.mu.Lock()
 	ids := make([]string, 0, len(s.clients))
-	for id := range s.clients {
+	labels := make(map[string]map[string]string, len(s.clients))
+	for id, cs := range s.clients {
 		ids = append(ids, id)
+		labels[id] = cs.labels
 	}
 	s.mu.Unlock()
-	writeJSON(w, http.StatusOK, map[string]any{"collectors": ids})
+	writeJSON(w, http.StatusOK, map[string]any{"collectors": ids, "labels": labels})
 }
 
 func (s *Server) authenticated(r *http.Request) bool {
@@ -258,6 +448,6 @@ func (s *Server) ListenAndServe(addr string) error {
 		Handler:           s.Handler(),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
-	s.log.Printf("[dev-host] listening on %s ws=%s", addr, s.WSPath)
+	s.log.Printf("[dev-host] listening on %s ws=%s dashboard=/dashboard/", addr, s.WSPath)
 	return srv.ListenAndServe()
 }
@@ -1,6 +1,8 @@
 package devhost
 
 import (
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -39,3 +41,87 @@ func TestDevHostHandshake(t *testing.T) {
 		t.Fatalf("expected hello_ack, got %+v", env)
 	}
 }
+
+func TestDevHostRejectsOldProtocol(t *testing.T) {
+	srv := NewServer("", "/digeino/v1/collector/ws")
+	srv.MinProtocolVersion = protocol.ProtocolVersion
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/digeino/v1/collector/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	legacy := protocol.Envelope{Type: protocol.TypeCollectorHello, InstanceID: "inst_old", RuntimeVersion: "0.1.0"}
+	if err := writeEnvelope(conn, legacy); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := protocol.DecodeEnvelope(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.OK || env.Error == nil || env.Error.Code != protocol.CodeUpgradeRequired {
+		t.Fatalf("expected upgrade rejection, got %+v", env)
+	}
+}
+
+func TestDevHostRoutesCallsByLabels(t *testing.T) {
+	srv := NewServer("", "/digeino/v1/collector/ws")
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/digeino/v1/collector/ws"
+	for id, labels := range map[string]map[string]string{
+		"inst_plain": {"os": "linux"},
+		"inst_xhs":   {"os": "darwin", "cookie.xiaohongshu.com": "valid"},
+	} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		hello := protocol.NewCollectorHello(id, "digeino", "0.1.0")
+		hello.Labels = labels
+		if err := writeEnvelope(conn, hello); err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enqueue := func(body string) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/dev/enqueue", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	route := `"route":{"labels":{"cookie.xiaohongshu.com":"*"}}`
+	if code, out := enqueue(`{"call":{"tool":"browser.browse",` + route + `}}`); code != http.StatusOK || out["instance_id"] != "inst_xhs" {
+		t.Fatalf("routed enqueue = %d %v, want inst_xhs", code, out)
+	}
+	if code, _ := enqueue(`{"instance_id":"inst_plain","call":{"tool":"browser.browse",` + route + `}}`); code != http.StatusConflict {
+		t.Fatalf("explicit mismatch = %d, want 409", code)
+	}
+	if code, _ := enqueue(`{"call":{"tool":"x","route":{"labels":{"region":"eu"}}}}`); code != http.StatusConflict {
+		t.Fatalf("unmatched route = %d, want 409", code)
+	}
+	// 无约束时分给负载最低的 collector
+	if code, out := enqueue(`{"call":{"tool":"x"}}`); code != http.StatusOK || out["instance_id"] != "inst_plain" {
+		t.Fatalf("unconstrained enqueue = %d %v, want inst_plain", code, out)
+	}
+}
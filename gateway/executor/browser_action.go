@@ -46,7 +46,7 @@ func BrowserActionEntry(configDomains []string) registry.Entry {
 			}
 			resp, err := research.BrowserAction(ctx, &in)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, classifyError(err)
 			}
 			return map[string]any{
 				"success": resp.Success,
@@ -2,10 +2,12 @@ package executor
 
 import (
 	"context"
+	"strings"
 
 	"github.com/originaleric/digeino/gateway/artifact"
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/usage"
 	"github.com/originaleric/digeino/tools/research"
 )
 
@@ -63,7 +65,8 @@ func BrowserBrowseEntry(configDomains []string, artStore artifact.Store) registr
 				return nil, nil, err
 			}
 
-			resp, err := research.BrowserBrowse(ctx, &research.BrowserBrowseRequest{
+			meter := usage.FromContext(ctx)
+			resp, err := research.BrowserBrowse(research.WithNavigationObserver(ctx, meter.AddPage), &research.BrowserBrowseRequest{
 				URL:             in.URL,
 				Action:          in.Action,
 				Mode:            in.Mode,
@@ -73,7 +76,7 @@ func BrowserBrowseEntry(configDomains []string, artStore artifact.Store) registr
 				UseCookieDomain: in.UseCookieDomain,
 			})
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, classifyError(err)
 			}
 
 			out := map[string]any{
@@ -94,6 +97,7 @@ func BrowserBrowseEntry(configDomains []string, artStore artifact.Store) registr
 			}
 			var artifacts []protocol.Artifact
 			if resp.ScreenshotBase != "" {
+				meter.AddScreenshot()
 				artID := call.ID + "_screenshot"
 				if artStore != nil {
 					art, err := artifact.PutBase64PNG(ctx, artStore, artID, resp.ScreenshotBase)
@@ -113,5 +117,17 @@ func BrowserBrowseEntry(configDomains []string, artStore artifact.Store) registr
 			}
 			return out, artifacts, nil
 		},
+		Cacheable: browseCacheable,
+	}
+}
+
+// browseCacheable allows caching plain reads; screenshots, tab reuse and cookie reads depend on
+// browser state.
+func browseCacheable(call *protocol.ToolCall) bool {
+	in, err := decodeInput[browserBrowseInput](call)
+	if err != nil || in.TabID != "" || in.UseCookieDomain != "" {
+		return false
 	}
+	action := strings.ToLower(strings.TrimSpace(in.Action))
+	return action == "" || action == "read"
 }
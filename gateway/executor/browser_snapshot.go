@@ -5,6 +5,7 @@ import (
 
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/usage"
 	"github.com/originaleric/digeino/tools/research"
 )
 
@@ -41,9 +42,9 @@ func BrowserSnapshotEntry(configDomains []string) registry.Entry {
 			if err := validateCookieDomain(in.UseCookieDomain, call, configDomains); err != nil {
 				return nil, nil, err
 			}
-			resp, err := research.BrowserSnapshot(ctx, &in)
+			resp, err := research.BrowserSnapshot(research.WithNavigationObserver(ctx, usage.FromContext(ctx).AddPage), &in)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, classifyError(err)
 			}
 			return map[string]any{
 				"source_url": resp.URL,
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/tools/ocr"
+	"github.com/originaleric/digeino/tools/research"
+)
+
+// ocrCodes maps OCR tool error codes onto gateway error codes.
+var ocrCodes = map[string]string{
+	ocr.CodeInvalidInput:    protocol.CodeInvalidInput,
+	ocr.CodeImageTooLarge:   protocol.CodeInvalidInput,
+	ocr.CodeMimeNotAllowed:  protocol.CodeInvalidInput,
+	ocr.CodePathNotAllowed:  protocol.CodeToolNotAllowed,
+	ocr.CodeURLNotAllowed:   protocol.CodeDomainNotAllowed,
+	ocr.CodeConfigMissing:   protocol.CodeConfigMissing,
+	ocr.CodeDownloadError:   protocol.CodeNavigationFailed,
+	ocr.CodeProviderError:   protocol.CodeProviderError,
+	ocr.CodeProviderTimeout: protocol.CodeTimeout,
+}
+
+// classifyError converts errors from legacy tools into typed *protocol.ToolError.
+// Unrecognized errors are returned unchanged and become TOOL_EXEC_FAILED in the runtime.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := protocol.AsToolError(err); ok {
+		return err
+	}
+	var oe *ocr.OCRError
+	if errors.As(err, &oe) {
+		code, ok := ocrCodes[oe.Code]
+		if !ok {
+			code = protocol.CodeToolExecFailed
+		}
+		return protocol.WrapToolError(code, err).WithDetail("ocr_code", oe.Code)
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return protocol.WrapToolError(protocol.CodeTimeout, err)
+	case errors.Is(err, research.ErrLoginRequired):
+		return protocol.WrapToolError(protocol.CodeLoginRequired, err)
+	case errors.Is(err, research.ErrSelectorNotFound):
+		return protocol.WrapToolError(protocol.CodeSelectorNotFound, err)
+	case errors.Is(err, research.ErrNavigationFailed):
+		return protocol.WrapToolError(protocol.CodeNavigationFailed, err)
+	}
+	return err
+}
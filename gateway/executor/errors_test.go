@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/tools/ocr"
+	"github.com/originaleric/digeino/tools/research"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		err  error
+		code string
+	}{
+		{&ocr.OCRError{Code: ocr.CodeProviderTimeout, Message: "slow"}, protocol.CodeTimeout},
+		{&ocr.OCRError{Code: ocr.CodeURLNotAllowed, Message: "blocked"}, protocol.CodeDomainNotAllowed},
+		{fmt.Errorf("页面导航失败: %w", research.ErrNavigationFailed), protocol.CodeNavigationFailed},
+		{fmt.Errorf("等待选择器失败: %w", research.ErrSelectorNotFound), protocol.CodeSelectorNotFound},
+		{fmt.Errorf("跳转登录页: %w", research.ErrLoginRequired), protocol.CodeLoginRequired},
+		{fmt.Errorf("navigate: %w", context.DeadlineExceeded), protocol.CodeTimeout},
+	}
+	for _, tc := range cases {
+		te, ok := protocol.AsToolError(classifyError(tc.err))
+		if !ok || te.Code != tc.code {
+			t.Fatalf("%v: expected %s, got %+v", tc.err, tc.code, te)
+		}
+		if !errors.Is(te, tc.err) && te.Unwrap() != tc.err {
+			t.Fatalf("%v: cause lost", tc.err)
+		}
+	}
+	plain := errors.New("boom")
+	if classifyError(plain) != plain {
+		t.Fatal("unrecognized errors should pass through")
+	}
+}
@@ -37,7 +37,7 @@ func FileReadEntry(allowedPaths []string) registry.Entry {
 			}
 			resp, err := research.ReadFile(ctx, &in)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, classifyError(err)
 			}
 			return map[string]any{
 				"path":    in.Path,
@@ -2,7 +2,6 @@ package executor
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,10 +13,10 @@ import (
 func decodeInput[T any](call *protocol.ToolCall) (T, error) {
 	var in T
 	if call == nil || len(call.Input) == 0 {
-		return in, fmt.Errorf("%s: empty input", policy.CodeInvalidInput)
+		return in, protocol.NewToolError(policy.CodeInvalidInput, "empty input")
 	}
 	if err := json.Unmarshal(call.Input, &in); err != nil {
-		return in, fmt.Errorf("%s: %w", policy.CodeInvalidInput, err)
+		return in, protocol.WrapToolError(policy.CodeInvalidInput, err)
 	}
 	return in, nil
 }
@@ -39,15 +38,15 @@ func validateCookieDomain(domain string, call *protocol.ToolCall, configDomains
 func validateReadPath(path string, allowedPrefixes []string) error {
 	path = strings.TrimSpace(path)
 	if path == "" {
-		return fmt.Errorf("%s: path is required", policy.CodeInvalidInput)
+		return protocol.NewToolError(policy.CodeInvalidInput, "path is required")
 	}
 	abs, err := filepath.Abs(path)
 	if err != nil {
-		return fmt.Errorf("%s: %w", policy.CodeInvalidInput, err)
+		return protocol.WrapToolError(policy.CodeInvalidInput, err)
 	}
 	clean := filepath.Clean(abs)
 	if len(allowedPrefixes) == 0 {
-		return fmt.Errorf("%s: file read is disabled without Gateway.AllowedReadPaths", policy.CodeToolNotAllowed)
+		return protocol.NewToolError(policy.CodeToolNotAllowed, "file read is disabled without Gateway.AllowedReadPaths")
 	}
 	for _, prefix := range allowedPrefixes {
 		prefix = strings.TrimSpace(prefix)
@@ -63,5 +62,5 @@ func validateReadPath(path string, allowedPrefixes []string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("%s: path %q is not under allowed prefixes", policy.CodeToolNotAllowed, path)
+	return protocol.NewToolError(policy.CodeToolNotAllowed, "path %q is not under allowed prefixes", path)
 }
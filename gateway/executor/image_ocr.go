@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/usage"
+	"github.com/originaleric/digeino/tools/ocr"
+)
+
+const ToolImageOCR = "image.ocr"
+
+// ImageOCREntry returns registry entry for image.ocr.
+func ImageOCREntry() registry.Entry {
+	return registry.Entry{
+		Descriptor: protocol.ToolDescriptor{
+			Name:        ToolImageOCR,
+			Description: "Recognize text in an image by URL, base64 or allowed local path (Tools.OCR).",
+			InputSchema: registry.MustSchema(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"image_url":     map[string]string{"type": "string"},
+					"image_base64":  map[string]string{"type": "string"},
+					"file_path":     map[string]string{"type": "string"},
+					"mime_type":     map[string]string{"type": "string"},
+					"task":          map[string]any{"type": "string", "enum": []string{"plain_text", "layout", "table", "receipt", "form"}},
+					"language":      map[string]string{"type": "string"},
+					"return_bbox":   map[string]string{"type": "boolean"},
+					"return_layout": map[string]string{"type": "boolean"},
+				},
+			}),
+			OutputSchema: registry.MustSchema(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"text":     map[string]string{"type": "string"},
+					"blocks":   map[string]string{"type": "array"},
+					"provider": map[string]string{"type": "string"},
+					"model":    map[string]string{"type": "string"},
+				},
+			}),
+			Capabilities: []string{"ocr"},
+			Risk:         "network",
+		},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			in, err := decodeInput[ocr.OCRRequest](call)
+			if err != nil {
+				return nil, nil, err
+			}
+			meter := usage.FromContext(ctx)
+			meter.AddProviderRequest()
+			resp, err := ocr.ImageOCR(ctx, &in)
+			if err != nil {
+				return nil, nil, classifyError(err)
+			}
+			if resp.Usage != nil {
+				meter.AddTokens(int64(resp.Usage.InputTokens), int64(resp.Usage.OutputTokens))
+			}
+			out := map[string]any{
+				"text":     resp.Text,
+				"provider": resp.Provider,
+				"model":    resp.Model,
+			}
+			if len(resp.Blocks) > 0 {
+				out["blocks"] = resp.Blocks
+			}
+			if len(resp.Tables) > 0 {
+				out["tables"] = resp.Tables
+			}
+			if resp.Confidence > 0 {
+				out["confidence"] = resp.Confidence
+			}
+			return out, nil, nil
+		},
+	}
+}
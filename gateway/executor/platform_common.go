@@ -7,7 +7,9 @@ import (
 	"github.com/originaleric/digeino/gateway/policy"
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/usage"
 	"github.com/originaleric/digeino/tools/platform"
+	"github.com/originaleric/digeino/tools/research"
 )
 
 type platformReadInput struct {
@@ -87,10 +89,17 @@ func platformReadEntry(
 			Capabilities: capabilities,
 			Risk:         "network",
 		},
-		Handler: platformReadHandler(defaultDomains, configDomains, artStore, read),
+		Handler:   platformReadHandler(defaultDomains, configDomains, artStore, read),
+		Cacheable: platformCacheable,
 	}
 }
 
+// platformCacheable allows caching anonymous reads; cookie reads depend on the local session.
+func platformCacheable(call *protocol.ToolCall) bool {
+	in, err := decodeInput[platformReadInput](call)
+	return err == nil && in.UseCookieDomain == ""
+}
+
 func platformReadHandler(
 	defaultDomains, configDomains []string,
 	artStore artifact.Store,
@@ -108,13 +117,15 @@ func platformReadHandler(
 			return nil, nil, err
 		}
 
-		content, err := read(ctx, in.toPlatformReadInput())
+		meter := usage.FromContext(ctx)
+		content, err := read(research.WithNavigationObserver(ctx, meter.AddPage), in.toPlatformReadInput())
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, classifyError(err)
 		}
 		out := platform.ApplyFormats(content, in.Format)
 		var artifacts []protocol.Artifact
 		if content.ScreenshotBase64 != "" {
+			meter.AddScreenshot()
 			artID := call.ID + "_screenshot"
 			if artStore != nil {
 				art, err := artifact.PutBase64PNG(ctx, artStore, artID, content.ScreenshotBase64)
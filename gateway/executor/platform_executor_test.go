@@ -3,9 +3,11 @@ package executor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/usage"
 	"github.com/originaleric/digeino/tools/platform"
 )
 
@@ -27,6 +29,24 @@ func TestPlatformReadInvalidURL(t *testing.T) {
 	}
 }
 
+func TestPlatformReadFailureBeforeNavigationCountsNoPage(t *testing.T) {
+	t.Parallel()
+	read := func(context.Context, platform.ReadInput) (*platform.Content, error) {
+		return nil, errors.New("browser unavailable")
+	}
+	handler := platformReadHandler([]string{"example.com"}, nil, nil, read)
+	call := &protocol.ToolCall{ID: "call_3", Input: json.RawMessage(`{"url":"https://example.com/a"}`)}
+	meter := &usage.Meter{}
+	if _, _, err := handler(usage.WithMeter(context.Background(), meter), call); err == nil {
+		t.Fatal("expected read error")
+	}
+	var u protocol.Usage
+	meter.Fill(&u)
+	if u.PagesOpened != 0 {
+		t.Fatalf("pages opened = %d, want 0", u.PagesOpened)
+	}
+}
+
 func TestPlatformReadInvalidInput(t *testing.T) {
 	t.Parallel()
 	entry := DouyinVideoReadEntry(nil, nil)
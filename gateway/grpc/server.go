@@ -0,0 +1,158 @@
+package grpcgw
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/originaleric/digeino/gateway/artifact"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/protocol/pb"
+	"github.com/originaleric/digeino/gateway/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// artifactChunkSize bounds each GetArtifact message.
+const artifactChunkSize = 64 << 10
+
+// Server is the gRPC Tool Gateway for DigEino. It shares the runtime (and so audit,
+// limits and cache) with the HTTP gateway and accepts the same auth token.
+type Server struct {
+	pb.UnimplementedToolGatewayServer
+
+	rt        *runtime.Runtime
+	artStore  artifact.Store
+	authToken string
+}
+
+// NewServer creates a gRPC gateway server.
+func NewServer(rt *runtime.Runtime, artStore artifact.Store, authToken string) *Server {
+	return &Server{rt: rt, artStore: artStore, authToken: strings.TrimSpace(authToken)}
+}
+
+// GRPCServer returns a grpc.Server with auth interceptors and the ToolGateway service registered.
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.unaryAuth),
+		grpc.ChainStreamInterceptor(s.streamAuth),
+	)
+	gs := grpc.NewServer(opts...)
+	pb.RegisterToolGatewayServer(gs, s)
+	return gs
+}
+
+// ListenAndServe starts the gRPC server.
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.GRPCServer().Serve(lis)
+}
+
+func (s *Server) GetManifest(context.Context, *pb.GetManifestRequest) (*pb.ToolManifest, error) {
+	m := s.rt.Manifest()
+	return protocol.ManifestToPB(&m), nil
+}
+
+func (s *Server) Call(ctx context.Context, req *pb.ToolCall) (*pb.ToolResult, error) {
+	result := s.rt.Execute(ctx, toolCall(req))
+	out, err := protocol.ToolResultToPB(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return out, nil
+}
+
+func (s *Server) CallStream(req *pb.ToolCall, stream grpc.ServerStreamingServer[pb.CallEvent]) error {
+	// Execute reports progress synchronously, so Send is never called concurrently.
+	progress := func(p runtime.Progress) {
+		_ = stream.Send(&pb.CallEvent{Event: &pb.CallEvent_Progress{Progress: &pb.Progress{
+			CallId:    p.CallID,
+			Stage:     p.Stage,
+			Attempt:   int64(p.Attempt),
+			Message:   p.Message,
+			ElapsedMs: p.Elapsed.Milliseconds(),
+		}}})
+	}
+	ctx := runtime.WithProgress(stream.Context(), progress)
+	result := s.rt.Execute(ctx, toolCall(req))
+	out, err := protocol.ToolResultToPB(result)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.Send(&pb.CallEvent{Event: &pb.CallEvent_Result{Result: out}})
+}
+
+func (s *Server) GetArtifact(req *pb.GetArtifactRequest, stream grpc.ServerStreamingServer[pb.ArtifactChunk]) error {
+	if s.artStore == nil {
+		return status.Error(codes.NotFound, "artifact store disabled")
+	}
+	id := strings.TrimPrefix(req.GetId(), "digeino-artifact://")
+	data, contentType, err := s.artStore.Get(stream.Context(), id)
+	if err != nil {
+		return status.Error(codes.NotFound, "artifact not found")
+	}
+	first := true
+	for len(data) > 0 || first {
+		n := min(len(data), artifactChunkSize)
+		chunk := &pb.ArtifactChunk{Data: data[:n]}
+		if first {
+			chunk.Id = id
+			chunk.ContentType = contentType
+			first = false
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func toolCall(req *pb.ToolCall) *protocol.ToolCall {
+	call := protocol.ToolCallFromPB(req)
+	if call == nil {
+		call = &protocol.ToolCall{}
+	}
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
+	}
+	return call
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authorize accepts "authorization: Bearer <token>" or "x-digeino-token" metadata, like the HTTP gateway.
+func (s *Server) authorize(ctx context.Context) error {
+	if s.authToken == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if strings.HasPrefix(v, "Bearer ") && strings.TrimSpace(strings.TrimPrefix(v, "Bearer ")) == s.authToken {
+			return nil
+		}
+	}
+	for _, v := range md.Get("x-digeino-token") {
+		if v == s.authToken {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
@@ -0,0 +1,125 @@
+package grpcgw
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/artifact"
+	"github.com/originaleric/digeino/gateway/client"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func startServer(t *testing.T, srv *Server, token string) *client.GRPCClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := srv.GRPCServer()
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	c, err := client.NewGRPC("passthrough:///bufnet", token,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestGRPCCallAndStream(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	var calls atomic.Int32
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "flaky.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			if calls.Add(1)%2 == 1 {
+				return nil, nil, protocol.NewToolError(protocol.CodeNavigationFailed, "net::ERR_TIMED_OUT")
+			}
+			return map[string]any{"ok": true}, nil, nil
+		},
+	})
+	rt := runtime.New(reg, runtime.Options{InstanceID: "test", ToolPolicies: map[string]runtime.ToolPolicy{
+		"flaky.tool": {Retry: runtime.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}},
+	}})
+	c := startServer(t, NewServer(rt, nil, "secret"), "secret")
+	ctx := context.Background()
+
+	m, err := c.Manifest(ctx)
+	if err != nil || len(m.Tools) != 1 || m.InstanceID != "test" {
+		t.Fatalf("manifest: %+v err=%v", m, err)
+	}
+
+	res, err := c.Call(ctx, protocol.ToolCall{ID: "c1", Tool: "flaky.tool"})
+	if err != nil || res.Status != "success" || res.Usage.Attempts != 2 {
+		t.Fatalf("call: %+v err=%v", res, err)
+	}
+
+	var stages []string
+	res, err = c.CallStream(ctx, protocol.ToolCall{ID: "c2", Tool: "flaky.tool"}, func(p client.Progress) {
+		stages = append(stages, p.Stage)
+	})
+	if err != nil || res.Status != "success" {
+		t.Fatalf("stream: %+v err=%v", res, err)
+	}
+	want := []string{runtime.StageQueued, runtime.StageRunning, runtime.StageRetrying, runtime.StageRunning}
+	if len(stages) != len(want) {
+		t.Fatalf("expected stages %v, got %v", want, stages)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Fatalf("expected stages %v, got %v", want, stages)
+		}
+	}
+
+	res, err = c.Call(ctx, protocol.ToolCall{ID: "c3", Tool: "missing.tool"})
+	if te, ok := protocol.AsToolError(err); !ok || te.Code != protocol.CodeToolNotAllowed || res.Status != "error" {
+		t.Fatalf("expected tool error, got %+v err=%v", res, err)
+	}
+}
+
+func TestGRPCAuth(t *testing.T) {
+	t.Parallel()
+	rt := runtime.New(registry.New(), runtime.Options{})
+	c := startServer(t, NewServer(rt, nil, "secret"), "wrong")
+	_, err := c.Manifest(context.Background())
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestGRPCGetArtifact(t *testing.T) {
+	t.Parallel()
+	store, err := artifact.NewDiskStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, artifactChunkSize*2+10)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	if _, err := store.Put(context.Background(), "art_1", "image/png", "page.png", payload); err != nil {
+		t.Fatal(err)
+	}
+	rt := runtime.New(registry.New(), runtime.Options{})
+	c := startServer(t, NewServer(rt, store, ""), "")
+
+	data, contentType, err := c.FetchArtifact(context.Background(), "digeino-artifact://art_1")
+	if err != nil || contentType != "image/png" || len(data) != len(payload) {
+		t.Fatalf("artifact: len=%d type=%s err=%v", len(data), contentType, err)
+	}
+	if _, _, err := c.FetchArtifact(context.Background(), "missing"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
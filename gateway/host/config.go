@@ -0,0 +1,111 @@
+package host
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+var (
+	ErrNoConfigKey         = errors.New("host: no config signing key (Options.ConfigKey)")
+	ErrUnknownCollector    = errors.New("host: collector not connected")
+	ErrRemoteConfigRefused = errors.New("host: collector does not accept remote config")
+)
+
+// PushConfig signs cfg with Options.ConfigKey and sends it to instanceID, or to every connected
+// collector that negotiated remote_config when instanceID is empty. It returns the config version;
+// collectors acknowledge asynchronously, see CollectorInfo.ConfigVersion / ConfigError.
+func (h *Host) PushConfig(instanceID string, cfg protocol.RemoteConfig) (int64, error) {
+	if h.opts.ConfigKey == nil {
+		return 0, ErrNoConfigKey
+	}
+	h.mu.Lock()
+	var targets []*collectorConn
+	if instanceID != "" {
+		c := h.collectors[instanceID]
+		if c == nil {
+			h.mu.Unlock()
+			return 0, ErrUnknownCollector
+		}
+		if !protocol.HasFeature(c.features, protocol.FeatureRemoteConfig) {
+			h.mu.Unlock()
+			return 0, ErrRemoteConfigRefused
+		}
+		targets = append(targets, c)
+	} else {
+		for _, c := range h.collectors {
+			if protocol.HasFeature(c.features, protocol.FeatureRemoteConfig) {
+				targets = append(targets, c)
+			}
+		}
+	}
+	// versions only need to grow per collector; wall-clock milliseconds keep them growing across
+	// host restarts
+	h.configVersion = max(h.configVersion+1, time.Now().UnixMilli())
+	version := h.configVersion
+	h.mu.Unlock()
+
+	for _, c := range targets {
+		u, err := protocol.SignConfigUpdate(h.opts.ConfigKey, protocol.ConfigPayload{
+			Version:    version,
+			InstanceID: c.instanceID,
+			IssuedAt:   time.Now().UTC(),
+			Config:     cfg,
+		})
+		if err != nil {
+			return 0, err
+		}
+		c.send(protocol.NewConfigUpdate(u))
+	}
+	h.log.Printf("[host] config version %d pushed to %d collectors", version, len(targets))
+	return version, nil
+}
+
+// configAcked records a collector_config_ack.
+func (h *Host) configAcked(c *collectorConn, env protocol.Envelope) {
+	h.mu.Lock()
+	c.configVersion = env.ConfigVersion
+	c.configError = ""
+	if !env.OK {
+		c.configError = env.Message
+	}
+	h.mu.Unlock()
+	if !env.OK {
+		h.log.Printf("[host] collector %s refused config: %s (version %d in effect)", c.instanceID, env.Message, env.ConfigVersion)
+		return
+	}
+	h.log.Printf("[host] collector %s applied config version %d", c.instanceID, env.ConfigVersion)
+}
+
+// handlePushConfig serves POST /collectors/{id}/config; id "*" pushes to every collector.
+func (h *Host) handlePushConfig(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read body"})
+		return
+	}
+	var cfg protocol.RemoteConfig
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	id := r.PathValue("id")
+	if id == "*" {
+		id = ""
+	}
+	version, err := h.PushConfig(id, cfg)
+	switch {
+	case errors.Is(err, ErrUnknownCollector):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrNoConfigKey), errors.Is(err, ErrRemoteConfigRefused):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]any{"version": version})
+	}
+}
@@ -0,0 +1,339 @@
+package host
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin:       func(r *http.Request) bool { return true },
+	EnableCompression: true,
+}
+
+const statusDraining = "draining"
+
+// sendQueueSize bounds the frames waiting for a collector's socket; a collector that falls this
+// far behind is disconnected and its calls redelivered.
+const sendQueueSize = 256
+
+// CollectorInfo describes a connected collector.
+type CollectorInfo struct {
+	InstanceID      string            `json:"instance_id"`
+	SessionID       string            `json:"session_id"`
+	Runtime         string            `json:"runtime,omitempty"`
+	RuntimeVersion  string            `json:"runtime_version,omitempty"`
+	ProtocolVersion int               `json:"protocol_version"`
+	Features        []string          `json:"features"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Tools           []string          `json:"tools"`
+	Status          string            `json:"status,omitempty"` // last instance_status: online | busy | draining
+	ActiveCalls     int               `json:"active_calls"`
+	QueuedCalls     int               `json:"queued_calls"`
+	Assigned        int               `json:"assigned"` // calls from this host awaiting a result
+	ConnectedAt     time.Time         `json:"connected_at"`
+	LastSeen        time.Time         `json:"last_seen"`
+	ConfigVersion   int64             `json:"config_version,omitempty"` // remote config in effect, from hello / collector_config_ack
+	ConfigError     string            `json:"config_error,omitempty"`   // why the last pushed config was refused
+}
+
+// collectorConn is one collector session. Fields other than conn and out are guarded by Host.mu.
+type collectorConn struct {
+	conn *websocket.Conn
+	out  chan protocol.Envelope
+	done chan struct{}
+
+	instanceID     string
+	sessionID      string
+	runtime        string
+	runtimeVersion string
+	protoVersion   int
+	features       []string
+	labels         map[string]string
+	tools          map[string]bool // nil until the manifest arrives
+	manifest       []protocol.ToolDescriptor
+	status         string
+	active, queued int
+	connectedAt    time.Time
+	lastSeen       time.Time
+	configVersion  int64
+	configError    string
+
+	assigned map[string]*pendingCall // calls leased to this collector
+}
+
+func (c *collectorConn) info() CollectorInfo {
+	tools := make([]string, 0, len(c.tools))
+	for t := range c.tools {
+		tools = append(tools, t)
+	}
+	slices.Sort(tools)
+	return CollectorInfo{
+		InstanceID:      c.instanceID,
+		SessionID:       c.sessionID,
+		Runtime:         c.runtime,
+		RuntimeVersion:  c.runtimeVersion,
+		ProtocolVersion: c.protoVersion,
+		Features:        c.features,
+		Labels:          c.labels,
+		Tools:           tools,
+		Status:          c.status,
+		ActiveCalls:     c.active,
+		QueuedCalls:     c.queued,
+		Assigned:        len(c.assigned),
+		ConnectedAt:     c.connectedAt,
+		LastSeen:        c.lastSeen,
+		ConfigVersion:   c.configVersion,
+		ConfigError:     c.configError,
+	}
+}
+
+// canRun reports whether call may be routed to c.
+func (c *collectorConn) canRun(call protocol.ToolCall) bool {
+	return c.status != statusDraining && c.tools[call.Tool] && call.Route.Matches(c.labels) &&
+		(protocol.HasFeature(c.features, protocol.FeaturePush) || protocol.HasFeature(c.features, protocol.FeaturePull))
+}
+
+// load is the routing weight: the collector's own report covers calls from other hosts, the
+// assigned count covers calls it has not reported yet.
+func (c *collectorConn) load() int {
+	return max(len(c.assigned), c.active+c.queued)
+}
+
+// send queues env for the writer without blocking; a full queue drops the connection.
+func (c *collectorConn) send(env protocol.Envelope) {
+	select {
+	case c.out <- env:
+	case <-c.done:
+	default:
+		_ = c.conn.Close()
+	}
+}
+
+// writeLoop owns the socket's write side: protobuf binary frames when negotiated, JSON otherwise.
+func (c *collectorConn) writeLoop() {
+	for {
+		select {
+		case env := <-c.out:
+			if err := writeFrame(c.conn, env, protocol.HasFeature(c.features, protocol.FeatureBinary)); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func writeFrame(conn *websocket.Conn, env protocol.Envelope, binary bool) error {
+	msgType, data := websocket.TextMessage, []byte(nil)
+	var err error
+	if binary {
+		msgType = websocket.BinaryMessage
+		data, err = env.EncodeBinary()
+	} else {
+		data, err = env.Encode()
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	return conn.WriteMessage(msgType, data)
+}
+
+func (h *Host) handleWS(w http.ResponseWriter, r *http.Request) {
+	if !tokenOK(r, h.opts.Token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.EnableWriteCompression(false)
+
+	c, err := h.accept(r, conn)
+	if err != nil || c == nil {
+		return
+	}
+	go c.writeLoop()
+	defer h.disconnect(c)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(h.opts.IdleTimeout))
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		env, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+		if err != nil {
+			continue
+		}
+		h.handleEnvelope(c, env)
+	}
+}
+
+// accept runs the hello exchange (always JSON) and registers the collector. A collector that
+// reconnects under the same instance ID replaces its previous session.
+func (h *Host) accept(r *http.Request, conn *websocket.Conn) (*collectorConn, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	msgType, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	hello, err := protocol.DecodeFrame(msgType == websocket.BinaryMessage, data)
+	if err != nil || hello.Type != protocol.TypeCollectorHello || hello.InstanceID == "" {
+		_ = writeFrame(conn, protocol.NewWireError(protocol.CodeInvalidInput, "expected collector_hello with instance_id"), false)
+		return nil, err
+	}
+	ack := protocol.NegotiateHello(hello, uuid.NewString(), h.opts.Features, h.opts.MinProtocolVersion)
+	if ack.OK && h.opts.AuthorizeCollector != nil {
+		if err := h.opts.AuthorizeCollector(r, hello); err != nil {
+			ack = protocol.Envelope{Type: protocol.TypeCollectorHelloAck, Message: err.Error()}
+		}
+	}
+	if !ack.OK {
+		h.log.Printf("[host] rejected collector %s: %s", hello.InstanceID, ack.Message)
+		_ = writeFrame(conn, ack, false)
+		return nil, nil
+	}
+	now := time.Now()
+	c := &collectorConn{
+		conn:           conn,
+		out:            make(chan protocol.Envelope, sendQueueSize),
+		done:           make(chan struct{}),
+		instanceID:     hello.InstanceID,
+		sessionID:      ack.SessionID,
+		runtime:        hello.Runtime,
+		runtimeVersion: hello.RuntimeVersion,
+		protoVersion:   protocol.PeerVersion(hello),
+		features:       ack.Capabilities,
+		labels:         hello.Labels,
+		connectedAt:    now,
+		lastSeen:       now,
+		configVersion:  hello.ConfigVersion,
+		assigned:       make(map[string]*pendingCall),
+	}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrClosed
+	}
+	old := h.collectors[c.instanceID]
+	h.collectors[c.instanceID] = c
+	// leases belong to the instance, not the session: results resent from the collector's outbox
+	// still complete them, and an unanswered lease expires as usual
+	for id, p := range h.calls {
+		if p.result == nil && p.owner == c.instanceID {
+			c.assigned[id] = p
+		}
+	}
+	h.mu.Unlock()
+	if old != nil {
+		h.log.Printf("[host] collector %s reconnected; closing previous session", c.instanceID)
+		_ = old.conn.Close()
+	}
+	if err := writeFrame(conn, ack, false); err != nil {
+		h.disconnect(c)
+		return nil, err
+	}
+	conn.EnableWriteCompression(protocol.HasFeature(c.features, protocol.FeatureCompression))
+	h.log.Printf("[host] collector %s connected session=%s features=%s",
+		c.instanceID, c.sessionID, strings.Join(c.features, ","))
+	return c, nil
+}
+
+func (h *Host) handleEnvelope(c *collectorConn, env protocol.Envelope) {
+	h.mu.Lock()
+	c.lastSeen = time.Now()
+	h.mu.Unlock()
+	switch env.Type {
+	case protocol.TypeCollectorManifest:
+		tools := make(map[string]bool)
+		if env.Manifest != nil {
+			for _, t := range env.Manifest.Tools {
+				tools[t.Name] = true
+			}
+		}
+		h.mu.Lock()
+		c.tools = tools
+		if env.Manifest != nil {
+			c.manifest = env.Manifest.Tools
+		}
+		h.dispatchLocked()
+		h.mu.Unlock()
+	case protocol.TypeInstanceStatus:
+		h.mu.Lock()
+		c.status, c.active, c.queued = env.Status, env.ActiveCalls, env.QueuedCalls
+		if env.Labels != nil {
+			c.labels = env.Labels
+		}
+		// a draining collector leases nothing new; running calls finish or come back rejected
+		h.dispatchLocked()
+		h.mu.Unlock()
+	case protocol.TypePullTasks:
+		h.mu.Lock()
+		var calls []protocol.ToolCall
+		if c.tools != nil && c.status != statusDraining && !h.closed {
+			for _, p := range h.leaseLocked(c, max(env.Limit, 1)) {
+				calls = append(calls, h.withRemainingTimeout(p))
+			}
+		}
+		h.mu.Unlock()
+		c.send(protocol.Envelope{Type: protocol.TypePullTasksAck, Calls: calls})
+	case protocol.TypeToolResult:
+		if env.ToolResult == nil {
+			return
+		}
+		if protocol.HasFeature(c.features, protocol.FeatureResultAck) {
+			c.send(protocol.NewToolResultAck(env.ToolResult.ID))
+		}
+		h.complete(c, *env.ToolResult)
+	case protocol.TypeCollectorConfigAck:
+		h.configAcked(c, env)
+	case protocol.TypePing:
+		c.send(protocol.Envelope{Type: protocol.TypePong})
+	}
+}
+
+// disconnect unregisters c and releases the leases it had not answered. A session already
+// replaced by a reconnect keeps its leases on the instance.
+func (h *Host) disconnect(c *collectorConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	close(c.done)
+	if h.collectors[c.instanceID] != c {
+		return
+	}
+	h.log.Printf("[host] collector %s disconnected", c.instanceID)
+	for _, p := range c.assigned {
+		if p.result == nil && p.owner == c.instanceID {
+			h.releaseLocked(p, "collector "+c.instanceID+" disconnected")
+		}
+	}
+	delete(h.collectors, c.instanceID)
+	h.dispatchLocked()
+}
+
+func tokenOK(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got := r.Header.Get("X-Digeino-Token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
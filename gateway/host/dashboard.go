@@ -0,0 +1,71 @@
+package host
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/originaleric/digeino/gateway/dashboard"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// dashboardSource exposes the host to the web dashboard.
+type dashboardSource struct{ h *Host }
+
+func (s dashboardSource) Collectors() []dashboard.Collector {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	out := make([]dashboard.Collector, 0, len(s.h.collectors))
+	for _, c := range s.h.collectors {
+		out = append(out, dashboard.Collector{
+			InstanceID:  c.instanceID,
+			Runtime:     c.runtime,
+			Status:      c.status,
+			ActiveCalls: c.active,
+			QueuedCalls: c.queued,
+			Features:    c.features,
+			Labels:      c.labels,
+			Tools:       c.manifest,
+			ConnectedAt: c.connectedAt,
+			LastSeen:    c.lastSeen,
+		})
+	}
+	slices.SortFunc(out, func(a, b dashboard.Collector) int { return cmp.Compare(a.InstanceID, b.InstanceID) })
+	return out
+}
+
+func (s dashboardSource) Tasks() []dashboard.Task {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	out := []dashboard.Task{}
+	for _, p := range s.h.calls {
+		if p.result != nil {
+			continue
+		}
+		state := dashboard.TaskQueued
+		if p.owner != "" {
+			state = dashboard.TaskRunning
+		}
+		out = append(out, dashboard.Task{
+			ID:          p.call.ID,
+			Tool:        p.call.Tool,
+			State:       state,
+			InstanceID:  p.owner,
+			Deliveries:  p.deliveries,
+			Input:       p.call.Input,
+			SubmittedAt: p.submitted,
+		})
+	}
+	slices.SortFunc(out, func(a, b dashboard.Task) int { return a.SubmittedAt.Compare(b.SubmittedAt) })
+	return out
+}
+
+func (s dashboardSource) Submit(_ context.Context, call protocol.ToolCall) (string, error) {
+	id, err := s.h.Submit(call)
+	if errors.Is(err, ErrInvalidCall) {
+		return "", fmt.Errorf("%w: %v", dashboard.ErrInvalidCall, err)
+	}
+	return id, err
+}
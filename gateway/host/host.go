@@ -0,0 +1,549 @@
+// Package host is an embeddable host for DigEino collectors: it accepts collector WebSocket
+// connections, routes tool calls to them and correlates the results.
+package host
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/originaleric/digeino/gateway/dashboard"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/taskqueue"
+)
+
+// Options configures a Host.
+type Options struct {
+	WSPath string // collector WebSocket path, default /digeino/v1/collector/ws
+	// Token is required from collectors (Bearer or X-Digeino-Token); empty allows any collector.
+	Token string
+	// APIToken guards the HTTP submission API; empty falls back to Token.
+	APIToken string
+	// AuthorizeCollector, when set, vets each hello after token auth (e.g. binding tokens to
+	// instance IDs); a non-nil error rejects the connection.
+	AuthorizeCollector func(r *http.Request, hello protocol.Envelope) error
+	MinProtocolVersion int           // 0 = protocol minimum
+	Features           []string      // offered in hello_ack; nil = protocol.SupportedFeatures()
+	CallTimeout        time.Duration // for calls without policy.timeout_ms, default 2m
+	// Queue stores calls until a collector completes them; every push or pull_tasks delivery is
+	// a lease. nil uses an in-memory queue (3 attempts); taskqueue.OpenFileQueue survives restarts.
+	Queue taskqueue.Queue
+	// LeaseTimeout is how long a delivered call may go without a result before it is
+	// redelivered; it should exceed the slowest tool. Default 5m.
+	LeaseTimeout time.Duration
+	// IdleTimeout drops collectors that send nothing (status heartbeats included) for this long;
+	// default 90s.
+	IdleTimeout time.Duration
+	// ResultTTL keeps finished results of submitted calls for Wait and GET /calls/{id}; default 10m.
+	ResultTTL time.Duration
+	// ArtifactBaseURL lets the dashboard show collector-local artifacts inline (see
+	// dashboard.Options).
+	ArtifactBaseURL string
+	// ConfigKey signs collector_config_update (PushConfig); collectors trust its public key via
+	// Collector.RemoteConfig.TrustedKeys. nil disables PushConfig.
+	ConfigKey ed25519.PrivateKey
+	Logger    *log.Logger
+}
+
+// Call states reported by Host.Status.
+const (
+	StateQueued    = "queued"    // in the queue, waiting for a matching collector or a pull_tasks
+	StateDelivered = "delivered" // leased to a collector, result pending
+	StateDone      = "done"
+)
+
+// sweepInterval is how often expired leases are collected.
+const sweepInterval = time.Second
+
+var (
+	ErrDuplicateCall = errors.New("host: call id already in flight")
+	ErrUnknownCall   = errors.New("host: unknown call id")
+	ErrInvalidCall   = errors.New("host: call has no tool")
+	ErrClosed        = errors.New("host: closed")
+)
+
+// CallStatus is a snapshot of a submitted call.
+type CallStatus struct {
+	ID         string               `json:"id"`
+	Tool       string               `json:"tool"`
+	State      string               `json:"state"`
+	InstanceID string               `json:"instance_id,omitempty"` // collector holding the lease
+	Deliveries int                  `json:"deliveries"`
+	Result     *protocol.ToolResult `json:"result,omitempty"`
+}
+
+// Host routes tool calls to connected collectors. Mount Handler on an HTTP server.
+type Host struct {
+	opts Options
+	log  *log.Logger
+	q    taskqueue.Queue
+	stop chan struct{}
+	feed *dashboard.Feed
+
+	mu         sync.Mutex
+	collectors map[string]*collectorConn // by instance ID
+	calls      map[string]*pendingCall
+	closed     bool
+	// configVersion is the last version PushConfig issued.
+	configVersion int64
+}
+
+type pendingCall struct {
+	call       protocol.ToolCall
+	deadline   time.Time
+	owner      string // instance ID holding the lease; "" while queued
+	deliveries int
+	submitted  time.Time
+	timer      *time.Timer
+	result     *protocol.ToolResult
+	done       chan struct{}
+}
+
+func (p *pendingCall) status() CallStatus {
+	st := CallStatus{ID: p.call.ID, Tool: p.call.Tool, InstanceID: p.owner, Deliveries: p.deliveries, Result: p.result}
+	switch {
+	case p.result != nil:
+		st.State = StateDone
+	case p.owner == "":
+		st.State = StateQueued
+	default:
+		st.State = StateDelivered
+	}
+	return st
+}
+
+// New creates a Host. Calls already in Options.Queue (from a previous run) are resumed: their
+// results can be fetched with Status / Wait once a collector completes them.
+func New(opts Options) *Host {
+	if opts.WSPath == "" {
+		opts.WSPath = "/digeino/v1/collector/ws"
+	}
+	if opts.APIToken == "" {
+		opts.APIToken = opts.Token
+	}
+	if opts.CallTimeout <= 0 {
+		opts.CallTimeout = 2 * time.Minute
+	}
+	if opts.LeaseTimeout <= 0 {
+		opts.LeaseTimeout = 5 * time.Minute
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 90 * time.Second
+	}
+	if opts.ResultTTL <= 0 {
+		opts.ResultTTL = 10 * time.Minute
+	}
+	if opts.Queue == nil {
+		opts.Queue = taskqueue.NewMemoryQueue(taskqueue.Options{})
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	h := &Host{
+		opts:       opts,
+		log:        logger,
+		q:          opts.Queue,
+		stop:       make(chan struct{}),
+		feed:       dashboard.NewFeed(0),
+		collectors: make(map[string]*collectorConn),
+		calls:      make(map[string]*pendingCall),
+	}
+	tasks, err := h.q.List(context.Background())
+	if err != nil {
+		h.log.Printf("[host] task queue: %v", err)
+	}
+	for _, t := range tasks {
+		p := &pendingCall{call: t.Call, deadline: t.Deadline, owner: t.Owner, deliveries: t.Attempts,
+			submitted: t.EnqueuedAt, done: make(chan struct{})}
+		if p.deadline.IsZero() {
+			p.deadline = time.Now().Add(opts.CallTimeout)
+		}
+		h.calls[t.ID] = p
+		p.timer = time.AfterFunc(time.Until(p.deadline), func() { h.expire(p) })
+	}
+	if len(tasks) > 0 {
+		h.log.Printf("[host] resumed %d queued calls", len(tasks))
+	}
+	go h.sweepLoop()
+	return h
+}
+
+// Call routes call to a collector and waits for its result. Timeouts and routing failures come
+// back as error results (TIMEOUT, NO_COLLECTOR); the error is non-nil only for calls that could not
+// be submitted or when ctx ends first, in which case the call is canceled on the collector.
+func (h *Host) Call(ctx context.Context, call protocol.ToolCall) (*protocol.ToolResult, error) {
+	id, err := h.Submit(call)
+	if err != nil {
+		return nil, err
+	}
+	res, err := h.Wait(ctx, id)
+	if err != nil {
+		h.Cancel(id)
+	}
+	h.forget(id)
+	return res, err
+}
+
+// Submit queues call without waiting and returns its ID (generated when empty). The call waits
+// for a matching collector until its timeout: policy.timeout_ms, or Options.CallTimeout.
+func (h *Host) Submit(call protocol.ToolCall) (string, error) {
+	if call.Tool == "" {
+		return "", ErrInvalidCall
+	}
+	if call.Type == "" {
+		call.Type = protocol.TypeToolCall
+	}
+	if call.ID == "" {
+		call.ID = "host_" + uuid.NewString()
+	}
+	timeout := time.Duration(call.Policy.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = h.opts.CallTimeout
+	}
+	now := time.Now()
+	p := &pendingCall{call: call, deadline: now.Add(timeout), submitted: now, done: make(chan struct{})}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return "", ErrClosed
+	}
+	if _, ok := h.calls[call.ID]; ok {
+		return "", ErrDuplicateCall
+	}
+	err := h.q.Enqueue(context.Background(), taskqueue.Task{
+		ID:         call.ID,
+		Tenant:     call.Context.TenantID,
+		Call:       call,
+		Deadline:   p.deadline,
+		EnqueuedAt: now,
+	})
+	if errors.Is(err, taskqueue.ErrDuplicate) {
+		return "", ErrDuplicateCall
+	} else if err != nil {
+		return "", err
+	}
+	h.calls[call.ID] = p
+	p.timer = time.AfterFunc(timeout, func() { h.expire(p) })
+	h.dispatchLocked()
+	return call.ID, nil
+}
+
+// Wait blocks until the call finishes or ctx ends; ending ctx does not cancel the call.
+func (h *Host) Wait(ctx context.Context, id string) (*protocol.ToolResult, error) {
+	h.mu.Lock()
+	p, ok := h.calls[id]
+	h.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownCall
+	}
+	select {
+	case <-p.done:
+		return p.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Status returns a snapshot of a submitted call; finished calls are kept for Options.ResultTTL.
+func (h *Host) Status(id string) (CallStatus, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.calls[id]
+	if !ok {
+		return CallStatus{}, false
+	}
+	return p.status(), true
+}
+
+// Cancel finishes a pending call with CANCELED, removes it from the queue and asks its collector
+// to stop it.
+func (h *Host) Cancel(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.calls[id]
+	if !ok || p.result != nil {
+		return false
+	}
+	h.dequeueLocked(id)
+	h.stopLocked(p)
+	h.finishLocked(p, errorResult(id, protocol.CodeCanceled, "canceled by host"))
+	return true
+}
+
+// Collectors lists the connected collectors.
+func (h *Host) Collectors() []CollectorInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]CollectorInfo, 0, len(h.collectors))
+	for _, c := range h.collectors {
+		out = append(out, c.info())
+	}
+	slices.SortFunc(out, func(a, b CollectorInfo) int { return cmp.Compare(a.InstanceID, b.InstanceID) })
+	return out
+}
+
+// DeadLetters returns calls that ran out of delivery attempts. Calls that reach their deadline
+// fail with NO_COLLECTOR or TIMEOUT and leave the queue without a dead letter.
+func (h *Host) DeadLetters(ctx context.Context) ([]taskqueue.Task, error) {
+	return h.q.DeadLetters(ctx)
+}
+
+// Close wakes waiters with CANCELED and disconnects all collectors. Queued calls stay in the
+// queue, so a durable queue resumes them on the next New.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.stop)
+	for _, p := range h.calls {
+		if p.result == nil {
+			h.finishLocked(p, errorResult(p.call.ID, protocol.CodeCanceled, "host closed"))
+		}
+	}
+	conns := make([]*collectorConn, 0, len(h.collectors))
+	for _, c := range h.collectors {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+	for _, c := range conns {
+		_ = c.conn.Close()
+	}
+	return nil
+}
+
+// dispatchLocked pushes queued calls to push-capable collectors, least loaded first (ties by
+// instance ID). Pull-only collectors lease calls when they send pull_tasks.
+func (h *Host) dispatchLocked() {
+	if h.closed {
+		return
+	}
+	var candidates []*collectorConn
+	for _, c := range h.collectors {
+		if c.tools != nil && c.status != statusDraining && protocol.HasFeature(c.features, protocol.FeaturePush) {
+			candidates = append(candidates, c)
+		}
+	}
+	for len(candidates) > 0 {
+		i := 0
+		for j, c := range candidates {
+			if c.load() < candidates[i].load() || (c.load() == candidates[i].load() && c.instanceID < candidates[i].instanceID) {
+				i = j
+			}
+		}
+		c := candidates[i]
+		leased := h.leaseLocked(c, 1)
+		if len(leased) == 0 {
+			candidates = slices.Delete(candidates, i, i+1)
+			continue
+		}
+		call := h.withRemainingTimeout(leased[0])
+		c.send(protocol.Envelope{Type: protocol.TypeToolCall, ToolCall: &call})
+	}
+}
+
+// leaseLocked leases up to limit calls c can run and records c as their owner.
+func (h *Host) leaseLocked(c *collectorConn, limit int) []*pendingCall {
+	tasks, err := h.q.Lease(context.Background(), c.instanceID, limit, h.opts.LeaseTimeout, func(t taskqueue.Task) bool {
+		p := h.calls[t.ID]
+		return p != nil && p.result == nil && c.canRun(t.Call)
+	})
+	if err != nil {
+		h.log.Printf("[host] lease for %s: %v", c.instanceID, err)
+	}
+	out := make([]*pendingCall, 0, len(tasks))
+	for _, t := range tasks {
+		p := h.calls[t.ID]
+		p.owner, p.deliveries = c.instanceID, t.Attempts
+		c.assigned[t.ID] = p
+		out = append(out, p)
+	}
+	return out
+}
+
+// withRemainingTimeout returns the call with policy.timeout_ms reduced to the time left.
+func (h *Host) withRemainingTimeout(p *pendingCall) protocol.ToolCall {
+	call := p.call
+	call.Policy.TimeoutMs = max(int(time.Until(p.deadline).Milliseconds()), 1)
+	return call
+}
+
+// releaseLocked returns p to the queue after its collector disconnected or refused it; a call out
+// of attempts fails with NO_COLLECTOR.
+func (h *Host) releaseLocked(p *pendingCall, reason string) {
+	if c := h.collectors[p.owner]; c != nil {
+		delete(c.assigned, p.call.ID)
+	}
+	owner := p.owner
+	p.owner = ""
+	dead, err := h.q.Release(context.Background(), p.call.ID, reason)
+	switch {
+	case errors.Is(err, taskqueue.ErrNotFound):
+	case err != nil:
+		h.log.Printf("[host] release %s: %v", p.call.ID, err)
+	case dead:
+		h.finishLocked(p, errorResult(p.call.ID, protocol.CodeNoCollector,
+			fmt.Sprintf("%s; gave up after %d deliveries", reason, p.deliveries)))
+	default:
+		h.log.Printf("[host] redeliver %s: %s (was on %s)", p.call.ID, reason, owner)
+	}
+}
+
+// complete records a result reported by c. The first result for a call wins; a collector other
+// than the lease holder (e.g. resending its outbox after a reconnect) may supply it.
+func (h *Host) complete(c *collectorConn, res protocol.ToolResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(c.assigned, res.ID)
+	p, ok := h.calls[res.ID]
+	if !ok || p.result != nil {
+		return // duplicate or unknown: already acked by the caller
+	}
+	if c.status == statusDraining && res.Error != nil && res.Error.Code == protocol.CodeRateLimited {
+		// a draining collector rejects new work; hand it to another instance
+		h.releaseLocked(p, "collector "+c.instanceID+" draining")
+		h.dispatchLocked()
+		return
+	}
+	if p.owner != c.instanceID {
+		h.stopLocked(p)
+	}
+	h.dequeueLocked(res.ID)
+	h.finishLocked(p, &res)
+	h.dispatchLocked()
+}
+
+func (h *Host) expire(p *pendingCall) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expireLocked(p)
+}
+
+// expireLocked fails p at its deadline. Both p's timer and sweep (when the queue drops the task
+// first) end up here, so the result does not depend on which one runs first.
+func (h *Host) expireLocked(p *pendingCall) {
+	if p.result != nil {
+		return
+	}
+	h.dequeueLocked(p.call.ID)
+	h.stopLocked(p)
+	if p.deliveries == 0 {
+		h.finishLocked(p, errorResult(p.call.ID, protocol.CodeNoCollector,
+			fmt.Sprintf("no collector available for %s", p.call.Tool)))
+		return
+	}
+	h.finishLocked(p, errorResult(p.call.ID, protocol.CodeTimeout, "no result before the call timeout"))
+}
+
+func (h *Host) sweepLoop() {
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-t.C:
+			h.sweep()
+		}
+	}
+}
+
+// sweep applies expired leases: those calls go back to the queue, or fail once out of attempts.
+// Calls the queue dropped at their deadline fail as their timer would.
+func (h *Host) sweep() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tasks, err := h.q.Sweep(context.Background())
+	if err != nil {
+		h.log.Printf("[host] task queue sweep: %v", err)
+	}
+	for _, t := range tasks {
+		p := h.calls[t.ID]
+		if p == nil || p.result != nil {
+			continue
+		}
+		if t.DeadAt.IsZero() && t.Expired(time.Now()) {
+			h.expireLocked(p)
+			p.owner = ""
+			continue
+		}
+		if c := h.collectors[p.owner]; c != nil {
+			delete(c.assigned, t.ID)
+		}
+		if !t.DeadAt.IsZero() {
+			h.finishLocked(p, errorResult(t.ID, protocol.CodeNoCollector,
+				fmt.Sprintf("%s; gave up after %d deliveries", t.LastError, t.Attempts)))
+			p.owner = ""
+			continue
+		}
+		p.owner = ""
+		h.log.Printf("[host] redeliver %s: %s", t.ID, t.LastError)
+	}
+	if len(tasks) > 0 {
+		h.dispatchLocked()
+	}
+}
+
+// dequeueLocked removes a call that finished or was abandoned from the queue.
+func (h *Host) dequeueLocked(id string) {
+	if err := h.q.Complete(context.Background(), id); err != nil && !errors.Is(err, taskqueue.ErrNotFound) {
+		h.log.Printf("[host] complete %s: %v", id, err)
+	}
+}
+
+// stopLocked tells the collector holding p's lease to abandon it.
+func (h *Host) stopLocked(p *pendingCall) {
+	c := h.collectors[p.owner]
+	if c == nil || !protocol.HasFeature(c.features, protocol.FeatureCancel) {
+		return
+	}
+	c.send(protocol.NewCancelCall(p.call.ID))
+}
+
+// finishLocked stores the result, wakes waiters and schedules the call's removal after ResultTTL.
+func (h *Host) finishLocked(p *pendingCall, res *protocol.ToolResult) {
+	p.result = res
+	p.timer.Stop()
+	close(p.done)
+	h.feed.Publish(dashboard.Event{Tool: p.call.Tool, InstanceID: p.owner, Result: *res})
+	if c := h.collectors[p.owner]; c != nil {
+		delete(c.assigned, p.call.ID)
+	}
+	time.AfterFunc(h.opts.ResultTTL, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.calls[p.call.ID] == p {
+			delete(h.calls, p.call.ID)
+		}
+	})
+}
+
+// forget drops a finished call whose result was handed to a synchronous caller.
+func (h *Host) forget(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p, ok := h.calls[id]; ok && p.result != nil {
+		delete(h.calls, id)
+	}
+}
+
+func errorResult(id, code, msg string) *protocol.ToolResult {
+	return &protocol.ToolResult{
+		Type:   protocol.TypeToolResult,
+		ID:     id,
+		Status: "error",
+		Error:  protocol.NewToolError(code, "%s", msg),
+	}
+}
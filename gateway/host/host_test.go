@@ -0,0 +1,408 @@
+package host
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/collector"
+	"github.com/originaleric/digeino/gateway/dashboard"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/runtime"
+	"github.com/originaleric/digeino/gateway/taskqueue"
+)
+
+// fakeCollector speaks the wire protocol in JSON frames and hands received calls to the test.
+type fakeCollector struct {
+	id    string
+	conn  *websocket.Conn
+	mu    sync.Mutex
+	calls chan protocol.ToolCall
+}
+
+func newTestHost(t *testing.T, opts Options) (*Host, *httptest.Server) {
+	t.Helper()
+	opts.Logger = log.New(io.Discard, "", 0)
+	h := New(opts)
+	ts := httptest.NewServer(h.Handler())
+	t.Cleanup(func() {
+		_ = h.Close()
+		ts.Close()
+	})
+	return h, ts
+}
+
+func dialCollector(t *testing.T, h *Host, ts *httptest.Server, id string, caps []string, tools ...string) *fakeCollector {
+	t.Helper()
+	header := http.Header{"X-Digeino-Token": {h.opts.Token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+h.opts.WSPath, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	f := &fakeCollector{id: id, conn: conn, calls: make(chan protocol.ToolCall, 8)}
+	hello := protocol.NewCollectorHello(id, "digeino", "test")
+	hello.Capabilities = caps
+	f.send(t, hello)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	} else if ack, _ := protocol.DecodeEnvelope(data); !ack.OK {
+		t.Fatalf("hello rejected: %s", data)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	m := protocol.ToolManifest{Type: protocol.TypeToolManifest}
+	for _, name := range tools {
+		m.Tools = append(m.Tools, protocol.ToolDescriptor{Name: name})
+	}
+	f.send(t, protocol.NewCollectorManifest(m))
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			env, err := protocol.DecodeEnvelope(data)
+			if err != nil {
+				continue
+			}
+			switch env.Type {
+			case protocol.TypeToolCall:
+				f.calls <- *env.ToolCall
+			case protocol.TypePullTasksAck:
+				for _, c := range env.Calls {
+					f.calls <- c
+				}
+			}
+		}
+	}()
+	waitFor(t, func() bool {
+		for _, c := range h.Collectors() {
+			if c.InstanceID == id && len(c.Tools) == len(tools) {
+				return true
+			}
+		}
+		return false
+	})
+	return f
+}
+
+func (f *fakeCollector) send(t *testing.T, env protocol.Envelope) {
+	t.Helper()
+	data, err := env.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fakeCollector) next(t *testing.T) protocol.ToolCall {
+	t.Helper()
+	select {
+	case c := <-f.calls:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: no call received", f.id)
+		return protocol.ToolCall{}
+	}
+}
+
+func (f *fakeCollector) reply(t *testing.T, call protocol.ToolCall) {
+	t.Helper()
+	out, _ := json.Marshal(map[string]string{"by": f.id})
+	f.send(t, protocol.NewToolResultEnvelope(protocol.ToolResult{
+		Type: protocol.TypeToolResult, ID: call.ID, Status: "success", Output: out,
+	}))
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met")
+}
+
+func callAsync(h *Host, call protocol.ToolCall) <-chan *protocol.ToolResult {
+	ch := make(chan *protocol.ToolResult, 1)
+	go func() {
+		res, _ := h.Call(context.Background(), call)
+		ch <- res
+	}()
+	return ch
+}
+
+func TestCallRoutesByToolAndLabels(t *testing.T) {
+	t.Parallel()
+	h, ts := newTestHost(t, Options{Token: "secret"})
+	push := []string{protocol.FeaturePush, protocol.FeatureResultAck}
+	_ = dialCollector(t, h, ts, "c_search", push, "web.search")
+	browser := dialCollector(t, h, ts, "c_browser", push, "browser.browse")
+
+	done := callAsync(h, protocol.ToolCall{Tool: "browser.browse", Input: json.RawMessage(`{}`)})
+	call := browser.next(t)
+	if call.Policy.TimeoutMs <= 0 {
+		t.Fatalf("delivered call has no remaining timeout: %+v", call.Policy)
+	}
+	browser.reply(t, call)
+	res := <-done
+	if res == nil || res.ID != call.ID || res.Status != "success" || !strings.Contains(string(res.Output), "c_browser") {
+		t.Fatalf("result = %+v", res)
+	}
+
+	// 标签约束不满足时一直等待，超时返回 NO_COLLECTOR
+	res, err := h.Call(context.Background(), protocol.ToolCall{Tool: "browser.browse",
+		Policy: protocol.CallPolicy{TimeoutMs: 50},
+		Route:  &protocol.CallRoute{Labels: map[string]string{"region": "eu"}}})
+	if err != nil || res.Error == nil || res.Error.Code != protocol.CodeNoCollector {
+		t.Fatalf("unroutable call = %+v, %v", res, err)
+	}
+	// 超时的调用不进入死信
+	if dead, err := h.DeadLetters(context.Background()); err != nil || len(dead) != 0 {
+		t.Fatalf("dead letters after timeout = %+v, %v", dead, err)
+	}
+}
+
+func TestCallRedeliveredWhenCollectorDisconnects(t *testing.T) {
+	t.Parallel()
+	h, ts := newTestHost(t, Options{})
+	push := []string{protocol.FeaturePush}
+	first := dialCollector(t, h, ts, "a_first", push, "x")
+	second := dialCollector(t, h, ts, "b_second", push, "x")
+
+	done := callAsync(h, protocol.ToolCall{ID: "call_1", Tool: "x"})
+	if c := first.next(t); c.ID != "call_1" {
+		t.Fatalf("first got %q", c.ID)
+	}
+	_ = first.conn.Close()
+
+	call := second.next(t)
+	if call.ID != "call_1" {
+		t.Fatalf("second got %q", call.ID)
+	}
+	second.reply(t, call)
+	if res := <-done; res.Status != "success" || !strings.Contains(string(res.Output), "b_second") {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestHTTPSubmitWithPullCollector(t *testing.T) {
+	t.Parallel()
+	h, ts := newTestHost(t, Options{Token: "collector", APIToken: "api"})
+	f := dialCollector(t, h, ts, "c_pull", []string{protocol.FeaturePull}, "x")
+
+	do := func(method, path, body string) (int, CallStatus) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer api")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var st CallStatus
+		_ = json.NewDecoder(resp.Body).Decode(&st)
+		return resp.StatusCode, st
+	}
+
+	resp, err := http.Post(ts.URL+"/calls?wait=false", "application/json", strings.NewReader(`{"tool":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated submit = %d", resp.StatusCode)
+	}
+
+	code, st := do(http.MethodPost, "/calls?wait=false", `{"id":"call_p","tool":"x"}`)
+	if code != http.StatusAccepted || st.State != StateQueued || st.InstanceID != "" {
+		t.Fatalf("submit = %d %+v", code, st)
+	}
+	if code, _ := do(http.MethodPost, "/calls?wait=false", `{"id":"call_p","tool":"x"}`); code != http.StatusConflict {
+		t.Fatalf("duplicate submit = %d", code)
+	}
+
+	f.send(t, protocol.NewPullTasks(5))
+	call := f.next(t)
+	if code, st := do(http.MethodGet, "/calls/call_p", ""); code != http.StatusOK || st.State != StateDelivered || st.InstanceID != "c_pull" {
+		t.Fatalf("leased status = %d %+v", code, st)
+	}
+	f.reply(t, call)
+	code, st = do(http.MethodGet, "/calls/call_p?wait=5s", "")
+	if code != http.StatusOK || st.State != StateDone || st.Result == nil || st.Result.Status != "success" {
+		t.Fatalf("status = %d %+v", code, st)
+	}
+	if code, _ := do(http.MethodGet, "/calls/nope", ""); code != http.StatusNotFound {
+		t.Fatalf("unknown call = %d", code)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/dashboard/api/state", nil)
+	req.Header.Set("Authorization", "Bearer api")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var state struct {
+		Collectors []dashboard.Collector `json:"collectors"`
+		Tasks      []dashboard.Task      `json:"tasks"`
+		Results    []dashboard.Event     `json:"results"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&state)
+	if len(state.Collectors) != 1 || len(state.Collectors[0].Tools) != 1 || len(state.Tasks) != 0 ||
+		len(state.Results) != 1 || state.Results[0].Result.ID != "call_p" || state.Results[0].InstanceID != "c_pull" {
+		t.Fatalf("dashboard state = %+v", state)
+	}
+}
+
+func TestExpiredLeaseRedeliveredThenDeadLettered(t *testing.T) {
+	t.Parallel()
+	h, ts := newTestHost(t, Options{
+		LeaseTimeout: 50 * time.Millisecond,
+		Queue:        taskqueue.NewMemoryQueue(taskqueue.Options{MaxAttempts: 2}),
+	})
+	f := dialCollector(t, h, ts, "c_slow", []string{protocol.FeaturePush}, "x")
+
+	done := callAsync(h, protocol.ToolCall{ID: "call_l", Tool: "x", Policy: protocol.CallPolicy{TimeoutMs: 10000}})
+	// 租约到期没有结果：重投一次，第二次到期后进入死信
+	if c := f.next(t); c.ID != "call_l" {
+		t.Fatalf("first delivery %q", c.ID)
+	}
+	if c := f.next(t); c.ID != "call_l" {
+		t.Fatalf("redelivery %q", c.ID)
+	}
+	res := <-done
+	if res == nil || res.Error == nil || res.Error.Code != protocol.CodeNoCollector || !strings.Contains(res.Error.Message, "lease expired") {
+		t.Fatalf("result = %+v", res)
+	}
+	dead, err := h.DeadLetters(context.Background())
+	if err != nil || len(dead) != 1 || dead[0].ID != "call_l" || dead[0].Attempts != 2 {
+		t.Fatalf("dead letters = %+v, %v", dead, err)
+	}
+}
+
+// expiringQueue 在 Sweep 时把 expire 中的任务当作已过截止时间移除，模拟 Sweep 先于调用的计时器执行
+type expiringQueue struct {
+	*taskqueue.MemoryQueue
+	expire map[string]bool
+}
+
+func (q *expiringQueue) Sweep(ctx context.Context) ([]taskqueue.Task, error) {
+	var out []taskqueue.Task
+	for id := range q.expire {
+		tk, ok, _ := q.Get(ctx, id)
+		if !ok {
+			continue
+		}
+		_ = q.Complete(ctx, id)
+		tk.Deadline, tk.LastError = time.Now().Add(-time.Second), "deadline exceeded"
+		out = append(out, tk)
+	}
+	return out, nil
+}
+
+func TestSweepExpiresCallPastDeadline(t *testing.T) {
+	t.Parallel()
+	q := &expiringQueue{MemoryQueue: taskqueue.NewMemoryQueue(taskqueue.Options{}), expire: map[string]bool{"call_d": true}}
+	h := New(Options{Queue: q, Logger: log.New(io.Discard, "", 0)})
+	t.Cleanup(func() { _ = h.Close() })
+	if _, err := h.Submit(protocol.ToolCall{ID: "call_d", Tool: "x", Policy: protocol.CallPolicy{TimeoutMs: 60000}}); err != nil {
+		t.Fatal(err)
+	}
+	h.sweep()
+	st, ok := h.Status("call_d")
+	if !ok || st.Result == nil || st.Result.Error == nil || st.Result.Error.Code != protocol.CodeNoCollector ||
+		strings.Contains(st.Result.Error.Message, "gave up") {
+		t.Fatalf("status = %+v", st)
+	}
+	if dead, _ := h.DeadLetters(context.Background()); len(dead) != 0 {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+}
+
+func TestPushConfigNarrowsCollectorTools(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	h, ts := newTestHost(t, Options{Token: "tok", ConfigKey: key})
+
+	reg := registry.New()
+	for _, name := range []string{"web.read", "browser.browse"} {
+		reg.Register(registry.Entry{
+			Descriptor: protocol.ToolDescriptor{Name: name},
+			Handler: func(context.Context, *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+				return map[string]any{}, nil, nil
+			},
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := collector.NewClient(collector.Options{
+		ServerURL:          ts.URL,
+		Token:              "tok",
+		InstanceID:         "c1",
+		HeartbeatInterval:  time.Minute,
+		ReconnectDelay:     time.Second,
+		MaxConcurrentCalls: 1,
+		RemoteConfig: collector.RemoteConfigOptions{
+			TrustedKeys:  []ed25519.PublicKey{pub},
+			AllowedTools: []string{"web.read"},
+			StateFile:    filepath.Join(t.TempDir(), "remote.json"),
+		},
+	}, runtime.New(reg, runtime.Options{}))
+	go func() { _ = c.Run(ctx) }()
+	collectorInfo := func() CollectorInfo {
+		if cs := h.Collectors(); len(cs) == 1 {
+			return cs[0]
+		}
+		return CollectorInfo{}
+	}
+	waitFor(t, func() bool { return len(collectorInfo().Tools) == 2 })
+
+	// above the collector's ceiling: refused, nothing changes
+	if _, err := h.PushConfig("c1", protocol.RemoteConfig{AllowedTools: []string{"browser.browse"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return collectorInfo().ConfigError != "" })
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/collectors/c1/config", strings.NewReader(`{"allowed_tools":["web.read"]}`))
+	req.Header.Set("Authorization", "Bearer tok")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pushed struct {
+		Version int64 `json:"version"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&pushed)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || pushed.Version == 0 {
+		t.Fatalf("push = %d %+v", resp.StatusCode, pushed)
+	}
+	waitFor(t, func() bool {
+		info := collectorInfo()
+		return info.ConfigVersion == pushed.Version && info.ConfigError == "" && len(info.Tools) == 1 && info.Tools[0] == "web.read"
+	})
+	if c.RemoteConfigVersion() != pushed.Version {
+		t.Fatalf("collector version = %d, want %d", c.RemoteConfigVersion(), pushed.Version)
+	}
+	if _, err := h.PushConfig("nobody", protocol.RemoteConfig{}); !errors.Is(err, ErrUnknownCollector) {
+		t.Fatalf("unknown collector: err = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/dashboard"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// Handler serves the collector WebSocket at Options.WSPath and the submission API:
+//
+//	POST   /calls         submit a ToolCall; waits for the result unless ?wait=false (202 + status)
+//	GET    /calls/{id}    CallStatus; ?wait=30s blocks until done or the duration elapses
+//	DELETE /calls/{id}    cancel
+//	GET    /collectors    connected collectors
+//	POST   /collectors/{id}/config  push a signed RemoteConfig ("*" = all collectors); 202 + version
+//	GET    /dead-letters  calls that ran out of delivery attempts
+//	GET    /dashboard/    web UI (see package dashboard), API guarded by APIToken
+//	GET    /health
+//
+// Mount it under a prefix with http.StripPrefix.
+func (h *Host) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc(h.opts.WSPath, h.handleWS)
+	mux.HandleFunc("POST /calls", h.api(h.handleSubmit))
+	mux.HandleFunc("GET /calls/{id}", h.api(h.handleStatus))
+	mux.HandleFunc("DELETE /calls/{id}", h.api(h.handleCancel))
+	mux.HandleFunc("GET /collectors", h.api(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"collectors": h.Collectors()})
+	}))
+	mux.HandleFunc("POST /collectors/{id}/config", h.api(h.handlePushConfig))
+	mux.Handle("/dashboard/", http.StripPrefix("/dashboard", dashboard.Handler(dashboardSource{h}, h.feed,
+		dashboard.Options{Token: h.opts.APIToken, ArtifactBaseURL: h.opts.ArtifactBaseURL})))
+	mux.HandleFunc("GET /dead-letters", h.api(func(w http.ResponseWriter, r *http.Request) {
+		tasks, err := h.DeadLetters(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"dead_letters": tasks})
+	}))
+	return mux
+}
+
+func (h *Host) api(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tokenOK(r, h.opts.APIToken) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Host) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read body"})
+		return
+	}
+	var call protocol.ToolCall
+	if err := json.Unmarshal(body, &call); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	if r.URL.Query().Get("wait") == "false" {
+		id, err := h.Submit(call)
+		if err != nil {
+			writeJSON(w, submitStatus(err), map[string]string{"error": err.Error()})
+			return
+		}
+		st, _ := h.Status(id)
+		writeJSON(w, http.StatusAccepted, st)
+		return
+	}
+	res, err := h.Call(r.Context(), call)
+	if err != nil {
+		writeJSON(w, submitStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (h *Host) handleStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "wait must be a duration such as 30s"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		_, _ = h.Wait(ctx, id)
+	}
+	st, ok := h.Status(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrUnknownCall.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (h *Host) handleCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := h.Status(id); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrUnknownCall.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "canceled": h.Cancel(id)})
+}
+
+func submitStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidCall):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrDuplicateCall):
+		return http.StatusConflict
+	case errors.Is(err, ErrClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusGatewayTimeout // the request context ended before the result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
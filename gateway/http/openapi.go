@@ -0,0 +1,253 @@
+package httpgw
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/originaleric/digeino/gateway/gwversion"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// emptyObjectSchema is used for tools that declare no input/output schema.
+var emptyObjectSchema = json.RawMessage(`{"type":"object"}`)
+
+// 按工具路由（POST /tools/{name}）的可选请求头，对应 ToolCall.Context / Policy。
+const (
+	headerCallID    = "X-Digeino-Call-Id"
+	headerTenantID  = "X-Digeino-Tenant-Id"
+	headerUserID    = "X-Digeino-User-Id"
+	headerTraceID   = "X-Digeino-Trace-Id"
+	headerTimeoutMs = "X-Digeino-Timeout-Ms"
+	headerCache     = "X-Digeino-Cache"
+	headerCacheHit  = "X-Digeino-Cache-Hit"
+)
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.OpenAPI())
+}
+
+// OpenAPI builds an OpenAPI 3.1 document for the generic endpoints and one typed
+// POST /tools/{name} operation per tool in the manifest.
+func (s *Server) OpenAPI() map[string]any {
+	manifest := s.rt.Manifest()
+	paths := map[string]any{
+		"/health": map[string]any{"get": operation("getHealth", "Health check", nil,
+			jsonResponse("OK", map[string]any{"type": "object", "properties": map[string]any{"status": map[string]string{"type": "string"}}}))},
+		"/manifest": map[string]any{"get": operation("getManifest", "List available tools", nil,
+			jsonResponse("Tool manifest", ref("ToolManifest")))},
+		"/tools/call": map[string]any{"post": operation("callTool", "Execute any tool with a ToolCall envelope",
+			jsonBody(ref("ToolCall")),
+			jsonResponse("Tool result; failed calls return status=error, mapped codes also set the HTTP status", ref("ToolResult")))},
+		"/artifacts/{id}": map[string]any{"get": withParams(operation("getArtifact", "Download an artifact", nil, map[string]any{
+			"200": map[string]any{"description": "Artifact content", "content": map[string]any{"*/*": map[string]any{}}},
+			"404": map[string]any{"description": "Artifact not found"},
+		}), []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]string{"type": "string"}}})},
+		"/usage": map[string]any{"get": withParams(operation("getUsage", "Aggregated usage per tenant and tool", nil, map[string]any{
+			"200": map[string]any{"description": "Usage report", "content": map[string]any{
+				"application/json": map[string]any{"schema": ref("UsageReport")},
+				"text/csv":         map[string]any{"schema": map[string]string{"type": "string"}},
+			}},
+		}), []any{
+			queryParam("window", "Rolling window as a Go duration, default 24h"),
+			queryParam("date", "Calendar day YYYY-MM-DD; overrides window"),
+			queryParam("tenant", "Only this tenant"),
+			queryParam("format", "json (default) or csv"),
+		})},
+		"/openapi.json": map[string]any{"get": operation("getOpenAPI", "This document", nil,
+			jsonResponse("OpenAPI document", map[string]string{"type": "object"}))},
+	}
+	for _, tool := range manifest.Tools {
+		if tool.Name == "call" {
+			continue // shadowed by POST /tools/call
+		}
+		paths["/tools/"+tool.Name] = map[string]any{"post": toolOperation(tool)}
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "DigEino Tool Gateway",
+			"version":     gwversion.RuntimeVersion,
+			"description": "Instance " + manifest.InstanceID + ". Typed operations under /tools/{name} take the tool input as the request body and return the tool output.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": componentSchemas(),
+			"securitySchemes": map[string]any{
+				"bearerAuth":  map[string]string{"type": "http", "scheme": "bearer"},
+				"tokenHeader": map[string]string{"type": "apiKey", "in": "header", "name": "X-Digeino-Token"},
+			},
+		},
+	}
+	if s.authToken != "" {
+		doc["security"] = []any{map[string]any{"bearerAuth": []string{}}, map[string]any{"tokenHeader": []string{}}}
+	}
+	return doc
+}
+
+func toolOperation(tool protocol.ToolDescriptor) map[string]any {
+	summary := tool.Description
+	if summary == "" {
+		summary = tool.Name
+	}
+	op := operation(operationID(tool.Name), summary, jsonBody(schemaOrObject(tool.InputSchema)), map[string]any{
+		"200": map[string]any{
+			"description": "Tool output",
+			"headers": map[string]any{
+				headerCallID:   map[string]any{"schema": map[string]string{"type": "string"}},
+				headerCacheHit: map[string]any{"schema": map[string]string{"type": "boolean"}},
+			},
+			"content": map[string]any{"application/json": map[string]any{"schema": schemaOrObject(tool.OutputSchema)}},
+		},
+		"default": map[string]any{
+			"description": "Tool error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": ref("ToolError")}},
+		},
+	})
+	op["tags"] = []string{"tools"}
+	if tool.Risk != "" {
+		op["x-digeino-risk"] = tool.Risk
+	}
+	if len(tool.Capabilities) > 0 {
+		op["x-digeino-capabilities"] = tool.Capabilities
+	}
+	return withParams(op, []any{
+		headerParam(headerCallID, "Call ID; generated when absent"),
+		headerParam(headerTenantID, "context.tenant_id"),
+		headerParam(headerUserID, "context.user_id"),
+		headerParam(headerTraceID, "context.trace_id"),
+		map[string]any{"name": headerTimeoutMs, "in": "header", "description": "policy.timeout_ms", "schema": map[string]string{"type": "integer"}},
+		map[string]any{"name": headerCache, "in": "header", "description": "policy.cache", "schema": map[string]any{"type": "string", "enum": []string{protocol.CacheBypass, protocol.CacheRefresh, protocol.CacheOnly}}},
+	})
+}
+
+// operationID turns "wechat.article.read" into "wechatArticleRead".
+func operationID(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '.' || r == '_' || r == '-' })
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			p = strings.ToUpper(p[:1]) + p[1:]
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+func schemaOrObject(schema json.RawMessage) json.RawMessage {
+	if len(schema) == 0 {
+		return emptyObjectSchema
+	}
+	return schema
+}
+
+func operation(id, summary string, body map[string]any, responses map[string]any) map[string]any {
+	op := map[string]any{"operationId": id, "summary": summary, "responses": responses}
+	if body != nil {
+		op["requestBody"] = body
+	}
+	return op
+}
+
+func withParams(op map[string]any, params []any) map[string]any {
+	op["parameters"] = params
+	return op
+}
+
+func jsonBody(schema any) map[string]any {
+	return map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": schema}}}
+}
+
+func jsonResponse(description string, schema any) map[string]any {
+	return map[string]any{"200": map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}}
+}
+
+func queryParam(name, description string) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": description, "schema": map[string]string{"type": "string"}}
+}
+
+func headerParam(name, description string) map[string]any {
+	return map[string]any{"name": name, "in": "header", "description": description, "schema": map[string]string{"type": "string"}}
+}
+
+func ref(name string) map[string]string {
+	return map[string]string{"$ref": "#/components/schemas/" + name}
+}
+
+// componentSchemas describes the protocol types used by the generic endpoints.
+func componentSchemas() map[string]any {
+	str := map[string]string{"type": "string"}
+	integer := map[string]string{"type": "integer"}
+	boolean := map[string]string{"type": "boolean"}
+	strArray := map[string]any{"type": "array", "items": str}
+	object := func(required []string, props map[string]any) map[string]any {
+		o := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			o["required"] = required
+		}
+		return o
+	}
+	return map[string]any{
+		"ToolDescriptor": object([]string{"name"}, map[string]any{
+			"name": str, "description": str,
+			"input_schema": map[string]string{"type": "object"}, "output_schema": map[string]string{"type": "object"},
+			"capabilities": strArray, "risk": str, "requires_user_approval": boolean,
+		}),
+		"ToolManifest": object([]string{"type", "tools"}, map[string]any{
+			"type": str, "runtime": str, "runtime_version": str, "instance_id": str,
+			"tools": map[string]any{"type": "array", "items": ref("ToolDescriptor")},
+		}),
+		"ToolCall": object([]string{"id", "tool"}, map[string]any{
+			"type": str, "id": str, "tool": str,
+			"input":   map[string]string{"type": "object"},
+			"context": object(nil, map[string]any{"user_id": str, "tenant_id": str, "trace_id": str, "host": str}),
+			"policy": object(nil, map[string]any{
+				"timeout_ms": integer, "allowed_domains": strArray, "store_cookies": str,
+				"max_output_bytes": integer, "rate_limit_key": str,
+				"retry": object(nil, map[string]any{
+					"max_attempts": integer, "backoff_ms": integer, "max_backoff_ms": integer, "attempt_timeout_ms": integer,
+				}),
+				"cache":    map[string]any{"type": "string", "enum": []string{"", protocol.CacheBypass, protocol.CacheRefresh, protocol.CacheOnly}},
+				"priority": integer,
+			}),
+			"route": object(nil, map[string]any{
+				"labels": map[string]any{"type": "object", "additionalProperties": str},
+			}),
+		}),
+		"ToolError": object([]string{"code", "message"}, map[string]any{
+			"code": str, "message": str, "retryable": boolean, "retry_after_ms": integer,
+			"details": map[string]string{"type": "object"},
+		}),
+		"Artifact": object([]string{"id", "uri"}, map[string]any{
+			"id": str, "type": str, "name": str, "size": integer, "uri": str, "expires_at": str,
+		}),
+		"Usage": object([]string{"duration_ms"}, map[string]any{
+			"duration_ms": integer, "attempts": integer, "bytes_in": integer, "bytes_out": integer,
+			"pages_opened": integer, "navigation_ms": integer, "screenshots": integer, "artifact_bytes": integer,
+			"provider_requests": integer, "input_tokens": integer, "output_tokens": integer,
+		}),
+		"ToolResult": object([]string{"type", "id", "status", "usage"}, map[string]any{
+			"type": str, "id": str,
+			"status":    map[string]any{"type": "string", "enum": []string{"success", "error"}},
+			"output":    map[string]string{"type": "object"},
+			"artifacts": map[string]any{"type": "array", "items": ref("Artifact")},
+			"error":     ref("ToolError"),
+			"usage":     ref("Usage"),
+			"cache_hit": boolean,
+		}),
+		"UsageReport": object([]string{"from", "to", "rows"}, map[string]any{
+			"from": map[string]string{"type": "string", "format": "date-time"},
+			"to":   map[string]string{"type": "string", "format": "date-time"},
+			"rows": map[string]any{"type": "array", "items": object(nil, map[string]any{
+				"tenant": str, "tool": str, "calls": integer, "errors": integer, "cache_hits": integer,
+				"duration_ms": integer, "bytes_in": integer, "bytes_out": integer, "pages_opened": integer,
+				"navigation_ms": integer, "screenshots": integer, "artifact_bytes": integer,
+				"provider_requests": integer, "input_tokens": integer, "output_tokens": integer,
+			})},
+		}),
+	}
+}
@@ -1,14 +1,17 @@
 package httpgw
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/originaleric/digeino/gateway/artifact"
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/runtime"
@@ -28,7 +31,10 @@ func NewServer(rt *runtime.Runtime, artStore artifact.Store, authToken string) *
 	s.mux.HandleFunc("GET /health", s.handleHealth)
 	s.mux.HandleFunc("GET /manifest", s.handleManifest)
 	s.mux.HandleFunc("POST /tools/call", s.handleToolCall)
+	s.mux.HandleFunc("POST /tools/{name}", s.handleTypedToolCall)
 	s.mux.HandleFunc("GET /artifacts/{id}", s.handleArtifact)
+	s.mux.HandleFunc("GET /usage", s.handleUsage)
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
 	return s
 }
 
@@ -103,16 +109,88 @@ func (s *Server) handleToolCall(w http.ResponseWriter, r *http.Request) {
 		call.Type = protocol.TypeToolCall
 	}
 	result := s.rt.Execute(r.Context(), &call)
-	status := http.StatusOK
-	if result.Status == "error" && result.Error != nil {
-		switch result.Error.Code {
-		case "INVALID_INPUT", "TOOL_NOT_ALLOWED":
-			status = http.StatusBadRequest
-		case "DOMAIN_NOT_ALLOWED":
-			status = http.StatusForbidden
+	writeJSON(w, errorHTTPStatus(w, result, http.StatusOK), result)
+}
+
+// handleTypedToolCall serves POST /tools/{name}: the body is the tool input and a
+// successful response is the tool output, so SDKs generated from /openapi.json work directly.
+func (s *Server) handleTypedToolCall(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, protocol.NewToolError(protocol.CodeInvalidInput, "failed to read body"))
+		return
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+	if !json.Valid(body) {
+		writeJSON(w, http.StatusBadRequest, protocol.NewToolError(protocol.CodeInvalidInput, "invalid json"))
+		return
+	}
+	call := protocol.ToolCall{
+		Type:  protocol.TypeToolCall,
+		ID:    r.Header.Get(headerCallID),
+		Tool:  r.PathValue("name"),
+		Input: body,
+		Context: protocol.CallContext{
+			TenantID: r.Header.Get(headerTenantID),
+			UserID:   r.Header.Get(headerUserID),
+			TraceID:  r.Header.Get(headerTraceID),
+		},
+		Policy: protocol.CallPolicy{Cache: r.Header.Get(headerCache)},
+	}
+	if call.ID == "" {
+		call.ID = "http_" + uuid.NewString()
+	}
+	if v := r.Header.Get(headerTimeoutMs); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			writeJSON(w, http.StatusBadRequest, protocol.NewToolError(protocol.CodeInvalidInput, "%s must be a non-negative integer", headerTimeoutMs))
+			return
 		}
+		call.Policy.TimeoutMs = ms
 	}
-	writeJSON(w, status, result)
+
+	result := s.rt.Execute(r.Context(), &call)
+	w.Header().Set(headerCallID, call.ID)
+	if result.Status == "error" && result.Error != nil {
+		writeJSON(w, errorHTTPStatus(w, result, http.StatusBadGateway), result.Error)
+		return
+	}
+	w.Header().Set(headerCacheHit, strconv.FormatBool(result.CacheHit))
+	output := result.Output
+	if len(output) == 0 {
+		output = json.RawMessage("{}")
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+// errorHTTPStatus returns the HTTP status for a failed result (fallback for unmapped
+// codes) and sets Retry-After; successful results return http.StatusOK.
+func errorHTTPStatus(w http.ResponseWriter, result *protocol.ToolResult, fallback int) int {
+	if result.Status != "error" || result.Error == nil {
+		return http.StatusOK
+	}
+	if result.Error.RetryAfterMs > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt((result.Error.RetryAfterMs+999)/1000, 10))
+	}
+	if code, ok := errorStatus[result.Error.Code]; ok {
+		return code
+	}
+	return fallback
+}
+
+// errorStatus maps ToolError codes to HTTP status; unlisted codes return 200 with status=error
+// on /tools/call and 502 on the typed per-tool routes.
+var errorStatus = map[string]int{
+	protocol.CodeInvalidInput:     http.StatusBadRequest,
+	protocol.CodeToolNotAllowed:   http.StatusBadRequest,
+	protocol.CodeDomainNotAllowed: http.StatusForbidden,
+	protocol.CodeRateLimited:      http.StatusTooManyRequests,
+	protocol.CodeCircuitOpen:      http.StatusServiceUnavailable,
+	protocol.CodeTimeout:          http.StatusGatewayTimeout,
+	protocol.CodeOutputTooLarge:   http.StatusRequestEntityTooLarge,
+	protocol.CodeInternal:         http.StatusInternalServerError,
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
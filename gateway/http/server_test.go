@@ -2,14 +2,20 @@ package httpgw
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/registry"
 	"github.com/originaleric/digeino/gateway/runtime"
+	"github.com/originaleric/digeino/gateway/usage"
 )
 
 func TestManifestEndpoint(t *testing.T) {
@@ -59,3 +65,177 @@ func TestToolCallAuth(t *testing.T) {
 		t.Fatal("expected authorized request to pass auth")
 	}
 }
+
+func TestToolCallErrorStatus(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "busy.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			return nil, nil, protocol.NewToolError(protocol.CodeRateLimited, "slow down").WithRetryAfter(1500 * time.Millisecond)
+		},
+	})
+	srv := NewServer(runtime.New(reg, runtime.Options{}), nil, "")
+
+	body, _ := json.Marshal(protocol.ToolCall{ID: "1", Tool: "busy.tool"})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tools/call", bytes.NewReader(body)))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("status %d retry-after %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestUsageEndpoint(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "web.search"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			return map[string]any{"results": []any{}}, nil, nil
+		},
+	})
+	rt := runtime.New(reg, runtime.Options{Usage: usage.NewAggregator(0)})
+	srv := NewServer(rt, nil, "")
+	for _, tenant := range []string{"t1", "t1", "t2"} {
+		rt.Execute(context.Background(), &protocol.ToolCall{ID: "c", Tool: "web.search", Context: protocol.CallContext{TenantID: tenant}})
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/usage?window=1h&tenant=t1", nil))
+	var rep usage.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("status %d body %s", rec.Code, rec.Body.String())
+	}
+	if len(rep.Rows) != 1 || rep.Rows[0].Tenant != "t1" || rep.Rows[0].Calls != 2 {
+		t.Fatalf("unexpected usage: %+v", rep)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/usage?date="+time.Now().Format(time.DateOnly)+"&format=csv", nil))
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "tenant,tool,calls") {
+		t.Fatalf("unexpected csv: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/usage?window=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestOpenAPIAndTypedToolRoute(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{
+			Name:         "web.search",
+			InputSchema:  registry.MustSchema(map[string]any{"type": "object", "required": []string{"query"}}),
+			OutputSchema: registry.MustSchema(map[string]any{"type": "object", "properties": map[string]any{"results": map[string]string{"type": "array"}}}),
+		},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			var in struct{ Query string }
+			_ = json.Unmarshal(call.Input, &in)
+			if in.Query == "" {
+				return nil, nil, protocol.NewToolError(protocol.CodeInvalidInput, "query is required")
+			}
+			return map[string]any{"results": []string{in.Query}, "tenant": call.Context.TenantID}, nil, nil
+		},
+	})
+	srv := NewServer(runtime.New(reg, runtime.Options{InstanceID: "test"}), nil, "")
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema map[string]any `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	op, ok := doc.Paths["/tools/web.search"]["post"]
+	if doc.OpenAPI != "3.1.0" || !ok || op.OperationID != "webSearch" {
+		t.Fatalf("unexpected document: %s", rec.Body.String())
+	}
+	if req := op.RequestBody.Content["application/json"].Schema; req["required"] == nil {
+		t.Fatalf("expected input schema as request body, got %v", req)
+	}
+	if _, ok := doc.Paths["/tools/call"]["post"]; !ok {
+		t.Fatal("missing generic /tools/call")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tools/web.search", strings.NewReader(`{"query":"go"}`))
+	req.Header.Set("X-Digeino-Tenant-Id", "t1")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Digeino-Call-Id") == "" ||
+		strings.TrimSpace(rec.Body.String()) != `{"results":["go"],"tenant":"t1"}` {
+		t.Fatalf("typed call: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tools/web.search", strings.NewReader(`{}`)))
+	var te protocol.ToolError
+	if err := json.Unmarshal(rec.Body.Bytes(), &te); err != nil || rec.Code != http.StatusBadRequest || te.Code != protocol.CodeInvalidInput {
+		t.Fatalf("typed error: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOpenAPIToolCallSchemaMatchesType(t *testing.T) {
+	t.Parallel()
+	srv := NewServer(runtime.New(registry.New(), runtime.Options{}), nil, "")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	schema := doc.Components.Schemas["ToolCall"]
+	if !slices.Contains(schema["required"].([]any), any("id")) {
+		t.Fatalf("ToolCall.required = %v, want id", schema["required"])
+	}
+	checkSchemaFields(t, "ToolCall", reflect.TypeOf(protocol.ToolCall{}), schema)
+}
+
+// checkSchemaFields asserts that schema lists exactly the JSON fields of typ, recursing into
+// nested structs.
+func checkSchemaFields(t *testing.T, path string, typ reflect.Type, schema map[string]any) {
+	t.Helper()
+	props, _ := schema["properties"].(map[string]any)
+	want := map[string]bool{}
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		want[name] = true
+		prop, ok := props[name].(map[string]any)
+		if !ok {
+			t.Errorf("%s.%s missing from the OpenAPI schema", path, name)
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft.PkgPath() == typ.PkgPath() {
+			checkSchemaFields(t, path+"."+name, ft, prop)
+		}
+	}
+	for name := range props {
+		if !want[name] {
+			t.Errorf("%s.%s in the OpenAPI schema is not a field of %s", path, name, typ)
+		}
+	}
+}
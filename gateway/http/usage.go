@@ -0,0 +1,67 @@
+package httpgw
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/usage"
+)
+
+// handleUsage serves aggregated usage per tenant and tool.
+//
+//	GET /usage?window=24h            rolling window (default 24h)
+//	GET /usage?date=2026-01-02       one local calendar day
+//	    &tenant=t1                   only one tenant
+//	    &format=csv                  CSV instead of JSON
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	agg := s.rt.Usage()
+	if agg == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "usage accounting disabled"})
+		return
+	}
+	q := r.URL.Query()
+	var (
+		rows     []usage.Row
+		from, to time.Time
+	)
+	if date := q.Get("date"); date != "" {
+		day, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		rows, from, to = agg.Day(day), day, day.AddDate(0, 0, 1)
+	} else {
+		window := 24 * time.Hour
+		if v := q.Get("window"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "window must be a positive duration such as 1h or 168h"})
+				return
+			}
+			window = d
+		}
+		to = time.Now()
+		rows, from = agg.Window(window), to.Add(-window)
+	}
+	if tenant := q.Get("tenant"); tenant != "" {
+		filtered := rows[:0]
+		for _, row := range rows {
+			if row.Tenant == tenant {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	switch q.Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = usage.WriteJSON(w, from, to, rows)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		_ = usage.WriteCSV(w, rows)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json or csv"})
+	}
+}
@@ -1,7 +1,6 @@
 package policy
 
 import (
-	"fmt"
 	"net/url"
 	"strings"
 
@@ -10,9 +9,9 @@ import (
 
 // Error codes aligned with gateway protocol.
 const (
-	CodeDomainNotAllowed = "DOMAIN_NOT_ALLOWED"
-	CodeToolNotAllowed   = "TOOL_NOT_ALLOWED"
-	CodeInvalidInput     = "INVALID_INPUT"
+	CodeDomainNotAllowed = protocol.CodeDomainNotAllowed
+	CodeToolNotAllowed   = protocol.CodeToolNotAllowed
+	CodeInvalidInput     = protocol.CodeInvalidInput
 )
 
 // ValidateToolAllowed checks tool name against gateway allowlist.
@@ -25,7 +24,7 @@ func ValidateToolAllowed(toolName string, allowedTools []string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("%s: tool %q is not allowed", CodeToolNotAllowed, toolName)
+	return protocol.NewToolError(CodeToolNotAllowed, "tool %q is not allowed", toolName)
 }
 
 // ValidateURLDomain validates http(s) URL and optional domain allowlists.
@@ -45,13 +44,13 @@ func ValidateURLDomain(rawURL string, callDomains, configDomains []string) error
 func parseHTTPURL(rawURL string) (*url.URL, error) {
 	u, err := url.Parse(strings.TrimSpace(rawURL))
 	if err != nil {
-		return nil, fmt.Errorf("%s: invalid url: %w", CodeInvalidInput, err)
+		return nil, protocol.NewToolError(CodeInvalidInput, "invalid url: %v", err)
 	}
 	if u.Scheme != "http" && u.Scheme != "https" {
-		return nil, fmt.Errorf("%s: only http/https URLs are supported", CodeInvalidInput)
+		return nil, protocol.NewToolError(CodeInvalidInput, "only http/https URLs are supported")
 	}
 	if u.Hostname() == "" {
-		return nil, fmt.Errorf("%s: url missing host", CodeInvalidInput)
+		return nil, protocol.NewToolError(CodeInvalidInput, "url missing host")
 	}
 	return u, nil
 }
@@ -59,7 +58,7 @@ func parseHTTPURL(rawURL string) (*url.URL, error) {
 func checkAllowedDomain(host string, allowedDomains []string) error {
 	h := strings.ToLower(strings.TrimSpace(host))
 	if h == "" {
-		return fmt.Errorf("%s: empty domain", CodeInvalidInput)
+		return protocol.NewToolError(CodeInvalidInput, "empty domain")
 	}
 	if len(allowedDomains) == 0 {
 		return nil
@@ -73,7 +72,7 @@ func checkAllowedDomain(host string, allowedDomains []string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("%s: target domain %q is not allowed", CodeDomainNotAllowed, host)
+	return protocol.NewToolError(CodeDomainNotAllowed, "target domain %q is not allowed", host).WithDetail("domain", host)
 }
 
 // MergeDomains returns call-level domains when set, otherwise config domains.
@@ -0,0 +1,382 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	"github.com/originaleric/digeino/gateway/protocol/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// EncodeBinary serializes an envelope as a protobuf binary frame (see pb/digeino.proto).
+// Use only after both sides negotiated FeatureBinary; Encode (JSON) remains the fallback.
+func (e Envelope) EncodeBinary() ([]byte, error) {
+	msg, err := envelopeToPB(e)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// DecodeBinaryEnvelope parses a protobuf binary WebSocket frame.
+func DecodeBinaryEnvelope(data []byte) (Envelope, error) {
+	var msg pb.Envelope
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return Envelope{}, err
+	}
+	return envelopeFromPB(&msg)
+}
+
+// DecodeFrame decodes a WebSocket frame; binary selects protobuf over JSON.
+func DecodeFrame(binary bool, data []byte) (Envelope, error) {
+	if binary {
+		return DecodeBinaryEnvelope(data)
+	}
+	return DecodeEnvelope(data)
+}
+
+func envelopeToPB(e Envelope) (*pb.Envelope, error) {
+	out := &pb.Envelope{
+		Type:               e.Type,
+		InstanceId:         e.InstanceID,
+		Runtime:            e.Runtime,
+		RuntimeVersion:     e.RuntimeVersion,
+		ProtocolVersion:    int64(e.ProtocolVersion),
+		MinProtocolVersion: int64(e.MinProtocolVersion),
+		SessionId:          e.SessionID,
+		Ok:                 e.OK,
+		Message:            e.Message,
+		Status:             e.Status,
+		ActiveCalls:        int64(e.ActiveCalls),
+		QueuedCalls:        int64(e.QueuedCalls),
+		Labels:             e.Labels,
+		Capabilities:       e.Capabilities,
+		Limit:              int64(e.Limit),
+		CallId:             e.CallID,
+		Manifest:           ManifestToPB(e.Manifest),
+		ConfigVersion:      e.ConfigVersion,
+	}
+	if u := e.ConfigUpdate; u != nil {
+		out.ConfigUpdate = &pb.ConfigUpdate{Payload: u.Payload, KeyId: u.KeyID, Signature: u.Signature}
+	}
+	for i := range e.Calls {
+		out.Calls = append(out.Calls, ToolCallToPB(&e.Calls[i]))
+	}
+	out.ToolCall = ToolCallToPB(e.ToolCall)
+	var err error
+	if out.ToolResult, err = ToolResultToPB(e.ToolResult); err != nil {
+		return nil, err
+	}
+	if out.Error, err = errorToPB(e.Error); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func envelopeFromPB(m *pb.Envelope) (Envelope, error) {
+	out := Envelope{
+		Type:               m.GetType(),
+		InstanceID:         m.GetInstanceId(),
+		Runtime:            m.GetRuntime(),
+		RuntimeVersion:     m.GetRuntimeVersion(),
+		ProtocolVersion:    int(m.GetProtocolVersion()),
+		MinProtocolVersion: int(m.GetMinProtocolVersion()),
+		SessionID:          m.GetSessionId(),
+		OK:                 m.GetOk(),
+		Message:            m.GetMessage(),
+		Status:             m.GetStatus(),
+		ActiveCalls:        int(m.GetActiveCalls()),
+		QueuedCalls:        int(m.GetQueuedCalls()),
+		Labels:             m.GetLabels(),
+		Capabilities:       m.GetCapabilities(),
+		Limit:              int(m.GetLimit()),
+		CallID:             m.GetCallId(),
+		Manifest:           ManifestFromPB(m.GetManifest()),
+		ToolCall:           ToolCallFromPB(m.GetToolCall()),
+		ConfigVersion:      m.GetConfigVersion(),
+	}
+	if u := m.GetConfigUpdate(); u != nil {
+		out.ConfigUpdate = &ConfigUpdate{Payload: u.GetPayload(), KeyID: u.GetKeyId(), Signature: u.GetSignature()}
+	}
+	for _, c := range m.GetCalls() {
+		out.Calls = append(out.Calls, *ToolCallFromPB(c))
+	}
+	var err error
+	if out.ToolResult, err = ToolResultFromPB(m.GetToolResult()); err != nil {
+		return Envelope{}, err
+	}
+	if out.Error, err = errorFromPB(m.GetError()); err != nil {
+		return Envelope{}, err
+	}
+	return out, nil
+}
+
+// ManifestToPB converts a manifest to its protobuf form.
+func ManifestToPB(m *ToolManifest) *pb.ToolManifest {
+	if m == nil {
+		return nil
+	}
+	out := &pb.ToolManifest{
+		Type:           m.Type,
+		Runtime:        m.Runtime,
+		RuntimeVersion: m.RuntimeVersion,
+		InstanceId:     m.InstanceID,
+	}
+	for _, t := range m.Tools {
+		out.Tools = append(out.Tools, &pb.ToolDescriptor{
+			Name:                 t.Name,
+			Description:          t.Description,
+			InputSchema:          t.InputSchema,
+			OutputSchema:         t.OutputSchema,
+			Capabilities:         t.Capabilities,
+			Risk:                 t.Risk,
+			RequiresUserApproval: t.RequiresUserApproval,
+		})
+	}
+	return out
+}
+
+// ManifestFromPB converts a protobuf manifest back.
+func ManifestFromPB(m *pb.ToolManifest) *ToolManifest {
+	if m == nil {
+		return nil
+	}
+	out := &ToolManifest{
+		Type:           m.GetType(),
+		Runtime:        m.GetRuntime(),
+		RuntimeVersion: m.GetRuntimeVersion(),
+		InstanceID:     m.GetInstanceId(),
+	}
+	for _, t := range m.GetTools() {
+		out.Tools = append(out.Tools, ToolDescriptor{
+			Name:                 t.GetName(),
+			Description:          t.GetDescription(),
+			InputSchema:          rawJSON(t.GetInputSchema()),
+			OutputSchema:         rawJSON(t.GetOutputSchema()),
+			Capabilities:         t.GetCapabilities(),
+			Risk:                 t.GetRisk(),
+			RequiresUserApproval: t.GetRequiresUserApproval(),
+		})
+	}
+	return out
+}
+
+// ToolCallToPB converts a tool call to its protobuf form.
+func ToolCallToPB(c *ToolCall) *pb.ToolCall {
+	if c == nil {
+		return nil
+	}
+	out := &pb.ToolCall{
+		Type:  c.Type,
+		Id:    c.ID,
+		Tool:  c.Tool,
+		Input: c.Input,
+		Context: &pb.CallContext{
+			UserId:   c.Context.UserID,
+			TenantId: c.Context.TenantID,
+			TraceId:  c.Context.TraceID,
+			Host:     c.Context.Host,
+		},
+		Policy: &pb.CallPolicy{
+			TimeoutMs:      int64(c.Policy.TimeoutMs),
+			AllowedDomains: c.Policy.AllowedDomains,
+			StoreCookies:   c.Policy.StoreCookies,
+			MaxOutputBytes: int64(c.Policy.MaxOutputBytes),
+			RateLimitKey:   c.Policy.RateLimitKey,
+			Cache:          c.Policy.Cache,
+			Priority:       int64(c.Policy.Priority),
+		},
+	}
+	if c.Route != nil {
+		out.Route = &pb.CallRoute{Labels: c.Route.Labels}
+	}
+	if r := c.Policy.Retry; r != nil {
+		out.Policy.Retry = &pb.RetryPolicy{
+			MaxAttempts:      int64(r.MaxAttempts),
+			BackoffMs:        int64(r.BackoffMs),
+			MaxBackoffMs:     int64(r.MaxBackoffMs),
+			AttemptTimeoutMs: int64(r.AttemptTimeoutMs),
+		}
+	}
+	return out
+}
+
+// ToolCallFromPB converts a protobuf tool call back.
+func ToolCallFromPB(c *pb.ToolCall) *ToolCall {
+	if c == nil {
+		return nil
+	}
+	ctx, pol := c.GetContext(), c.GetPolicy()
+	out := &ToolCall{
+		Type:  c.GetType(),
+		ID:    c.GetId(),
+		Tool:  c.GetTool(),
+		Input: rawJSON(c.GetInput()),
+		Context: CallContext{
+			UserID:   ctx.GetUserId(),
+			TenantID: ctx.GetTenantId(),
+			TraceID:  ctx.GetTraceId(),
+			Host:     ctx.GetHost(),
+		},
+		Policy: CallPolicy{
+			TimeoutMs:      int(pol.GetTimeoutMs()),
+			AllowedDomains: pol.GetAllowedDomains(),
+			StoreCookies:   pol.GetStoreCookies(),
+			MaxOutputBytes: int(pol.GetMaxOutputBytes()),
+			RateLimitKey:   pol.GetRateLimitKey(),
+			Cache:          pol.GetCache(),
+			Priority:       int(pol.GetPriority()),
+		},
+	}
+	if r := c.GetRoute(); r != nil {
+		out.Route = &CallRoute{Labels: r.GetLabels()}
+	}
+	if r := pol.GetRetry(); r != nil {
+		out.Policy.Retry = &RetryPolicy{
+			MaxAttempts:      int(r.GetMaxAttempts()),
+			BackoffMs:        int(r.GetBackoffMs()),
+			MaxBackoffMs:     int(r.GetMaxBackoffMs()),
+			AttemptTimeoutMs: int(r.GetAttemptTimeoutMs()),
+		}
+	}
+	return out
+}
+
+// ToolResultToPB converts a tool result to its protobuf form.
+func ToolResultToPB(r *ToolResult) (*pb.ToolResult, error) {
+	if r == nil {
+		return nil, nil
+	}
+	te, err := errorToPB(r.Error)
+	if err != nil {
+		return nil, err
+	}
+	out := &pb.ToolResult{
+		Type:     r.Type,
+		Id:       r.ID,
+		Status:   r.Status,
+		Output:   r.Output,
+		Error:    te,
+		Usage:    usageToPB(r.Usage),
+		CacheHit: r.CacheHit,
+	}
+	for _, a := range r.Artifacts {
+		out.Artifacts = append(out.Artifacts, &pb.Artifact{
+			Id:        a.ID,
+			Type:      a.Type,
+			Name:      a.Name,
+			Size:      a.Size,
+			Uri:       a.URI,
+			ExpiresAt: a.ExpiresAt,
+		})
+	}
+	return out, nil
+}
+
+// ToolResultFromPB converts a protobuf tool result back.
+func ToolResultFromPB(r *pb.ToolResult) (*ToolResult, error) {
+	if r == nil {
+		return nil, nil
+	}
+	te, err := errorFromPB(r.GetError())
+	if err != nil {
+		return nil, err
+	}
+	out := &ToolResult{
+		Type:     r.GetType(),
+		ID:       r.GetId(),
+		Status:   r.GetStatus(),
+		Output:   rawJSON(r.GetOutput()),
+		Error:    te,
+		Usage:    usageFromPB(r.GetUsage()),
+		CacheHit: r.GetCacheHit(),
+	}
+	for _, a := range r.GetArtifacts() {
+		out.Artifacts = append(out.Artifacts, Artifact{
+			ID:        a.GetId(),
+			Type:      a.GetType(),
+			Name:      a.GetName(),
+			Size:      a.GetSize(),
+			URI:       a.GetUri(),
+			ExpiresAt: a.GetExpiresAt(),
+		})
+	}
+	return out, nil
+}
+
+func usageToPB(u Usage) *pb.Usage {
+	return &pb.Usage{
+		DurationMs:       u.DurationMs,
+		Attempts:         int64(u.Attempts),
+		BytesIn:          u.BytesIn,
+		BytesOut:         u.BytesOut,
+		PagesOpened:      int64(u.PagesOpened),
+		NavigationMs:     u.NavigationMs,
+		Screenshots:      int64(u.Screenshots),
+		ArtifactBytes:    u.ArtifactBytes,
+		ProviderRequests: int64(u.ProviderRequests),
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+	}
+}
+
+func usageFromPB(u *pb.Usage) Usage {
+	return Usage{
+		DurationMs:       u.GetDurationMs(),
+		Attempts:         int(u.GetAttempts()),
+		BytesIn:          u.GetBytesIn(),
+		BytesOut:         u.GetBytesOut(),
+		PagesOpened:      int(u.GetPagesOpened()),
+		NavigationMs:     u.GetNavigationMs(),
+		Screenshots:      int(u.GetScreenshots()),
+		ArtifactBytes:    u.GetArtifactBytes(),
+		ProviderRequests: int(u.GetProviderRequests()),
+		InputTokens:      u.GetInputTokens(),
+		OutputTokens:     u.GetOutputTokens(),
+	}
+}
+
+func errorToPB(e *ToolError) (*pb.ToolError, error) {
+	if e == nil {
+		return nil, nil
+	}
+	out := &pb.ToolError{
+		Code:         e.Code,
+		Message:      e.Message,
+		Retryable:    e.Retryable,
+		RetryAfterMs: e.RetryAfterMs,
+	}
+	if len(e.Details) > 0 {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			return nil, err
+		}
+		out.Details = b
+	}
+	return out, nil
+}
+
+func errorFromPB(e *pb.ToolError) (*ToolError, error) {
+	if e == nil {
+		return nil, nil
+	}
+	out := &ToolError{
+		Code:         e.GetCode(),
+		Message:      e.GetMessage(),
+		Retryable:    e.GetRetryable(),
+		RetryAfterMs: e.GetRetryAfterMs(),
+	}
+	if len(e.GetDetails()) > 0 {
+		if err := json.Unmarshal(e.GetDetails(), &out.Details); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// rawJSON keeps empty byte fields as nil so omitempty JSON output matches the original.
+func rawJSON(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	return json.RawMessage(b)
+}
@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RemoteConfig 可由宿主下发的 collector 配置（部分更新）：字段为 null / 缺省表示不变，
+// 空列表表示清除远程覆盖、回到本地配置。Collector 只接受本地上限（ceiling）允许的值。
+type RemoteConfig struct {
+	AllowedTools   []string          `json:"allowed_tools"`   // 在本地允许列表之内进一步限定可调用的工具
+	AllowedDomains []string          `json:"allowed_domains"` // 调用可访问的域名（含子域名）
+	RateLimits     []RemoteRateLimit `json:"rate_limits"`     // 优先于本地规则匹配
+}
+
+// RemoteRateLimit 与本地 Collector.RateLimits 规则含义相同。
+type RemoteRateLimit struct {
+	Match         string `json:"match,omitempty"`
+	Domain        string `json:"domain,omitempty"`
+	MinIntervalMs int    `json:"min_interval_ms,omitempty"`
+	JitterMs      int    `json:"jitter_ms,omitempty"`
+	MaxCalls      int    `json:"max_calls,omitempty"`
+	WindowSec     int    `json:"window_sec,omitempty"`
+	DailyCap      int    `json:"daily_cap,omitempty"`
+}
+
+// ConfigPayload 是被签名的内容。Version 单调递增，Collector 拒绝不大于已应用版本的更新（防重放）。
+type ConfigPayload struct {
+	Version    int64        `json:"version"`
+	InstanceID string       `json:"instance_id,omitempty"` // 为空表示任意实例
+	IssuedAt   time.Time    `json:"issued_at"`
+	Config     RemoteConfig `json:"config"`
+}
+
+// ConfigUpdate 是 collector_config_update 携带的签名配置。Payload 为 ConfigPayload 的 JSON 原文，
+// 以字节传输（JSON 中为 base64），签名不受重新编码影响。
+type ConfigUpdate struct {
+	Payload   []byte `json:"payload"`
+	KeyID     string `json:"key_id,omitempty"` // ConfigKeyID(公钥)
+	Signature []byte `json:"signature"`        // Ed25519(Payload)
+}
+
+var ErrConfigSignature = errors.New("config update signature is not valid for any trusted key")
+
+// ConfigKeyID identifies an Ed25519 public key: the first 8 bytes of its SHA-256, in hex.
+func ConfigKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseConfigPublicKey decodes a base64 (standard or URL alphabet) Ed25519 public key.
+func ParseConfigPublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := decodeKey(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is %d bytes, want %d", len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParseConfigPrivateKey decodes a base64 Ed25519 seed (32 bytes) or private key (64 bytes).
+func ParseConfigPrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := decodeKey(s)
+	if err != nil {
+		return nil, err
+	}
+	switch len(b) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(b), nil
+	}
+	return nil, fmt.Errorf("private key is %d bytes, want %d or %d", len(b), ed25519.SeedSize, ed25519.PrivateKeySize)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if b, err := base64.RawStdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// SignConfigUpdate signs p with key.
+func SignConfigUpdate(key ed25519.PrivateKey, p ConfigPayload) (ConfigUpdate, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return ConfigUpdate{}, err
+	}
+	return ConfigUpdate{
+		Payload:   payload,
+		KeyID:     ConfigKeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, payload),
+	}, nil
+}
+
+// VerifyConfigUpdate checks u against the trusted keys and decodes its payload.
+func VerifyConfigUpdate(u ConfigUpdate, trusted []ed25519.PublicKey) (ConfigPayload, error) {
+	for _, pub := range trusted {
+		if u.KeyID != "" && u.KeyID != ConfigKeyID(pub) {
+			continue
+		}
+		if !ed25519.Verify(pub, u.Payload, u.Signature) {
+			continue
+		}
+		var p ConfigPayload
+		if err := json.Unmarshal(u.Payload, &p); err != nil {
+			return ConfigPayload{}, err
+		}
+		return p, nil
+	}
+	return ConfigPayload{}, ErrConfigSignature
+}
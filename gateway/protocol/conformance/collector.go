@@ -0,0 +1,392 @@
+package conformance
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// unknownTool is called to check error results; no collector should provide it.
+const unknownTool = "conformance.no_such_tool"
+
+// CollectorOptions configures CheckCollector.
+type CollectorOptions struct {
+	// Listener accepts the collector's connections; point the collector's ServerURL at it.
+	Listener net.Listener
+	WSPath   string // default /digeino/v1/collector/ws
+	// Token is required from the collector (Bearer or X-Digeino-Token); empty accepts any.
+	Token string
+	// Tool and Input are called in the push check; empty calls a tool that does not exist, which
+	// still exercises the round trip without side effects.
+	Tool  string
+	Input json.RawMessage
+	// Features are offered in collector_hello_ack; nil offers protocol.SupportedFeatures().
+	Features []string
+	// Timeout bounds each wait; default 10s. ConnectTimeout bounds waiting for the collector to
+	// connect and to reconnect after the host drops it; default 60s.
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+	// LargePayload is the size of the large call input in bytes; default 1 MiB.
+	LargePayload int
+}
+
+// collectorCheck is the scripted host.
+type collectorCheck struct {
+	ctx   context.Context
+	opts  CollectorOptions
+	conns chan *websocket.Conn
+	rec   recorder
+
+	mu       sync.Mutex
+	statuses []string // instance_status values seen on the current session
+}
+
+// CheckCollector serves the scripted host on opts.Listener and runs the checks against the first
+// collector that connects. It closes the listener when done.
+func CheckCollector(ctx context.Context, opts CollectorOptions) Report {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = time.Minute
+	}
+	if opts.LargePayload <= 0 {
+		opts.LargePayload = 1 << 20
+	}
+	if opts.WSPath == "" {
+		opts.WSPath = "/digeino/v1/collector/ws"
+	}
+	if len(opts.Input) == 0 {
+		opts.Input = json.RawMessage(`{}`)
+	}
+	report := Report{Role: "collector", Target: "ws://" + opts.Listener.Addr().String() + opts.WSPath}
+	c := &collectorCheck{ctx: ctx, opts: opts, conns: make(chan *websocket.Conn, 4), rec: recorder{report: &report}}
+
+	upgrader := websocket.Upgrader{EnableCompression: true, CheckOrigin: func(*http.Request) bool { return true }}
+	mux := http.NewServeMux()
+	mux.HandleFunc(opts.WSPath, func(w http.ResponseWriter, r *http.Request) {
+		if opts.Token != "" && r.Header.Get("Authorization") != "Bearer "+opts.Token && r.Header.Get("X-Digeino-Token") != opts.Token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.EnableWriteCompression(false)
+		select {
+		case c.conns <- conn:
+		default:
+			_ = conn.Close()
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = srv.Serve(opts.Listener) }()
+	defer srv.Close()
+
+	c.rec.section = SectionHandshake
+	var p *peer
+	var hello protocol.Envelope
+	if !c.rec.run("collector_hello first", func() (string, error) {
+		var err error
+		p, hello, err = c.accept("")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("instance %q, %s %s, protocol %d", hello.InstanceID, hello.Runtime, hello.RuntimeVersion, protocol.PeerVersion(hello)), nil
+	}) {
+		return report
+	}
+	defer func() { p.close() }()
+	report.Features = p.features
+
+	c.rec.section = SectionManifest
+	var tools []protocol.ToolDescriptor
+	c.rec.run("collector_manifest", func() (string, error) {
+		var err error
+		tools, err = c.awaitManifest(p)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d tools", len(tools)), nil
+	})
+
+	c.rec.section = SectionHeartbeat
+	c.rec.run("instance_status", func() (string, error) {
+		st, err := c.awaitStatus(p)
+		return "status " + st, err
+	})
+	c.rec.run("ping / pong", func() (string, error) { return "pong received", p.ping(opts.Timeout) })
+
+	c.rec.section = SectionPush
+	if c.require("tool_call → tool_result", p, protocol.FeaturePush) {
+		c.rec.run("tool_call → tool_result", func() (string, error) {
+			tool := cmp.Or(opts.Tool, unknownTool)
+			res, err := c.call(p, tool, opts.Input, false)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s returned %s", tool, res.Status), nil
+		})
+		c.rec.run("bare tool_call frame", func() (string, error) {
+			res, err := c.call(p, unknownTool, json.RawMessage(`{}`), true)
+			if err != nil {
+				return "", err
+			}
+			return "answered with " + res.Status, nil
+		})
+	}
+
+	c.rec.section = SectionPull
+	if c.require("pull_tasks answered", p, protocol.FeaturePull) {
+		c.rec.run("pull_tasks answered", func() (string, error) { return c.checkPull(p) })
+	}
+
+	c.rec.section = SectionErrors
+	if c.require("unknown tool → error result", p, protocol.FeaturePush) {
+		c.rec.run("unknown tool → error result", func() (string, error) {
+			res, err := c.call(p, unknownTool, json.RawMessage(`{}`), false)
+			if err != nil {
+				return "", err
+			}
+			if res.Status != "error" || res.Error == nil || res.Error.Code == "" {
+				return "", fmt.Errorf("result status %q error %+v, want status error with a code", res.Status, res.Error)
+			}
+			return "code " + res.Error.Code, nil
+		})
+	}
+	c.rec.run("malformed frame ignored", func() (string, error) {
+		if err := p.write(websocket.TextMessage, []byte("{not json")); err != nil {
+			return "", err
+		}
+		return "session kept", p.ping(opts.Timeout)
+	})
+	c.rec.run("unknown type ignored", func() (string, error) {
+		if err := p.send(protocol.Envelope{Type: "conformance_unknown"}); err != nil {
+			return "", err
+		}
+		return "session kept", p.ping(opts.Timeout)
+	})
+	c.rec.run("wire_error tolerated", func() (string, error) {
+		if err := p.send(protocol.NewWireError(protocol.CodeInvalidInput, "conformance: synthetic host error")); err != nil {
+			return "", err
+		}
+		return "session kept", p.ping(opts.Timeout)
+	})
+
+	c.rec.section = SectionLarge
+	if c.require("large tool_call", p, protocol.FeaturePush) {
+		c.rec.run("large tool_call", func() (string, error) {
+			input, _ := json.Marshal(map[string]string{"blob": strings.Repeat("x", opts.LargePayload)})
+			if _, err := c.call(p, unknownTool, input, false); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d KiB call answered", opts.LargePayload>>10), nil
+		})
+	}
+
+	c.rec.section = SectionReconnect
+	c.rec.run("reconnects after drop", func() (string, error) {
+		p.close()
+		start := time.Now()
+		next, again, err := c.accept(hello.InstanceID)
+		if err != nil {
+			return "", err
+		}
+		p = next
+		if _, err := c.awaitManifest(p); err != nil {
+			return "", fmt.Errorf("after reconnect: %w", err)
+		}
+		return fmt.Sprintf("instance %q back after %s with a new manifest", again.InstanceID, time.Since(start).Round(100*time.Millisecond)), nil
+	})
+	return report
+}
+
+// accept waits for the collector to connect and runs the hello exchange. A non-empty instanceID
+// must match the hello.
+func (c *collectorCheck) accept(instanceID string) (*peer, protocol.Envelope, error) {
+	var conn *websocket.Conn
+	select {
+	case conn = <-c.conns:
+	case <-c.ctx.Done():
+		return nil, protocol.Envelope{}, c.ctx.Err()
+	case <-time.After(c.opts.ConnectTimeout):
+		return nil, protocol.Envelope{}, fmt.Errorf("no collector connected within %s", c.opts.ConnectTimeout)
+	}
+	p := newPeer(conn)
+	p.auto = c.auto
+	c.mu.Lock()
+	c.statuses = nil
+	c.mu.Unlock()
+	f, err := p.next(c.opts.Timeout)
+	if err != nil {
+		p.close()
+		return nil, protocol.Envelope{}, fmt.Errorf("waiting for collector_hello: %w", err)
+	}
+	hello := f.env
+	var problem error
+	switch {
+	case f.binary:
+		problem = errors.New("collector_hello must be a JSON text frame, got binary")
+	case f.err != nil:
+		problem = fmt.Errorf("first frame is not a valid envelope: %v", f.err)
+	case hello.Type != protocol.TypeCollectorHello:
+		problem = fmt.Errorf("first frame is %q, want collector_hello before anything else", hello.Type)
+	case hello.InstanceID == "":
+		problem = errors.New("collector_hello has no instance_id")
+	case instanceID != "" && hello.InstanceID != instanceID:
+		problem = fmt.Errorf("reconnected as %q, want the same instance_id %q", hello.InstanceID, instanceID)
+	}
+	if problem != nil {
+		_ = p.sendJSON(protocol.NewWireError(protocol.CodeInvalidInput, problem.Error()))
+		p.close()
+		return nil, hello, problem
+	}
+	ack := protocol.NegotiateHello(hello, "conformance-"+uuid.NewString()[:8], c.opts.Features, 0)
+	if err := p.sendJSON(ack); err != nil {
+		p.close()
+		return nil, hello, err
+	}
+	if !ack.OK {
+		p.close()
+		return nil, hello, fmt.Errorf("hello rejected by the scripted host: %s", ack.Message)
+	}
+	p.setFeatures(ack.Capabilities)
+	return p, hello, nil
+}
+
+// auto answers pings and pull_tasks (with no calls) and records status reports.
+func (c *collectorCheck) auto(p *peer, env protocol.Envelope) {
+	switch env.Type {
+	case protocol.TypePullTasks:
+		_ = p.send(protocol.Envelope{Type: protocol.TypePullTasksAck})
+	case protocol.TypeInstanceStatus:
+		c.mu.Lock()
+		c.statuses = append(c.statuses, env.Status)
+		c.mu.Unlock()
+	default:
+		answerPing(p, env)
+	}
+}
+
+func (c *collectorCheck) awaitManifest(p *peer) ([]protocol.ToolDescriptor, error) {
+	env, err := p.await(c.opts.Timeout, "collector_manifest", isType(protocol.TypeCollectorManifest))
+	if err != nil {
+		return nil, err
+	}
+	if env.Manifest == nil {
+		return nil, errors.New("collector_manifest has no manifest")
+	}
+	for i, t := range env.Manifest.Tools {
+		if t.Name == "" {
+			return nil, fmt.Errorf("tools[%d] has no name", i)
+		}
+		if len(t.InputSchema) > 0 && !json.Valid(t.InputSchema) {
+			return nil, fmt.Errorf("tool %q: input_schema is not valid JSON", t.Name)
+		}
+	}
+	return env.Manifest.Tools, nil
+}
+
+// awaitStatus returns the first instance_status of the session, waiting for it if needed.
+func (c *collectorCheck) awaitStatus(p *peer) (string, error) {
+	c.mu.Lock()
+	seen := c.statuses
+	c.mu.Unlock()
+	st := ""
+	if len(seen) > 0 {
+		st = seen[0]
+	} else {
+		env, err := p.await(c.opts.Timeout, "instance_status", isType(protocol.TypeInstanceStatus))
+		if err != nil {
+			return "", err
+		}
+		st = env.Status
+	}
+	switch st {
+	case "online", "busy", "draining":
+		return st, nil
+	}
+	return st, fmt.Errorf("status %q, want online | busy | draining", st)
+}
+
+// call pushes a tool_call (bare: a ToolCall frame without the envelope, JSON only) and waits for its
+// result, acknowledging it when result_ack was negotiated.
+func (c *collectorCheck) call(p *peer, tool string, input json.RawMessage, bare bool) (protocol.ToolResult, error) {
+	call := protocol.ToolCall{Type: protocol.TypeToolCall, ID: "conf_" + uuid.NewString()[:8], Tool: tool, Input: input}
+	var err error
+	if bare {
+		data, _ := json.Marshal(call)
+		err = p.write(websocket.TextMessage, data)
+	} else {
+		err = p.send(protocol.Envelope{Type: protocol.TypeToolCall, ToolCall: &call})
+	}
+	if err != nil {
+		return protocol.ToolResult{}, err
+	}
+	return c.awaitResult(p, call.ID)
+}
+
+func (c *collectorCheck) awaitResult(p *peer, id string) (protocol.ToolResult, error) {
+	env, err := p.await(c.opts.Timeout, "tool_result for "+id, func(env protocol.Envelope) bool {
+		return env.Type == protocol.TypeToolResult && env.ToolResult != nil && env.ToolResult.ID == id
+	})
+	if err != nil {
+		return protocol.ToolResult{}, err
+	}
+	res := *env.ToolResult
+	if p.has(protocol.FeatureResultAck) {
+		if err := p.send(protocol.NewToolResultAck(id)); err != nil {
+			return res, err
+		}
+	}
+	switch {
+	case res.Status != "success" && res.Status != "error":
+		return res, fmt.Errorf("result status %q, want success | error", res.Status)
+	case res.Status == "error" && res.Error == nil:
+		return res, errors.New("error result has no error object")
+	case len(res.Output) > 0 && !json.Valid(res.Output):
+		return res, errors.New("result output is not valid JSON")
+	}
+	return res, nil
+}
+
+// checkPull waits for the collector to poll and hands it one call.
+func (c *collectorCheck) checkPull(p *peer) (string, error) {
+	env, err := p.await(c.opts.Timeout, "pull_tasks", isType(protocol.TypePullTasks))
+	if err != nil {
+		return "", skipped("collector sent no pull_tasks within %s (pulling disabled?)", c.opts.Timeout)
+	}
+	if env.Limit < 0 {
+		return "", fmt.Errorf("pull_tasks limit %d", env.Limit)
+	}
+	call := protocol.ToolCall{Type: protocol.TypeToolCall, ID: "conf_" + uuid.NewString()[:8], Tool: unknownTool, Input: json.RawMessage(`{}`)}
+	if err := p.send(protocol.Envelope{Type: protocol.TypePullTasksAck, Calls: []protocol.ToolCall{call}}); err != nil {
+		return "", err
+	}
+	res, err := c.awaitResult(p, call.ID)
+	if err != nil {
+		return "", err
+	}
+	return "pulled call answered with " + res.Status, nil
+}
+
+// require records a skip and returns false when p did not negotiate feature.
+func (c *collectorCheck) require(name string, p *peer, feature string) bool {
+	if p.has(feature) {
+		return true
+	}
+	c.rec.skip(name, "collector did not negotiate "+feature)
+	return false
+}
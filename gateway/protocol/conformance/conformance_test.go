@@ -0,0 +1,99 @@
+package conformance
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/collector"
+	"github.com/originaleric/digeino/gateway/devhost"
+	"github.com/originaleric/digeino/gateway/host"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/runtime"
+)
+
+func requireConformant(t *testing.T, r Report) {
+	t.Helper()
+	var out strings.Builder
+	WriteText(&out, r)
+	if !r.Passed() || r.Count(Skip) > 0 {
+		t.Fatalf("report:\n%s", out.String())
+	}
+	t.Logf("report:\n%s", out.String())
+}
+
+func TestGatewayHostConforms(t *testing.T) {
+	h := host.New(host.Options{Token: "tok", Logger: log.New(io.Discard, "", 0)})
+	ts := httptest.NewServer(h.Handler())
+	defer ts.Close()
+	defer h.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	requireConformant(t, CheckHost(ctx, HostOptions{
+		ServerURL:    ts.URL,
+		Token:        "tok",
+		Submit:       HTTPSubmitter(ts.URL+"/calls?wait=false", "tok"),
+		Timeout:      5 * time.Second,
+		LargePayload: 256 << 10,
+	}))
+}
+
+func TestDevHostConforms(t *testing.T) {
+	s := devhost.NewServer("tok", "")
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	requireConformant(t, CheckHost(ctx, HostOptions{
+		ServerURL:    ts.URL,
+		Token:        "tok",
+		Submit:       HTTPSubmitter(ts.URL+"/dev/enqueue", "tok"),
+		Timeout:      5 * time.Second,
+		LargePayload: 256 << 10,
+	}))
+}
+
+func TestDigeinoCollectorConforms(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "web.read"},
+		Handler: func(context.Context, *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			return map[string]any{"ok": true}, nil, nil
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c := collector.NewClient(collector.Options{
+		ServerURL:          "http://" + ln.Addr().String(),
+		Token:              "tok",
+		InstanceID:         "conformance-collector",
+		HeartbeatInterval:  time.Minute,
+		PullInterval:       100 * time.Millisecond,
+		PullBatchSize:      1,
+		ReconnectDelay:     100 * time.Millisecond,
+		ReconnectMaxDelay:  time.Second,
+		MaxConcurrentCalls: 2,
+	}, runtime.New(reg, runtime.Options{}))
+	go func() { _ = c.Run(ctx) }()
+
+	requireConformant(t, CheckCollector(ctx, CollectorOptions{
+		Listener:       ln,
+		Token:          "tok",
+		Tool:           "web.read",
+		Timeout:        5 * time.Second,
+		ConnectTimeout: 10 * time.Second,
+		LargePayload:   256 << 10,
+	}))
+}
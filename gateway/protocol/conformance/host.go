@@ -0,0 +1,541 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/gwversion"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// EchoTool is the only tool the scripted collector advertises; it echoes its input. Hosts route
+// the calls made through Submitter to it.
+const EchoTool = "conformance.echo"
+
+// SessionLabel is advertised by each scripted collector with its instance ID; submitted calls
+// require it in route.labels so a host that honours routing picks the intended session.
+const SessionLabel = "conformance.session"
+
+// Submitter makes the host under test dispatch call. The scripted collector instanceID is then
+// connected and advertises call.Tool (EchoTool); the host may assign its own call ID.
+type Submitter func(ctx context.Context, instanceID string, call protocol.ToolCall) error
+
+// HostOptions configures CheckHost.
+type HostOptions struct {
+	ServerURL string // http(s) or ws(s) base URL of the host
+	WSPath    string // default /digeino/v1/collector/ws
+	Token     string // collector token, sent as Bearer
+	// Submit triggers calls for the push, pull and large-result checks; nil skips them.
+	Submit Submitter
+	// Features are offered in collector_hello; nil offers protocol.SupportedFeatures().
+	Features []string
+	// Timeout bounds each wait; default 10s.
+	Timeout time.Duration
+	// LargePayload is the size of the large frames in bytes; default 1 MiB.
+	LargePayload int
+}
+
+// hostCheck is the scripted collector.
+type hostCheck struct {
+	ctx    context.Context
+	opts   HostOptions
+	url    string
+	header http.Header
+	prefix string // instance ID prefix, unique per run
+	rec    recorder
+}
+
+// CheckHost runs the scripted collector against the host at opts.ServerURL.
+func CheckHost(ctx context.Context, opts HostOptions) Report {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.LargePayload <= 0 {
+		opts.LargePayload = 1 << 20
+	}
+	if opts.Features == nil {
+		opts.Features = protocol.SupportedFeatures()
+	}
+	report := Report{Role: "host", Target: opts.ServerURL}
+	h := &hostCheck{ctx: ctx, opts: opts, prefix: "conformance-" + uuid.NewString()[:8], rec: recorder{report: &report}}
+	wsURL, err := wsEndpoint(opts.ServerURL, opts.WSPath)
+	if err != nil {
+		h.rec.section = SectionHandshake
+		h.rec.run("dial", func() (string, error) { return "", err })
+		return report
+	}
+	report.Target = wsURL
+	h.url, h.header = wsURL, http.Header{}
+	if opts.Token != "" {
+		h.header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	h.rec.section = SectionHandshake
+	var main *peer
+	if !h.rec.run("hello / hello_ack", func() (string, error) {
+		p, ack, err := h.connect(h.prefix+"-a", opts.Features)
+		if err != nil {
+			return "", err
+		}
+		main = p
+		return fmt.Sprintf("session %q, protocol %d", ack.SessionID, protocol.PeerVersion(ack)), nil
+	}) {
+		return report
+	}
+	defer func() { main.close() }()
+	report.Features = main.features
+	h.rec.run("bad token rejected", h.checkBadToken)
+
+	h.rec.section = SectionManifest
+	h.rec.run("collector_manifest", func() (string, error) {
+		if err := main.send(protocol.NewCollectorManifest(echoManifest(""))); err != nil {
+			return "", err
+		}
+		return "host still answers ping afterwards", main.ping(opts.Timeout)
+	})
+	h.rec.run("legacy tool_manifest frame", func() (string, error) {
+		m := echoManifest("")
+		m.Type = protocol.TypeToolManifest
+		data, _ := json.Marshal(m)
+		if err := main.write(websocket.TextMessage, data); err != nil {
+			return "", err
+		}
+		if err := main.send(protocol.NewCollectorManifest(echoManifest(""))); err != nil {
+			return "", err
+		}
+		return "bare ToolManifest accepted", main.ping(opts.Timeout)
+	})
+
+	h.rec.section = SectionHeartbeat
+	h.rec.run("ping / pong", func() (string, error) { return "pong received", main.ping(opts.Timeout) })
+	h.rec.run("instance_status", func() (string, error) {
+		st := protocol.NewInstanceStatus(h.prefix+"-a", "online", 0, main.features)
+		if err := main.send(st); err != nil {
+			return "", err
+		}
+		return "status online accepted", main.ping(opts.Timeout)
+	})
+
+	h.rec.section = SectionPush
+	if h.canSubmit("tool_call delivered", main, protocol.FeaturePush) {
+		h.rec.run("tool_call delivered", func() (string, error) {
+			call, err := h.submitAndReceive(main, h.prefix+"-a", json.RawMessage(`{"n":1}`))
+			if err != nil {
+				return "", err
+			}
+			if err := h.reply(main, call, call.Input); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("call %s pushed and answered", call.ID), nil
+		})
+	}
+
+	h.rec.section = SectionPull
+	h.checkPull()
+
+	h.rec.section = SectionErrors
+	h.rec.run("malformed frame ignored", func() (string, error) {
+		if err := main.write(websocket.TextMessage, []byte("{not json")); err != nil {
+			return "", err
+		}
+		return "session kept", main.ping(opts.Timeout)
+	})
+	h.rec.run("unknown type ignored", func() (string, error) {
+		if err := main.send(protocol.Envelope{Type: "conformance_unknown"}); err != nil {
+			return "", err
+		}
+		return "session kept", main.ping(opts.Timeout)
+	})
+	h.rec.run("hello without instance_id", h.checkAnonymousHello)
+
+	h.rec.section = SectionLarge
+	h.rec.run("large collector_manifest", func() (string, error) {
+		if err := main.send(protocol.NewCollectorManifest(echoManifest(strings.Repeat("x", opts.LargePayload)))); err != nil {
+			return "", err
+		}
+		if err := main.ping(opts.Timeout); err != nil {
+			return "", err
+		}
+		// restore the short manifest for the checks below
+		if err := main.send(protocol.NewCollectorManifest(echoManifest(""))); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d KiB frame accepted", opts.LargePayload>>10), nil
+	})
+	if h.canSubmit("large tool_result", main, protocol.FeaturePush) {
+		h.rec.run("large tool_result", func() (string, error) {
+			call, err := h.submitAndReceive(main, h.prefix+"-a", json.RawMessage(`{}`))
+			if err != nil {
+				return "", err
+			}
+			out, _ := json.Marshal(map[string]string{"blob": strings.Repeat("y", opts.LargePayload)})
+			if err := h.reply(main, call, out); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d KiB result accepted", opts.LargePayload>>10), main.ping(opts.Timeout)
+		})
+	}
+
+	h.rec.section = SectionReconnect
+	h.rec.run("reconnect after drop", func() (string, error) {
+		main.close()
+		p, ack, err := h.connect(h.prefix+"-a", opts.Features)
+		if err != nil {
+			return "", err
+		}
+		main = p
+		if err := main.send(protocol.NewCollectorManifest(echoManifest(""))); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("new session %q", ack.SessionID), main.ping(opts.Timeout)
+	})
+	h.rec.run("duplicate session replaces", func() (string, error) {
+		p, _, err := h.connect(h.prefix+"-a", opts.Features)
+		if err != nil {
+			return "", err
+		}
+		old := main
+		main = p
+		if err := main.send(protocol.NewCollectorManifest(echoManifest(""))); err != nil {
+			return "", err
+		}
+		if err := main.ping(opts.Timeout); err != nil {
+			return "", err
+		}
+		old.close()
+		return "second session with the same instance_id is served", nil
+	})
+	if h.canSubmit("call routed to new session", main, protocol.FeaturePush) {
+		h.rec.run("call routed to new session", func() (string, error) {
+			call, err := h.submitAndReceive(main, h.prefix+"-a", json.RawMessage(`{"n":2}`))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("call %s delivered after reconnect", call.ID), h.reply(main, call, call.Input)
+		})
+	}
+	return report
+}
+
+// connect dials the host and runs the hello exchange.
+func (h *hostCheck) connect(instanceID string, features []string) (*peer, protocol.Envelope, error) {
+	p, err := h.dial(h.header)
+	if err != nil {
+		return nil, protocol.Envelope{}, err
+	}
+	hello := newHello(instanceID)
+	hello.Capabilities = features
+	if err := p.sendJSON(hello); err != nil {
+		p.close()
+		return nil, protocol.Envelope{}, err
+	}
+	f, err := p.next(h.opts.Timeout)
+	if err != nil {
+		p.close()
+		return nil, protocol.Envelope{}, fmt.Errorf("waiting for collector_hello_ack: %w", err)
+	}
+	ack, err := checkHelloAck(f, features)
+	if err != nil {
+		p.close()
+		return nil, ack, err
+	}
+	p.setFeatures(ack.Capabilities)
+	return p, ack, nil
+}
+
+func newHello(instanceID string) protocol.Envelope {
+	hello := protocol.NewCollectorHello(instanceID, "digeino-conformance", gwversion.RuntimeVersion)
+	if instanceID != "" {
+		hello.Labels = map[string]string{SessionLabel: instanceID}
+	}
+	return hello
+}
+
+// echoCall is an EchoTool call routed to instanceID.
+func echoCall(instanceID string, input json.RawMessage) protocol.ToolCall {
+	return protocol.ToolCall{
+		Type:  protocol.TypeToolCall,
+		ID:    "conf_" + uuid.NewString()[:8],
+		Tool:  EchoTool,
+		Input: input,
+		Route: &protocol.CallRoute{Labels: map[string]string{SessionLabel: instanceID}},
+	}
+}
+
+// checkHelloAck validates the first frame the host sends.
+func checkHelloAck(f frame, offered []string) (protocol.Envelope, error) {
+	switch {
+	case f.binary:
+		return protocol.Envelope{}, errors.New("collector_hello_ack must be a JSON text frame, got binary")
+	case f.err != nil:
+		return protocol.Envelope{}, fmt.Errorf("first frame is not a valid envelope: %v", f.err)
+	case f.env.Type != protocol.TypeCollectorHelloAck:
+		return f.env, fmt.Errorf("first frame is %q, want collector_hello_ack before anything else", f.env.Type)
+	case !f.env.OK:
+		return f.env, fmt.Errorf("hello rejected: %s", f.env.Message)
+	case f.env.SessionID == "":
+		return f.env, errors.New("collector_hello_ack has no session_id")
+	}
+	for _, c := range f.env.Capabilities {
+		if !slices.Contains(offered, c) {
+			return f.env, fmt.Errorf("host enabled capability %q the collector did not offer", c)
+		}
+	}
+	return f.env, nil
+}
+
+func (h *hostCheck) dial(header http.Header) (*peer, error) {
+	dialer := websocket.Dialer{EnableCompression: true, HandshakeTimeout: h.opts.Timeout}
+	conn, resp, err := dialer.DialContext(h.ctx, h.url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial %s: %v (HTTP %d)", h.url, err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("dial %s: %v", h.url, err)
+	}
+	conn.EnableWriteCompression(false)
+	p := newPeer(conn)
+	p.auto = answerPing
+	return p, nil
+}
+
+func (h *hostCheck) checkBadToken() (string, error) {
+	if h.opts.Token == "" {
+		return "", skipped("no token configured")
+	}
+	p, err := h.dial(http.Header{"Authorization": {"Bearer " + h.opts.Token + "-wrong"}})
+	if err != nil {
+		return "upgrade refused", nil
+	}
+	defer p.close()
+	// some hosts upgrade first and reject the hello instead
+	if err := p.sendJSON(newHello(h.prefix + "-bad")); err != nil {
+		return "connection closed", nil
+	}
+	f, err := p.next(h.opts.Timeout)
+	if err != nil || f.env.Type == protocol.TypeWireError || (f.env.Type == protocol.TypeCollectorHelloAck && !f.env.OK) {
+		return "hello refused", nil
+	}
+	return "", errors.New("host accepted a collector with a wrong token")
+}
+
+func (h *hostCheck) checkAnonymousHello() (string, error) {
+	p, err := h.dial(h.header)
+	if err != nil {
+		return "", err
+	}
+	defer p.close()
+	if err := p.sendJSON(newHello("")); err != nil {
+		return "connection closed", nil
+	}
+	f, err := p.next(h.opts.Timeout)
+	switch {
+	case err != nil:
+		return "connection closed", nil
+	case f.env.Type == protocol.TypeWireError:
+		code := ""
+		if f.env.Error != nil {
+			code = f.env.Error.Code
+		}
+		return "wire_error " + code, nil
+	case f.env.Type == protocol.TypeCollectorHelloAck && !f.env.OK:
+		return "hello_ack ok=false", nil
+	}
+	return "", fmt.Errorf("host answered %q (ok=%v) to a hello without instance_id", f.env.Type, f.env.OK)
+}
+
+// canSubmit records a skip and returns false when a call-driven check cannot run on p.
+func (h *hostCheck) canSubmit(name string, p *peer, feature string) bool {
+	switch {
+	case h.opts.Submit == nil:
+		h.rec.skip(name, "no submitter configured (--submit-url)")
+	case !p.has(feature):
+		h.rec.skip(name, "host did not negotiate "+feature)
+	default:
+		return true
+	}
+	return false
+}
+
+// submitAndReceive submits an EchoTool call and waits for it to be pushed to p.
+func (h *hostCheck) submitAndReceive(p *peer, instanceID string, input json.RawMessage) (protocol.ToolCall, error) {
+	call := echoCall(instanceID, input)
+	if err := h.opts.Submit(h.ctx, instanceID, call); err != nil {
+		return call, fmt.Errorf("submit: %w", err)
+	}
+	env, err := p.await(h.opts.Timeout, "tool_call for "+EchoTool, func(env protocol.Envelope) bool {
+		return env.Type == protocol.TypeToolCall && env.ToolCall != nil && env.ToolCall.Tool == EchoTool
+	})
+	if err != nil {
+		return call, err
+	}
+	return checkCall(*env.ToolCall)
+}
+
+func checkCall(call protocol.ToolCall) (protocol.ToolCall, error) {
+	if call.ID == "" {
+		return call, errors.New("tool_call has no id")
+	}
+	if len(call.Input) > 0 && !json.Valid(call.Input) {
+		return call, errors.New("tool_call input is not valid JSON")
+	}
+	return call, nil
+}
+
+// reply sends a success result for call and, when result_ack was negotiated, waits for the ack.
+func (h *hostCheck) reply(p *peer, call protocol.ToolCall, output json.RawMessage) error {
+	res := protocol.ToolResult{Type: protocol.TypeToolResult, ID: call.ID, Status: "success", Output: output}
+	if err := p.send(protocol.NewToolResultEnvelope(res)); err != nil {
+		return err
+	}
+	if !p.has(protocol.FeatureResultAck) {
+		return nil
+	}
+	_, err := p.await(h.opts.Timeout, "tool_result_ack for "+call.ID, func(env protocol.Envelope) bool {
+		return env.Type == protocol.TypeToolResultAck && env.CallID == call.ID
+	})
+	return err
+}
+
+// checkPull runs a pull-only session: the host must answer pull_tasks and must not push.
+func (h *hostCheck) checkPull() {
+	features := slices.DeleteFunc(slices.Clone(h.opts.Features), func(f string) bool { return f == protocol.FeaturePush })
+	id := h.prefix + "-pull"
+	var p *peer
+	if !h.rec.run("pull-only session", func() (string, error) {
+		var err error
+		p, _, err = h.connect(id, features)
+		if err != nil {
+			return "", err
+		}
+		if !p.has(protocol.FeaturePull) {
+			return "", skipped("host did not negotiate pull")
+		}
+		return "pull negotiated without push", p.send(protocol.NewCollectorManifest(echoManifest("")))
+	}) {
+		if p != nil {
+			p.close()
+		}
+		return
+	}
+	defer p.close()
+	h.rec.run("empty pull_tasks answered", func() (string, error) {
+		if err := p.send(protocol.NewPullTasks(1)); err != nil {
+			return "", err
+		}
+		_, err := p.await(h.opts.Timeout, "pull_tasks_ack", isType(protocol.TypePullTasksAck))
+		return "pull_tasks_ack received", err
+	})
+	if h.canSubmit("queued call pulled", p, protocol.FeaturePull) {
+		h.rec.run("queued call pulled", func() (string, error) {
+			call := echoCall(id, json.RawMessage(`{"pull":true}`))
+			if err := h.opts.Submit(h.ctx, id, call); err != nil {
+				return "", fmt.Errorf("submit: %w", err)
+			}
+			deadline := time.Now().Add(h.opts.Timeout)
+			for time.Now().Before(deadline) {
+				if err := p.send(protocol.NewPullTasks(1)); err != nil {
+					return "", err
+				}
+				env, err := p.await(h.opts.Timeout, "pull_tasks_ack", func(env protocol.Envelope) bool {
+					return env.Type == protocol.TypePullTasksAck || env.Type == protocol.TypeToolCall
+				})
+				if err != nil {
+					return "", err
+				}
+				if env.Type == protocol.TypeToolCall {
+					return "", errors.New("host pushed tool_call to a collector that did not negotiate push")
+				}
+				for _, c := range env.Calls {
+					if c.Tool != EchoTool {
+						continue
+					}
+					c, err := checkCall(c)
+					if err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("call %s pulled and answered", c.ID), h.reply(p, c, c.Input)
+				}
+				time.Sleep(200 * time.Millisecond)
+			}
+			return "", fmt.Errorf("submitted call not returned by pull_tasks within %s", h.opts.Timeout)
+		})
+	}
+}
+
+// echoManifest advertises EchoTool; description pads the frame for the large-payload check.
+func echoManifest(description string) protocol.ToolManifest {
+	if description == "" {
+		description = "Echoes its input (DigEino conformance kit)."
+	}
+	return protocol.ToolManifest{Type: protocol.TypeToolManifest, Tools: []protocol.ToolDescriptor{{
+		Name:        EchoTool,
+		Description: description,
+		InputSchema: json.RawMessage(`{"type":"object"}`),
+	}}}
+}
+
+// wsEndpoint builds the collector WebSocket URL from a host base URL.
+func wsEndpoint(serverURL, wsPath string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	case "ws", "wss":
+	default:
+		return "", fmt.Errorf("server URL %q must be http(s) or ws(s)", serverURL)
+	}
+	if wsPath == "" {
+		wsPath = "/digeino/v1/collector/ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(wsPath, "/")
+	return u.String(), nil
+}
+
+// HTTPSubmitter posts calls to a host's submission API with an optional Bearer token. An endpoint
+// ending in /dev/enqueue (digeino dev-host) receives {"instance_id","mode":"auto","call"}; any
+// other endpoint receives the ToolCall itself, as gateway/host's POST /calls?wait=false expects.
+func HTTPSubmitter(endpoint, token string) Submitter {
+	return func(ctx context.Context, instanceID string, call protocol.ToolCall) error {
+		var body any = call
+		if u, err := url.Parse(endpoint); err == nil && strings.HasSuffix(u.Path, "/dev/enqueue") {
+			body = map[string]any{"instance_id": instanceID, "mode": "auto", "call": call}
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s: HTTP %d", endpoint, resp.StatusCode)
+		}
+		return nil
+	}
+}
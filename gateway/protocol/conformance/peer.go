@@ -0,0 +1,177 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// frame is one message read from the peer.
+type frame struct {
+	env    protocol.Envelope
+	binary bool
+	raw    []byte
+	err    error // decode error; env is empty
+}
+
+// peer wraps the connection to the implementation under test. A goroutine reads frames so waits
+// can time out without breaking the connection (gorilla read deadlines are fatal).
+type peer struct {
+	conn   *websocket.Conn
+	frames chan frame
+	done   chan struct{} // closed when reading stops; readErr is set
+	// auto answers frames the current wait does not match (ping, pull_tasks); it may be nil.
+	auto func(p *peer, env protocol.Envelope)
+
+	mu       sync.Mutex
+	readErr  error
+	features []string
+}
+
+func newPeer(conn *websocket.Conn) *peer {
+	p := &peer{conn: conn, frames: make(chan frame, 64), done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				p.mu.Lock()
+				p.readErr = err
+				p.mu.Unlock()
+				return
+			}
+			binary := msgType == websocket.BinaryMessage
+			env, err := protocol.DecodeFrame(binary, data)
+			select {
+			case p.frames <- frame{env: env, binary: binary, raw: data, err: err}:
+			case <-time.After(time.Minute):
+				return // nobody is reading any more
+			}
+		}
+	}()
+	return p
+}
+
+// setFeatures records the negotiated features; frames are protobuf afterwards when binary is among
+// them.
+func (p *peer) setFeatures(f []string) {
+	p.mu.Lock()
+	p.features = f
+	p.mu.Unlock()
+	p.conn.EnableWriteCompression(protocol.HasFeature(f, protocol.FeatureCompression))
+}
+
+func (p *peer) has(feature string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return protocol.HasFeature(p.features, feature)
+}
+
+// send writes env as negotiated: protobuf after a binary negotiation, JSON text otherwise.
+func (p *peer) send(env protocol.Envelope) error {
+	if p.has(protocol.FeatureBinary) {
+		data, err := env.EncodeBinary()
+		if err != nil {
+			return err
+		}
+		return p.write(websocket.BinaryMessage, data)
+	}
+	return p.sendJSON(env)
+}
+
+// sendJSON writes env as a JSON text frame regardless of negotiation (handshake frames).
+func (p *peer) sendJSON(env protocol.Envelope) error {
+	data, err := env.Encode()
+	if err != nil {
+		return err
+	}
+	return p.write(websocket.TextMessage, data)
+}
+
+func (p *peer) write(msgType int, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = p.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	return p.conn.WriteMessage(msgType, data)
+}
+
+func (p *peer) close() {
+	_ = p.conn.Close()
+	<-p.done
+}
+
+var errClosed = errors.New("connection closed")
+
+// next returns the next frame, whatever it is.
+func (p *peer) next(timeout time.Duration) (frame, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case f := <-p.frames:
+		return f, nil
+	case <-p.done:
+		// frames read before the close are still delivered
+		select {
+		case f := <-p.frames:
+			return f, nil
+		default:
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return frame{}, fmt.Errorf("%w: %v", errClosed, p.readErr)
+	case <-timer.C:
+		return frame{}, fmt.Errorf("nothing received within %s", timeout)
+	}
+}
+
+// await reads frames until match accepts one; other frames go to p.auto. what names the expected
+// frame in the timeout error.
+func (p *peer) await(timeout time.Duration, what string, match func(protocol.Envelope) bool) (protocol.Envelope, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return protocol.Envelope{}, fmt.Errorf("no %s within %s", what, timeout)
+		}
+		f, err := p.next(left)
+		if err != nil {
+			if errors.Is(err, errClosed) {
+				return protocol.Envelope{}, fmt.Errorf("waiting for %s: %w", what, err)
+			}
+			return protocol.Envelope{}, fmt.Errorf("no %s within %s", what, timeout)
+		}
+		if f.err != nil {
+			continue
+		}
+		if match(f.env) {
+			return f.env, nil
+		}
+		if p.auto != nil {
+			p.auto(p, f.env)
+		}
+	}
+}
+
+// ping sends a ping and waits for the pong.
+func (p *peer) ping(timeout time.Duration) error {
+	if err := p.send(protocol.Envelope{Type: protocol.TypePing}); err != nil {
+		return err
+	}
+	_, err := p.await(timeout, "pong", isType(protocol.TypePong))
+	return err
+}
+
+func isType(t string) func(protocol.Envelope) bool {
+	return func(env protocol.Envelope) bool { return env.Type == t }
+}
+
+// answerPing is the auto handler shared by both roles.
+func answerPing(p *peer, env protocol.Envelope) {
+	if env.Type == protocol.TypePing {
+		_ = p.send(protocol.Envelope{Type: protocol.TypePong})
+	}
+}
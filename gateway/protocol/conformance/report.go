@@ -0,0 +1,127 @@
+// Package conformance checks third-party implementations of the collector wire protocol
+// (digeino conformance). CheckHost drives a host with a scripted collector; CheckCollector drives
+// a collector with a scripted host. Both cover the handshake, manifest, heartbeat, push, pull,
+// error handling, reconnects and large frames, and return a pass/fail report.
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of one check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Sections of the report, in the order they run.
+const (
+	SectionHandshake = "handshake"
+	SectionManifest  = "manifest"
+	SectionHeartbeat = "heartbeat"
+	SectionPush      = "push"
+	SectionPull      = "pull"
+	SectionErrors    = "errors"
+	SectionLarge     = "large payloads"
+	SectionReconnect = "reconnect"
+)
+
+// Result is one check in the report.
+type Result struct {
+	Section string        `json:"section"`
+	Name    string        `json:"name"`
+	Status  Status        `json:"status"`
+	Message string        `json:"message"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+// Report is the output of CheckHost or CheckCollector.
+type Report struct {
+	Role     string   `json:"role"`   // "host" or "collector": the side under test
+	Target   string   `json:"target"` // URL dialed, or address listened on
+	Features []string `json:"features,omitempty"`
+	Results  []Result `json:"results"`
+}
+
+// Count returns the number of results with status s.
+func (r Report) Count(s Status) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed reports whether no check failed.
+func (r Report) Passed() bool {
+	return r.Count(Fail) == 0
+}
+
+// WriteText prints the report as a table grouped by section.
+func WriteText(w io.Writer, r Report) {
+	fmt.Fprintf(w, "%s under test: %s\n", r.Role, r.Target)
+	if len(r.Features) > 0 {
+		fmt.Fprintf(w, "negotiated: %s\n", strings.Join(r.Features, ", "))
+	}
+	section := ""
+	for _, res := range r.Results {
+		if res.Section != section {
+			section = res.Section
+			fmt.Fprintf(w, "\n%s\n", section)
+		}
+		fmt.Fprintf(w, "  [%s] %-28s %s", strings.ToUpper(string(res.Status)), res.Name, res.Message)
+		if res.Status != Skip {
+			fmt.Fprintf(w, " (%s)", res.Elapsed.Round(time.Millisecond))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", r.Count(Pass), r.Count(Fail), r.Count(Skip))
+}
+
+// recorder collects results for a report.
+type recorder struct {
+	report  *Report
+	section string
+}
+
+// run times check and records its outcome: a nil error passes, errSkip skips.
+func (rec *recorder) run(name string, check func() (string, error)) bool {
+	start := time.Now()
+	msg, err := check()
+	res := Result{Section: rec.section, Name: name, Status: Pass, Message: msg, Elapsed: time.Since(start)}
+	switch {
+	case err == nil:
+	case isSkip(err):
+		res.Status, res.Message = Skip, err.Error()
+	default:
+		res.Status, res.Message = Fail, err.Error()
+	}
+	rec.report.Results = append(rec.report.Results, res)
+	return res.Status == Pass
+}
+
+// skip records a check that could not run.
+func (rec *recorder) skip(name, why string) {
+	rec.report.Results = append(rec.report.Results, Result{Section: rec.section, Name: name, Status: Skip, Message: why})
+}
+
+type skipError struct{ msg string }
+
+func (e skipError) Error() string { return e.msg }
+
+func skipped(format string, args ...any) error {
+	return skipError{fmt.Sprintf(format, args...)}
+}
+
+func isSkip(err error) bool {
+	_, ok := err.(skipError)
+	return ok
+}
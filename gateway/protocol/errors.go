@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 标准错误码（ToolResult.error.code）。
+const (
+	CodeInvalidInput     = "INVALID_INPUT"
+	CodeToolNotAllowed   = "TOOL_NOT_ALLOWED"
+	CodeDomainNotAllowed = "DOMAIN_NOT_ALLOWED"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeCircuitOpen      = "CIRCUIT_OPEN"
+	CodeTimeout          = "TIMEOUT"
+	CodeCanceled         = "CANCELED"
+	CodeNavigationFailed = "NAVIGATION_FAILED"
+	CodeSelectorNotFound = "SELECTOR_NOT_FOUND"
+	CodeLoginRequired    = "LOGIN_REQUIRED"
+	CodeProviderError    = "PROVIDER_ERROR"
+	CodeConfigMissing    = "CONFIG_MISSING"
+	CodeToolExecFailed   = "TOOL_EXEC_FAILED"
+	CodeOutputTooLarge   = "OUTPUT_TOO_LARGE"
+	CodeCacheMiss        = "CACHE_MISS"
+	CodeUpgradeRequired  = "UPGRADE_REQUIRED"
+	CodeNoCollector      = "NO_COLLECTOR" // 宿主没有可分派的 collector（未连接、工具或标签不匹配）
+	CodeInternal         = "INTERNAL"
+	CodeUnknown          = "UNKNOWN"
+)
+
+// retryableCodes 默认可重试的错误码；单个错误可通过 Retryable 字段覆盖。
+var retryableCodes = map[string]bool{
+	CodeRateLimited:      true,
+	CodeCircuitOpen:      true,
+	CodeTimeout:          true,
+	CodeNavigationFailed: true,
+	CodeProviderError:    true,
+	CodeNoCollector:      true,
+}
+
+// IsRetryableCode reports whether a code is transient by default.
+func IsRetryableCode(code string) bool {
+	return retryableCodes[code]
+}
+
+// NewToolError builds a ToolError with the default retryable flag for code.
+func NewToolError(code, format string, args ...any) *ToolError {
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	return &ToolError{Code: code, Message: msg, Retryable: IsRetryableCode(code)}
+}
+
+// WrapToolError builds a ToolError whose message is err's text and which unwraps to err.
+func WrapToolError(code string, err error) *ToolError {
+	te := NewToolError(code, "%s", err.Error())
+	te.cause = err
+	return te
+}
+
+// Error implements error so executors can return *ToolError directly.
+func (e *ToolError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the underlying cause, if any.
+func (e *ToolError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.cause
+}
+
+// WithRetryAfter sets the suggested wait before retrying and marks the error retryable.
+func (e *ToolError) WithRetryAfter(d time.Duration) *ToolError {
+	e.Retryable = true
+	e.RetryAfterMs = d.Milliseconds()
+	return e
+}
+
+// WithDetail attaches one structured detail field.
+func (e *ToolError) WithDetail(key string, value any) *ToolError {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// AsToolError extracts a *ToolError from err's chain.
+func AsToolError(err error) (*ToolError, bool) {
+	var te *ToolError
+	if errors.As(err, &te) && te != nil {
+		return te, true
+	}
+	return nil, false
+}
@@ -0,0 +1,5 @@
+// Package pb holds the protobuf form of the gateway wire protocol, used for
+// binary WebSocket frames once both sides negotiate the "binary" capability.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative digeino.proto gateway.proto
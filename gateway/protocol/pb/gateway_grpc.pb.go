@@ -0,0 +1,251 @@
+// DigEino 工具网关 gRPC 服务，消息复用 digeino.proto 中的线协议定义。
+// 修改后在本目录运行 go generate 重新生成（需要 protoc、protoc-gen-go v1.35.1 与 protoc-gen-go-grpc v1.5.1）。
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: gateway.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ToolGateway_GetManifest_FullMethodName = "/digeino.gateway.v1.ToolGateway/GetManifest"
+	ToolGateway_Call_FullMethodName        = "/digeino.gateway.v1.ToolGateway/Call"
+	ToolGateway_CallStream_FullMethodName  = "/digeino.gateway.v1.ToolGateway/CallStream"
+	ToolGateway_GetArtifact_FullMethodName = "/digeino.gateway.v1.ToolGateway/GetArtifact"
+)
+
+// ToolGatewayClient is the client API for ToolGateway service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ToolGatewayClient interface {
+	GetManifest(ctx context.Context, in *GetManifestRequest, opts ...grpc.CallOption) (*ToolManifest, error)
+	// Call 执行工具；工具级错误在 ToolResult.error 中返回，gRPC 状态仅反映传输与鉴权错误。
+	Call(ctx context.Context, in *ToolCall, opts ...grpc.CallOption) (*ToolResult, error)
+	// CallStream 依次推送进度事件，最后一条为 result。
+	CallStream(ctx context.Context, in *ToolCall, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CallEvent], error)
+	// GetArtifact 分块下载 Artifact，首块携带 content_type。
+	GetArtifact(ctx context.Context, in *GetArtifactRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ArtifactChunk], error)
+}
+
+type toolGatewayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewToolGatewayClient(cc grpc.ClientConnInterface) ToolGatewayClient {
+	return &toolGatewayClient{cc}
+}
+
+func (c *toolGatewayClient) GetManifest(ctx context.Context, in *GetManifestRequest, opts ...grpc.CallOption) (*ToolManifest, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ToolManifest)
+	err := c.cc.Invoke(ctx, ToolGateway_GetManifest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toolGatewayClient) Call(ctx context.Context, in *ToolCall, opts ...grpc.CallOption) (*ToolResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ToolResult)
+	err := c.cc.Invoke(ctx, ToolGateway_Call_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *toolGatewayClient) CallStream(ctx context.Context, in *ToolCall, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CallEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ToolGateway_ServiceDesc.Streams[0], ToolGateway_CallStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ToolCall, CallEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ToolGateway_CallStreamClient = grpc.ServerStreamingClient[CallEvent]
+
+func (c *toolGatewayClient) GetArtifact(ctx context.Context, in *GetArtifactRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ArtifactChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ToolGateway_ServiceDesc.Streams[1], ToolGateway_GetArtifact_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetArtifactRequest, ArtifactChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ToolGateway_GetArtifactClient = grpc.ServerStreamingClient[ArtifactChunk]
+
+// ToolGatewayServer is the server API for ToolGateway service.
+// All implementations must embed UnimplementedToolGatewayServer
+// for forward compatibility.
+type ToolGatewayServer interface {
+	GetManifest(context.Context, *GetManifestRequest) (*ToolManifest, error)
+	// Call 执行工具；工具级错误在 ToolResult.error 中返回，gRPC 状态仅反映传输与鉴权错误。
+	Call(context.Context, *ToolCall) (*ToolResult, error)
+	// CallStream 依次推送进度事件，最后一条为 result。
+	CallStream(*ToolCall, grpc.ServerStreamingServer[CallEvent]) error
+	// GetArtifact 分块下载 Artifact，首块携带 content_type。
+	GetArtifact(*GetArtifactRequest, grpc.ServerStreamingServer[ArtifactChunk]) error
+	mustEmbedUnimplementedToolGatewayServer()
+}
+
+// UnimplementedToolGatewayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedToolGatewayServer struct{}
+
+func (UnimplementedToolGatewayServer) GetManifest(context.Context, *GetManifestRequest) (*ToolManifest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetManifest not implemented")
+}
+func (UnimplementedToolGatewayServer) Call(context.Context, *ToolCall) (*ToolResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Call not implemented")
+}
+func (UnimplementedToolGatewayServer) CallStream(*ToolCall, grpc.ServerStreamingServer[CallEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method CallStream not implemented")
+}
+func (UnimplementedToolGatewayServer) GetArtifact(*GetArtifactRequest, grpc.ServerStreamingServer[ArtifactChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method GetArtifact not implemented")
+}
+func (UnimplementedToolGatewayServer) mustEmbedUnimplementedToolGatewayServer() {}
+func (UnimplementedToolGatewayServer) testEmbeddedByValue()                     {}
+
+// UnsafeToolGatewayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ToolGatewayServer will
+// result in compilation errors.
+type UnsafeToolGatewayServer interface {
+	mustEmbedUnimplementedToolGatewayServer()
+}
+
+func RegisterToolGatewayServer(s grpc.ServiceRegistrar, srv ToolGatewayServer) {
+	// If the following call pancis, it indicates UnimplementedToolGatewayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ToolGateway_ServiceDesc, srv)
+}
+
+func _ToolGateway_GetManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetManifestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToolGatewayServer).GetManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ToolGateway_GetManifest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToolGatewayServer).GetManifest(ctx, req.(*GetManifestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToolGateway_Call_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ToolCall)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ToolGatewayServer).Call(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ToolGateway_Call_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ToolGatewayServer).Call(ctx, req.(*ToolCall))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ToolGateway_CallStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ToolCall)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ToolGatewayServer).CallStream(m, &grpc.GenericServerStream[ToolCall, CallEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ToolGateway_CallStreamServer = grpc.ServerStreamingServer[CallEvent]
+
+func _ToolGateway_GetArtifact_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetArtifactRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ToolGatewayServer).GetArtifact(m, &grpc.GenericServerStream[GetArtifactRequest, ArtifactChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ToolGateway_GetArtifactServer = grpc.ServerStreamingServer[ArtifactChunk]
+
+// ToolGateway_ServiceDesc is the grpc.ServiceDesc for ToolGateway service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ToolGateway_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "digeino.gateway.v1.ToolGateway",
+	HandlerType: (*ToolGatewayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetManifest",
+			Handler:    _ToolGateway_GetManifest_Handler,
+		},
+		{
+			MethodName: "Call",
+			Handler:    _ToolGateway_Call_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CallStream",
+			Handler:       _ToolGateway_CallStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetArtifact",
+			Handler:       _ToolGateway_GetArtifact_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gateway.proto",
+}
@@ -37,6 +37,27 @@ type ToolCall struct {
 	Input   json.RawMessage `json:"input"`
 	Context CallContext     `json:"context,omitempty"`
 	Policy  CallPolicy      `json:"policy,omitempty"`
+	// Route 宿主分派调用时的约束（collector 不校验）。
+	Route *CallRoute `json:"route,omitempty"`
+}
+
+// CallRoute 路由约束：只分派给标签全部匹配的 collector。值为 "*" 时只要求存在该标签。
+type CallRoute struct {
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Matches 报告 labels 是否满足约束；nil 约束匹配任何 collector。
+func (r *CallRoute) Matches(labels map[string]string) bool {
+	if r == nil {
+		return true
+	}
+	for k, want := range r.Labels {
+		got, ok := labels[k]
+		if !ok || (want != "*" && got != want) {
+			return false
+		}
+	}
+	return true
 }
 
 // CallContext 调用上下文（审计与多租户隔离）。
@@ -54,6 +75,27 @@ type CallPolicy struct {
 	StoreCookies   string   `json:"store_cookies,omitempty"`
 	MaxOutputBytes int      `json:"max_output_bytes,omitempty"`
 	RateLimitKey   string   `json:"rate_limit_key,omitempty"`
+	// Retry 覆盖工具级重试策略（仅对 retryable 错误生效）。
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Cache 结果缓存模式：空为默认（命中即返回）| bypass | refresh | only。
+	Cache string `json:"cache,omitempty"`
+	// Priority 在 collector 本地排队时的优先级，越大越先执行；同优先级按到达顺序。
+	Priority int `json:"priority,omitempty"`
+}
+
+// 结果缓存模式（CallPolicy.Cache）。
+const (
+	CacheBypass  = "bypass"  // 不读不写缓存
+	CacheRefresh = "refresh" // 跳过读取，执行后写入
+	CacheOnly    = "only"    // 仅读缓存，未命中返回 CACHE_MISS
+)
+
+// RetryPolicy 单次调用的重试参数；零值字段沿用工具级配置。
+type RetryPolicy struct {
+	MaxAttempts      int `json:"max_attempts,omitempty"` // 含首次尝试，1 表示不重试
+	BackoffMs        int `json:"backoff_ms,omitempty"`
+	MaxBackoffMs     int `json:"max_backoff_ms,omitempty"`
+	AttemptTimeoutMs int `json:"attempt_timeout_ms,omitempty"`
 }
 
 // ToolResult 工具执行结果。
@@ -65,17 +107,33 @@ type ToolResult struct {
 	Artifacts []Artifact      `json:"artifacts,omitempty"`
 	Error     *ToolError      `json:"error,omitempty"`
 	Usage     Usage           `json:"usage"`
+	CacheHit  bool            `json:"cache_hit,omitempty"`
 }
 
-// ToolError 结构化错误。
+// ToolError 结构化错误，同时实现 error，执行器可直接返回（见 errors.go）。
 type ToolError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code         string         `json:"code"`
+	Message      string         `json:"message"`
+	Retryable    bool           `json:"retryable,omitempty"`
+	RetryAfterMs int64          `json:"retry_after_ms,omitempty"`
+	Details      map[string]any `json:"details,omitempty"`
+
+	cause error
 }
 
-// Usage 执行用量。
+// Usage 执行用量（计费与审计）。
 type Usage struct {
-	DurationMs int64 `json:"duration_ms"`
+	DurationMs       int64 `json:"duration_ms"`
+	Attempts         int   `json:"attempts,omitempty"`
+	BytesIn          int64 `json:"bytes_in,omitempty"`      // 调用 input 字节数
+	BytesOut         int64 `json:"bytes_out,omitempty"`     // 结果 output 字节数
+	PagesOpened      int   `json:"pages_opened,omitempty"`  // 浏览器页面加载次数（含重试）
+	NavigationMs     int64 `json:"navigation_ms,omitempty"` // 浏览器导航与渲染耗时
+	Screenshots      int   `json:"screenshots,omitempty"`
+	ArtifactBytes    int64 `json:"artifact_bytes,omitempty"`    // 本次写入 Artifact 存储的字节数
+	ProviderRequests int   `json:"provider_requests,omitempty"` // OCR / 搜索等上游计费请求数
+	InputTokens      int64 `json:"input_tokens,omitempty"`
+	OutputTokens     int64 `json:"output_tokens,omitempty"`
 }
 
 // Artifact 大对象引用（截图、文件等）。
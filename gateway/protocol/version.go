@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 线协议版本。未携带 protocol_version 的旧 Collector / 宿主视为 1。
+const (
+	ProtocolVersion              = 2
+	MinCompatibleProtocolVersion = 1
+)
+
+// 握手能力（collector_hello / collector_hello_ack 的 capabilities）。
+// 可选能力仅在双方都声明时启用。
+const (
+	FeaturePush        = "push"        // 宿主主动下发 tool_call
+	FeaturePull        = "pull"        // Collector 通过 pull_tasks 拉取
+	FeatureCancel      = "cancel"      // 宿主发送 cancel_call 取消执行中的调用
+	FeatureCompression = "compression" // permessage-deflate 写压缩
+	FeatureBinary      = "binary"      // 握手后使用 protobuf 二进制帧（见 pb/digeino.proto）
+	FeatureResultAck   = "result_ack"  // 宿主对每个 tool_result 回 tool_result_ack，未确认的结果重连后重发
+	// FeatureRemoteConfig 宿主可下发签名的 collector_config_update；Collector 本地关闭远程配置时不声明
+	FeatureRemoteConfig = "remote_config"
+)
+
+// legacyFeatures 是未声明 capabilities 的 v1 对端隐含支持的能力。
+var legacyFeatures = []string{FeaturePush, FeaturePull}
+
+// SupportedFeatures returns the features this build implements.
+func SupportedFeatures() []string {
+	return []string{FeaturePush, FeaturePull, FeatureCancel, FeatureCompression, FeatureBinary, FeatureResultAck, FeatureRemoteConfig}
+}
+
+// PeerVersion returns the advertised protocol version, treating absent as 1.
+func PeerVersion(env Envelope) int {
+	if env.ProtocolVersion <= 0 {
+		return 1
+	}
+	return env.ProtocolVersion
+}
+
+// PeerFeatures returns the advertised capabilities, or the v1 defaults when absent.
+func PeerFeatures(env Envelope) []string {
+	if len(env.Capabilities) == 0 {
+		return slices.Clone(legacyFeatures)
+	}
+	return env.Capabilities
+}
+
+// NegotiateFeatures returns the features both sides advertise, in local order.
+func NegotiateFeatures(local, peer []string) []string {
+	out := make([]string, 0, len(local))
+	for _, f := range local {
+		if slices.Contains(peer, f) && !slices.Contains(out, f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// HasFeature reports whether f is in the negotiated feature list.
+func HasFeature(features []string, f string) bool {
+	return slices.Contains(features, f)
+}
+
+// CheckPeerVersion rejects peers older than minVersion with UPGRADE_REQUIRED.
+func CheckPeerVersion(env Envelope, minVersion int) error {
+	if minVersion <= 0 {
+		minVersion = MinCompatibleProtocolVersion
+	}
+	if v := PeerVersion(env); v < minVersion {
+		return NewToolError(CodeUpgradeRequired,
+			"protocol version %d is no longer supported; upgrade %s to protocol >= %d",
+			v, peerName(env), minVersion).
+			WithDetail("protocol_version", v).
+			WithDetail("min_protocol_version", minVersion)
+	}
+	return nil
+}
+
+// NegotiateHello builds the host's collector_hello_ack for a hello.
+// hostFeatures nil means SupportedFeatures(); minVersion <= 0 means MinCompatibleProtocolVersion.
+// A rejected hello yields OK=false with the upgrade message and error.
+func NegotiateHello(hello Envelope, sessionID string, hostFeatures []string, minVersion int) Envelope {
+	if hostFeatures == nil {
+		hostFeatures = SupportedFeatures()
+	}
+	if minVersion <= 0 {
+		minVersion = MinCompatibleProtocolVersion
+	}
+	ack := Envelope{
+		Type:               TypeCollectorHelloAck,
+		ProtocolVersion:    ProtocolVersion,
+		MinProtocolVersion: minVersion,
+	}
+	if err := CheckPeerVersion(hello, minVersion); err != nil {
+		te, _ := AsToolError(err)
+		ack.Message = te.Message
+		ack.Error = te
+		return ack
+	}
+	ack.OK = true
+	ack.SessionID = sessionID
+	ack.Capabilities = NegotiateFeatures(hostFeatures, PeerFeatures(hello))
+	return ack
+}
+
+func peerName(env Envelope) string {
+	name := env.Runtime
+	if name == "" {
+		name = "peer"
+	}
+	if env.RuntimeVersion != "" {
+		return fmt.Sprintf("%s %s", name, env.RuntimeVersion)
+	}
+	return name
+}
@@ -12,6 +12,10 @@ const (
 	TypePong              = "pong"
 	TypePullTasks         = "pull_tasks"
 	TypePullTasksAck      = "pull_tasks_ack"
+	TypeCancelCall        = "cancel_call"
+	TypeToolResultAck     = "tool_result_ack"
+	TypeCollectorConfigUpdate = "collector_config_update"
+	TypeCollectorConfigAck    = "collector_config_ack"
 	TypeWireError         = "error"
 )
 
@@ -24,6 +28,10 @@ type Envelope struct {
 	Runtime        string `json:"runtime,omitempty"`
 	RuntimeVersion string `json:"runtime_version,omitempty"`
 
+	// CollectorHello / CollectorHelloAck：协议版本与能力协商（ack 中 capabilities 为协商结果）
+	ProtocolVersion    int `json:"protocol_version,omitempty"`
+	MinProtocolVersion int `json:"min_protocol_version,omitempty"`
+
 	// CollectorHelloAck
 	SessionID string `json:"session_id,omitempty"`
 	OK        bool   `json:"ok,omitempty"`
@@ -32,14 +40,26 @@ type Envelope struct {
 	// InstanceStatus
 	Status       string `json:"status,omitempty"` // online | busy | draining
 	ActiveCalls  int    `json:"active_calls,omitempty"`
+	QueuedCalls  int    `json:"queued_calls,omitempty"` // 本地队列中等待执行的调用数
 	Capabilities []string `json:"capabilities,omitempty"`
 
+	// CollectorHello / InstanceStatus：配置标签与自动探测的事实（os、browser、cookie.<域名> 等），供宿主路由
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// PullTasks
 	Limit int `json:"limit,omitempty"`
 
+	// CancelCall / ToolResultAck
+	CallID string `json:"call_id,omitempty"`
+
 	// PullTasksAck
 	Calls []ToolCall `json:"calls,omitempty"`
 
+	// CollectorConfigUpdate（宿主 → Collector，需协商 remote_config 能力）
+	ConfigUpdate *ConfigUpdate `json:"config_update,omitempty"`
+	// CollectorConfigAck：已应用的配置版本；OK=false 时 Message 说明拒绝原因
+	ConfigVersion int64 `json:"config_version,omitempty"`
+
 	// 内嵌标准工具协议
 	Manifest  *ToolManifest `json:"manifest,omitempty"`
 	ToolCall  *ToolCall     `json:"tool_call,omitempty"`
@@ -50,10 +70,13 @@ type Envelope struct {
 // CollectorHello 建连握手（Collector → 宿主）。
 func NewCollectorHello(instanceID, runtime, version string) Envelope {
 	return Envelope{
-		Type:           TypeCollectorHello,
-		InstanceID:     instanceID,
-		Runtime:        runtime,
-		RuntimeVersion: version,
+		Type:               TypeCollectorHello,
+		InstanceID:         instanceID,
+		Runtime:            runtime,
+		RuntimeVersion:     version,
+		ProtocolVersion:    ProtocolVersion,
+		MinProtocolVersion: MinCompatibleProtocolVersion,
+		Capabilities:       SupportedFeatures(),
 	}
 }
 
@@ -65,14 +88,14 @@ func NewCollectorManifest(m ToolManifest) Envelope {
 	}
 }
 
-// NewInstanceStatus 上报实例状态（兼心跳）。
-func NewInstanceStatus(instanceID, status string, activeCalls int) Envelope {
+// NewInstanceStatus 上报实例状态（兼心跳），capabilities 为握手协商后的能力。
+func NewInstanceStatus(instanceID, status string, activeCalls int, capabilities []string) Envelope {
 	return Envelope{
 		Type:         TypeInstanceStatus,
 		InstanceID:   instanceID,
 		Status:       status,
 		ActiveCalls:  activeCalls,
-		Capabilities: []string{"push", "pull"},
+		Capabilities: capabilities,
 	}
 }
 
@@ -84,6 +107,43 @@ func NewPullTasks(limit int) Envelope {
 	}
 }
 
+// NewCancelCall 取消执行中的调用（需协商 cancel 能力）。
+func NewCancelCall(callID string) Envelope {
+	return Envelope{
+		Type:   TypeCancelCall,
+		CallID: callID,
+	}
+}
+
+// NewToolResultAck 确认已收到某次调用的结果（需协商 result_ack 能力），Collector 据此清理 outbox。
+func NewToolResultAck(callID string) Envelope {
+	return Envelope{
+		Type:   TypeToolResultAck,
+		CallID: callID,
+	}
+}
+
+// NewConfigUpdate 下发签名配置（见 SignConfigUpdate）。
+func NewConfigUpdate(u ConfigUpdate) Envelope {
+	return Envelope{
+		Type:         TypeCollectorConfigUpdate,
+		ConfigUpdate: &u,
+	}
+}
+
+// NewConfigAck 确认配置更新：err 为 nil 表示 version 已生效，否则表示拒绝，version 为仍在生效的版本。
+func NewConfigAck(version int64, err error) Envelope {
+	env := Envelope{
+		Type:          TypeCollectorConfigAck,
+		ConfigVersion: version,
+		OK:            err == nil,
+	}
+	if err != nil {
+		env.Message = err.Error()
+	}
+	return env
+}
+
 // NewToolResultEnvelope 回传执行结果。
 func NewToolResultEnvelope(r ToolResult) Envelope {
 	return Envelope{
@@ -113,12 +173,21 @@ func DecodeEnvelope(data []byte) (Envelope, error) {
 	}
 	switch peek.Type {
 	case TypeToolCall:
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err == nil && env.ToolCall != nil {
+			return env, nil
+		}
 		var call ToolCall
 		if err := json.Unmarshal(data, &call); err != nil {
 			return Envelope{}, err
 		}
 		return Envelope{Type: TypeToolCall, ToolCall: &call}, nil
 	case TypeToolResult:
+		// NewToolResultEnvelope nests the result under "tool_result"; bare ToolResult frames are also accepted.
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err == nil && env.ToolResult != nil {
+			return env, nil
+		}
 		var res ToolResult
 		if err := json.Unmarshal(data, &res); err != nil {
 			return Envelope{}, err
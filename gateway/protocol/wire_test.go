@@ -1,6 +1,11 @@
 package protocol
 
-import "testing"
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+)
 
 func TestDecodeToolCallEnvelope(t *testing.T) {
 	t.Parallel()
@@ -18,3 +23,194 @@ func TestDecodeToolCallEnvelope(t *testing.T) {
 		t.Fatalf("unexpected env: %+v", env)
 	}
 }
+
+func TestToolErrorIsError(t *testing.T) {
+	t.Parallel()
+	var err error = NewToolError(CodeTimeout, "navigate %s", "https://example.com")
+	te, ok := AsToolError(fmt.Errorf("wrapped: %w", err))
+	if !ok || te.Code != CodeTimeout || !te.Retryable {
+		t.Fatalf("unexpected tool error: %+v", te)
+	}
+	if err.Error() != "TIMEOUT: navigate https://example.com" {
+		t.Fatalf("unexpected message: %s", err)
+	}
+	if NewToolError(CodeInvalidInput, "bad").Retryable {
+		t.Fatal("INVALID_INPUT must not be retryable")
+	}
+}
+
+func TestNegotiateHello(t *testing.T) {
+	t.Parallel()
+	hello := NewCollectorHello("inst_1", "digeino", "0.2.0")
+	ack := NegotiateHello(hello, "s1", []string{FeaturePush, FeatureCancel, "progress"}, 0)
+	if !ack.OK || ack.ProtocolVersion != ProtocolVersion {
+		t.Fatalf("expected accepted ack, got %+v", ack)
+	}
+	if !HasFeature(ack.Capabilities, FeatureCancel) || HasFeature(ack.Capabilities, "progress") {
+		t.Fatalf("expected intersection of features, got %v", ack.Capabilities)
+	}
+
+	legacy := Envelope{Type: TypeCollectorHello, InstanceID: "old", Runtime: "digeino", RuntimeVersion: "0.1.0"}
+	ack = NegotiateHello(legacy, "s2", nil, 0)
+	if !ack.OK || HasFeature(ack.Capabilities, FeatureCancel) || !HasFeature(ack.Capabilities, FeaturePull) {
+		t.Fatalf("expected legacy collector to get push/pull only, got %+v", ack)
+	}
+
+	ack = NegotiateHello(legacy, "s3", nil, 2)
+	if ack.OK || ack.Error == nil || ack.Error.Code != CodeUpgradeRequired {
+		t.Fatalf("expected UPGRADE_REQUIRED, got %+v", ack)
+	}
+}
+
+func TestDecodeNestedToolResultEnvelope(t *testing.T) {
+	t.Parallel()
+	data, err := NewToolResultEnvelope(ToolResult{Type: TypeToolResult, ID: "c1", Status: "success"}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := DecodeEnvelope(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.ToolResult == nil || env.ToolResult.ID != "c1" {
+		t.Fatalf("expected nested result, got %+v", env.ToolResult)
+	}
+}
+
+func TestBinaryEnvelopeRoundTrip(t *testing.T) {
+	t.Parallel()
+	in := NewToolResultEnvelope(ToolResult{
+		Type:   TypeToolResult,
+		ID:     "c1",
+		Status: "error",
+		Output: []byte(`{"markdown":"# title"}`),
+		Error:  NewToolError(CodeRateLimited, "slow down").WithDetail("key", "wechat"),
+		Usage:  Usage{DurationMs: 12, Attempts: 2},
+	})
+	data, err := in.EncodeBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecodeFrame(true, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := in.Encode()
+	got, _ := out.Encode()
+	if string(want) != string(got) {
+		t.Fatalf("round trip mismatch:\nwant %s\ngot  %s", want, got)
+	}
+}
+
+func TestBinaryEnvelopeKeepsQueueFields(t *testing.T) {
+	t.Parallel()
+	for _, in := range []Envelope{
+		{Type: TypeToolCall, ToolCall: &ToolCall{Type: TypeToolCall, ID: "c1", Tool: "x", Policy: CallPolicy{Priority: 7, RateLimitKey: "xhs"}}},
+		func() Envelope {
+			env := NewInstanceStatus("i1", "busy", 1, nil)
+			env.QueuedCalls = 3
+			return env
+		}(),
+	} {
+		data, err := in.EncodeBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := DecodeFrame(true, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := in.Encode()
+		got, _ := out.Encode()
+		if string(want) != string(got) {
+			t.Fatalf("round trip mismatch:\nwant %s\ngot  %s", want, got)
+		}
+	}
+}
+
+func TestBinaryEnvelopeKeepsLabelsAndRoute(t *testing.T) {
+	t.Parallel()
+	hello := NewCollectorHello("i1", "digeino", "0.1.0")
+	hello.Labels = map[string]string{"region": "cn-east", "cookie.xiaohongshu.com": "valid"}
+	for _, in := range []Envelope{
+		hello,
+		{Type: TypeToolCall, ToolCall: &ToolCall{Type: TypeToolCall, ID: "c1", Tool: "x",
+			Route: &CallRoute{Labels: map[string]string{"cookie.xiaohongshu.com": "*"}}}},
+	} {
+		data, err := in.EncodeBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := DecodeFrame(true, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := in.Encode()
+		got, _ := out.Encode()
+		if string(want) != string(got) {
+			t.Fatalf("round trip mismatch:\nwant %s\ngot  %s", want, got)
+		}
+	}
+}
+
+func TestCallRouteMatches(t *testing.T) {
+	t.Parallel()
+	labels := map[string]string{"region": "cn-east", "browser.headless": "false"}
+	cases := []struct {
+		route *CallRoute
+		want  bool
+	}{
+		{nil, true},
+		{&CallRoute{}, true},
+		{&CallRoute{Labels: map[string]string{"region": "cn-east"}}, true},
+		{&CallRoute{Labels: map[string]string{"region": "us-west"}}, false},
+		{&CallRoute{Labels: map[string]string{"browser.headless": "*"}}, true},
+		{&CallRoute{Labels: map[string]string{"cookie.xiaohongshu.com": "*"}}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.route.Matches(labels); got != tc.want {
+			t.Errorf("%+v.Matches = %v, want %v", tc.route, got, tc.want)
+		}
+	}
+}
+
+func TestConfigUpdateSurvivesBinaryAndVerifies(t *testing.T) {
+	t.Parallel()
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	other, _, _ := ed25519.GenerateKey(nil)
+	u, err := SignConfigUpdate(priv, ConfigPayload{Version: 7, InstanceID: "i1", Config: RemoteConfig{AllowedTools: []string{"x"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := NewConfigUpdate(u).EncodeBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := DecodeFrame(true, data)
+	if err != nil || env.Type != TypeCollectorConfigUpdate || env.ConfigUpdate == nil {
+		t.Fatalf("decoded %+v, %v", env, err)
+	}
+	p, err := VerifyConfigUpdate(*env.ConfigUpdate, []ed25519.PublicKey{other, pub})
+	if err != nil || p.Version != 7 || p.InstanceID != "i1" || len(p.Config.AllowedTools) != 1 || p.Config.AllowedDomains != nil {
+		t.Fatalf("verified %+v, %v", p, err)
+	}
+	if _, err := VerifyConfigUpdate(*env.ConfigUpdate, []ed25519.PublicKey{other}); !errors.Is(err, ErrConfigSignature) {
+		t.Fatalf("untrusted key: err = %v", err)
+	}
+	env.ConfigUpdate.Payload[len(env.ConfigUpdate.Payload)-2] ^= 1
+	if _, err := VerifyConfigUpdate(*env.ConfigUpdate, []ed25519.PublicKey{pub}); !errors.Is(err, ErrConfigSignature) {
+		t.Fatalf("tampered payload: err = %v", err)
+	}
+
+	ack, _ := DecodeFrame(true, must(NewConfigAck(7, errors.New("no")).EncodeBinary()))
+	if ack.Type != TypeCollectorConfigAck || ack.ConfigVersion != 7 || ack.OK || ack.Message != "no" {
+		t.Fatalf("ack = %+v", ack)
+	}
+}
+
+func must(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
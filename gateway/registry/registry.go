@@ -10,12 +10,20 @@ import (
 // Handler executes a tool and returns structured output plus optional artifacts.
 type Handler func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error)
 
+// CachePredicate reports whether a call's result may be served from the result cache.
+type CachePredicate func(call *protocol.ToolCall) bool
+
 // Entry binds a tool name to metadata and handler.
 type Entry struct {
 	Descriptor protocol.ToolDescriptor
 	Handler    Handler
+	// Cacheable marks read-only tools; nil means results are never cached.
+	Cacheable CachePredicate
 }
 
+// AlwaysCacheable is a CachePredicate for tools whose output depends only on input.
+func AlwaysCacheable(*protocol.ToolCall) bool { return true }
+
 // Registry holds gateway-exposed tools.
 type Registry struct {
 	entries map[string]Entry
@@ -0,0 +1,197 @@
+package runtime
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breakerOutcome classifies a finished execution for the circuit breaker.
+type breakerOutcome int
+
+const (
+	// outcomeIgnored does not affect the failure count (policy or input errors).
+	outcomeIgnored breakerOutcome = iota
+	outcomeSuccess
+	outcomeFailure
+)
+
+type circuit struct {
+	state       breakerState
+	failures    int
+	lastFailure time.Time
+	window      time.Duration // failures further apart than this are not consecutive
+	openUntil   time.Time
+	probing     bool
+}
+
+// stale reports whether c no longer holds state worth keeping: a closed circuit whose last failure
+// is older than its window, or an open one that expired a window ago without a probe.
+func (c *circuit) stale(now time.Time) bool {
+	switch c.state {
+	case breakerClosed:
+		return now.Sub(c.lastFailure) > c.window
+	case breakerOpen:
+		return now.Sub(c.openUntil) > c.window
+	default:
+		return false
+	}
+}
+
+// breakers tracks circuits keyed by tool and target domain. Circuits exist only while a key has
+// recent failures: they are removed on success and pruned once stale, so one-off failures on many
+// distinct domains do not accumulate.
+type breakers struct {
+	mu        sync.Mutex
+	circuits  map[string]*circuit
+	now       func() time.Time
+	lastPrune time.Time
+}
+
+func newBreakers() *breakers {
+	return &breakers{circuits: make(map[string]*circuit), now: time.Now}
+}
+
+// allow reports whether a call may proceed. In half-open state only one probe is let through.
+func (b *breakers) allow(key string, p BreakerPolicy) bool {
+	if p.FailureThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		return true
+	}
+	switch c.state {
+	case breakerOpen:
+		if b.now().Before(c.openUntil) {
+			return false
+		}
+		c.state = breakerHalfOpen
+		c.probing = true
+		return true
+	case breakerHalfOpen:
+		if c.probing {
+			return false
+		}
+		c.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the circuit after an execution that allow() let through.
+func (b *breakers) record(key string, p BreakerPolicy, outcome breakerOutcome) {
+	if p.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	c, ok := b.circuits[key]
+	if !ok {
+		if outcome != outcomeFailure {
+			return
+		}
+		b.pruneLocked(now, openDuration(p))
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	switch outcome {
+	case outcomeSuccess:
+		delete(b.circuits, key)
+	case outcomeFailure:
+		c.probing = false
+		c.window = openDuration(p)
+		if c.state == breakerClosed && now.Sub(c.lastFailure) > c.window {
+			c.failures = 0
+		}
+		c.failures++
+		c.lastFailure = now
+		if c.state == breakerHalfOpen || c.failures >= p.FailureThreshold {
+			c.state = breakerOpen
+			c.openUntil = now.Add(c.window)
+		}
+	default:
+		// a half-open probe that ended without a verdict frees the slot for the next probe
+		c.probing = false
+	}
+}
+
+// pruneLocked drops stale circuits, at most once per interval.
+func (b *breakers) pruneLocked(now time.Time, interval time.Duration) {
+	if now.Sub(b.lastPrune) < interval {
+		return
+	}
+	b.lastPrune = now
+	for key, c := range b.circuits {
+		if c.stale(now) {
+			delete(b.circuits, key)
+		}
+	}
+}
+
+// retryAfter returns the remaining open time for key.
+func (b *breakers) retryAfter(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok && c.state == breakerOpen {
+		if d := c.openUntil.Sub(b.now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// outcomeFor counts execution failures (not policy or input rejections) toward the breaker.
+func outcomeFor(code string) breakerOutcome {
+	switch code {
+	case protocol.CodeToolExecFailed, protocol.CodeTimeout, protocol.CodeNavigationFailed, protocol.CodeProviderError:
+		return outcomeFailure
+	default:
+		return outcomeIgnored
+	}
+}
+
+func openDuration(p BreakerPolicy) time.Duration {
+	if p.OpenDuration > 0 {
+		return p.OpenDuration
+	}
+	return 30 * time.Second
+}
+
+// breakerKey combines tool name and the host of input.url, when present.
+func breakerKey(call *protocol.ToolCall) string {
+	return call.Tool + "|" + targetDomain(call.Input)
+}
+
+func targetDomain(input json.RawMessage) string {
+	if len(input) == 0 {
+		return ""
+	}
+	var in struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(input, &in); err != nil || in.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(strings.TrimSpace(in.URL))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,45 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/cache"
+	"github.com/originaleric/digeino/gateway/policy"
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+)
+
+// cacheKey returns the result-cache key and TTL, or "" when the call must not use the cache.
+func (st *runtimeState) cacheKey(entry registry.Entry, call *protocol.ToolCall, tp ToolPolicy) (string, time.Duration) {
+	if st.opts.Cache == nil || entry.Cacheable == nil || call.Policy.Cache == protocol.CacheBypass {
+		return "", 0
+	}
+	ttl := tp.CacheTTL
+	if ttl == 0 {
+		ttl = st.opts.CacheTTL
+	}
+	if ttl <= 0 || !entry.Cacheable(call) {
+		return "", 0
+	}
+	return cache.Key(call, policy.MergeDomains(&call.Policy, st.opts.ConfigDomains)), ttl
+}
+
+func (r *Runtime) cacheGet(ctx context.Context, key string) (cache.Entry, bool) {
+	e, ok, err := r.load().opts.Cache.Get(ctx, key)
+	if err != nil || !ok {
+		return cache.Entry{}, false
+	}
+	return e, true
+}
+
+func (r *Runtime) cacheSet(ctx context.Context, key, tool string, output json.RawMessage, ttl time.Duration) {
+	now := time.Now()
+	_ = r.load().opts.Cache.Set(ctx, key, cache.Entry{
+		Tool:      tool,
+		Output:    output,
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// DefaultTimeout is used when neither the call nor the tool policy sets a timeout.
+const DefaultTimeout = 60 * time.Second
+
+// DefaultToolKey is the ToolPolicies key applied to tools without their own entry.
+const DefaultToolKey = "*"
+
+// Error codes produced by runtime-level guards.
+const (
+	CodeCircuitOpen = protocol.CodeCircuitOpen
+	CodeRateLimited = protocol.CodeRateLimited
+)
+
+// ToolPolicy configures timeouts, bulkhead and circuit breaker for one tool.
+type ToolPolicy struct {
+	DefaultTimeout time.Duration
+	MaxTimeout     time.Duration
+	// MaxConcurrent limits parallel executions; 0 means unlimited.
+	MaxConcurrent int
+	// MaxQueue limits callers waiting for a slot once MaxConcurrent is reached.
+	MaxQueue int
+	Breaker  BreakerPolicy
+	Retry    RetryPolicy
+	// CacheTTL overrides Options.CacheTTL for cacheable tools; negative disables caching.
+	CacheTTL time.Duration
+}
+
+// BreakerPolicy opens the circuit after FailureThreshold consecutive execution failures
+// (TOOL_EXEC_FAILED, TIMEOUT, NAVIGATION_FAILED, PROVIDER_ERROR). Failures more than OpenDuration
+// apart are not consecutive.
+type BreakerPolicy struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+func (p ToolPolicy) timeout(requestedMs int) time.Duration {
+	timeout := time.Duration(requestedMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = p.DefaultTimeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if p.MaxTimeout > 0 && timeout > p.MaxTimeout {
+		timeout = p.MaxTimeout
+	}
+	return timeout
+}
+
+func (st *runtimeState) toolPolicy(tool string) ToolPolicy {
+	if p, ok := st.opts.ToolPolicies[tool]; ok {
+		return p
+	}
+	return st.opts.ToolPolicies[DefaultToolKey]
+}
+
+// bulkhead bounds concurrent executions of a single tool with a waiting queue.
+type bulkhead struct {
+	slots   chan struct{}
+	mu      sync.Mutex
+	waiting int
+}
+
+type bulkheads struct {
+	mu    sync.Mutex
+	tools map[string]*bulkhead
+}
+
+func newBulkheads() *bulkheads {
+	return &bulkheads{tools: make(map[string]*bulkhead)}
+}
+
+// resize drops bulkheads whose capacity no longer matches the tool's MaxConcurrent;
+// the next acquire creates one with the new size.
+func (b *bulkheads) resize(policyFor func(tool string) ToolPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for tool, bh := range b.tools {
+		if cap(bh.slots) != policyFor(tool).MaxConcurrent {
+			delete(b.tools, tool)
+		}
+	}
+}
+
+// acquire waits for an execution slot; the returned func releases it.
+func (b *bulkheads) acquire(ctx context.Context, tool string, p ToolPolicy) (func(), error) {
+	if p.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	b.mu.Lock()
+	bh, ok := b.tools[tool]
+	if !ok {
+		bh = &bulkhead{slots: make(chan struct{}, p.MaxConcurrent)}
+		b.tools[tool] = bh
+	}
+	b.mu.Unlock()
+
+	select {
+	case bh.slots <- struct{}{}:
+		return func() { <-bh.slots }, nil
+	default:
+	}
+
+	bh.mu.Lock()
+	if bh.waiting >= p.MaxQueue {
+		bh.mu.Unlock()
+		return nil, protocol.NewToolError(CodeRateLimited, "tool %q is at max concurrency (%d) and queue is full", tool, p.MaxConcurrent).
+			WithDetail("max_concurrent", p.MaxConcurrent)
+	}
+	bh.waiting++
+	bh.mu.Unlock()
+	defer func() {
+		bh.mu.Lock()
+		bh.waiting--
+		bh.mu.Unlock()
+	}()
+
+	select {
+	case bh.slots <- struct{}{}:
+		return func() { <-bh.slots }, nil
+	case <-ctx.Done():
+		return nil, protocol.NewToolError(CodeRateLimited, "timed out waiting for a %q slot: %v", tool, ctx.Err())
+	}
+}
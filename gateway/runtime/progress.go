@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"context"
+	"time"
+)
+
+// 进度阶段。
+const (
+	StageQueued   = "queued"   // 等待工具并发槽位
+	StageRunning  = "running"  // 开始一次尝试
+	StageRetrying = "retrying" // 上次尝试失败，退避后重试
+)
+
+// Progress is an execution milestone reported to streaming transports.
+type Progress struct {
+	CallID  string
+	Stage   string
+	Attempt int
+	Message string
+	Elapsed time.Duration
+}
+
+// ProgressFunc receives progress events; it must not block.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+type progressSink struct {
+	fn    ProgressFunc
+	start time.Time
+}
+
+// WithProgress returns a context whose Execute calls report progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progressSink{fn: fn, start: time.Now()})
+}
+
+func reportProgress(ctx context.Context, p Progress) {
+	sink, ok := ctx.Value(progressKey{}).(*progressSink)
+	if !ok || sink.fn == nil {
+		return
+	}
+	p.Elapsed = time.Since(sink.start)
+	sink.fn(p)
+}
@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+	"github.com/originaleric/digeino/gateway/registry"
+)
+
+// MaxRetryAttempts caps attempts regardless of tool or call settings.
+const MaxRetryAttempts = 10
+
+// RetryPolicy retries retryable ToolErrors with exponential backoff and jitter.
+// All attempts share the call's overall timeout budget.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt; <= 1 disables retries.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// AttemptTimeout bounds a single attempt; 0 lets each attempt use the remaining budget.
+	AttemptTimeout time.Duration
+}
+
+// merge applies non-zero call-level overrides on top of the tool policy.
+func (p RetryPolicy) merge(call *protocol.RetryPolicy) RetryPolicy {
+	if call != nil {
+		if call.MaxAttempts > 0 {
+			p.MaxAttempts = call.MaxAttempts
+		}
+		if call.BackoffMs > 0 {
+			p.InitialBackoff = time.Duration(call.BackoffMs) * time.Millisecond
+		}
+		if call.MaxBackoffMs > 0 {
+			p.MaxBackoff = time.Duration(call.MaxBackoffMs) * time.Millisecond
+		}
+		if call.AttemptTimeoutMs > 0 {
+			p.AttemptTimeout = time.Duration(call.AttemptTimeoutMs) * time.Millisecond
+		}
+	}
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.MaxAttempts > MaxRetryAttempts {
+		p.MaxAttempts = MaxRetryAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 500 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+	return p
+}
+
+// backoff returns the wait before the given retry (1-based) using equal jitter.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// runAttempts executes the handler until success, a non-retryable error or the budget runs out.
+func (r *Runtime) runAttempts(
+	ctx context.Context,
+	entry registry.Entry,
+	call *protocol.ToolCall,
+	tp ToolPolicy,
+) (map[string]any, []protocol.Artifact, int, error) {
+	rp := tp.Retry.merge(call.Policy.Retry)
+	brKey := breakerKey(call)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if !r.breakers.allow(brKey, tp.Breaker) {
+			err := protocol.NewToolError(CodeCircuitOpen, "circuit open for %s after repeated failures", brKey).
+				WithRetryAfter(r.breakers.retryAfter(brKey))
+			return nil, nil, attempt - 1, firstNonNil(lastErr, err)
+		}
+
+		reportProgress(ctx, Progress{CallID: call.ID, Stage: StageRunning, Attempt: attempt})
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if rp.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, rp.AttemptTimeout)
+		}
+		output, artifacts, err := entry.Handler(attemptCtx, call)
+		cancel()
+		if err == nil {
+			r.breakers.record(brKey, tp.Breaker, outcomeSuccess)
+			return output, artifacts, attempt, nil
+		}
+		te := mapError(err)
+		r.breakers.record(brKey, tp.Breaker, outcomeFor(te.Code))
+		lastErr = te
+		if !te.Retryable || attempt >= rp.MaxAttempts || ctx.Err() != nil {
+			return nil, nil, attempt, te
+		}
+
+		wait := rp.backoff(attempt)
+		if ra := time.Duration(te.RetryAfterMs) * time.Millisecond; ra > wait {
+			wait = ra
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			return nil, nil, attempt, te
+		}
+		reportProgress(ctx, Progress{CallID: call.ID, Stage: StageRetrying, Attempt: attempt, Message: te.Error()})
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, attempt, te
+		case <-timer.C:
+		}
+	}
+}
+
+func firstNonNil(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,16 +3,19 @@ package runtime
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/originaleric/digeino/gateway/artifact"
 	"github.com/originaleric/digeino/gateway/audit"
+	"github.com/originaleric/digeino/gateway/cache"
 	"github.com/originaleric/digeino/gateway/gwversion"
 	"github.com/originaleric/digeino/gateway/policy"
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/usage"
 )
 
 // Options configures the gateway runtime.
@@ -22,14 +25,38 @@ type Options struct {
 	ConfigDomains []string
 	ArtifactStore artifact.Store
 	Audit         *audit.Logger
+	// ToolPolicies maps tool name (or DefaultToolKey) to timeout, bulkhead and breaker settings.
+	ToolPolicies map[string]ToolPolicy
+	// Cache stores results of cacheable tools; nil disables caching.
+	Cache cache.Store
+	// CacheTTL applies to cacheable tools whose policy sets no CacheTTL.
+	CacheTTL time.Duration
+	// Usage aggregates per-tenant, per-tool usage; nil disables aggregation.
+	Usage *usage.Aggregator
 }
 
 // Runtime executes ToolCall against a tool registry.
 type Runtime struct {
-	reg       *registry.Registry
-	opts      Options
+	state     atomic.Pointer[runtimeState]
 	audit     *audit.Logger
 	artifacts artifact.Store
+	bulkheads *bulkheads
+	breakers  *breakers
+}
+
+// runtimeState is the registry and options a call runs with; Reload swaps it atomically.
+type runtimeState struct {
+	reg  *registry.Registry
+	opts Options
+}
+
+func (r *Runtime) load() *runtimeState {
+	return r.state.Load()
+}
+
+// Usage returns the usage aggregator (may be nil).
+func (r *Runtime) Usage() *usage.Aggregator {
+	return r.load().opts.Usage
 }
 
 // ArtifactStore returns the configured artifact store (may be nil).
@@ -42,16 +69,40 @@ func New(reg *registry.Registry, opts Options) *Runtime {
 	if lg == nil {
 		lg = audit.NewLogger()
 	}
-	return &Runtime{reg: reg, opts: opts, audit: lg, artifacts: opts.ArtifactStore}
+	r := &Runtime{
+		audit:     lg,
+		artifacts: opts.ArtifactStore,
+		bulkheads: newBulkheads(),
+		breakers:  newBreakers(),
+	}
+	r.state.Store(&runtimeState{reg: reg, opts: opts})
+	return r
+}
+
+// Reload swaps the registry, allow-list, domains, tool policies and cache TTL for calls
+// that start afterwards; in-flight calls finish with the previous values. InstanceID,
+// ArtifactStore, Audit, Cache and Usage are fixed at New and ignored here. Bulkheads whose
+// MaxConcurrent changed are replaced, so callers already holding a slot release it on the old one.
+func (r *Runtime) Reload(reg *registry.Registry, opts Options) {
+	prev := r.load()
+	opts.InstanceID = prev.opts.InstanceID
+	opts.ArtifactStore = prev.opts.ArtifactStore
+	opts.Audit = prev.opts.Audit
+	opts.Cache = prev.opts.Cache
+	opts.Usage = prev.opts.Usage
+	next := &runtimeState{reg: reg, opts: opts}
+	r.state.Store(next)
+	r.bulkheads.resize(next.toolPolicy)
 }
 
 // Manifest builds the current tool manifest.
 func (r *Runtime) Manifest() protocol.ToolManifest {
-	tools := r.reg.List()
-	if len(r.opts.AllowedTools) > 0 {
+	st := r.load()
+	tools := st.reg.List()
+	if len(st.opts.AllowedTools) > 0 {
 		filtered := make([]protocol.ToolDescriptor, 0, len(tools))
 		for _, tool := range tools {
-			if err := policy.ValidateToolAllowed(tool.Name, r.opts.AllowedTools); err == nil {
+			if err := policy.ValidateToolAllowed(tool.Name, st.opts.AllowedTools); err == nil {
 				filtered = append(filtered, tool)
 			}
 		}
@@ -61,7 +112,7 @@ func (r *Runtime) Manifest() protocol.ToolManifest {
 		Type:           protocol.TypeToolManifest,
 		Runtime:        gwversion.RuntimeName,
 		RuntimeVersion: gwversion.RuntimeVersion,
-		InstanceID:     r.opts.InstanceID,
+		InstanceID:     st.opts.InstanceID,
 		Tools:          tools,
 	}
 }
@@ -76,90 +127,133 @@ func (r *Runtime) Execute(ctx context.Context, call *protocol.ToolCall) *protoco
 		result.ID = call.ID
 	}
 
+	st := r.load()
+	meter := &usage.Meter{}
+	ctx = usage.WithMeter(ctx, meter)
 	defer func() {
 		result.Usage.DurationMs = time.Since(start).Milliseconds()
+		fillUsage(result, call, meter)
 		r.audit.LogCall(call, result)
+		st.opts.Usage.Record(call, result)
 	}()
 
-	if err := r.validateCall(call); err != nil {
+	if err := st.validateCall(call); err != nil {
 		result.Status = "error"
 		result.Error = mapError(err)
 		return result
 	}
 
-	entry, ok := r.reg.Get(call.Tool)
+	entry, ok := st.reg.Get(call.Tool)
 	if !ok {
 		result.Status = "error"
-		result.Error = &protocol.ToolError{Code: policy.CodeToolNotAllowed, Message: fmt.Sprintf("unknown tool %q", call.Tool)}
+		result.Error = protocol.NewToolError(policy.CodeToolNotAllowed, "unknown tool %q", call.Tool)
 		return result
 	}
 
-	timeout := time.Duration(call.Policy.TimeoutMs) * time.Millisecond
-	if timeout <= 0 {
-		timeout = 60 * time.Second
+	tp := st.toolPolicy(call.Tool)
+	cacheKey, ttl := st.cacheKey(entry, call, tp)
+	if cacheKey != "" && call.Policy.Cache != protocol.CacheRefresh {
+		if hit, ok := r.cacheGet(ctx, cacheKey); ok {
+			result.CacheHit = true
+			r.finish(result, call, hit.Output, nil)
+			return result
+		}
 	}
-	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	if call.Policy.Cache == protocol.CacheOnly {
+		result.Status = "error"
+		result.Error = protocol.NewToolError(protocol.CodeCacheMiss, "no cached result for %q", call.Tool)
+		return result
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, tp.timeout(call.Policy.TimeoutMs))
 	defer cancel()
 
-	output, artifacts, err := entry.Handler(execCtx, call)
+	reportProgress(ctx, Progress{CallID: call.ID, Stage: StageQueued})
+	release, err := r.bulkheads.acquire(execCtx, call.Tool, tp)
 	if err != nil {
 		result.Status = "error"
 		result.Error = mapError(err)
 		return result
 	}
+	defer release()
 
-	outBytes, err := json.Marshal(output)
+	output, artifacts, attempts, err := r.runAttempts(execCtx, entry, call, tp)
+	result.Usage.Attempts = attempts
 	if err != nil {
 		result.Status = "error"
-		result.Error = &protocol.ToolError{Code: "INTERNAL", Message: err.Error()}
+		result.Error = mapError(err)
 		return result
 	}
 
-	maxOut := call.Policy.MaxOutputBytes
-	if maxOut > 0 && len(outBytes) > maxOut {
+	outBytes, err := json.Marshal(output)
+	if err != nil {
 		result.Status = "error"
-		result.Error = &protocol.ToolError{
-			Code:    "OUTPUT_TOO_LARGE",
-			Message: fmt.Sprintf("output exceeds max_output_bytes (%d)", maxOut),
-		}
+		result.Error = protocol.NewToolError(protocol.CodeInternal, "%s", err.Error())
 		return result
 	}
+	r.finish(result, call, outBytes, artifacts)
+	if cacheKey != "" && result.Status == "success" && len(artifacts) == 0 {
+		r.cacheSet(ctx, cacheKey, call.Tool, outBytes, ttl)
+	}
+	return result
+}
 
+// finish applies output limits and fills a successful result.
+func (r *Runtime) finish(result *protocol.ToolResult, call *protocol.ToolCall, output json.RawMessage, artifacts []protocol.Artifact) {
+	maxOut := call.Policy.MaxOutputBytes
+	if maxOut > 0 && len(output) > maxOut {
+		result.Status = "error"
+		result.Error = protocol.NewToolError(protocol.CodeOutputTooLarge, "output exceeds max_output_bytes (%d)", maxOut)
+		return
+	}
 	result.Status = "success"
-	result.Output = outBytes
+	result.Output = output
 	result.Artifacts = artifacts
-	return result
 }
 
-func (r *Runtime) validateCall(call *protocol.ToolCall) error {
+// fillUsage adds payload sizes and executor-reported counters to result.Usage.
+func fillUsage(result *protocol.ToolResult, call *protocol.ToolCall, meter *usage.Meter) {
+	meter.Fill(&result.Usage)
+	if call != nil {
+		result.Usage.BytesIn = int64(len(call.Input))
+	}
+	result.Usage.BytesOut = int64(len(result.Output))
+	for _, a := range result.Artifacts {
+		result.Usage.ArtifactBytes += a.Size
+	}
+}
+
+func (st *runtimeState) validateCall(call *protocol.ToolCall) error {
 	if call == nil {
-		return fmt.Errorf("%s: nil tool call", policy.CodeInvalidInput)
+		return protocol.NewToolError(policy.CodeInvalidInput, "nil tool call")
 	}
 	if strings.TrimSpace(call.ID) == "" {
-		return fmt.Errorf("%s: call id is required", policy.CodeInvalidInput)
+		return protocol.NewToolError(policy.CodeInvalidInput, "call id is required")
 	}
 	if strings.TrimSpace(call.Tool) == "" {
-		return fmt.Errorf("%s: tool name is required", policy.CodeInvalidInput)
+		return protocol.NewToolError(policy.CodeInvalidInput, "tool name is required")
 	}
 	if call.Type != "" && call.Type != protocol.TypeToolCall {
-		return fmt.Errorf("%s: unexpected type %q", policy.CodeInvalidInput, call.Type)
+		return protocol.NewToolError(policy.CodeInvalidInput, "unexpected type %q", call.Type)
 	}
-	return policy.ValidateToolAllowed(call.Tool, r.opts.AllowedTools)
+	return policy.ValidateToolAllowed(call.Tool, st.opts.AllowedTools)
 }
 
+// mapError converts a handler or validation error into a wire ToolError.
+// Typed *protocol.ToolError values pass through; context errors become TIMEOUT/CANCELED.
 func mapError(err error) *protocol.ToolError {
 	if err == nil {
-		return &protocol.ToolError{Code: "UNKNOWN", Message: "unknown error"}
-	}
-	msg := err.Error()
-	for _, code := range []string{
-		policy.CodeDomainNotAllowed,
-		policy.CodeToolNotAllowed,
-		policy.CodeInvalidInput,
-	} {
-		if strings.HasPrefix(msg, code+":") || strings.HasPrefix(msg, code) {
-			return &protocol.ToolError{Code: code, Message: strings.TrimPrefix(strings.TrimPrefix(msg, code+":"), code)}
-		}
+		return &protocol.ToolError{Code: protocol.CodeUnknown, Message: "unknown error"}
+	}
+	if te, ok := protocol.AsToolError(err); ok {
+		out := *te
+		return &out
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return protocol.NewToolError(protocol.CodeTimeout, "%s", err.Error())
+	case errors.Is(err, context.Canceled):
+		return protocol.NewToolError(protocol.CodeCanceled, "%s", err.Error())
 	}
-	return &protocol.ToolError{Code: "TOOL_EXEC_FAILED", Message: msg}
+	return protocol.NewToolError(protocol.CodeToolExecFailed, "%s", err.Error())
 }
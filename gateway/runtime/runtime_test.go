@@ -3,10 +3,16 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/originaleric/digeino/gateway/cache"
 	"github.com/originaleric/digeino/gateway/protocol"
 	"github.com/originaleric/digeino/gateway/registry"
+	"github.com/originaleric/digeino/gateway/usage"
 )
 
 func TestExecuteUnknownTool(t *testing.T) {
@@ -74,3 +80,306 @@ func TestValidateCallRequiresID(t *testing.T) {
 	}
 	_, _ = json.Marshal(res)
 }
+
+func TestToolPolicyTimeout(t *testing.T) {
+	t.Parallel()
+	p := ToolPolicy{DefaultTimeout: 5 * time.Second, MaxTimeout: 10 * time.Second}
+	if got := p.timeout(0); got != 5*time.Second {
+		t.Fatalf("default timeout: got %s", got)
+	}
+	if got := p.timeout(60_000); got != 10*time.Second {
+		t.Fatalf("clamped timeout: got %s", got)
+	}
+	if got := (ToolPolicy{}).timeout(0); got != DefaultTimeout {
+		t.Fatalf("fallback timeout: got %s", got)
+	}
+}
+
+func TestExecuteBulkheadQueueFull(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "slow.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			started <- struct{}{}
+			<-unblock
+			return map[string]any{}, nil, nil
+		},
+	})
+	rt := New(reg, Options{ToolPolicies: map[string]ToolPolicy{
+		"slow.tool": {MaxConcurrent: 1, MaxQueue: 0},
+	}})
+
+	done := make(chan *protocol.ToolResult, 1)
+	go func() {
+		done <- rt.Execute(context.Background(), &protocol.ToolCall{ID: "a", Tool: "slow.tool"})
+	}()
+	<-started
+
+	res := rt.Execute(context.Background(), &protocol.ToolCall{ID: "b", Tool: "slow.tool"})
+	if res.Error == nil || res.Error.Code != CodeRateLimited {
+		t.Fatalf("expected RATE_LIMITED, got %+v", res)
+	}
+	close(unblock)
+	if first := <-done; first.Status != "success" {
+		t.Fatalf("expected first call to succeed, got %+v", first)
+	}
+}
+
+func TestExecuteCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	fail := true
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "flaky.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			if fail {
+				return nil, nil, errors.New("page crashed")
+			}
+			return map[string]any{"ok": true}, nil, nil
+		},
+	})
+	rt := New(reg, Options{ToolPolicies: map[string]ToolPolicy{
+		DefaultToolKey: {Breaker: BreakerPolicy{FailureThreshold: 2, OpenDuration: time.Minute}},
+	}})
+	now := time.Now()
+	rt.breakers.now = func() time.Time { return now }
+
+	call := func(rawURL string) *protocol.ToolResult {
+		input, _ := json.Marshal(map[string]string{"url": rawURL})
+		return rt.Execute(context.Background(), &protocol.ToolCall{ID: "c", Tool: "flaky.tool", Input: input})
+	}
+	for i := 0; i < 2; i++ {
+		if res := call("https://a.example/x"); res.Error == nil || res.Error.Code != "TOOL_EXEC_FAILED" {
+			t.Fatalf("attempt %d: expected TOOL_EXEC_FAILED, got %+v", i, res)
+		}
+	}
+	if res := call("https://a.example/y"); res.Error == nil || res.Error.Code != CodeCircuitOpen {
+		t.Fatalf("expected CIRCUIT_OPEN, got %+v", res)
+	}
+	if res := call("https://b.example/x"); res.Error == nil || res.Error.Code != "TOOL_EXEC_FAILED" {
+		t.Fatalf("expected other domain to be unaffected, got %+v", res)
+	}
+
+	now = now.Add(2 * time.Minute)
+	fail = false
+	if res := call("https://a.example/x"); res.Status != "success" {
+		t.Fatalf("expected half-open probe to succeed, got %+v", res)
+	}
+	if res := call("https://a.example/x"); res.Status != "success" {
+		t.Fatalf("expected circuit closed after probe, got %+v", res)
+	}
+
+	// 间隔超过窗口的失败不算连续，过期的熔断器在下次记录新失败时清理
+	fail = true
+	call("https://c.example/x")
+	now = now.Add(2 * time.Minute)
+	if res := call("https://c.example/x"); res.Error == nil || res.Error.Code != "TOOL_EXEC_FAILED" {
+		t.Fatalf("expected failures a window apart not to open the circuit, got %+v", res)
+	}
+	now = now.Add(2 * time.Minute)
+	call("https://d.example/x")
+	rt.breakers.mu.Lock()
+	_, staleB := rt.breakers.circuits["flaky.tool|b.example"]
+	_, staleC := rt.breakers.circuits["flaky.tool|c.example"]
+	n := len(rt.breakers.circuits)
+	rt.breakers.mu.Unlock()
+	if staleB || staleC || n != 1 {
+		t.Fatalf("expected stale circuits pruned, %d left", n)
+	}
+}
+
+func TestMapErrorTyped(t *testing.T) {
+	t.Parallel()
+	te := protocol.NewToolError(protocol.CodeLoginRequired, "redirected to login").WithDetail("url", "https://x.com/login")
+	got := mapError(fmt.Errorf("read post: %w", te))
+	if got.Code != protocol.CodeLoginRequired || got.Details["url"] != "https://x.com/login" {
+		t.Fatalf("unexpected mapping: %+v", got)
+	}
+	if got := mapError(context.DeadlineExceeded); got.Code != protocol.CodeTimeout || !got.Retryable {
+		t.Fatalf("expected retryable TIMEOUT, got %+v", got)
+	}
+	if got := mapError(errors.New("boom")); got.Code != protocol.CodeToolExecFailed {
+		t.Fatalf("expected TOOL_EXEC_FAILED, got %+v", got)
+	}
+}
+
+func TestExecuteRetriesRetryableErrors(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	var calls atomic.Int32
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "nav.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			if calls.Add(1) < 3 {
+				return nil, nil, protocol.NewToolError(protocol.CodeNavigationFailed, "net::ERR_TIMED_OUT")
+			}
+			return map[string]any{"ok": true}, nil, nil
+		},
+	})
+	rt := New(reg, Options{ToolPolicies: map[string]ToolPolicy{
+		"nav.tool": {Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}},
+	}})
+
+	res := rt.Execute(context.Background(), &protocol.ToolCall{ID: "r1", Tool: "nav.tool"})
+	if res.Status != "success" || res.Usage.Attempts != 3 {
+		t.Fatalf("expected success after 3 attempts, got %+v", res)
+	}
+
+	calls.Store(0)
+	res = rt.Execute(context.Background(), &protocol.ToolCall{
+		ID:     "r2",
+		Tool:   "nav.tool",
+		Policy: protocol.CallPolicy{Retry: &protocol.RetryPolicy{MaxAttempts: 1}},
+	})
+	if res.Status != "error" || res.Usage.Attempts != 1 || res.Error.Code != protocol.CodeNavigationFailed {
+		t.Fatalf("expected single failed attempt, got %+v", res)
+	}
+}
+
+func TestExecuteDoesNotRetryPermanentErrors(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	var calls atomic.Int32
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "login.tool"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			calls.Add(1)
+			return nil, nil, protocol.NewToolError(protocol.CodeLoginRequired, "redirected to login")
+		},
+	})
+	rt := New(reg, Options{ToolPolicies: map[string]ToolPolicy{
+		DefaultToolKey: {Retry: RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}},
+	}})
+	res := rt.Execute(context.Background(), &protocol.ToolCall{ID: "r3", Tool: "login.tool"})
+	if calls.Load() != 1 || res.Usage.Attempts != 1 {
+		t.Fatalf("expected one attempt, got calls=%d result=%+v", calls.Load(), res)
+	}
+}
+
+func TestRetryBackoffBounds(t *testing.T) {
+	t.Parallel()
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for retry := 1; retry <= 5; retry++ {
+		d := p.backoff(retry)
+		if d < 50*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("retry %d: backoff %s out of bounds", retry, d)
+		}
+	}
+}
+
+func TestExecuteResultCache(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	var calls atomic.Int32
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "page.read"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			return map[string]any{"n": calls.Add(1)}, nil, nil
+		},
+		Cacheable: registry.AlwaysCacheable,
+	})
+	rt := New(reg, Options{Cache: cache.NewMemoryStore(10), CacheTTL: time.Minute})
+	call := func(id, input, mode string) *protocol.ToolResult {
+		return rt.Execute(context.Background(), &protocol.ToolCall{
+			ID:     id,
+			Tool:   "page.read",
+			Input:  json.RawMessage(input),
+			Policy: protocol.CallPolicy{Cache: mode},
+		})
+	}
+
+	if res := call("c0", `{"url":"https://a"}`, protocol.CacheOnly); res.Error == nil || res.Error.Code != protocol.CodeCacheMiss {
+		t.Fatalf("expected CACHE_MISS, got %+v", res)
+	}
+	if res := call("c1", `{"url":"https://a"}`, ""); res.CacheHit || calls.Load() != 1 {
+		t.Fatalf("expected miss and one execution, got %+v", res)
+	}
+	res := call("c2", `{ "url": "https://a" }`, "")
+	if !res.CacheHit || calls.Load() != 1 || string(res.Output) != `{"n":1}` {
+		t.Fatalf("expected cache hit for normalized input, got %+v", res)
+	}
+	if res := call("c3", `{"url":"https://a"}`, protocol.CacheBypass); res.CacheHit || calls.Load() != 2 {
+		t.Fatalf("expected bypass to execute, got %+v", res)
+	}
+	if res := call("c4", `{"url":"https://a"}`, protocol.CacheRefresh); res.CacheHit || calls.Load() != 3 {
+		t.Fatalf("expected refresh to execute, got %+v", res)
+	}
+	if res := call("c5", `{"url":"https://a"}`, protocol.CacheOnly); !res.CacheHit || string(res.Output) != `{"n":3}` {
+		t.Fatalf("expected refreshed entry, got %+v", res)
+	}
+	res = rt.Execute(context.Background(), &protocol.ToolCall{
+		ID:     "c6",
+		Tool:   "page.read",
+		Input:  json.RawMessage(`{"url":"https://a"}`),
+		Policy: protocol.CallPolicy{Cache: protocol.CacheOnly, AllowedDomains: []string{"example.com"}},
+	})
+	if res.CacheHit || res.Error == nil || res.Error.Code != protocol.CodeCacheMiss {
+		t.Fatalf("expected CACHE_MISS under a different allow-list, got %+v", res)
+	}
+}
+
+func TestExecuteRecordsUsage(t *testing.T) {
+	t.Parallel()
+	reg := registry.New()
+	reg.Register(registry.Entry{
+		Descriptor: protocol.ToolDescriptor{Name: "page.read"},
+		Handler: func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+			m := usage.FromContext(ctx)
+			m.AddPage(20 * time.Millisecond)
+			m.AddScreenshot()
+			m.AddTokens(7, 3)
+			return map[string]any{"ok": true}, []protocol.Artifact{{ID: "a1", Size: 100}}, nil
+		},
+	})
+	agg := usage.NewAggregator(0)
+	rt := New(reg, Options{Usage: agg})
+	res := rt.Execute(context.Background(), &protocol.ToolCall{
+		ID:      "c1",
+		Tool:    "page.read",
+		Input:   json.RawMessage(`{"url":"https://a"}`),
+		Context: protocol.CallContext{TenantID: "t1"},
+	})
+	u := res.Usage
+	if u.BytesIn != 19 || u.BytesOut != int64(len(res.Output)) || u.PagesOpened != 1 || u.NavigationMs != 20 ||
+		u.Screenshots != 1 || u.ArtifactBytes != 100 || u.InputTokens != 7 || u.OutputTokens != 3 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+	rows := agg.Window(time.Hour)
+	if len(rows) != 1 || rows[0].Tenant != "t1" || rows[0].Calls != 1 || rows[0].PagesOpened != 1 {
+		t.Fatalf("unexpected aggregate: %+v", rows)
+	}
+}
+
+func TestReloadSwapsRegistryAndAllowList(t *testing.T) {
+	t.Parallel()
+	ok := func(ctx context.Context, call *protocol.ToolCall) (map[string]any, []protocol.Artifact, error) {
+		return map[string]any{"ok": true}, nil, nil
+	}
+	reg := registry.New()
+	reg.Register(registry.Entry{Descriptor: protocol.ToolDescriptor{Name: "browser.browse"}, Handler: ok})
+	agg := usage.NewAggregator(time.Hour)
+	rt := New(reg, Options{InstanceID: "inst", AllowedTools: []string{"browser.browse"}, Usage: agg})
+
+	next := registry.New()
+	next.Register(registry.Entry{Descriptor: protocol.ToolDescriptor{Name: "browser.browse"}, Handler: ok})
+	next.Register(registry.Entry{Descriptor: protocol.ToolDescriptor{Name: "web.search"}, Handler: ok})
+	rt.Reload(next, Options{InstanceID: "ignored", AllowedTools: []string{"web.search"}})
+
+	m := rt.Manifest()
+	if m.InstanceID != "inst" || len(m.Tools) != 1 || m.Tools[0].Name != "web.search" {
+		t.Fatalf("unexpected manifest after reload: %+v", m)
+	}
+	if rt.Usage() != agg {
+		t.Fatal("usage aggregator must survive reload")
+	}
+	res := rt.Execute(context.Background(), &protocol.ToolCall{ID: "c1", Tool: "browser.browse"})
+	if res.Error == nil || res.Error.Code != protocol.CodeToolNotAllowed {
+		t.Fatalf("expected TOOL_NOT_ALLOWED, got %+v", res)
+	}
+	if res := rt.Execute(context.Background(), &protocol.ToolCall{ID: "c2", Tool: "web.search"}); res.Status != "success" {
+		t.Fatalf("expected success, got %+v", res.Error)
+	}
+}
@@ -0,0 +1,121 @@
+package taskqueue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FileQueue is a MemoryQueue that keeps one JSON file per task under Dir/tasks and per dead
+// letter under Dir/dead, so queued and leased calls survive a host restart. Leases held when the
+// process stopped expire normally on the next Sweep.
+type FileQueue struct {
+	*MemoryQueue
+	Dir string
+}
+
+var _ Queue = (*FileQueue)(nil)
+
+// OpenFileQueue loads the queue stored in dir, creating it if needed.
+func OpenFileQueue(dir string, opts Options) (*FileQueue, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, fmt.Errorf("task queue dir is required")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range []string{"tasks", "dead"} {
+		if err := os.MkdirAll(filepath.Join(abs, sub), 0o750); err != nil {
+			return nil, err
+		}
+	}
+	q := &FileQueue{MemoryQueue: NewMemoryQueue(opts), Dir: abs}
+	tasks, err := readTasks(filepath.Join(abs, "tasks"))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(tasks, func(a, b Task) int { return a.EnqueuedAt.Compare(b.EnqueuedAt) })
+	for _, t := range tasks {
+		q.addLocked(t)
+	}
+	dead, err := readTasks(filepath.Join(abs, "dead"))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(dead, func(a, b Task) int { return a.DeadAt.Compare(b.DeadAt) })
+	q.dead = dead
+	q.persist = fileStore{dir: abs}
+	return q, nil
+}
+
+// readTasks loads every task file in dir, skipping unreadable ones (e.g. a torn write).
+func readTasks(dir string) ([]Task, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []Task
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var t Task
+		if json.Unmarshal(data, &t) != nil || t.ID == "" {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+type fileStore struct {
+	dir string
+}
+
+func (s fileStore) save(t Task) error {
+	return writeFileAtomic(s.path("tasks", t.ID), t)
+}
+
+func (s fileStore) remove(id string) error {
+	err := os.Remove(s.path("tasks", id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s fileStore) bury(t Task) error {
+	if err := writeFileAtomic(s.path("dead", t.ID), t); err != nil {
+		return err
+	}
+	return s.remove(t.ID)
+}
+
+func (s fileStore) forget(id string) error {
+	return os.Remove(s.path("dead", id))
+}
+
+func (s fileStore) path(sub, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, sub, hex.EncodeToString(sum[:16])+".json")
+}
+
+func writeFileAtomic(path string, t Task) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,340 @@
+// Package taskqueue holds tool calls waiting for a collector. Deliveries are leases: a task
+// leased to a collector becomes available again when the lease is released or expires without a
+// result, and is dead-lettered after Options.MaxAttempts deliveries. A task past its deadline is
+// dropped without a dead letter: the caller owns the timeout and reports it.
+package taskqueue
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// Task is a queued tool call.
+type Task struct {
+	ID         string            `json:"id"` // the call ID
+	Tenant     string            `json:"tenant,omitempty"`
+	Call       protocol.ToolCall `json:"call"`
+	Deadline   time.Time         `json:"deadline,omitempty"` // no longer leased once passed; Sweep drops it
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+	Attempts   int               `json:"attempts"`              // leases so far
+	Owner      string            `json:"owner,omitempty"`       // lease holder, e.g. a collector instance ID
+	LeaseUntil time.Time         `json:"lease_until,omitempty"` // Sweep returns the task to the queue after this
+	LastError  string            `json:"last_error,omitempty"`  // why the last lease ended, or the dead-letter reason
+	DeadAt     time.Time         `json:"dead_at,omitempty"`
+
+	seq uint64
+}
+
+// Leased reports whether the task is currently delivered to Owner.
+func (t Task) Leased() bool { return t.Owner != "" }
+
+// Expired reports whether the task's deadline passed at now.
+func (t Task) Expired(now time.Time) bool { return !t.Deadline.IsZero() && now.After(t.Deadline) }
+
+// Queue is the storage behind a host's undelivered and in-flight calls.
+type Queue interface {
+	// Enqueue adds a task; the ID must be unique among queued tasks.
+	Enqueue(ctx context.Context, t Task) error
+	// Lease hands up to limit available tasks accepted by accept to owner for lease, visiting
+	// tenants round-robin and, within a tenant, higher policy.priority first, then FIFO.
+	Lease(ctx context.Context, owner string, limit int, lease time.Duration, accept func(Task) bool) ([]Task, error)
+	// Complete removes a task once its result arrived (or the caller gave up on it).
+	Complete(ctx context.Context, id string) error
+	// Release ends a lease early; dead reports whether the task ran out of attempts.
+	Release(ctx context.Context, id, reason string) (dead bool, err error)
+	// Sweep returns expired leases to the queue, dead-letters tasks that ran out of attempts and
+	// removes tasks past their deadline. It returns every task it changed: released ones have
+	// Owner cleared and LastError set, dead-lettered ones have DeadAt set, removed ones are
+	// Expired and have no DeadAt.
+	Sweep(ctx context.Context) ([]Task, error)
+	// Get returns a queued task.
+	Get(ctx context.Context, id string) (Task, bool, error)
+	// List returns all queued tasks, leased ones included, in enqueue order.
+	List(ctx context.Context) ([]Task, error)
+	// DeadLetters returns dead-lettered tasks, oldest first.
+	DeadLetters(ctx context.Context) ([]Task, error)
+}
+
+var (
+	ErrDuplicate = errors.New("taskqueue: task id already queued")
+	ErrNotFound  = errors.New("taskqueue: task not found")
+)
+
+// Options configures a queue.
+type Options struct {
+	MaxAttempts    int // deliveries before a task is dead-lettered, default 3
+	MaxDeadLetters int // oldest dead letters are dropped beyond this, default 1000
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if o.MaxDeadLetters <= 0 {
+		o.MaxDeadLetters = 1000
+	}
+	return o
+}
+
+// persister mirrors queue changes to durable storage (see FileQueue).
+type persister interface {
+	save(t Task) error
+	remove(id string) error
+	bury(t Task) error
+	forget(id string) error // drops a dead letter beyond MaxDeadLetters
+}
+
+// MemoryQueue keeps tasks in process memory.
+type MemoryQueue struct {
+	mu      sync.Mutex
+	opts    Options
+	tasks   map[string]*Task
+	tenants []string           // round-robin order
+	byTen   map[string][]*Task // per tenant, enqueue order
+	cursor  int
+	dead    []Task
+	seq     uint64
+	now     func() time.Time
+	persist persister
+}
+
+var _ Queue = (*MemoryQueue)(nil)
+
+// NewMemoryQueue creates an in-memory queue.
+func NewMemoryQueue(opts Options) *MemoryQueue {
+	return &MemoryQueue{
+		opts:  opts.withDefaults(),
+		tasks: make(map[string]*Task),
+		byTen: make(map[string][]*Task),
+		now:   time.Now,
+	}
+}
+
+func (q *MemoryQueue) Enqueue(_ context.Context, t Task) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.tasks[t.ID]; ok {
+		return ErrDuplicate
+	}
+	if t.EnqueuedAt.IsZero() {
+		t.EnqueuedAt = q.now()
+	}
+	if q.persist != nil {
+		if err := q.persist.save(t); err != nil {
+			return err
+		}
+	}
+	q.addLocked(t)
+	return nil
+}
+
+// addLocked indexes t; tasks must be added in enqueue order.
+func (q *MemoryQueue) addLocked(t Task) {
+	q.seq++
+	t.seq = q.seq
+	p := &t
+	q.tasks[t.ID] = p
+	if _, ok := q.byTen[t.Tenant]; !ok {
+		q.tenants = append(q.tenants, t.Tenant)
+	}
+	q.byTen[t.Tenant] = append(q.byTen[t.Tenant], p)
+}
+
+func (q *MemoryQueue) Lease(_ context.Context, owner string, limit int, lease time.Duration, accept func(Task) bool) ([]Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	var out []Task
+	for len(out) < limit && len(q.tenants) > 0 {
+		progress := false
+		n, start := len(q.tenants), q.cursor
+		for i := 0; i < n && len(out) < limit; i++ {
+			idx := (start + i) % n
+			t := q.bestLocked(q.tenants[idx], now, accept)
+			if t == nil {
+				continue
+			}
+			prev := *t
+			t.Attempts++
+			t.Owner, t.LeaseUntil = owner, now.Add(lease)
+			if q.persist != nil {
+				if err := q.persist.save(*t); err != nil {
+					*t = prev
+					return out, err
+				}
+			}
+			out = append(out, *t)
+			progress = true
+			q.cursor = (idx + 1) % n // the next lease starts with the following tenant
+		}
+		if !progress {
+			break
+		}
+	}
+	return out, nil
+}
+
+// bestLocked returns tenant's highest-priority, oldest available task that accept takes.
+func (q *MemoryQueue) bestLocked(tenant string, now time.Time, accept func(Task) bool) *Task {
+	var best *Task
+	for _, t := range q.byTen[tenant] {
+		if t.Leased() || t.Expired(now) {
+			continue
+		}
+		if best != nil && t.Call.Policy.Priority <= best.Call.Policy.Priority {
+			continue
+		}
+		if accept == nil || accept(*t) {
+			best = t
+		}
+	}
+	return best
+}
+
+func (q *MemoryQueue) Complete(_ context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.tasks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if q.persist != nil {
+		if err := q.persist.remove(id); err != nil {
+			return err
+		}
+	}
+	q.dropLocked(t)
+	return nil
+}
+
+func (q *MemoryQueue) Release(_ context.Context, id, reason string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.tasks[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	return q.releaseLocked(t, reason, q.now())
+}
+
+func (q *MemoryQueue) releaseLocked(t *Task, reason string, now time.Time) (bool, error) {
+	if t.Attempts >= q.opts.MaxAttempts {
+		return true, q.buryLocked(t, reason, now)
+	}
+	prev := *t
+	t.Owner, t.LeaseUntil, t.LastError = "", time.Time{}, reason
+	if q.persist != nil {
+		if err := q.persist.save(*t); err != nil {
+			*t = prev
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (q *MemoryQueue) Sweep(_ context.Context) ([]Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	var changed []Task
+	for _, t := range q.ordered() {
+		switch {
+		case t.Expired(now):
+			if q.persist != nil {
+				if err := q.persist.remove(t.ID); err != nil {
+					return changed, err
+				}
+			}
+			q.dropLocked(t)
+			t.Owner, t.LeaseUntil, t.LastError = "", time.Time{}, "deadline exceeded"
+			changed = append(changed, *t)
+		case t.Leased() && now.After(t.LeaseUntil):
+			if _, err := q.releaseLocked(t, "lease expired on "+t.Owner, now); err != nil {
+				return changed, err
+			}
+			changed = append(changed, *t)
+		}
+	}
+	return changed, nil
+}
+
+func (q *MemoryQueue) buryLocked(t *Task, reason string, now time.Time) error {
+	d := *t
+	d.Owner, d.LeaseUntil, d.LastError, d.DeadAt = "", time.Time{}, reason, now
+	if q.persist != nil {
+		if err := q.persist.bury(d); err != nil {
+			return err
+		}
+	}
+	q.dropLocked(t)
+	*t = d
+	q.dead = append(q.dead, d)
+	for len(q.dead) > q.opts.MaxDeadLetters {
+		if q.persist != nil {
+			_ = q.persist.forget(q.dead[0].ID)
+		}
+		q.dead = q.dead[1:]
+	}
+	return nil
+}
+
+func (q *MemoryQueue) dropLocked(t *Task) {
+	delete(q.tasks, t.ID)
+	list := slices.DeleteFunc(q.byTen[t.Tenant], func(x *Task) bool { return x == t })
+	if len(list) > 0 {
+		q.byTen[t.Tenant] = list
+		return
+	}
+	delete(q.byTen, t.Tenant)
+	if i := slices.Index(q.tenants, t.Tenant); i >= 0 {
+		q.tenants = slices.Delete(q.tenants, i, i+1)
+		if q.cursor > i {
+			q.cursor--
+		}
+		if q.cursor >= len(q.tenants) {
+			q.cursor = 0
+		}
+	}
+}
+
+func (q *MemoryQueue) Get(_ context.Context, id string) (Task, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.tasks[id]
+	if !ok {
+		return Task{}, false, nil
+	}
+	return *t, true, nil
+}
+
+func (q *MemoryQueue) List(_ context.Context) ([]Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Task, 0, len(q.tasks))
+	for _, t := range q.ordered() {
+		out = append(out, *t)
+	}
+	return out, nil
+}
+
+func (q *MemoryQueue) DeadLetters(_ context.Context) ([]Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.dead), nil
+}
+
+// ordered returns the queued tasks in enqueue order.
+func (q *MemoryQueue) ordered() []*Task {
+	out := make([]*Task, 0, len(q.tasks))
+	for _, t := range q.tasks {
+		out = append(out, t)
+	}
+	slices.SortFunc(out, func(a, b *Task) int { return cmp.Compare(a.seq, b.seq) })
+	return out
+}
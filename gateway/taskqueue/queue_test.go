@@ -0,0 +1,153 @@
+package taskqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+func task(id, tenant string, priority int) Task {
+	return Task{ID: id, Tenant: tenant, Call: protocol.ToolCall{ID: id, Tool: "x", Policy: protocol.CallPolicy{Priority: priority}}}
+}
+
+func leaseIDs(t *testing.T, q Queue, limit int) []string {
+	t.Helper()
+	tasks, err := q.Lease(context.Background(), "c1", limit, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+func TestLeaseRoundRobinsTenants(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue(Options{})
+	// 租户 a 先塞满队列，b 和 c 仍然轮得到
+	for _, tk := range []Task{task("a1", "a", 0), task("a2", "a", 0), task("a3", "a", 5), task("b1", "b", 0), task("c1", "c", 0)} {
+		if err := q.Enqueue(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Enqueue(ctx, task("a1", "a", 0)); err != ErrDuplicate {
+		t.Fatalf("duplicate enqueue = %v", err)
+	}
+	got := append(leaseIDs(t, q, 4), leaseIDs(t, q, 4)...)
+	want := []string{"a3", "b1", "c1", "a1", "a2"}
+	if len(got) != len(want) {
+		t.Fatalf("leased %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("leased %v, want %v", got, want)
+		}
+	}
+}
+
+func TestExpiredLeaseIsRedeliveredThenDeadLettered(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	q := NewMemoryQueue(Options{MaxAttempts: 2})
+	q.now = func() time.Time { return now }
+	if err := q.Enqueue(ctx, task("t1", "", 0)); err != nil {
+		t.Fatal(err)
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if ids := leaseIDs(t, q, 1); len(ids) != 1 {
+			t.Fatalf("attempt %d leased %v", attempt, ids)
+		}
+		if ids := leaseIDs(t, q, 1); len(ids) != 0 {
+			t.Fatalf("leased task handed out twice: %v", ids)
+		}
+		now = now.Add(2 * time.Minute)
+		changed, err := q.Sweep(ctx)
+		if err != nil || len(changed) != 1 || changed[0].Leased() {
+			t.Fatalf("sweep = %+v, %v", changed, err)
+		}
+		if dead := !changed[0].DeadAt.IsZero(); dead != (attempt == 2) {
+			t.Fatalf("attempt %d dead = %v", attempt, dead)
+		}
+	}
+	if _, ok, _ := q.Get(ctx, "t1"); ok {
+		t.Fatal("dead task still queued")
+	}
+	dead, _ := q.DeadLetters(ctx)
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].LastError != "lease expired on c1" {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+}
+
+func TestSweepDropsTasksPastDeadline(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	q := NewMemoryQueue(Options{})
+	q.now = func() time.Time { return now }
+	for _, id := range []string{"leased", "waiting"} {
+		tk := task(id, "", 0)
+		tk.Deadline = now.Add(time.Minute)
+		if err := q.Enqueue(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := leaseIDs(t, q, 1); len(ids) != 1 || ids[0] != "leased" {
+		t.Fatalf("leased %v", ids)
+	}
+	now = now.Add(2 * time.Minute)
+	if ids := leaseIDs(t, q, 1); len(ids) != 0 {
+		t.Fatalf("task past its deadline leased: %v", ids)
+	}
+	changed, err := q.Sweep(ctx)
+	if err != nil || len(changed) != 2 {
+		t.Fatalf("sweep = %+v, %v", changed, err)
+	}
+	for _, tk := range changed {
+		if !tk.Expired(now) || !tk.DeadAt.IsZero() || tk.Leased() {
+			t.Fatalf("swept task = %+v", tk)
+		}
+	}
+	if tasks, _ := q.List(ctx); len(tasks) != 0 {
+		t.Fatalf("expired tasks still queued: %+v", tasks)
+	}
+	if dead, _ := q.DeadLetters(ctx); len(dead) != 0 {
+		t.Fatalf("expired tasks dead-lettered: %+v", dead)
+	}
+}
+
+func TestFileQueueSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	q, err := OpenFileQueue(dir, Options{MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"t1", "t2", "t3"} {
+		if err := q.Enqueue(ctx, task(id, "", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	leaseIDs(t, q, 2)
+	if err := q.Complete(ctx, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if dead, err := q.Release(ctx, "t2", "collector gone"); err != nil || !dead {
+		t.Fatalf("release = %v, %v", dead, err)
+	}
+
+	q, err = OpenFileQueue(dir, Options{MaxAttempts: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := q.List(ctx)
+	if len(tasks) != 1 || tasks[0].ID != "t3" || tasks[0].Leased() {
+		t.Fatalf("tasks after reopen = %+v", tasks)
+	}
+	dead, _ := q.DeadLetters(ctx)
+	if len(dead) != 1 || dead[0].ID != "t2" || dead[0].LastError != "collector gone" {
+		t.Fatalf("dead letters after reopen = %+v", dead)
+	}
+}
@@ -0,0 +1,167 @@
+package usage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// DefaultRetention keeps enough hourly buckets for 7-day windows and the previous day's report.
+const DefaultRetention = 8 * 24 * time.Hour
+
+// Row is aggregated usage for one tenant and tool.
+type Row struct {
+	Tenant           string `json:"tenant"`
+	Tool             string `json:"tool"`
+	Calls            int64  `json:"calls"`
+	Errors           int64  `json:"errors"`
+	CacheHits        int64  `json:"cache_hits"`
+	DurationMs       int64  `json:"duration_ms"`
+	BytesIn          int64  `json:"bytes_in"`
+	BytesOut         int64  `json:"bytes_out"`
+	PagesOpened      int64  `json:"pages_opened"`
+	NavigationMs     int64  `json:"navigation_ms"`
+	Screenshots      int64  `json:"screenshots"`
+	ArtifactBytes    int64  `json:"artifact_bytes"`
+	ProviderRequests int64  `json:"provider_requests"`
+	InputTokens      int64  `json:"input_tokens"`
+	OutputTokens     int64  `json:"output_tokens"`
+}
+
+func (r *Row) add(o Row) {
+	r.Calls += o.Calls
+	r.Errors += o.Errors
+	r.CacheHits += o.CacheHits
+	r.DurationMs += o.DurationMs
+	r.BytesIn += o.BytesIn
+	r.BytesOut += o.BytesOut
+	r.PagesOpened += o.PagesOpened
+	r.NavigationMs += o.NavigationMs
+	r.Screenshots += o.Screenshots
+	r.ArtifactBytes += o.ArtifactBytes
+	r.ProviderRequests += o.ProviderRequests
+	r.InputTokens += o.InputTokens
+	r.OutputTokens += o.OutputTokens
+}
+
+type bucketKey struct {
+	hour   int64 // unix seconds truncated to the hour
+	tenant string
+	tool   string
+}
+
+// Aggregator keeps per-tenant, per-tool usage in hourly buckets.
+type Aggregator struct {
+	mu        sync.Mutex
+	buckets   map[bucketKey]*Row
+	retention time.Duration
+	pruned    int64 // hour of the last prune
+	now       func() time.Time
+}
+
+// NewAggregator creates an aggregator; retention <= 0 uses DefaultRetention.
+func NewAggregator(retention time.Duration) *Aggregator {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Aggregator{
+		buckets:   make(map[bucketKey]*Row),
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Record adds one finished call.
+func (a *Aggregator) Record(call *protocol.ToolCall, result *protocol.ToolResult) {
+	if a == nil || call == nil || result == nil {
+		return
+	}
+	u := result.Usage
+	row := Row{
+		Calls:            1,
+		DurationMs:       u.DurationMs,
+		BytesIn:          u.BytesIn,
+		BytesOut:         u.BytesOut,
+		PagesOpened:      int64(u.PagesOpened),
+		NavigationMs:     u.NavigationMs,
+		Screenshots:      int64(u.Screenshots),
+		ArtifactBytes:    u.ArtifactBytes,
+		ProviderRequests: int64(u.ProviderRequests),
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+	}
+	if result.Status != "success" {
+		row.Errors = 1
+	}
+	if result.CacheHit {
+		row.CacheHits = 1
+	}
+
+	now := a.now()
+	key := bucketKey{hour: now.Truncate(time.Hour).Unix(), tenant: call.Context.TenantID, tool: call.Tool}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b, ok := a.buckets[key]
+	if !ok {
+		b = &Row{Tenant: key.tenant, Tool: key.tool}
+		a.buckets[key] = b
+	}
+	b.add(row)
+	if key.hour != a.pruned {
+		a.prune(now)
+		a.pruned = key.hour
+	}
+}
+
+// Window sums the buckets overlapping [now-window, now].
+func (a *Aggregator) Window(window time.Duration) []Row {
+	now := a.now()
+	return a.sum(now.Add(-window).Truncate(time.Hour), now)
+}
+
+// Day sums the buckets of the calendar day containing day (in day's location).
+func (a *Aggregator) Day(day time.Time) []Row {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return a.sum(start, start.AddDate(0, 0, 1).Add(-time.Nanosecond))
+}
+
+func (a *Aggregator) sum(from, to time.Time) []Row {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	type rowKey struct{ tenant, tool string }
+	acc := make(map[rowKey]*Row)
+	for k, b := range a.buckets {
+		if k.hour < from.Unix() || k.hour > to.Unix() {
+			continue
+		}
+		rk := rowKey{k.tenant, k.tool}
+		r, ok := acc[rk]
+		if !ok {
+			r = &Row{Tenant: k.tenant, Tool: k.tool}
+			acc[rk] = r
+		}
+		r.add(*b)
+	}
+	rows := make([]Row, 0, len(acc))
+	for _, r := range acc {
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Tenant != rows[j].Tenant {
+			return rows[i].Tenant < rows[j].Tenant
+		}
+		return rows[i].Tool < rows[j].Tool
+	})
+	return rows
+}
+
+func (a *Aggregator) prune(now time.Time) {
+	cutoff := now.Add(-a.retention).Unix()
+	for k := range a.buckets {
+		if k.hour < cutoff {
+			delete(a.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+// Meter collects resource usage reported by an executor during one tool call.
+// All methods are safe on a nil *Meter, so executors can report unconditionally.
+type Meter struct {
+	pages            atomic.Int64
+	navigation       atomic.Int64 // nanoseconds
+	screenshots      atomic.Int64
+	providerRequests atomic.Int64
+	inputTokens      atomic.Int64
+	outputTokens     atomic.Int64
+}
+
+type meterKey struct{}
+
+// WithMeter attaches m to ctx for executors to report into.
+func WithMeter(ctx context.Context, m *Meter) context.Context {
+	return context.WithValue(ctx, meterKey{}, m)
+}
+
+// FromContext returns the call's meter, or nil outside the runtime.
+func FromContext(ctx context.Context) *Meter {
+	m, _ := ctx.Value(meterKey{}).(*Meter)
+	return m
+}
+
+// AddPage records one browser page load and the time spent navigating.
+func (m *Meter) AddPage(navigation time.Duration) {
+	if m == nil {
+		return
+	}
+	m.pages.Add(1)
+	m.navigation.Add(int64(navigation))
+}
+
+// AddScreenshot records one captured screenshot.
+func (m *Meter) AddScreenshot() {
+	if m == nil {
+		return
+	}
+	m.screenshots.Add(1)
+}
+
+// AddProviderRequest records one billable upstream API request (OCR, search).
+func (m *Meter) AddProviderRequest() {
+	if m == nil {
+		return
+	}
+	m.providerRequests.Add(1)
+}
+
+// AddTokens records model tokens reported by a provider.
+func (m *Meter) AddTokens(input, output int64) {
+	if m == nil {
+		return
+	}
+	m.inputTokens.Add(input)
+	m.outputTokens.Add(output)
+}
+
+// Fill copies the collected counters into u.
+func (m *Meter) Fill(u *protocol.Usage) {
+	if m == nil {
+		return
+	}
+	u.PagesOpened = int(m.pages.Load())
+	u.NavigationMs = time.Duration(m.navigation.Load()).Milliseconds()
+	u.Screenshots = int(m.screenshots.Load())
+	u.ProviderRequests = int(m.providerRequests.Load())
+	u.InputTokens = m.inputTokens.Load()
+	u.OutputTokens = m.outputTokens.Load()
+}
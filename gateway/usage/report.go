@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var csvHeader = []string{
+	"tenant", "tool", "calls", "errors", "cache_hits", "duration_ms", "bytes_in", "bytes_out",
+	"pages_opened", "navigation_ms", "screenshots", "artifact_bytes", "provider_requests",
+	"input_tokens", "output_tokens",
+}
+
+// WriteCSV writes rows with a header line.
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		rec := []string{r.Tenant, r.Tool}
+		for _, n := range []int64{
+			r.Calls, r.Errors, r.CacheHits, r.DurationMs, r.BytesIn, r.BytesOut,
+			r.PagesOpened, r.NavigationMs, r.Screenshots, r.ArtifactBytes, r.ProviderRequests,
+			r.InputTokens, r.OutputTokens,
+		} {
+			rec = append(rec, strconv.FormatInt(n, 10))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Report is the JSON form of a usage export.
+type Report struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Rows []Row     `json:"rows"`
+}
+
+// WriteJSON writes rows wrapped in a Report.
+func WriteJSON(w io.Writer, from, to time.Time, rows []Row) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Report{From: from, To: to, Rows: rows})
+}
+
+// ExportDaily writes usage-YYYY-MM-DD.csv and .json for day into dir.
+func (a *Aggregator) ExportDaily(dir string, day time.Time) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	rows := a.Day(start)
+	base := filepath.Join(dir, "usage-"+start.Format(time.DateOnly))
+	if err := writeFile(base+".csv", func(w io.Writer) error { return WriteCSV(w, rows) }); err != nil {
+		return err
+	}
+	return writeFile(base+".json", func(w io.Writer) error {
+		return WriteJSON(w, start, start.AddDate(0, 0, 1), rows)
+	})
+}
+
+// RunDailyExport exports the previous day's report shortly after each local midnight until ctx ends.
+func (a *Aggregator) RunDailyExport(ctx context.Context, dir string) {
+	for {
+		now := a.now()
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 1, 0, 0, now.Location())
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		day := a.now().AddDate(0, 0, -1)
+		if err := a.ExportDaily(dir, day); err != nil {
+			log.Printf("[gateway-usage] daily export failed: %v", err)
+		}
+	}
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
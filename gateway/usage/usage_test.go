@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/originaleric/digeino/gateway/protocol"
+)
+
+func record(a *Aggregator, tenant, tool, status string, u protocol.Usage) {
+	a.Record(&protocol.ToolCall{Tool: tool, Context: protocol.CallContext{TenantID: tenant}},
+		&protocol.ToolResult{Status: status, Usage: u})
+}
+
+func TestAggregatorWindowsAndPrune(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	a := NewAggregator(48 * time.Hour)
+	a.now = func() time.Time { return now }
+
+	record(a, "t1", "web.search", "success", protocol.Usage{ProviderRequests: 1, BytesOut: 10})
+	record(a, "t1", "web.search", "error", protocol.Usage{ProviderRequests: 1})
+	record(a, "t2", "browser.browse", "success", protocol.Usage{PagesOpened: 2, NavigationMs: 300})
+
+	now = now.Add(3 * time.Hour)
+	record(a, "t1", "web.search", "success", protocol.Usage{ProviderRequests: 1})
+
+	rows := a.Window(time.Hour)
+	if len(rows) != 1 || rows[0].Calls != 1 {
+		t.Fatalf("1h window: %+v", rows)
+	}
+	rows = a.Window(24 * time.Hour)
+	if len(rows) != 2 || rows[0].Tenant != "t1" || rows[1].Tenant != "t2" {
+		t.Fatalf("24h window: %+v", rows)
+	}
+	if r := rows[0]; r.Calls != 3 || r.Errors != 1 || r.ProviderRequests != 3 || r.BytesOut != 10 {
+		t.Fatalf("t1 row: %+v", r)
+	}
+	if r := rows[1]; r.PagesOpened != 2 || r.NavigationMs != 300 {
+		t.Fatalf("t2 row: %+v", r)
+	}
+	if rows := a.Day(now); len(rows) != 2 {
+		t.Fatalf("day: %+v", rows)
+	}
+
+	now = now.Add(72 * time.Hour)
+	record(a, "t3", "x.post.read", "success", protocol.Usage{})
+	if n := len(a.buckets); n != 1 {
+		t.Fatalf("expected old buckets pruned, %d left", n)
+	}
+}
+
+func TestExportDaily(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	a := NewAggregator(0)
+	a.now = func() time.Time { return day.Add(5 * time.Hour) }
+	record(a, "t1", "image.ocr", "success", protocol.Usage{InputTokens: 100, OutputTokens: 20})
+
+	dir := t.TempDir()
+	if err := a.ExportDaily(dir, day.Add(13*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	csvData, err := os.ReadFile(filepath.Join(dir, "usage-2026-03-10.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(csvData)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "t1,image.ocr,1,0,") || !strings.HasSuffix(lines[1], ",100,20") {
+		t.Fatalf("unexpected csv:\n%s", csvData)
+	}
+	jsonData, err := os.ReadFile(filepath.Join(dir, "usage-2026-03-10.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep Report
+	if err := json.NewDecoder(bytes.NewReader(jsonData)).Decode(&rep); err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Rows) != 1 || rep.Rows[0].InputTokens != 100 || !rep.From.Equal(day) {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
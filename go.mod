@@ -20,6 +20,7 @@ require (
 	github.com/whyiyhw/go-workwx v0.1.0
 	github.com/yuin/goldmark v1.7.8
 	golang.org/x/sys v0.38.0
+	google.golang.org/grpc v1.67.1
 	google.golang.org/protobuf v1.35.1
 	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.6.0
@@ -79,7 +80,6 @@ require (
 	golang.org/x/text v0.31.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240814211410-ddb44dafa142 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20241021214115-324edc3d5d38 // indirect
-	google.golang.org/grpc v1.67.1 // indirect
 )
 
 replace github.com/originaleric/goDig => ../goDig
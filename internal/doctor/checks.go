@@ -0,0 +1,555 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/gorilla/websocket"
+	"github.com/originaleric/digeino/gateway/collector"
+	"github.com/originaleric/digeino/secrets"
+	"github.com/originaleric/digeino/status"
+	"github.com/originaleric/digeino/tools/ocr"
+	"github.com/originaleric/digeino/tools/research"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	wechatAppIDRe    = regexp.MustCompile(`^wx[0-9a-f]{16}$`)
+	hexSecret32Re    = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	wecomCorpIDRe    = regexp.MustCompile(`^w[wx][0-9a-f]{16}$`)
+	wecomSecretRe    = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+	feishuAppIDRe    = regexp.MustCompile(`^cli_[0-9a-z]{16}$`)
+	feishuSecretRe   = regexp.MustCompile(`^[A-Za-z0-9]{32}$`)
+	unresolvedEnvRe  = regexp.MustCompile(`\$\{[^}]*\}`)
+	minAuthTokenSize = 16
+)
+
+func (c *checker) checkConfigFile() {
+	c.section = "config"
+	path := c.opts.ConfigPath
+	if path == "" {
+		c.warn("file", "no config file given; using built-in defaults", "pass --config path/to/config.yaml")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		c.warn("file", fmt.Sprintf("%s: %v; using built-in defaults", path, err), "copy config/config.yaml and pass --config")
+		return
+	}
+	c.pass("file", "loaded "+path)
+}
+
+func (c *checker) checkGateway() {
+	c.section = "gateway"
+	gw := c.cfg.Gateway
+
+	listen := gw.ListenAddr
+	if listen == "" {
+		listen = ":8787"
+	}
+	token := strings.TrimSpace(gw.AuthToken)
+	var tokenErr error
+	if secrets.IsRef(token) {
+		token, tokenErr = secrets.Resolve(token)
+	}
+	switch {
+	case tokenErr != nil:
+		c.fail("auth_token", fmt.Sprintf("Gateway.AuthToken: %v; the gateway refuses to start", tokenErr), "fix the reference (see the secrets section)")
+	case token == "" && !loopbackAddr(listen):
+		c.warn("auth_token", fmt.Sprintf("Gateway.AuthToken is empty and %s listens on all interfaces; anyone who can reach it can drive the browser", listen),
+			"set Gateway.AuthToken, or bind ListenAddr to 127.0.0.1")
+	case token == "":
+		c.warn("auth_token", "Gateway.AuthToken is empty (loopback only)", "set Gateway.AuthToken before exposing the gateway")
+	case len(token) < minAuthTokenSize:
+		c.warn("auth_token", fmt.Sprintf("Gateway.AuthToken is only %d characters", len(token)), "use a random token of at least 32 characters")
+	default:
+		c.pass("auth_token", "Gateway.AuthToken is set")
+	}
+
+	if len(gw.AllowedTools) == 0 {
+		c.warn("allowed_tools", "Gateway.AllowedTools is empty: every registered tool is callable", "list the tools hosts need in Gateway.AllowedTools")
+	} else {
+		c.pass("allowed_tools", fmt.Sprintf("%d tools allowed", len(gw.AllowedTools)))
+	}
+
+	if enabledDefault(gw.ArtifactEnabled) {
+		dir := gw.ArtifactDir
+		if dir == "" {
+			dir = "storage/app/gateway_artifacts"
+		}
+		c.checkWritableDir("artifact_dir", "Gateway.ArtifactDir", dir)
+	} else {
+		c.skip("artifact_dir", "artifacts disabled")
+	}
+
+	switch rc := gw.ResultCache; rc.Backend {
+	case "":
+		c.skip("result_cache", "result cache disabled")
+	case "memory":
+		c.pass("result_cache", "memory result cache")
+	case "disk":
+		dir := rc.Dir
+		if dir == "" {
+			dir = "storage/app/gateway_cache"
+		}
+		c.checkWritableDir("result_cache", "Gateway.ResultCache.Dir", dir)
+	default:
+		c.fail("result_cache", fmt.Sprintf("unknown Gateway.ResultCache.Backend %q", rc.Backend), "use memory, disk or leave empty")
+	}
+
+	if dir := gw.Usage.ReportDir; dir != "" && enabledDefault(gw.Usage.Enabled) {
+		c.checkWritableDir("usage_reports", "Gateway.Usage.ReportDir", dir)
+	}
+}
+
+func (c *checker) checkFileAccess() {
+	c.section = "file access"
+	gw := c.cfg.Gateway
+	if len(gw.AllowedReadPaths) == 0 && len(gw.AllowedWritePaths) == 0 {
+		c.skip("paths", "no AllowedReadPaths / AllowedWritePaths; file tools disabled")
+		return
+	}
+	if !research.SecureFileAccessSupported() {
+		c.fail("secure_open", "secure file access (openat without symlinks) is not supported on this platform; file tools stay disabled",
+			"run file tools on Linux or macOS, or remove AllowedReadPaths / AllowedWritePaths")
+	} else {
+		c.pass("secure_open", "secure file access supported")
+	}
+	for _, p := range gw.AllowedReadPaths {
+		c.checkAllowedPath("read_path", "Gateway.AllowedReadPaths", p, false)
+	}
+	for _, p := range gw.AllowedWritePaths {
+		c.checkAllowedPath("write_path", "Gateway.AllowedWritePaths", p, true)
+	}
+}
+
+func (c *checker) checkAllowedPath(name, key, path string, write bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		c.fail(name, fmt.Sprintf("%s entry %q: %v", key, path, err), "use an absolute directory path")
+		return
+	}
+	if broadPath(abs) {
+		c.warn(name, fmt.Sprintf("%s entry %q exposes a whole filesystem or home directory", key, path), "narrow it to a dedicated directory")
+	}
+	if write {
+		c.checkWritableDir(name, key+" entry", abs)
+		return
+	}
+	info, err := os.Stat(abs)
+	switch {
+	case err != nil:
+		c.fail(name, fmt.Sprintf("%s entry %q: %v", key, path, err), "create the directory or remove the entry")
+	case !info.IsDir():
+		c.fail(name, fmt.Sprintf("%s entry %q is not a directory", key, path), "allowlist directories, not files")
+	default:
+		c.pass(name, fmt.Sprintf("%q readable", abs))
+	}
+}
+
+func broadPath(abs string) bool {
+	if abs == filepath.VolumeName(abs)+string(filepath.Separator) {
+		return true
+	}
+	home, err := os.UserHomeDir()
+	return err == nil && filepath.Clean(home) == abs
+}
+
+func (c *checker) checkBrowser() {
+	c.section = "browser"
+	lb := c.cfg.Tools.LocalBrowser
+	if !enabled(lb.Enabled) && !c.exposed(browserTool) {
+		c.skip("browser", "no browser tools enabled")
+		return
+	}
+
+	if len(lb.AllowedDomains) == 0 {
+		c.warn("allowed_domains", "Tools.LocalBrowser.AllowedDomains is empty: browser tools may open any site",
+			"list the sites you read in AllowedDomains (calls can still narrow with policy.allowed_domains)")
+	} else {
+		c.pass("allowed_domains", fmt.Sprintf("%d domains allowed", len(lb.AllowedDomains)))
+	}
+
+	bin := lb.ChromePath
+	if bin != "" {
+		if info, err := os.Stat(bin); err != nil || info.IsDir() {
+			c.fail("chrome", fmt.Sprintf("Tools.LocalBrowser.ChromePath %q not found", bin), "fix ChromePath or clear it to auto-detect Chrome/Chromium")
+			bin = ""
+		} else {
+			c.pass("chrome", "using "+bin)
+		}
+	} else if found, ok := launcher.LookPath(); ok {
+		bin = found
+		c.pass("chrome", "found "+found)
+	} else {
+		c.fail("chrome", "Chrome/Chromium not found on this machine", "install Google Chrome or Chromium, or set Tools.LocalBrowser.ChromePath")
+	}
+	if bin != "" {
+		if c.opts.LaunchBrowser {
+			c.checkBrowserLaunch(bin)
+		} else {
+			c.skip("launch", "not launched (use --launch-browser)")
+		}
+	}
+
+	dir := lb.CookieStoreDir
+	if dir == "" {
+		dir = "storage/app/browser_cookies"
+	}
+	c.checkWritableDir("cookie_store", "Tools.LocalBrowser.CookieStoreDir", dir)
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o077 != 0 {
+			path := filepath.Join(dir, e.Name())
+			c.warn("cookie_file_mode", fmt.Sprintf("cookie file %q has mode %s; saved logins are readable by other users", path, info.Mode().Perm()),
+				"chmod 600 "+path)
+		}
+	}
+}
+
+func (c *checker) checkBrowserLaunch(bin string) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.opts.Timeout)
+	defer cancel()
+	l := launcher.New().Context(ctx).Bin(bin).Headless(true).NoSandbox(true)
+	defer l.Kill()
+	controlURL, err := l.Launch()
+	if err != nil {
+		c.fail("launch", fmt.Sprintf("launching Chrome failed: %v", err), "run Chrome manually to see missing libraries or sandbox errors")
+		return
+	}
+	browser := rod.New().Context(ctx).ControlURL(controlURL)
+	if err := browser.Connect(); err != nil {
+		c.fail("launch", fmt.Sprintf("connecting to Chrome failed: %v", err), "check that no policy blocks the DevTools protocol")
+		return
+	}
+	defer browser.Close()
+	v, err := browser.Version()
+	if err != nil {
+		c.fail("launch", fmt.Sprintf("Chrome did not answer: %v", err), "")
+		return
+	}
+	c.pass("launch", "headless launch ok ("+v.Product+")")
+}
+
+// exposed reports whether the gateway allowlist (empty = all), or the collector's when
+// a collector is configured, includes a tool matching match.
+func (c *checker) exposed(match func(string) bool) bool {
+	lists := [][]string{c.cfg.Gateway.AllowedTools}
+	if len(collector.OptionsFromConfig(c.cfg).Endpoints()) > 0 && len(c.cfg.Collector.AllowedTools) > 0 {
+		lists = append(lists, c.cfg.Collector.AllowedTools)
+	}
+	for _, allowed := range lists {
+		if len(allowed) == 0 {
+			return true
+		}
+		for _, t := range allowed {
+			if match(strings.TrimSpace(t)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// browserTool reports whether a gateway tool drives the local browser.
+func browserTool(name string) bool {
+	return strings.HasPrefix(name, "browser.") || (strings.HasSuffix(name, ".read") && name != "file.read")
+}
+
+func (c *checker) checkCollector() {
+	c.section = "collector"
+	opts := collector.OptionsFromConfig(c.cfg)
+	hosts := opts.Endpoints()
+	if len(hosts) == 0 {
+		c.skip("server_url", "Collector.ServerURL / Collector.Hosts not set")
+		return
+	}
+	if opts.OutboxDir != "" {
+		c.checkWritableDir("outbox_dir", "Collector.OutboxDir", opts.OutboxDir)
+	} else {
+		c.warn("outbox_dir", "Collector.OutboxDir is empty: results finished while disconnected are lost", "set Collector.OutboxDir")
+	}
+	if opts.RateLimitStateFile != "" {
+		c.checkWritableDir("rate_limit_state", "Collector.RateLimitStateFile", filepath.Dir(opts.RateLimitStateFile))
+	} else if len(opts.RateLimits) > 0 {
+		c.warn("rate_limit_state", "Collector.RateLimitStateFile is empty: pacing restarts from zero after a restart", "set Collector.RateLimitStateFile")
+	}
+	for _, h := range hosts {
+		suffix := ""
+		if len(hosts) > 1 {
+			suffix = "[" + h.Name + "]"
+		}
+		c.checkCollectorHost(h, suffix)
+	}
+}
+
+// checkCollectorHost checks one host endpoint; suffix tells hosts apart when there are several.
+func (c *checker) checkCollectorHost(h collector.Endpoint, suffix string) {
+	wsURL, hdr, err := h.WSEndpoint()
+	if err != nil {
+		c.fail("server_url"+suffix, fmt.Sprintf("server URL %q: %v", h.ServerURL, err), "use an http(s):// or ws(s):// base URL")
+		return
+	}
+	c.pass("server_url"+suffix, wsURL)
+	if u, _ := url.Parse(wsURL); u != nil && u.Scheme == "ws" && !loopbackHost(u.Hostname()) {
+		c.warn("transport"+suffix, "collector connects over plain ws://; the token is sent unencrypted", "use https:// / wss:// for remote hosts")
+	}
+	if h.Token == "" {
+		c.fail("token"+suffix, "collector token is empty; the host will reject the connection", "copy the collector token from the host into Collector.Token (or the host's Token)")
+		return
+	}
+	c.pass("token"+suffix, "token is set")
+
+	if !c.opts.Network {
+		c.skip("handshake"+suffix, "not probed (use --network)")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.opts.Timeout)
+	defer cancel()
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, hdr)
+	switch {
+	case err == nil:
+		conn.Close()
+		c.pass("handshake"+suffix, "host accepted the token")
+	case resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden):
+		c.fail("handshake"+suffix, fmt.Sprintf("host rejected the token (HTTP %d)", resp.StatusCode), "the collector token must match the token issued by the host")
+	case resp != nil:
+		c.fail("handshake"+suffix, fmt.Sprintf("WebSocket upgrade failed (HTTP %d)", resp.StatusCode), "check WSPath against the host route")
+	default:
+		c.fail("handshake"+suffix, fmt.Sprintf("cannot reach host: %v", err), "check ServerURL, DNS and firewalls")
+	}
+}
+
+func (c *checker) checkStore() {
+	c.section = "status store"
+	st := c.cfg.Status.Store
+	if !enabledDefault(st.Enabled) || st.Type == "" || st.Type == "memory" {
+		c.skip("store", "memory store")
+		return
+	}
+	m := st.MySQL
+	var missing []string
+	for _, f := range []struct{ key, value string }{{"Host", m.Host}, {"User", m.User}, {"Database", m.Database}} {
+		if strings.TrimSpace(f.value) == "" {
+			missing = append(missing, "Status.Store.MySQL."+f.key)
+		}
+	}
+	if len(missing) > 0 {
+		c.fail("mysql_config", "missing "+strings.Join(missing, ", "), "status silently falls back to the memory store without them")
+		return
+	}
+	if m.Port == 0 {
+		m.Port = 3306
+	}
+	c.pass("mysql_config", fmt.Sprintf("%s@%s:%d/%s", m.User, m.Host, m.Port, m.Database))
+	if !c.opts.Network {
+		c.skip("mysql_ping", "not probed (use --network)")
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.opts.Timeout)
+	defer cancel()
+	err := func() error {
+		db, err := gorm.Open(mysql.Open(status.MySQLDSN(m)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), DisableAutomaticPing: true})
+		if err != nil {
+			return err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		defer sqlDB.Close()
+		return sqlDB.PingContext(ctx)
+	}()
+	if err != nil {
+		c.fail("mysql_ping", fmt.Sprintf("cannot connect: %v", err), "status silently falls back to the memory store; check host, credentials and grants")
+		return
+	}
+	c.pass("mysql_ping", "connected")
+}
+
+func (c *checker) checkOCR() {
+	c.section = "ocr"
+	cfg := c.cfg.Tools.OCR
+	if !enabled(cfg.Enabled) {
+		c.skip("ocr", "Tools.OCR disabled")
+		return
+	}
+	if err := ocr.CheckConfig(cfg); err != nil {
+		c.fail("provider", err.Error(), "set the API key / base URL for Tools.OCR.Provider (environment references must resolve)")
+	} else {
+		provider := cfg.Provider
+		if provider == "" {
+			provider = "deepseek-ocr"
+		}
+		c.pass("provider", provider+" configured")
+	}
+	if len(cfg.AllowedFilePaths) > 0 && !research.SecureFileAccessSupported() {
+		c.warn("file_paths", "Tools.OCR.AllowedFilePaths is set but secure file access is unsupported here", "send images by URL or base64")
+	}
+	if cfg.BlockPrivateNetworks != nil && !*cfg.BlockPrivateNetworks {
+		c.warn("private_networks", "Tools.OCR.BlockPrivateNetworks is false: image URLs may reach internal services", "keep BlockPrivateNetworks enabled unless you need intranet images")
+	}
+}
+
+func (c *checker) checkWeChat() {
+	c.section = "wechat"
+	wc := c.cfg.WeChat
+	if !enabled(wc.Enabled) {
+		c.skip("wechat", "WeChat disabled")
+		return
+	}
+	c.checkShape("app_id", "WeChat.AppID", wc.AppID, wechatAppIDRe, "wx followed by 16 hex characters")
+	c.checkShape("app_secret", "WeChat.AppSecret", wc.AppSecret, hexSecret32Re, "32 hex characters")
+	if len(wc.OpenIDs) == 0 {
+		c.warn("open_ids", "WeChat.OpenIDs is empty; notifications have no default recipient", "add recipient openids")
+	}
+	path := wc.TokenFilePath
+	if path == "" {
+		path = "storage/app/wechat/access_token.json"
+	}
+	c.checkSecretFile("token_file", "WeChat.TokenFilePath", path)
+}
+
+func (c *checker) checkWeCom() {
+	c.section = "wecom"
+	wc := c.cfg.WeCom
+	if !enabled(wc.Enabled) {
+		c.skip("wecom", "WeCom disabled")
+		return
+	}
+	c.checkShape("corp_id", "WeCom.CorpID", wc.CorpID, wecomCorpIDRe, "ww followed by 16 hex characters")
+	if len(wc.Applications) == 0 {
+		c.fail("applications", "WeCom.Applications is empty", "add at least one application with AgentID and AgentSecret")
+	}
+	for i, app := range wc.Applications {
+		key := fmt.Sprintf("WeCom.Applications[%d]", i)
+		if app.AgentID <= 0 {
+			c.fail("agent_id", key+".AgentID is not set", "copy the AgentId from the WeCom admin console")
+		}
+		c.checkShape("agent_secret", key+".AgentSecret", app.AgentSecret, wecomSecretRe, "43 characters")
+	}
+	if cb := wc.Callback; enabled(cb.Enabled) {
+		if cb.Token == "" {
+			c.fail("callback_token", "WeCom.Callback.Token is empty", "copy the callback Token from the admin console")
+		}
+		c.checkShape("callback_aes_key", "WeCom.Callback.EncodingAESKey", cb.EncodingAESKey, wecomSecretRe, "43 characters")
+	}
+	if wc.TokenFilePath != "" {
+		c.checkSecretFile("token_file", "WeCom.TokenFilePath", wc.TokenFilePath)
+	}
+}
+
+func (c *checker) checkFeishu() {
+	c.section = "feishu"
+	fs := c.cfg.Feishu
+	if !enabled(fs.Enabled) {
+		c.skip("feishu", "Feishu disabled")
+		return
+	}
+	api := fs.API
+	if enabledDefault(api.Enabled) || enabled(fs.SendViaAPI) {
+		c.checkShape("app_id", "Feishu.API.AppID", api.AppID, feishuAppIDRe, "cli_ followed by 16 characters")
+		c.checkShape("app_secret", "Feishu.API.AppSecret", api.AppSecret, feishuSecretRe, "32 alphanumeric characters")
+		if api.BaseURL != "" && api.BaseURL != "https://open.feishu.cn" && api.BaseURL != "https://open.larksuite.com" {
+			c.warn("base_url", fmt.Sprintf("Feishu.API.BaseURL %q is not an official endpoint", api.BaseURL), "use https://open.feishu.cn or https://open.larksuite.com")
+		}
+		switch api.ReceiveIDType {
+		case "", "chat_id", "open_id", "user_id", "email", "union_id":
+		default:
+			c.fail("receive_id_type", fmt.Sprintf("unknown Feishu.API.ReceiveIDType %q", api.ReceiveIDType), "use chat_id, open_id, user_id, union_id or email")
+		}
+	}
+	if enabled(fs.EventIngest.WebhookEnabled) && fs.EventIngest.VerificationToken == "" {
+		c.fail("verification_token", "webhook ingest is enabled without Feishu.EventIngest.VerificationToken", "set it to reject forged events")
+	}
+}
+
+// checkShape fails empty or unresolved secrets and warns on values that do not look like the platform's format.
+func (c *checker) checkShape(name, key, value string, re *regexp.Regexp, format string) {
+	resolved, ok := resolveSecret(value)
+	switch {
+	case !ok:
+		c.fail(name, key+" is empty or its ${ENV} / secret:// reference does not resolve", "set "+key)
+	case !re.MatchString(resolved):
+		c.warn(name, key+" does not look like "+format, "check for copy/paste errors or a value from another app")
+	default:
+		c.pass(name, key+" looks valid")
+	}
+}
+
+// secretSet reports whether v is non-empty after expanding ${ENV} and resolving secret:// references.
+func secretSet(v string) bool {
+	_, ok := resolveSecret(v)
+	return ok
+}
+
+// resolveSecret expands ${ENV} references and resolves secret:// references; ok is false when the
+// result is empty or unresolved. The value is only used for checks and never reported.
+func resolveSecret(v string) (string, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", false
+	}
+	if secrets.IsRef(v) {
+		val, err := secrets.Resolve(v)
+		return val, err == nil && val != ""
+	}
+	expanded := strings.TrimSpace(os.ExpandEnv(v))
+	return expanded, expanded != "" && !unresolvedEnvRe.MatchString(expanded)
+}
+
+func (c *checker) checkSecrets() {
+	c.section = "secrets"
+	var refs []secrets.Field
+	for _, f := range secrets.Fields(c.cfg) {
+		if f.IsRef() {
+			refs = append(refs, f)
+		}
+	}
+	if len(refs) == 0 {
+		c.skip("refs", "no secret:// references in config")
+		return
+	}
+	failed, vault := 0, false
+	for _, f := range refs {
+		if ref, err := secrets.ParseRef(f.Value); err == nil && ref.Kind == secrets.KindVaultFile {
+			vault = true
+		}
+		if _, err := secrets.Resolve(f.Value); err != nil {
+			failed++
+			c.fail("ref", fmt.Sprintf("%s: %v", f.Path, err), "export the variable, create the file, or add the vault entry with digeino secrets set <key>")
+		}
+	}
+	if failed == 0 {
+		c.pass("refs", fmt.Sprintf("%d secret:// references resolve", len(refs)))
+	}
+	if vault {
+		c.checkSecretFile("vault_file", "Secrets.VaultFile", secrets.VaultPath(c.cfg.Secrets))
+	}
+}
+
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return loopbackHost(host)
+}
+
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
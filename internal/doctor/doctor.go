@@ -0,0 +1,192 @@
+// Package doctor checks a DigEino configuration and its environment (digeino doctor).
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
+)
+
+// Status is the outcome of one check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Result is one check in the report.
+type Result struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Report is the full doctor output.
+type Report struct {
+	ConfigPath string   `json:"config_path,omitempty"`
+	Results    []Result `json:"results"`
+}
+
+// Count returns the number of results with status s.
+func (r Report) Count(s Status) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Options controls which checks touch the outside world.
+type Options struct {
+	ConfigPath string
+	// LaunchBrowser starts and closes a headless Chrome instead of only locating the binary.
+	LaunchBrowser bool
+	// Network enables connectivity probes (collector host, status store DB).
+	Network bool
+	// Timeout bounds each network probe and the browser launch; default 10s.
+	Timeout time.Duration
+}
+
+type checker struct {
+	ctx     context.Context
+	cfg     *config.Config
+	opts    Options
+	section string
+	results []Result
+}
+
+// Run checks every enabled subsystem in cfg.
+func Run(ctx context.Context, cfg *config.Config, opts Options) Report {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	secrets.RememberConfig(cfg)
+	c := &checker{ctx: ctx, cfg: cfg, opts: opts}
+	c.checkConfigFile()
+	c.checkSecrets()
+	c.checkGateway()
+	c.checkFileAccess()
+	c.checkBrowser()
+	c.checkCollector()
+	c.checkStore()
+	c.checkOCR()
+	c.checkWeChat()
+	c.checkWeCom()
+	c.checkFeishu()
+	return Report{ConfigPath: opts.ConfigPath, Results: c.results}
+}
+
+// add records a result; messages pass through secrets.Scrub so no check can print a credential.
+func (c *checker) add(name string, status Status, msg, hint string) {
+	c.results = append(c.results, Result{Section: c.section, Name: name, Status: status, Message: secrets.Scrub(msg), Hint: secrets.Scrub(hint)})
+}
+
+func (c *checker) pass(name, msg string)       { c.add(name, Pass, msg, "") }
+func (c *checker) warn(name, msg, hint string) { c.add(name, Warn, msg, hint) }
+func (c *checker) fail(name, msg, hint string) { c.add(name, Fail, msg, hint) }
+func (c *checker) skip(name, msg string)       { c.add(name, Skip, msg, "") }
+
+// checkWritableDir records whether dir accepts new files. A missing dir is not created (doctor
+// leaves the filesystem alone): its nearest existing parent is tested instead and the result is a
+// warning.
+func (c *checker) checkWritableDir(name, key, dir string) {
+	existing, err := writableDir(dir)
+	switch {
+	case err != nil:
+		c.fail(name, fmt.Sprintf("%s %q is not writable: %v", key, dir, err),
+			fmt.Sprintf("create the directory and grant write access to this user, or point %s elsewhere", key))
+	case existing != filepath.Clean(dir):
+		c.warn(name, fmt.Sprintf("%s %q does not exist yet; %q is writable so it can be created on first use", key, dir, existing),
+			fmt.Sprintf("mkdir -p %s", dir))
+	default:
+		c.pass(name, fmt.Sprintf("%s %q is writable", key, dir))
+	}
+}
+
+// writableDir tests that the nearest existing directory at or above dir accepts new files and
+// returns it.
+func writableDir(dir string) (string, error) {
+	existing := filepath.Clean(dir)
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return existing, fmt.Errorf("%s is not a directory", existing)
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return existing, err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return existing, err
+		}
+		existing = parent
+	}
+	f, err := os.CreateTemp(existing, ".digeino-doctor-*")
+	if err != nil {
+		return existing, err
+	}
+	name := f.Name()
+	f.Close()
+	return existing, os.Remove(name)
+}
+
+// checkSecretFile checks a token/cookie file's directory and that the file is not group/world readable.
+func (c *checker) checkSecretFile(name, key, path string) {
+	c.checkWritableDir(name+"_dir", key+" directory", filepath.Dir(path))
+	info, err := os.Stat(path)
+	if err != nil {
+		return // created on first use
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		c.warn(name+"_mode", fmt.Sprintf("%s %q has mode %s and is readable by other users", key, path, info.Mode().Perm()),
+			fmt.Sprintf("chmod 600 %s", path))
+	}
+}
+
+// WriteText prints the report grouped by section with remediation hints.
+func WriteText(w io.Writer, r Report) {
+	if r.ConfigPath != "" {
+		fmt.Fprintf(w, "config: %s\n", r.ConfigPath)
+	}
+	section := ""
+	for _, res := range r.Results {
+		if res.Section != section {
+			section = res.Section
+			fmt.Fprintf(w, "\n%s\n", section)
+		}
+		fmt.Fprintf(w, "  [%s] %-22s %s\n", strings.ToUpper(string(res.Status)), res.Name, res.Message)
+		if res.Hint != "" && (res.Status == Warn || res.Status == Fail) {
+			fmt.Fprintf(w, "         %-22s → %s\n", "", res.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		r.Count(Pass), r.Count(Warn), r.Count(Fail), r.Count(Skip))
+}
+
+func enabled(b *bool) bool {
+	return b != nil && *b
+}
+
+// enabledDefault treats a nil flag as enabled.
+func enabledDefault(b *bool) bool {
+	return b == nil || *b
+}
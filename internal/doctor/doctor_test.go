@@ -0,0 +1,124 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/originaleric/digeino/config"
+)
+
+func find(r Report, section, name string) (Result, bool) {
+	for _, res := range r.Results {
+		if res.Section == section && res.Name == name {
+			return res, true
+		}
+	}
+	return Result{}, false
+}
+
+func expect(t *testing.T, r Report, section, name string, want Status) {
+	t.Helper()
+	res, ok := find(r, section, name)
+	if !ok {
+		t.Fatalf("missing %s/%s in %+v", section, name, r.Results)
+	}
+	if res.Status != want {
+		t.Fatalf("%s/%s: expected %s, got %s (%s)", section, name, want, res.Status, res.Message)
+	}
+}
+
+func TestRunFlagsRiskySettings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	on := true
+	cfg := config.Default()
+	cfg.Gateway.AuthToken = ""
+	cfg.Gateway.ListenAddr = ":8787"
+	cfg.Gateway.ArtifactDir = filepath.Join(dir, "artifacts")
+	cfg.Gateway.AllowedReadPaths = []string{filepath.Join(dir, "missing")}
+	cfg.Tools.LocalBrowser.AllowedDomains = nil
+	cfg.Tools.LocalBrowser.ChromePath = filepath.Join(dir, "no-chrome")
+	cfg.Tools.LocalBrowser.CookieStoreDir = filepath.Join(dir, "cookies")
+	cfg.Collector.ServerURL = "https://host.example.com"
+	cfg.Collector.Token = ""
+	cfg.WeCom.Enabled = &on
+	cfg.WeCom.CorpID = "not-a-corp-id"
+	cfg.WeCom.Applications = []config.WeComApplication{{AgentID: 1000002, AgentSecret: "${DIGEINO_DOCTOR_UNSET_SECRET}"}}
+
+	if err := os.MkdirAll(cfg.Tools.LocalBrowser.CookieStoreDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Tools.LocalBrowser.CookieStoreDir, "example.com.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Run(context.Background(), cfg, Options{})
+	expect(t, r, "gateway", "auth_token", Warn)
+	expect(t, r, "gateway", "artifact_dir", Warn)
+	if _, err := os.Stat(cfg.Gateway.ArtifactDir); !os.IsNotExist(err) {
+		t.Fatalf("doctor created %s: %v", cfg.Gateway.ArtifactDir, err)
+	}
+	expect(t, r, "file access", "read_path", Fail)
+	expect(t, r, "browser", "allowed_domains", Warn)
+	expect(t, r, "browser", "chrome", Fail)
+	expect(t, r, "browser", "cookie_store", Pass)
+	expect(t, r, "browser", "cookie_file_mode", Warn)
+	expect(t, r, "collector", "server_url", Pass)
+	expect(t, r, "collector", "token", Fail)
+	expect(t, r, "wecom", "corp_id", Warn)
+	expect(t, r, "wecom", "agent_secret", Fail)
+	expect(t, r, "feishu", "feishu", Skip)
+	if r.Count(Fail) < 4 {
+		t.Fatalf("expected failures, got %+v", r.Results)
+	}
+}
+
+func TestRunCleanConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Gateway.AuthToken = "0123456789abcdef0123456789abcdef"
+	cfg.Gateway.ListenAddr = "127.0.0.1:8787"
+	cfg.Gateway.AllowedTools = []string{"file.read"}
+	cfg.Gateway.ArtifactDir = filepath.Join(dir, "artifacts")
+	cfg.Gateway.AllowedReadPaths = []string{dir}
+
+	r := Run(context.Background(), cfg, Options{ConfigPath: filepath.Join(dir, "absent.yaml")})
+	expect(t, r, "gateway", "auth_token", Pass)
+	expect(t, r, "file access", "read_path", Pass)
+	expect(t, r, "browser", "browser", Skip)
+	if n := r.Count(Fail); n != 0 {
+		t.Fatalf("expected no failures, got %+v", r.Results)
+	}
+}
+
+func TestRunResolvesSecretRefsWithoutPrintingThem(t *testing.T) {
+	t.Chdir(t.TempDir())
+	const token = "doctor-env-token-0123456789abcdef"
+	t.Setenv("DIGEINO_DOCTOR_TOKEN", token)
+	on := true
+	cfg := config.Default()
+	cfg.Gateway.AuthToken = "secret://env/DIGEINO_DOCTOR_TOKEN"
+	cfg.Gateway.ListenAddr = "127.0.0.1:8787"
+	cfg.WeChat.Enabled = &on
+	cfg.WeChat.AppID = "wx0123456789abcdef"
+	cfg.WeChat.AppSecret = "secret://env/DIGEINO_DOCTOR_UNSET_SECRET"
+
+	r := Run(context.Background(), cfg, Options{})
+	expect(t, r, "gateway", "auth_token", Pass)
+	expect(t, r, "secrets", "ref", Fail)
+	expect(t, r, "wechat", "app_secret", Fail)
+
+	var buf bytes.Buffer
+	WriteText(&buf, r)
+	if strings.Contains(buf.String(), token) {
+		t.Fatalf("doctor output contains the secret:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "WeChat.AppSecret: secret://env/DIGEINO_DOCTOR_UNSET_SECRET") {
+		t.Fatalf("unresolved reference not reported:\n%s", buf.String())
+	}
+}
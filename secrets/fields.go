@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/originaleric/digeino/config"
+)
+
+// Field is a secret-bearing config field, e.g. Gateway.AuthToken.
+type Field struct {
+	Path  string
+	Value string
+}
+
+// IsRef reports whether the field holds a secret:// reference.
+func (f Field) IsRef() bool { return IsRef(f.Value) }
+
+// IsSecretName reports whether a config key holds a credential (…Secret, …Token, …Password, …Key, Authorization).
+func IsSecretName(name string) bool {
+	k := strings.ToLower(name)
+	for _, suffix := range []string{"secret", "token", "password", "key", "authorization"} {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields returns the non-empty secret fields of cfg, including string values in free-form maps such as
+// ChatModel.Config; every secret:// value anywhere in the config is included as well.
+func Fields(cfg *config.Config) []Field {
+	var out []Field
+	walk(reflect.ValueOf(cfg), "", "", &out)
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// RememberConfig registers the literal secret values of cfg with Scrub, so tokens written directly in
+// config.yaml are masked in logs just like resolved references. Later reloads are tracked as well.
+func RememberConfig(cfg *config.Config) {
+	Default()
+	rememberConfig(cfg)
+}
+
+func rememberConfig(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	for _, f := range Fields(cfg) {
+		Remember(f.Value)
+	}
+}
+
+func walk(v reflect.Value, path, name string, out *[]Field) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), path, name, out)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if key == "" || key == "-" {
+				key = f.Name
+			}
+			if strings.Contains(f.Tag.Get("yaml"), "inline") {
+				walk(v.Field(i), path, name, out)
+				continue
+			}
+			walk(v.Field(i), joinPath(path, key), key, out)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		for _, k := range v.MapKeys() {
+			walk(v.MapIndex(k), fmt.Sprintf("%s[%s]", path, k.String()), k.String(), out)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i), name, out)
+		}
+	case reflect.String:
+		s := v.String()
+		if s != "" && (IsRef(s) || IsSecretName(name)) {
+			*out = append(*out, Field{Path: path, Value: s})
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in Scrub output.
+const Mask = "******"
+
+// minScrubLen avoids masking short values such as "1" or "on" that would garble unrelated text.
+const minScrubLen = 6
+
+var (
+	knownMu sync.RWMutex
+	known   = map[string]struct{}{}
+)
+
+// remember registers a resolved value so Scrub masks it.
+func remember(v string) {
+	v = strings.TrimSpace(v)
+	if len(v) < minScrubLen {
+		return
+	}
+	knownMu.Lock()
+	known[v] = struct{}{}
+	knownMu.Unlock()
+}
+
+// Remember registers values that did not come through Resolve (e.g. literal tokens in config.yaml).
+func Remember(values ...string) {
+	for _, v := range values {
+		if !IsRef(v) && !strings.HasPrefix(v, "${") {
+			remember(v)
+		}
+	}
+}
+
+// Scrub replaces every known secret value in s with Mask.
+func Scrub(s string) string {
+	knownMu.RLock()
+	defer knownMu.RUnlock()
+	if len(known) == 0 {
+		return s
+	}
+	// 长值优先，避免某个密钥是另一个的前缀时只遮住一部分。
+	values := make([]string, 0, len(known))
+	for v := range known {
+		if strings.Contains(s, v) {
+			values = append(values, v)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+	return s
+}
+
+// ScrubWriter wraps w so that every write is passed through Scrub; use it as the log output.
+func ScrubWriter(w io.Writer) io.Writer {
+	return scrubWriter{w}
+}
+
+type scrubWriter struct{ w io.Writer }
+
+func (s scrubWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(s.w, Scrub(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,299 @@
+// Package secrets resolves secret:// references used in config.yaml for API keys and tokens.
+//
+//	secret://env/NAME           environment variable NAME
+//	secret://file/path          file content (trailing newline trimmed); secret://file//abs/path for absolute paths
+//	secret://vault-file/key     entry of the encrypted vault file (Secrets.VaultFile, see Vault)
+//
+// Values are resolved on first use and cached for Secrets.CacheTTLSec; a config reload clears the cache.
+// Every resolved value is remembered so Scrub / ScrubWriter can mask it in logs and diagnostics.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/originaleric/digeino/config"
+)
+
+// Scheme prefixes every secret reference.
+const Scheme = "secret://"
+
+// PassphraseEnv holds the vault passphrase when Secrets.PassphraseFile is empty.
+const PassphraseEnv = "DIGEINO_VAULT_PASSPHRASE"
+
+// Reference kinds.
+const (
+	KindEnv       = "env"
+	KindFile      = "file"
+	KindVaultFile = "vault-file"
+)
+
+// ErrNotFound is returned when a reference points at an unset variable, missing file or vault key.
+var ErrNotFound = errors.New("secret not found")
+
+// Ref is a parsed secret:// reference.
+type Ref struct {
+	Kind string
+	Name string
+}
+
+func (r Ref) String() string { return Scheme + r.Kind + "/" + r.Name }
+
+// IsRef reports whether s is a secret:// reference.
+func IsRef(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), Scheme)
+}
+
+// ParseRef parses secret://<kind>/<name>.
+func ParseRef(s string) (Ref, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, Scheme) {
+		return Ref{}, fmt.Errorf("not a secret reference (want %s<kind>/<name>)", Scheme)
+	}
+	kind, name, ok := strings.Cut(strings.TrimPrefix(s, Scheme), "/")
+	if !ok || name == "" {
+		return Ref{}, fmt.Errorf("secret reference %q has no name", s)
+	}
+	switch kind {
+	case KindEnv, KindFile, KindVaultFile:
+	default:
+		return Ref{}, fmt.Errorf("unknown secret kind %q in %q (env | file | vault-file)", kind, s)
+	}
+	return Ref{Kind: kind, Name: name}, nil
+}
+
+// Resolver resolves references with a per-reference cache.
+type Resolver struct {
+	// Settings returns the current Secrets config; defaults to config.Get().Secrets.
+	Settings func() config.SecretsConfig
+	// Getenv defaults to os.Getenv.
+	Getenv func(string) string
+
+	mu    sync.Mutex
+	cache map[string]cached
+	vault *Vault
+	vkey  vaultKey
+}
+
+type cached struct {
+	value   string
+	expires time.Time
+}
+
+type vaultKey struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// NewResolver returns a resolver reading settings from the global config.
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+func (r *Resolver) settings() config.SecretsConfig {
+	if r.Settings != nil {
+		return r.Settings()
+	}
+	return config.Get().Secrets
+}
+
+func (r *Resolver) getenv(name string) string {
+	if r.Getenv != nil {
+		return r.Getenv(name)
+	}
+	return os.Getenv(name)
+}
+
+// Resolve returns value unchanged unless it is a secret:// reference, in which case the
+// referenced secret is returned. Errors name the reference, never the value.
+func (r *Resolver) Resolve(value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	ref, err := ParseRef(value)
+	if err != nil {
+		return "", err
+	}
+	key := ref.String()
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.cache[key]; ok && now.Before(c.expires) {
+		return c.value, nil
+	}
+	v, err := r.lookup(ref)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	remember(v)
+	if r.cache == nil {
+		r.cache = make(map[string]cached)
+	}
+	r.cache[key] = cached{value: v, expires: now.Add(r.ttl())}
+	return v, nil
+}
+
+func (r *Resolver) ttl() time.Duration {
+	if sec := r.settings().CacheTTLSec; sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+func (r *Resolver) lookup(ref Ref) (string, error) {
+	switch ref.Kind {
+	case KindEnv:
+		v := r.getenv(ref.Name)
+		if v == "" {
+			return "", fmt.Errorf("environment variable %s is not set: %w", ref.Name, ErrNotFound)
+		}
+		return v, nil
+	case KindFile:
+		data, err := os.ReadFile(ref.Name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("file %s does not exist: %w", ref.Name, ErrNotFound)
+			}
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case KindVaultFile:
+		v, err := r.openVault()
+		if err != nil {
+			return "", err
+		}
+		val, ok := v.Get(ref.Name)
+		if !ok {
+			return "", fmt.Errorf("key %q not in vault %s: %w", ref.Name, v.path, ErrNotFound)
+		}
+		return val, nil
+	}
+	return "", fmt.Errorf("unknown secret kind %q", ref.Kind)
+}
+
+// openVault decrypts the vault file once and reuses it until the file changes. Caller holds r.mu.
+func (r *Resolver) openVault() (*Vault, error) {
+	s := r.settings()
+	path := VaultPath(s)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", path, err)
+	}
+	key := vaultKey{path: path, modTime: info.ModTime(), size: info.Size()}
+	if r.vault != nil && r.vkey == key {
+		return r.vault, nil
+	}
+	pass, err := Passphrase(s, r.getenv)
+	if err != nil {
+		return nil, err
+	}
+	v, err := OpenVault(path, pass)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range v.Keys() {
+		val, _ := v.Get(k)
+		remember(val)
+	}
+	r.vault, r.vkey = v, key
+	return v, nil
+}
+
+// Invalidate drops cached values and the decrypted vault.
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	r.cache = nil
+	r.vault = nil
+	r.vkey = vaultKey{}
+	r.mu.Unlock()
+}
+
+// VaultPath returns Secrets.VaultFile or its default.
+func VaultPath(s config.SecretsConfig) string {
+	if s.VaultFile != "" {
+		return s.VaultFile
+	}
+	return "storage/secrets.vault"
+}
+
+// Passphrase reads the vault passphrase from Secrets.PassphraseFile or $DIGEINO_VAULT_PASSPHRASE.
+func Passphrase(s config.SecretsConfig, getenv func(string) string) (string, error) {
+	if s.PassphraseFile != "" {
+		data, err := os.ReadFile(s.PassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("read Secrets.PassphraseFile: %w", err)
+		}
+		if p := strings.TrimRight(string(data), "\r\n"); p != "" {
+			remember(p)
+			return p, nil
+		}
+		return "", fmt.Errorf("Secrets.PassphraseFile %s is empty", s.PassphraseFile)
+	}
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if p := getenv(PassphraseEnv); p != "" {
+		remember(p)
+		return p, nil
+	}
+	return "", fmt.Errorf("vault passphrase not set (Secrets.PassphraseFile or $%s)", PassphraseEnv)
+}
+
+var (
+	defaultResolver = NewResolver()
+	subscribeOnce   sync.Once
+	warned          sync.Map
+)
+
+// Default returns the process-wide resolver; it is cleared whenever the config is replaced, and
+// literal secrets of the new config are registered with Scrub.
+func Default() *Resolver {
+	subscribeOnce.Do(func() {
+		config.Subscribe(func(_, next *config.Config) {
+			defaultResolver.Invalidate()
+			rememberConfig(next)
+		})
+	})
+	return defaultResolver
+}
+
+// Resolve resolves value with the default resolver.
+func Resolve(value string) (string, error) {
+	return Default().Resolve(value)
+}
+
+// Value resolves value and returns "" (logging the reference once) when it cannot be resolved,
+// so callers keep their "not configured" handling.
+func Value(value string) string {
+	v, err := Resolve(value)
+	if err != nil {
+		if _, loaded := warned.LoadOrStore(strings.TrimSpace(value), true); !loaded {
+			log.Printf("[secrets] %v", err)
+		}
+		return ""
+	}
+	return v
+}
+
+// Lookup resolves value (trimmed) and falls back to the first non-empty environment variable in
+// envFallbacks; it replaces the per-tool os.Getenv fallbacks.
+func Lookup(value string, envFallbacks ...string) string {
+	if v := strings.TrimSpace(Value(value)); v != "" {
+		remember(v)
+		return v
+	}
+	for _, name := range envFallbacks {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			remember(v)
+			return v
+		}
+	}
+	return ""
+}
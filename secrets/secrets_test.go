@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/originaleric/digeino/config"
+)
+
+func TestResolverEnvFileAndCache(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("file-secret-value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"API_KEY": "env-secret-value"}
+	r := &Resolver{
+		Settings: func() config.SecretsConfig { return config.SecretsConfig{CacheTTLSec: 3600} },
+		Getenv:   func(k string) string { return env[k] },
+	}
+
+	if v, err := r.Resolve("plain"); err != nil || v != "plain" {
+		t.Fatalf("non-reference must pass through, got %q %v", v, err)
+	}
+	if v, err := r.Resolve("secret://env/API_KEY"); err != nil || v != "env-secret-value" {
+		t.Fatalf("env ref = %q %v", v, err)
+	}
+	if v, err := r.Resolve("secret://file/" + file); err != nil || v != "file-secret-value" {
+		t.Fatalf("file ref = %q %v", v, err)
+	}
+	if _, err := r.Resolve("secret://env/MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := r.Resolve("secret://ssm/x"); err == nil {
+		t.Fatal("unknown kind must fail")
+	}
+
+	env["API_KEY"] = "rotated-secret-value"
+	if v, _ := r.Resolve("secret://env/API_KEY"); v != "env-secret-value" {
+		t.Fatalf("expected cached value, got %q", v)
+	}
+	r.Invalidate()
+	if v, _ := r.Resolve("secret://env/API_KEY"); v != "rotated-secret-value" {
+		t.Fatalf("expected value after Invalidate, got %q", v)
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "secrets.vault")
+	v, err := OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Set("openai", "sk-vault-secret-1234")
+	if err := v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("sk-vault-secret-1234")) {
+		t.Fatal("vault file contains the plaintext value")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("vault mode = %v", info.Mode().Perm())
+	}
+	if _, err := OpenVault(path, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("expected wrong passphrase error, got %v", err)
+	}
+
+	r := &Resolver{
+		Settings: func() config.SecretsConfig { return config.SecretsConfig{VaultFile: path} },
+		Getenv:   func(k string) string { return map[string]string{PassphraseEnv: "correct horse"}[k] },
+	}
+	if got, err := r.Resolve("secret://vault-file/openai"); err != nil || got != "sk-vault-secret-1234" {
+		t.Fatalf("vault ref = %q %v", got, err)
+	}
+	if _, err := r.Resolve("secret://vault-file/absent"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestScrubMasksResolvedAndLiteralSecrets(t *testing.T) {
+	r := &Resolver{Getenv: func(string) string { return "resolved-token-abc" }}
+	if _, err := r.Resolve("secret://env/ANY"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Gateway.AuthToken = "literal-gateway-token"
+	cfg.Tools.Firecrawl.ApiKey = "secret://env/FIRECRAWL"
+	rememberConfig(cfg)
+
+	var buf bytes.Buffer
+	logger := log.New(ScrubWriter(&buf), "", 0)
+	logger.Printf("auth=%s key=resolved-token-abc ref=%s", cfg.Gateway.AuthToken, cfg.Tools.Firecrawl.ApiKey)
+	out := buf.String()
+	if strings.Contains(out, "literal-gateway-token") || strings.Contains(out, "resolved-token-abc") {
+		t.Fatalf("secret leaked: %q", out)
+	}
+	if !strings.Contains(out, "ref=secret://env/FIRECRAWL") {
+		t.Fatalf("references are not secret and must stay readable: %q", out)
+	}
+}
+
+func TestFields(t *testing.T) {
+	cfg := config.Default()
+	cfg.Status.Webhook.Config.Secret = "secret://file/hook"
+	cfg.ChatModel.Config = map[string]interface{}{"ApiKey": "k-123456", "BaseUrl": "secret://env/BASE"}
+	got := map[string]string{}
+	for _, f := range Fields(cfg) {
+		got[f.Path] = f.Value
+	}
+	for path, want := range map[string]string{
+		"Status.Webhook.secret":     "secret://file/hook",
+		"ChatModel.Config[ApiKey]":  "k-123456",
+		"ChatModel.Config[BaseUrl]": "secret://env/BASE",
+	} {
+		if got[path] != want {
+			t.Fatalf("%s = %q, want %q (all: %v)", path, got[path], want, got)
+		}
+	}
+}
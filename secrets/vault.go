@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	vaultVersion    = 1
+	vaultKDF        = "pbkdf2-sha256"
+	vaultIterations = 600_000
+)
+
+// vaultFile is the on-disk format: a JSON envelope around an AES-256-GCM encrypted JSON map.
+type vaultFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Vault is a decrypted vault file. It is not safe for concurrent mutation.
+type Vault struct {
+	path       string
+	passphrase string
+	entries    map[string]string
+}
+
+// OpenVault decrypts path with passphrase. A missing file yields an empty vault that Save creates.
+func OpenVault(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, errors.New("vault passphrase is empty")
+	}
+	v := &Vault{path: path, passphrase: passphrase, entries: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read vault %s: %w", path, err)
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("vault %s: not a vault file: %w", path, err)
+	}
+	if f.Version != vaultVersion || f.KDF != vaultKDF {
+		return nil, fmt.Errorf("vault %s: unsupported version %d / kdf %q", path, f.Version, f.KDF)
+	}
+	aead, err := vaultCipher(passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: wrong passphrase or corrupted file", path)
+	}
+	if err := json.Unmarshal(plain, &v.entries); err != nil {
+		return nil, fmt.Errorf("vault %s: corrupted payload", path)
+	}
+	return v, nil
+}
+
+// Path returns the vault file path.
+func (v *Vault) Path() string { return v.path }
+
+// Get returns the value stored under key.
+func (v *Vault) Get(key string) (string, bool) {
+	val, ok := v.entries[key]
+	return val, ok
+}
+
+// Set stores value under key; call Save to persist.
+func (v *Vault) Set(key, value string) {
+	v.entries[key] = value
+}
+
+// Delete removes key and reports whether it existed.
+func (v *Vault) Delete(key string) bool {
+	_, ok := v.entries[key]
+	delete(v.entries, key)
+	return ok
+}
+
+// Keys returns the stored keys, sorted.
+func (v *Vault) Keys() []string {
+	keys := make([]string, 0, len(v.entries))
+	for k := range v.entries {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Save re-encrypts the vault with a fresh salt and nonce and atomically replaces the file (mode 0600).
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.entries)
+	if err != nil {
+		return err
+	}
+	f := vaultFile{Version: vaultVersion, KDF: vaultKDF, Iterations: vaultIterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := vaultCipher(v.passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, plain, nil)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(v.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+func vaultCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 || len(salt) == 0 {
+		return nil, errors.New("vault: invalid kdf parameters")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 	"github.com/originaleric/digeino/webhook"
 
 	"gorm.io/driver/mysql"
@@ -163,11 +164,14 @@ type mysqlStatusStore struct {
 	statusTable string
 }
 
-func NewMySQLStatusStore(cfg config.StatusStoreConfig) (StatusStore, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database)
+// MySQLDSN 根据配置构造 MySQL DSN。
+func MySQLDSN(cfg config.MySQLConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User, secrets.Value(cfg.Password), cfg.Host, cfg.Port, cfg.Database)
+}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+func NewMySQLStatusStore(cfg config.StatusStoreConfig) (StatusStore, error) {
+	db, err := gorm.Open(mysql.Open(MySQLDSN(cfg.MySQL)), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 // Client OCR 客户端，供固定流程节点或业务服务直接调用。
@@ -37,7 +38,16 @@ func (c *Client) Recognize(ctx context.Context, req *OCRRequest) (*OCRResponse,
 }
 
 func newProviderFromConfig() (OCRProvider, error) {
-	cfg := config.Get().Tools.OCR
+	return providerFromConfig(config.Get().Tools.OCR)
+}
+
+// CheckConfig 校验 OCR 配置能否构建 Provider（不发起网络请求），供 digeino doctor 使用。
+func CheckConfig(cfg config.OCRConfig) error {
+	_, err := providerFromConfig(cfg)
+	return err
+}
+
+func providerFromConfig(cfg config.OCRConfig) (OCRProvider, error) {
 	if cfg.Enabled == nil || !*cfg.Enabled {
 		return nil, newOCRError(CodeConfigMissing, "Tools.OCR is not enabled")
 	}
@@ -82,15 +92,7 @@ func newProviderFromConfig() (OCRProvider, error) {
 }
 
 func deepSeekAPIKey(ds config.DeepSeekOCRConfig) string {
-	if key := resolvedSecret(ds.ApiKey); key != "" {
-		return key
-	}
-	for _, env := range []string{"DEEPSEEK_OCR_API_KEY", "DEEPSEEK_API_KEY"} {
-		if v := os.Getenv(env); v != "" {
-			return v
-		}
-	}
-	return ""
+	return secrets.Lookup(resolvedSecret(ds.ApiKey), "DEEPSEEK_OCR_API_KEY", "DEEPSEEK_API_KEY")
 }
 
 func resolvedSecret(raw string) string {
@@ -98,6 +100,9 @@ func resolvedSecret(raw string) string {
 	if raw == "" {
 		return ""
 	}
+	if secrets.IsRef(raw) {
+		return secrets.Value(raw)
+	}
 	expanded := strings.TrimSpace(os.ExpandEnv(raw))
 	if expanded == "" || strings.Contains(expanded, "${") {
 		return ""
@@ -8,11 +8,11 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 const defaultMultipartOCRProvider = "multipart-ocr-http"
@@ -199,15 +199,7 @@ func (p *multipartOCRProvider) metadata(req *OCRRequest, img *OCRImage) map[stri
 }
 
 func multipartOCRAPIKey(cfg config.MultipartOCRConfig) string {
-	if key := resolvedSecret(cfg.ApiKey); key != "" {
-		return key
-	}
-	for _, env := range []string{"MULTIPART_OCR_API_KEY", "OCR_HTTP_API_KEY"} {
-		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
-			return v
-		}
-	}
-	return ""
+	return secrets.Lookup(resolvedSecret(cfg.ApiKey), "MULTIPART_OCR_API_KEY", "OCR_HTTP_API_KEY")
 }
 
 func multipartOCRProviderAliases(name string) bool {
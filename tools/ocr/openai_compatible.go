@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 const defaultOpenAICompatibleVisionProvider = "openai-compatible-vision"
@@ -142,15 +142,7 @@ func (p *openAICompatibleVisionProvider) metadata(req *OCRRequest, img *OCRImage
 }
 
 func openAICompatibleVisionAPIKey(cfg config.OpenAICompatibleVisionOCRConfig) string {
-	if key := resolvedSecret(cfg.ApiKey); key != "" {
-		return key
-	}
-	for _, env := range []string{"OPENAI_COMPATIBLE_VISION_API_KEY", "QWEN_API_KEY", "DASHSCOPE_API_KEY", "OPENAI_API_KEY"} {
-		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
-			return v
-		}
-	}
-	return ""
+	return secrets.Lookup(resolvedSecret(cfg.ApiKey), "OPENAI_COMPATIBLE_VISION_API_KEY", "QWEN_API_KEY", "DASHSCOPE_API_KEY", "OPENAI_API_KEY")
 }
 
 func openAICompatibleVisionProviderAliases(name string) bool {
@@ -84,11 +84,8 @@ func BrowserAction(ctx context.Context, req *BrowserActionRequest) (*BrowserActi
 		}
 	}
 	if !strings.Contains(currentURLStr, req.URL) {
-		if err := page.Timeout(time.Duration(cfg.NavigateTimeoutSec) * time.Second).Navigate(req.URL); err != nil {
-			return nil, fmt.Errorf("页面导航失败: %w", err)
-		}
-		if err := page.WaitLoad(); err != nil {
-			return nil, fmt.Errorf("页面加载失败: %w", err)
+		if err := navigate(ctx, page, req.URL, time.Duration(cfg.NavigateTimeoutSec)*time.Second); err != nil {
+			return nil, err
 		}
 	}
 
@@ -155,7 +152,7 @@ func executeClick(page *rod.Page, req *BrowserActionRequest) error {
 	if req.Selector != "" {
 		el, err := page.Element(req.Selector)
 		if err != nil {
-			return fmt.Errorf("找不到元素: %w", err)
+			return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 		}
 		return el.Click(proto.InputMouseButtonLeft, 1)
 	}
@@ -172,7 +169,7 @@ func executeType(page *rod.Page, req *BrowserActionRequest) error {
 	if req.Selector != "" {
 		el, err := page.Element(req.Selector)
 		if err != nil {
-			return fmt.Errorf("找不到元素: %w", err)
+			return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 		}
 		if req.HumanLike {
 			return humanType(el, req.Text)
@@ -192,7 +189,7 @@ func executeFill(page *rod.Page, req *BrowserActionRequest) error {
 	if req.Selector != "" {
 		el, err := page.Element(req.Selector)
 		if err != nil {
-			return fmt.Errorf("找不到元素: %w", err)
+			return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 		}
 		// 清空现有内容
 		if err := el.SelectAllText(); err == nil {
@@ -212,7 +209,7 @@ func executeHover(page *rod.Page, req *BrowserActionRequest) error {
 	if req.Selector != "" {
 		el, err := page.Element(req.Selector)
 		if err != nil {
-			return fmt.Errorf("找不到元素: %w", err)
+			return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 		}
 		return el.Hover()
 	}
@@ -225,7 +222,7 @@ func executeScroll(page *rod.Page, req *BrowserActionRequest) error {
 	if req.Selector != "" {
 		el, err := page.Element(req.Selector)
 		if err != nil {
-			return fmt.Errorf("找不到元素: %w", err)
+			return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 		}
 		return el.ScrollIntoView()
 	}
@@ -247,7 +244,7 @@ func executeFocus(page *rod.Page, req *BrowserActionRequest) error {
 	if req.Selector != "" {
 		el, err := page.Element(req.Selector)
 		if err != nil {
-			return fmt.Errorf("找不到元素: %w", err)
+			return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 		}
 		return el.Focus()
 	}
@@ -297,7 +294,7 @@ func executePress(page *rod.Page, req *BrowserActionRequest) error {
 		if req.Selector != "" {
 			el, err := page.Element(req.Selector)
 			if err != nil {
-				return fmt.Errorf("找不到元素: %w", err)
+				return fmt.Errorf("找不到元素: %w", withKind(ErrSelectorNotFound, err))
 			}
 			if err := el.Focus(); err != nil {
 				return fmt.Errorf("聚焦元素失败: %w", err)
@@ -100,15 +100,15 @@ func BrowserBrowse(ctx context.Context, req *BrowserBrowseRequest) (*BrowserBrow
 		}
 	}
 
-	if err := page.Timeout(time.Duration(cfg.NavigateTimeoutSec) * time.Second).Navigate(targetURL.String()); err != nil {
-		return nil, fmt.Errorf("页面导航失败: %w", err)
+	if err := navigate(ctx, page, targetURL.String(), time.Duration(cfg.NavigateTimeoutSec)*time.Second); err != nil {
+		return nil, err
 	}
-	if err := page.WaitLoad(); err != nil {
-		return nil, fmt.Errorf("页面加载失败: %w", err)
+	if err := checkLoginRedirect(page, targetURL); err != nil {
+		return nil, err
 	}
 	if req.WaitSelector != "" {
 		if _, err := page.Timeout(time.Duration(cfg.WaitSelectorTimeoutSec) * time.Second).Element(req.WaitSelector); err != nil {
-			return nil, fmt.Errorf("等待选择器失败: %w", err)
+			return nil, fmt.Errorf("等待选择器失败: %w", withKind(ErrSelectorNotFound, err))
 		}
 	}
 
@@ -378,7 +378,7 @@ func extractContentBySelector(page *rod.Page, selector string, waitSelectorTimeo
 	timeout := time.Duration(waitSelectorTimeoutSec) * time.Second
 	el, err := page.Timeout(timeout).Element(selector)
 	if err != nil {
-		return "", "", fmt.Errorf("找不到选择器 %s: %w", selector, err)
+		return "", "", fmt.Errorf("找不到选择器 %s: %w", selector, withKind(ErrSelectorNotFound, err))
 	}
 	htmlContent, err := el.HTML()
 	if err != nil {
@@ -0,0 +1,69 @@
+package research
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-rod/rod"
+)
+
+// 浏览器错误分类，供网关映射为结构化错误码（errors.Is 判断）。
+var (
+	ErrNavigationFailed = errors.New("navigation failed")
+	ErrSelectorNotFound = errors.New("selector not found")
+)
+
+// kindError 为底层错误附加分类，Error() 保持原始文案不变。
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// ErrLoginRequired 页面被重定向到登录页，需要先在本地浏览器登录并保存 Cookie。
+var ErrLoginRequired = errors.New("login required")
+
+// loginPathMarkers 常见平台登录页路径前缀。
+var loginPathMarkers = []string{"/login", "/signin", "/i/flow/login", "/website-login", "/passport"}
+
+// IsLoginURL 判断 URL 是否为登录页。
+func IsLoginURL(rawURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if strings.HasPrefix(host, "passport.") || strings.HasPrefix(host, "login.") {
+		return true
+	}
+	path := strings.ToLower(u.Path)
+	for _, marker := range loginPathMarkers {
+		if strings.HasPrefix(path, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLoginRedirect 目标不是登录页、但加载后落在登录页时返回 ErrLoginRequired。
+func checkLoginRedirect(page *rod.Page, target *url.URL) error {
+	info, err := page.Info()
+	if err != nil || info == nil {
+		return nil
+	}
+	if IsLoginURL(info.URL) && !IsLoginURL(target.String()) {
+		return fmt.Errorf("页面跳转到登录页 %s: %w", info.URL, ErrLoginRequired)
+	}
+	return nil
+}
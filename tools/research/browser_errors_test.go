@@ -0,0 +1,35 @@
+package research
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsLoginURL(t *testing.T) {
+	t.Parallel()
+	for _, u := range []string{
+		"https://x.com/i/flow/login?redirect_after_login=%2Fa",
+		"https://www.xiaohongshu.com/website-login/captcha",
+		"https://passport.douyin.com/web/",
+	} {
+		if !IsLoginURL(u) {
+			t.Fatalf("expected login url: %s", u)
+		}
+	}
+	if IsLoginURL("https://www.xiaohongshu.com/explore/abc") {
+		t.Fatal("explore page is not a login page")
+	}
+}
+
+func TestWithKindKeepsMessage(t *testing.T) {
+	t.Parallel()
+	base := errors.New("context deadline exceeded")
+	err := fmt.Errorf("页面导航失败: %w", withKind(ErrNavigationFailed, base))
+	if err.Error() != "页面导航失败: context deadline exceeded" {
+		t.Fatalf("unexpected message: %s", err)
+	}
+	if !errors.Is(err, ErrNavigationFailed) || !errors.Is(err, base) {
+		t.Fatal("expected both kind and cause in chain")
+	}
+}
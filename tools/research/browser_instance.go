@@ -54,10 +54,21 @@ func GetInstanceManager() *InstanceManager {
 			baseDir:   baseDir,
 			cfg:       cfg,
 		}
+		config.Subscribe(func(_, next *config.Config) {
+			globalInstanceMgr.applyConfig(next.Tools.LocalBrowser)
+		})
 	})
 	return globalInstanceMgr
 }
 
+// applyConfig 应用热更新的 LocalBrowser 配置，只影响之后创建的实例。
+func (im *InstanceManager) applyConfig(raw config.LocalBrowserConfig) {
+	cfg := normalizeLocalBrowserConfig(raw)
+	im.mu.Lock()
+	im.cfg = cfg
+	im.mu.Unlock()
+}
+
 // CreateInstance 创建新的浏览器实例
 func (im *InstanceManager) CreateInstance(ctx context.Context, name string) (*BrowserInstance, error) {
 	im.mu.Lock()
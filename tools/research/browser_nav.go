@@ -0,0 +1,32 @@
+package research
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+type navigationObserverKey struct{}
+
+// WithNavigationObserver 让本次调用中每次真正发起的页面导航（含加载失败）回调 fn，参数为导航与加载耗时。
+// 导航前失败（URL 校验、域名白名单、浏览器启动等）不会回调。
+func WithNavigationObserver(ctx context.Context, fn func(time.Duration)) context.Context {
+	return context.WithValue(ctx, navigationObserverKey{}, fn)
+}
+
+// navigate 导航到 target 并等待加载完成，完成或失败后通知 ctx 中的观察者。
+func navigate(ctx context.Context, page *rod.Page, target string, timeout time.Duration) error {
+	start := time.Now()
+	if fn, _ := ctx.Value(navigationObserverKey{}).(func(time.Duration)); fn != nil {
+		defer func() { fn(time.Since(start)) }()
+	}
+	if err := page.Timeout(timeout).Navigate(target); err != nil {
+		return fmt.Errorf("页面导航失败: %w", withKind(ErrNavigationFailed, err))
+	}
+	if err := page.WaitLoad(); err != nil {
+		return fmt.Errorf("页面加载失败: %w", withKind(ErrNavigationFailed, err))
+	}
+	return nil
+}
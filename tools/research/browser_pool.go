@@ -3,6 +3,7 @@ package research
 import (
 	"context"
 	"fmt"
+	"log"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,6 +35,10 @@ type browserManager struct {
 	// 新增：标签页管理
 	tabManager *TabManager
 	tabExecutor *TabExecutor
+	// relaunch 表示 Headless / ChromePath 已变更，浏览器空闲时按新配置重启
+	relaunch bool
+	// acquiring 为已拿到浏览器、尚未登记会话的 acquire 数；重启前须为 0，否则会关掉其正在使用的浏览器
+	acquiring int
 }
 
 var (
@@ -53,10 +58,29 @@ func getBrowserManager() *browserManager {
 			tabExecutor: executor,
 		}
 		globalBrowserMgr.startCleanupLoop()
+		config.Subscribe(func(_, next *config.Config) {
+			globalBrowserMgr.applyConfig(next.Tools.LocalBrowser)
+		})
 	})
 	return globalBrowserMgr
 }
 
+// applyConfig 应用热更新的 LocalBrowser 配置。超时立即生效；Headless / ChromePath 在没有进行中的会话时
+// 重启浏览器后生效；MaxConcurrency 决定并发槽位容量，需重启进程。AllowedDomains 每次调用时从 config.Get() 读取。
+func (m *browserManager) applyConfig(raw config.LocalBrowserConfig) {
+	cfg := normalizeLocalBrowserConfig(raw)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cfg.MaxConcurrency != m.cfg.MaxConcurrency {
+		log.Printf("[browser] LocalBrowser.MaxConcurrency %d -> %d takes effect after restart", m.cfg.MaxConcurrency, cfg.MaxConcurrency)
+		cfg.MaxConcurrency = m.cfg.MaxConcurrency
+	}
+	if *cfg.Headless != *m.cfg.Headless || cfg.ChromePath != m.cfg.ChromePath {
+		m.relaunch = true
+	}
+	m.cfg = cfg
+}
+
 // getTabManager 获取或创建标签页管理器
 func (m *browserManager) getTabManager() (*TabManager, error) {
 	m.mu.Lock()
@@ -68,7 +92,7 @@ func (m *browserManager) getTabManager() (*TabManager, error) {
 
 	// 确保浏览器已初始化
 	ctx := context.Background()
-	browser, err := m.ensureBrowser(ctx)
+	browser, err := m.ensureBrowserLocked(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +133,12 @@ func (m *browserManager) acquire(ctx context.Context) (*browserSession, error) {
 		return nil, fmt.Errorf("等待浏览器并发槽位超时: %w", ctx.Err())
 	}
 
-	browser, err := m.ensureBrowser(ctx)
+	m.mu.Lock()
+	browser, err := m.ensureBrowserLocked(ctx)
+	if err == nil {
+		m.acquiring++
+	}
+	m.mu.Unlock()
 	if err != nil {
 		<-m.slots
 		return nil, err
@@ -119,14 +148,18 @@ func (m *browserManager) acquire(ctx context.Context) (*browserSession, error) {
 	if err != nil {
 		_ = m.resetBrowser()
 		browser, err = m.ensureBrowser(ctx)
-		if err != nil {
-			<-m.slots
-			return nil, err
+		if err == nil {
+			page, err = stealth.Page(browser)
+			if err != nil {
+				err = fmt.Errorf("创建 stealth 页面失败: %w", err)
+			}
 		}
-		page, err = stealth.Page(browser)
 		if err != nil {
+			m.mu.Lock()
+			m.acquiring--
+			m.mu.Unlock()
 			<-m.slots
-			return nil, fmt.Errorf("创建 stealth 页面失败: %w", err)
+			return nil, err
 		}
 	}
 
@@ -139,6 +172,7 @@ func (m *browserManager) acquire(ctx context.Context) (*browserSession, error) {
 
 	m.mu.Lock()
 	m.sessions[id] = s
+	m.acquiring--
 	m.mu.Unlock()
 	return s, nil
 }
@@ -165,7 +199,15 @@ func (m *browserManager) release(s *browserSession) {
 func (m *browserManager) ensureBrowser(ctx context.Context) (*rod.Browser, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.ensureBrowserLocked(ctx)
+}
 
+// ensureBrowserLocked 需持有 m.mu；待重启时只在没有会话、也没有进行中的 acquire 时关闭旧浏览器。
+func (m *browserManager) ensureBrowserLocked(ctx context.Context) (*rod.Browser, error) {
+	if m.browser != nil && m.relaunch && len(m.sessions) == 0 && m.acquiring == 0 && m.tabManager == nil {
+		_ = m.browser.Close()
+		m.browser = nil
+	}
 	if m.browser != nil {
 		if _, err := m.browser.Version(); err == nil {
 			return m.browser, nil
@@ -173,6 +215,7 @@ func (m *browserManager) ensureBrowser(ctx context.Context) (*rod.Browser, error
 		_ = m.browser.Close()
 		m.browser = nil
 	}
+	m.relaunch = false
 
 	l := launcher.New().
 		Context(ctx).
@@ -79,17 +79,17 @@ func BrowserSnapshot(ctx context.Context, req *BrowserSnapshotRequest) (*Browser
 	}
 
 	// 导航到目标URL
-	if err := page.Timeout(time.Duration(cfg.NavigateTimeoutSec) * time.Second).Navigate(targetURL.String()); err != nil {
-		return nil, fmt.Errorf("页面导航失败: %w", err)
+	if err := navigate(ctx, page, targetURL.String(), time.Duration(cfg.NavigateTimeoutSec)*time.Second); err != nil {
+		return nil, err
 	}
-	if err := page.WaitLoad(); err != nil {
-		return nil, fmt.Errorf("页面加载失败: %w", err)
+	if err := checkLoginRedirect(page, targetURL); err != nil {
+		return nil, err
 	}
 
 	// 等待选择器（如果指定）
 	if req.WaitSelector != "" {
 		if _, err := page.Timeout(time.Duration(cfg.WaitSelectorTimeoutSec) * time.Second).Element(req.WaitSelector); err != nil {
-			return nil, fmt.Errorf("等待选择器失败: %w", err)
+			return nil, fmt.Errorf("等待选择器失败: %w", withKind(ErrSelectorNotFound, err))
 		}
 	}
 
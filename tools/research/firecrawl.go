@@ -8,7 +8,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -16,6 +15,7 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 // FirecrawlRequest 深度爬取请求
@@ -83,10 +83,7 @@ func extractLinksFromMarkdown(md string) []Link {
 // FirecrawlScrape 调用 Firecrawl API 将网页转换为 Markdown
 func FirecrawlScrape(ctx context.Context, req *FirecrawlRequest) (*FirecrawlResponse, error) {
 	cfg := config.Get()
-	apiKey := cfg.Tools.Firecrawl.ApiKey
-	if apiKey == "" {
-		apiKey = os.Getenv("FIRECRAWL_API_KEY")
-	}
+	apiKey := secrets.Lookup(cfg.Tools.Firecrawl.ApiKey, "FIRECRAWL_API_KEY")
 
 	if apiKey == "" {
 		return nil, fmt.Errorf("Firecrawl API Key 未配置，可通过 eino.yml 或 FIRECRAWL_API_KEY 环境变量设置")
@@ -15,6 +15,7 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 type pathAccessMode string
@@ -269,10 +270,7 @@ func DocToMarkdown(ctx context.Context, req *DocToMarkdownRequest) (*DocToMarkdo
 	cfg := config.Get()
 	toolCfg := cfg.Tools.Unstructured
 
-	apiKey := toolCfg.ApiKey
-	if apiKey == "" {
-		apiKey = os.Getenv("UNSTRUCTURED_API_KEY")
-	}
+	apiKey := secrets.Lookup(toolCfg.ApiKey, "UNSTRUCTURED_API_KEY")
 
 	if apiKey == "" {
 		return nil, fmt.Errorf("Unstructured API Key 未配置")
@@ -20,6 +20,7 @@ import (
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 	"github.com/originaleric/digeino/tools/research/websearch"
 	"github.com/pinecone-io/go-pinecone/v4/pinecone"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -185,13 +186,13 @@ type SemanticSearchResponse struct {
 func SemanticSearch(ctx context.Context, req *SemanticSearchRequest) (*SemanticSearchResponse, error) {
 	cfg := config.Get().Tools
 
-	idxConn, err := createPineconeConn(cfg.Pinecone.ApiKey, cfg.Pinecone.Host)
+	idxConn, err := createPineconeConn(secrets.Value(cfg.Pinecone.ApiKey), cfg.Pinecone.Host)
 	if err != nil {
 		return nil, err
 	}
 
 	embedder, err := openai.NewEmbedder(ctx, &openai.EmbeddingConfig{
-		APIKey:     cfg.Embedding.ApiKey,
+		APIKey:     secrets.Value(cfg.Embedding.ApiKey),
 		BaseURL:    cfg.Embedding.BaseUrl,
 		Model:      cfg.Embedding.Model,
 		Dimensions: &cfg.Embedding.Dimensions,
@@ -258,13 +259,13 @@ func CodeIndex(ctx context.Context, req *CodeIndexRequest) (*CodeIndexResponse,
 	}
 
 	// 3. Indexer
-	idxConn, err := createPineconeConn(cfg.Pinecone.ApiKey, cfg.Pinecone.Host)
+	idxConn, err := createPineconeConn(secrets.Value(cfg.Pinecone.ApiKey), cfg.Pinecone.Host)
 	if err != nil {
 		return nil, err
 	}
 
 	embedder, err := openai.NewEmbedder(ctx, &openai.EmbeddingConfig{
-		APIKey:     cfg.Embedding.ApiKey,
+		APIKey:     secrets.Value(cfg.Embedding.ApiKey),
 		BaseURL:    cfg.Embedding.BaseUrl,
 		Model:      cfg.Embedding.Model,
 		Dimensions: &cfg.Embedding.Dimensions,
@@ -3,11 +3,11 @@ package websearch
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 // WebSearch 执行网页搜索
@@ -30,31 +30,28 @@ func WebSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error)
 
 	switch engine {
 	case "bocha":
-		configMap["BochaApiKey"] = toolCfg.Bocha.ApiKey
+		configMap["BochaApiKey"] = secrets.Value(toolCfg.Bocha.ApiKey)
 		configMap["BochaBaseUrl"] = toolCfg.Bocha.BaseUrl
 		provider, err = NewBochaProvider(configMap)
 	case "serpapi":
-		configMap["SerpAPIKey"] = toolCfg.SerpApi.ApiKey
+		configMap["SerpAPIKey"] = secrets.Value(toolCfg.SerpApi.ApiKey)
 		configMap["SerpAPIEngine"] = "google"
 		if toolCfg.SerpApi.BaseUrl != "" {
 			// 如果有自定义URL需求，此处可以扩展，目前原生没这个参数
 		}
 		provider, err = NewSerpAPIProvider(configMap)
 	case "google":
-		configMap["GoogleApiKey"] = toolCfg.Google.ApiKey
+		configMap["GoogleApiKey"] = secrets.Value(toolCfg.Google.ApiKey)
 		configMap["GoogleSearchEngineId"] = toolCfg.Google.Cx
 		provider, err = NewGoogleProvider(configMap)
 	case "bing":
-		configMap["BingApiKey"] = toolCfg.Bing.ApiKey
+		configMap["BingApiKey"] = secrets.Value(toolCfg.Bing.ApiKey)
 		// configMap["BingSafeSearch"] = toolCfg.Bing.SafeSearch
 		provider, err = NewBingProvider(configMap)
 	case "firecrawl":
 		// Firecrawl Search 复用全局 Firecrawl ApiKey（与 firecrawl_scrape 相同）
 		firecrawlCfg := cfg.Tools.Firecrawl
-		apiKey := firecrawlCfg.ApiKey
-		if apiKey == "" {
-			apiKey = os.Getenv("FIRECRAWL_API_KEY")
-		}
+		apiKey := secrets.Lookup(firecrawlCfg.ApiKey, "FIRECRAWL_API_KEY")
 		if apiKey == "" {
 			err = fmt.Errorf("Firecrawl ApiKey 未配置，可在 Tools.Firecrawl 或环境变量 FIRECRAWL_API_KEY 中设置")
 			break
@@ -67,10 +64,7 @@ func WebSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error)
 		provider, err = NewFirecrawlProvider(configMap)
 	case "tavily":
 		tavilyCfg := toolCfg.Tavily
-		apiKey := tavilyCfg.ApiKey
-		if apiKey == "" {
-			apiKey = os.Getenv("TAVILY_API_KEY")
-		}
+		apiKey := secrets.Lookup(tavilyCfg.ApiKey, "TAVILY_API_KEY")
 		if apiKey == "" {
 			err = fmt.Errorf("Tavily ApiKey 未配置，可在 Tools.WebSearch.Tavily.ApiKey 或环境变量 TAVILY_API_KEY 中设置")
 			break
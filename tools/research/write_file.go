@@ -60,6 +60,11 @@ func WriteFile(ctx context.Context, req *WriteFileRequest) (*WriteFileResponse,
 	}, nil
 }
 
+// SecureFileAccessSupported 当前平台是否支持基于 openat 的安全文件访问（读写工具依赖此能力）。
+func SecureFileAccessSupported() bool {
+	return secureFileAccessSupported()
+}
+
 // --- Factory Function ---
 
 func NewWriteFileTool(ctx context.Context) (tool.BaseTool, error) {
@@ -9,6 +9,7 @@ import (
 	openaiModel "github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 // NewChatModelFromConfig 从配置创建 ChatModel（参考 DigFlow 的配置方式）
@@ -38,14 +39,19 @@ func NewChatModelFromConfig(ctx context.Context) (model.ChatModel, error) {
 }
 
 // processEnvVars 处理环境变量（参考 DigFlow 的实现）
-// 支持 ${VAR_NAME} 格式的环境变量替换
+// 支持 ${VAR_NAME} 格式的环境变量替换，secret:// 引用交给 secrets 包解析
 func processEnvVars(config map[string]interface{}) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
 	for k, v := range config {
 		if str, ok := v.(string); ok {
-			// 如果是环境变量格式 ${VAR_NAME}
-			if len(str) > 3 && str[:2] == "${" && str[len(str)-1:] == "}" {
+			if secrets.IsRef(str) {
+				val, err := secrets.Resolve(str)
+				if err != nil {
+					return nil, fmt.Errorf("ChatModel.Config.%s: %w", k, err)
+				}
+				result[k] = val
+			} else if len(str) > 3 && str[:2] == "${" && str[len(str)-1:] == "}" {
 				envKey := str[2 : len(str)-1]
 				if envVal := os.Getenv(envKey); envVal != "" {
 					result[k] = envVal
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 // SyncCustomerMessages 拉取企业微信客服消息
@@ -120,13 +121,13 @@ func (h *WeComCallbackHandler) VerifyURL(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 验证签名
-	if !verifySignature(callbackCfg.Token, timestamp, nonce, echostr, msgSignature) {
+	if !verifySignature(secrets.Value(callbackCfg.Token), timestamp, nonce, echostr, msgSignature) {
 		http.Error(w, "invalid signature", http.StatusBadRequest)
 		return
 	}
 
 	// 解密 echostr
-	decrypted, err := decrypt(callbackCfg.EncodingAESKey, cfg.WeCom.CorpID, echostr)
+	decrypted, err := decrypt(secrets.Value(callbackCfg.EncodingAESKey), cfg.WeCom.CorpID, echostr)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("decrypt failed: %v", err), http.StatusInternalServerError)
 		return
@@ -176,13 +177,13 @@ func (h *WeComCallbackHandler) HandleMessage(w http.ResponseWriter, r *http.Requ
 	}
 
 	// 验证签名
-	if !verifySignature(callbackCfg.Token, timestamp, nonce, encryptedMsg.Encrypt, msgSignature) {
+	if !verifySignature(secrets.Value(callbackCfg.Token), timestamp, nonce, encryptedMsg.Encrypt, msgSignature) {
 		http.Error(w, "invalid signature", http.StatusBadRequest)
 		return
 	}
 
 	// 解密消息
-	decryptedXML, err := decrypt(callbackCfg.EncodingAESKey, cfg.WeCom.CorpID, encryptedMsg.Encrypt)
+	decryptedXML, err := decrypt(secrets.Value(callbackCfg.EncodingAESKey), cfg.WeCom.CorpID, encryptedMsg.Encrypt)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("decrypt failed: %v", err), http.StatusInternalServerError)
 		return
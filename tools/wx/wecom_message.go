@@ -11,6 +11,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 	"github.com/whyiyhw/go-workwx"
 )
 
@@ -175,6 +176,9 @@ func getWeComCustomerApp() (*workwx.WorkwxApp, error) {
 	if corpSecret == "" {
 		return nil, fmt.Errorf("no WeCom application with ManageAllKFSession=true found in config")
 	}
+	if corpSecret = secrets.Value(corpSecret); corpSecret == "" {
+		return nil, fmt.Errorf("WeCom AgentSecret could not be resolved")
+	}
 
 	var wx *workwx.Workwx
 	if cfg.WeCom.QYAPIHost != "" {
@@ -201,6 +205,9 @@ func getWeComCustomerAccessToken(ctx context.Context) (string, error) {
 	if corpSecret == "" {
 		return "", fmt.Errorf("no WeCom application with ManageAllKFSession=true found in config")
 	}
+	if corpSecret = secrets.Value(corpSecret); corpSecret == "" {
+		return "", fmt.Errorf("WeCom AgentSecret could not be resolved")
+	}
 	baseURL := getWeComAPIHost()
 	url := fmt.Sprintf("%s/cgi-bin/gettoken?corpid=%s&corpsecret=%s", baseURL, cfg.WeCom.CorpID, corpSecret)
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -264,7 +271,7 @@ func getWeComApp(agentID int64) (*workwx.WorkwxApp, error) {
 		targetAgentID = app.AgentID
 	}
 
-	if corpSecret == "" {
+	if corpSecret = secrets.Value(corpSecret); corpSecret == "" {
 		return nil, fmt.Errorf("WeCom AgentSecret not configured")
 	}
 
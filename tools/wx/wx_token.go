@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 var (
@@ -155,7 +156,7 @@ func GetAccessToken(ctx context.Context) (string, error) {
 
 	// 3. 需要获取新 token
 	appID := cfg.WeChat.AppID
-	appSecret := cfg.WeChat.AppSecret
+	appSecret := secrets.Value(cfg.WeChat.AppSecret)
 
 	// 4. 调用微信 API 获取新 token
 	newTokenData, err := fetchAccessTokenFromWeChat(ctx, appID, appSecret)
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 type FeishuClient struct {
@@ -148,13 +149,14 @@ func (c *FeishuClient) getTenantAccessToken(ctx context.Context) (string, error)
 	}
 	c.mu.Unlock()
 
-	if strings.TrimSpace(c.cfg.AppID) == "" || strings.TrimSpace(c.cfg.AppSecret) == "" {
+	appSecret := secrets.Value(c.cfg.AppSecret)
+	if strings.TrimSpace(c.cfg.AppID) == "" || strings.TrimSpace(appSecret) == "" {
 		return "", fmt.Errorf("feishu app_id/app_secret not configured")
 	}
 
 	reqBody, _ := json.Marshal(map[string]string{
 		"app_id":     c.cfg.AppID,
-		"app_secret": c.cfg.AppSecret,
+		"app_secret": appSecret,
 	})
 	reqURL := fmt.Sprintf("%s/open-apis/auth/v3/tenant_access_token/internal", c.cfg.BaseURL)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewBuffer(reqBody))
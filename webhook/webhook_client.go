@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/originaleric/digeino/config"
+	"github.com/originaleric/digeino/secrets"
 )
 
 // WebhookClient Webhook 客户端
@@ -74,8 +75,8 @@ func (c *WebhookClient) SendStatus(ctx context.Context, status ExecutionStatus)
 	}
 
 	// 如果配置了密钥，生成签名
-	if c.config.Secret != "" {
-		payload.Signature = c.generateSignature(data, c.config.Secret)
+	if secret := secrets.Value(c.config.Secret); secret != "" {
+		payload.Signature = c.generateSignature(data, secret)
 		// 重新序列化包含签名的 payload
 		data, _ = json.Marshal(payload)
 	}
@@ -118,7 +119,7 @@ func (c *WebhookClient) doPost(ctx context.Context, data []byte) error {
 
 	req.Header.Set("Content-Type", "application/json")
 	for k, v := range c.config.Headers {
-		req.Header.Set(k, v)
+		req.Header.Set(k, secrets.Value(v))
 	}
 
 	resp, err := c.client.Do(req)